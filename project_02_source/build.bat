@@ -1,8 +1,6 @@
 @echo off
 echo Building Server...
-cd server
-go build -o server.exe
-cd ..
+go build -o server/server.exe ./cmd/server
 
 echo Building Client...
 cd client
@@ -0,0 +1,53 @@
+package account
+
+import (
+	"fmt"
+	"lab02_mahoa/client/api"
+	"lab02_mahoa/client/crypto"
+	"log"
+)
+
+// UnlockKEK derives the KEK of the logged in user from their password.
+// Users still on the legacy fixed salt are moved to a per-user salt first; if that
+// migration fails the legacy KEK is returned and the migration is retried next time.
+func UnlockKEK(client *api.Client, password string, params crypto.KDFParams) ([]byte, crypto.KDFParams, error) {
+	if params.IsLegacy() {
+		newParams, err := crypto.NewKDFParams()
+		if err != nil {
+			return nil, params, err
+		}
+		kek, err := MigrateKDF(client, password, params, newParams)
+		if err == nil {
+			log.Printf("Moved note keys to a per-user KDF salt")
+			return kek, newParams, nil
+		}
+		log.Printf("Warning: Failed to migrate KDF parameters: %v", err)
+	}
+
+	kek, err := crypto.DeriveKEK(password, params)
+	if err != nil {
+		return nil, params, err
+	}
+	return kek, params, nil
+}
+
+// MigrateKDF re-wraps every note and revision DEK under a KEK derived with
+// newParams (a fresh salt, or another algorithm such as Argon2id) and stores
+// them on the server in one transaction. It returns the new KEK.
+func MigrateKDF(client *api.Client, password string, oldParams, newParams crypto.KDFParams) ([]byte, error) {
+	newKEK, err := crypto.DeriveKEK(password, newParams)
+	if err != nil {
+		return nil, err
+	}
+
+	noteKeys, revisionKeys, err := rewrapAll(client, crypto.NewKEKCache(password, oldParams), newKEK, newParams.Descriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.MigrateKDF(password, newParams, noteKeys, revisionKeys); err != nil {
+		return nil, fmt.Errorf("failed to store re-wrapped keys: %w", err)
+	}
+
+	return newKEK, nil
+}
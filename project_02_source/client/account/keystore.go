@@ -0,0 +1,40 @@
+package account
+
+import (
+	"crypto/ecdh"
+	"fmt"
+	"lab02_mahoa/client/api"
+	"lab02_mahoa/client/crypto"
+)
+
+// LoadOrCreateDHKey unlocks the E2EE keypair of username from the local
+// keystore. If there is none yet, a new keypair is generated, saved encrypted
+// under the password and its public key registered with the server; created
+// is true in that case.
+func LoadOrCreateDHKey(client *api.Client, username, password string) (privateKey *ecdh.PrivateKey, created bool, err error) {
+	privateKey, err = crypto.LoadDHKeyPair(username, password)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load DH keypair: %w", err)
+	}
+	if privateKey != nil {
+		return privateKey, false, nil
+	}
+
+	keyPair, err := crypto.GenerateDHKeyPair()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate DH keypair: %w", err)
+	}
+	privateKey = keyPair.PrivateKey
+
+	if err := crypto.SaveDHKeyPair(username, password, privateKey); err != nil {
+		return nil, false, fmt.Errorf("failed to save DH keypair: %w", err)
+	}
+
+	// Register public key with server (first time only)
+	publicKeyBase64 := crypto.PublicKeyToBase64(privateKey.PublicKey())
+	if err := client.UpdatePublicKey(publicKeyBase64); err != nil {
+		return privateKey, true, fmt.Errorf("failed to register DH public key: %w", err)
+	}
+
+	return privateKey, true, nil
+}
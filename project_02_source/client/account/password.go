@@ -0,0 +1,105 @@
+package account
+
+import (
+	"fmt"
+	"lab02_mahoa/client/api"
+	"lab02_mahoa/client/crypto"
+	"os"
+)
+
+// ChangePassword moves everything protected by the old password to the new one:
+// every note and revision DEK is re-wrapped under the new KEK and the local DH
+// keystore is re-encrypted. The server stores all wrapped keys in one transaction;
+// if it refuses, the previous keystore file is put back. It returns the new KEK.
+func ChangePassword(client *api.Client, username, oldPassword, newPassword string, params crypto.KDFParams) ([]byte, error) {
+	newKEK, err := crypto.DeriveKEK(newPassword, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unlock the keystore first so a wrong old password fails before anything changes
+	privateKey, err := crypto.LoadDHKeyPair(username, oldPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock keystore: %w", err)
+	}
+
+	noteKeys, revisionKeys, err := rewrapAll(client, crypto.NewKEKCache(oldPassword, params), newKEK, params.Descriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the old keystore so it can be put back if the server refuses the change
+	keystorePath := crypto.GetKeystorePath(username)
+	backup, err := os.ReadFile(keystorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	if privateKey != nil {
+		if err := crypto.SaveDHKeyPair(username, newPassword, privateKey); err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt keystore: %w", err)
+		}
+	}
+
+	if err := client.ChangePassword(oldPassword, newPassword, noteKeys, revisionKeys); err != nil {
+		if backup != nil {
+			if restoreErr := os.WriteFile(keystorePath, backup, 0600); restoreErr != nil {
+				return nil, fmt.Errorf("%w (and failed to restore keystore: %v)", err, restoreErr)
+			}
+		}
+		return nil, err
+	}
+
+	return newKEK, nil
+}
+
+// rewrapAll re-wraps the DEK of every note and revision to newKEK. Each DEK is
+// unwrapped with the old KEK matching its KDF descriptor; newKDF describes newKEK.
+func rewrapAll(client *api.Client, oldKeys *crypto.KEKCache, newKEK []byte, newKDF string) (noteKeys, revisionKeys []api.RewrappedKey, err error) {
+	notes, err := client.ListNotes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	for _, note := range notes {
+		key, err := rewrap(note.ID, note.EncryptedKey, note.EncryptedKeyIV, note.KeyKDF, oldKeys, newKEK, newKDF)
+		if err != nil {
+			return nil, nil, fmt.Errorf("note %d: %w", note.ID, err)
+		}
+		noteKeys = append(noteKeys, key)
+
+		revisions, err := client.ListNoteRevisions(note.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list revisions of note %d: %w", note.ID, err)
+		}
+		for _, revision := range revisions {
+			key, err := rewrap(revision.ID, revision.EncryptedKey, revision.EncryptedKeyIV, revision.KeyKDF, oldKeys, newKEK, newKDF)
+			if err != nil {
+				return nil, nil, fmt.Errorf("revision %d of note %d: %w", revision.ID, note.ID, err)
+			}
+			revisionKeys = append(revisionKeys, key)
+		}
+	}
+
+	return noteKeys, revisionKeys, nil
+}
+
+// rewrap unwraps a DEK with the old KEK for keyKDF and wraps it again with the new one
+func rewrap(id uint, encryptedKey, encryptedKeyIV, keyKDF string, oldKeys *crypto.KEKCache, newKEK []byte, newKDF string) (api.RewrappedKey, error) {
+	oldKEK, err := oldKeys.KEK(keyKDF)
+	if err != nil {
+		return api.RewrappedKey{}, err
+	}
+
+	dek, err := crypto.UnwrapKey(encryptedKey, encryptedKeyIV, oldKEK)
+	if err != nil {
+		return api.RewrappedKey{}, fmt.Errorf("failed to unwrap key (wrong password?): %w", err)
+	}
+
+	newEncryptedKey, newIV, err := crypto.WrapKey(dek, newKEK)
+	if err != nil {
+		return api.RewrappedKey{}, fmt.Errorf("failed to wrap key: %w", err)
+	}
+
+	return api.RewrappedKey{ID: id, EncryptedKey: newEncryptedKey, EncryptedKeyIV: newIV, KeyKDF: newKDF}, nil
+}
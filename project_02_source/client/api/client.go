@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"crypto/ecdh"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"lab02_mahoa/client/crypto"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
-const BaseURL = "http://localhost:8080/api"
+// DefaultBaseURL is the API of a server running locally with the default settings
+const DefaultBaseURL = "http://localhost:8080/api"
 
 var (
 	CurrentUserID        uint
@@ -18,10 +22,43 @@ var (
 	CurrentPassword      string
 	AuthToken            string           // JWT Token để gọi API
 	CurrentDHPrivateKey  *ecdh.PrivateKey // User's DH private key for E2EE
+	CurrentKDF           crypto.KDFParams // How the KEK is derived from the password
+	CurrentKEK           []byte           // Key-encryption key derived at login (wraps new DEKs)
+	CurrentKeys          *crypto.KEKCache // KEKs for unwrapping DEKs, by KDF descriptor
 )
 
+// ErrNoteConflict is returned by UpdateNote when the note changed on the server
+// since the client read it
+var ErrNoteConflict = errors.New("note was modified by someone else, reload it and try again")
+
 type Client struct {
-	Token string
+	BaseURL      string // API root, e.g. https://notes.example.com/api; empty means DefaultBaseURL
+	Token        string
+	RefreshToken string           // Single-use token for renewing Token, rotated on every refresh
+	KDF          crypto.KDFParams // KDF parameters returned by the last login
+	MFAToken     string           // Login challenge waiting for a two-factor code (see CompleteMFALogin)
+
+	// OnTokenRefresh is called after the tokens were renewed, e.g. to persist them
+	OnTokenRefresh func(token, refreshToken string)
+
+	// HTTPClient sends the requests; nil means http.DefaultClient (see ConfigureTLS)
+	HTTPClient *http.Client
+
+	refreshMu sync.Mutex
+}
+
+// baseURL returns the API root requests are sent to
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimRight(c.BaseURL, "/")
+}
+
+// ShareLink returns the browser viewer URL of a share link with the base64
+// note key in its #key= fragment, which is never sent to the server
+func (c *Client) ShareLink(shareToken, keyBase64 string) string {
+	return fmt.Sprintf("%s/share/%s#key=%s", strings.TrimSuffix(c.baseURL(), "/api"), shareToken, keyBase64)
 }
 
 // RegisterRequest represents registration data
@@ -32,14 +69,21 @@ type RegisterRequest struct {
 
 // LoginRequest represents login data
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	OTP          string `json:"otp,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
 }
 
 // LoginResponse represents login response
 type LoginResponse struct {
-	Token   string `json:"token"`
-	Message string `json:"message"`
+	Token        string           `json:"token"`
+	RefreshToken string           `json:"refresh_token"`
+	ExpiresIn    int              `json:"expires_in"`
+	KDF          crypto.KDFParams `json:"kdf"`
+	Message      string           `json:"message"`
+	MFARequired  bool             `json:"mfa_required"`
+	MFAToken     string           `json:"mfa_token"`
 }
 
 // CreateNoteRequest represents note creation data
@@ -49,6 +93,7 @@ type CreateNoteRequest struct {
 	IV               string `json:"iv"`
 	EncryptedKey     string `json:"encrypted_key"`
 	EncryptedKeyIV   string `json:"encrypted_key_iv"`
+	KeyKDF           string `json:"key_kdf,omitempty"`
 }
 
 // Note represents a note from the server
@@ -59,10 +104,67 @@ type Note struct {
 	IV               string    `json:"iv"`
 	EncryptedKey     string    `json:"encrypted_key"`
 	EncryptedKeyIV   string    `json:"encrypted_key_iv"`
+	KeyKDF           string    `json:"key_kdf,omitempty"` // KDF descriptor of the KEK that wraps EncryptedKey
 	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Version          int       `json:"version"`
 	IsShared         bool      `json:"is_shared"`
 }
 
+// UpdateNoteRequest represents note update data
+type UpdateNoteRequest struct {
+	EncryptedContent string `json:"encrypted_content"`
+	IV               string `json:"iv"`
+	EncryptedKey     string `json:"encrypted_key,omitempty"`
+	EncryptedKeyIV   string `json:"encrypted_key_iv,omitempty"`
+	Version          int    `json:"version"`
+}
+
+// NoteRevision represents a previous version of a note
+type NoteRevision struct {
+	ID               uint      `json:"id"`
+	NoteID           uint      `json:"note_id"`
+	Version          int       `json:"version"`
+	EncryptedContent string    `json:"encrypted_content,omitempty"`
+	IV               string    `json:"iv,omitempty"`
+	EncryptedKey     string    `json:"encrypted_key,omitempty"`
+	EncryptedKeyIV   string    `json:"encrypted_key_iv,omitempty"`
+	KeyKDF           string    `json:"key_kdf,omitempty"`
+	Size             int       `json:"size"`
+	SavedAt          time.Time `json:"saved_at"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ListNoteRevisionsResponse represents the response from listing note revisions
+type ListNoteRevisionsResponse struct {
+	Revisions []NoteRevision `json:"revisions"`
+	Count     int            `json:"count"`
+}
+
+// RewrappedKey is a note (or revision) DEK wrapped under a new KEK
+type RewrappedKey struct {
+	ID             uint   `json:"id"`
+	EncryptedKey   string `json:"encrypted_key"`
+	EncryptedKeyIV string `json:"encrypted_key_iv"`
+	KeyKDF         string `json:"key_kdf,omitempty"`
+}
+
+// ChangePasswordRequest represents a password change with all re-wrapped DEKs
+type ChangePasswordRequest struct {
+	OldPassword  string         `json:"old_password"`
+	NewPassword  string         `json:"new_password"`
+	NoteKeys     []RewrappedKey `json:"note_keys"`
+	RevisionKeys []RewrappedKey `json:"revision_keys"`
+}
+
+// MigrateKDFRequest represents a move to new KDF parameters with all re-wrapped DEKs
+type MigrateKDFRequest struct {
+	Password     string           `json:"password"`
+	KDF          crypto.KDFParams `json:"kdf"`
+	NoteKeys     []RewrappedKey   `json:"note_keys"`
+	RevisionKeys []RewrappedKey   `json:"revision_keys"`
+}
+
 // ListNotesResponse represents the response from listing notes
 type ListNotesResponse struct {
 	Notes []Note `json:"notes"`
@@ -71,13 +173,16 @@ type ListNotesResponse struct {
 
 // SharedNote represents a note accessed via share link
 type SharedNote struct {
-	ID               uint      `json:"id"`
-	Title            string    `json:"title"`
-	EncryptedContent string    `json:"encrypted_content"`
-	IV               string    `json:"iv"`
-	CreatedAt        time.Time `json:"created_at"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	OwnerUsername    string    `json:"owner_username"`
+	ID               uint       `json:"id"`
+	Title            string     `json:"title"`
+	EncryptedContent string     `json:"encrypted_content"`
+	IV               string     `json:"iv"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	OwnerUsername    string     `json:"owner_username"`
+	BurnAfterReading bool       `json:"burn_after_reading"`
+	Burned           bool       `json:"burned"` // This read destroyed the server's copy
+	BurnedAt         *time.Time `json:"burned_at"`
 }
 
 // Register creates a new user account
@@ -92,60 +197,225 @@ func (c *Client) Register(username, password string) error {
 		return err
 	}
 
-	resp, err := http.Post(BaseURL+"/auth/register", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.httpClient().Post(c.baseURL()+"/auth/register", "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("registration failed: %s", string(body))
+		return responseError("registration failed", resp)
 	}
 
 	return nil
 }
 
-// Login authenticates user and returns JWT token
+// Login authenticates user and returns JWT token.
+// It returns ErrMFARequired if the account has two-factor authentication.
 func (c *Client) Login(username, password string) (string, error) {
+	return c.LoginWithOTP(username, password, "")
+}
+
+// LoginWithOTP authenticates user with the password and a TOTP code in one
+// request. Without a code, accounts with two-factor authentication get
+// ErrMFARequired and the login is finished with CompleteMFALogin.
+func (c *Client) LoginWithOTP(username, password, otp string) (string, error) {
 	reqBody := LoginRequest{
 		Username: username,
 		Password: password,
 	}
+	if isTOTPCode(otp) {
+		reqBody.OTP = otp
+	} else {
+		reqBody.RecoveryCode = otp
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := http.Post(BaseURL+"/auth/login", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.httpClient().Post(c.baseURL()+"/auth/login", "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("login failed: %s", string(body))
+		return "", responseError("login failed", resp)
 	}
 
+	return c.finishLogin(resp)
+}
+
+// finishLogin stores the tokens of a successful login response
+func (c *Client) finishLogin(resp *http.Response) (string, error) {
 	var loginResp LoginResponse
 	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
 		return "", err
 	}
 
+	if loginResp.MFARequired {
+		c.MFAToken = loginResp.MFAToken
+		return "", ErrMFARequired
+	}
+
 	c.Token = loginResp.Token
+	c.RefreshToken = loginResp.RefreshToken
+	c.KDF = loginResp.KDF
+	c.MFAToken = ""
 	return loginResp.Token, nil
 }
 
-// CreateNote creates a new encrypted note with encrypted key
-func (c *Client) CreateNote(title, encryptedContent, iv, encryptedKey, encryptedKeyIV string) error {
+// Logout revokes the current token on the server
+func (c *Client) Logout() error {
+	return c.revokeTokens("/auth/logout")
+}
+
+// RevokeAllTokens signs the user out everywhere, including this client
+func (c *Client) RevokeAllTokens() error {
+	return c.revokeTokens("/auth/revoke-all")
+}
+
+// revokeTokens posts to a token revocation endpoint. The refresh token is sent
+// along so the server can end its family as well.
+func (c *Client) revokeTokens(path string) error {
+	jsonData, err := json.Marshal(map[string]string{"refresh_token": c.RefreshToken})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL()+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("logout failed", resp)
+	}
+
+	c.Token = ""
+	c.RefreshToken = ""
+	return nil
+}
+
+// GetKDFParams returns the KDF parameters of the logged in user
+func (c *Client) GetKDFParams() (crypto.KDFParams, error) {
+	req, err := http.NewRequest("GET", c.baseURL()+"/auth/kdf", nil)
+	if err != nil {
+		return crypto.KDFParams{}, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return crypto.KDFParams{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return crypto.KDFParams{}, responseError("get KDF parameters failed", resp)
+	}
+
+	var params crypto.KDFParams
+	if err := json.NewDecoder(resp.Body).Decode(&params); err != nil {
+		return crypto.KDFParams{}, err
+	}
+
+	return params, nil
+}
+
+// MigrateKDF moves the user to new KDF parameters and stores the re-wrapped DEKs atomically
+func (c *Client) MigrateKDF(password string, params crypto.KDFParams, noteKeys, revisionKeys []RewrappedKey) error {
+	reqBody := MigrateKDFRequest{
+		Password:     password,
+		KDF:          params,
+		NoteKeys:     noteKeys,
+		RevisionKeys: revisionKeys,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", c.baseURL()+"/auth/kdf", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("KDF migration failed", resp)
+	}
+
+	c.KDF = params
+	return nil
+}
+
+// ChangePassword changes the account password and stores the re-wrapped DEKs atomically
+func (c *Client) ChangePassword(oldPassword, newPassword string, noteKeys, revisionKeys []RewrappedKey) error {
+	reqBody := ChangePasswordRequest{
+		OldPassword:  oldPassword,
+		NewPassword:  newPassword,
+		NoteKeys:     noteKeys,
+		RevisionKeys: revisionKeys,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL()+"/auth/password", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("change password failed", resp)
+	}
+
+	return nil
+}
+
+// CreateNote creates a new encrypted note with encrypted key.
+// keyKDF is the descriptor of the KDF parameters used for the wrapping KEK.
+func (c *Client) CreateNote(title, encryptedContent, iv, encryptedKey, encryptedKeyIV, keyKDF string) error {
 	reqBody := CreateNoteRequest{
 		Title:            title,
 		EncryptedContent: encryptedContent,
 		IV:               iv,
 		EncryptedKey:     encryptedKey,
 		EncryptedKeyIV:   encryptedKeyIV,
+		KeyKDF:           keyKDF,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -153,7 +423,7 @@ func (c *Client) CreateNote(title, encryptedContent, iv, encryptedKey, encrypted
 		return err
 	}
 
-	req, err := http.NewRequest("POST", BaseURL+"/notes", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", c.baseURL()+"/notes", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -161,15 +431,14 @@ func (c *Client) CreateNote(title, encryptedContent, iv, encryptedKey, encrypted
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("create note failed: %s", string(body))
+		return responseError("create note failed", resp)
 	}
 
 	return nil
@@ -177,22 +446,21 @@ func (c *Client) CreateNote(title, encryptedContent, iv, encryptedKey, encrypted
 
 // ListNotes retrieves all notes for the authenticated user
 func (c *Client) ListNotes() ([]Note, error) {
-	req, err := http.NewRequest("GET", BaseURL+"/notes", nil)
+	req, err := http.NewRequest("GET", c.baseURL()+"/notes", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("list notes failed: %s", string(body))
+		return nil, responseError("list notes failed", resp)
 	}
 
 	// Parse response with nested notes array
@@ -204,24 +472,162 @@ func (c *Client) ListNotes() ([]Note, error) {
 	return response.Notes, nil
 }
 
+// UpdateNote replaces the ciphertext of a note. version must be the version the
+// caller read; ErrNoteConflict is returned if the note changed in the meantime.
+// encryptedKey and encryptedKeyIV may be empty to keep the current wrapped DEK.
+func (c *Client) UpdateNote(id uint, encryptedContent, iv, encryptedKey, encryptedKeyIV string, version int) (Note, error) {
+	reqBody := UpdateNoteRequest{
+		EncryptedContent: encryptedContent,
+		IV:               iv,
+		EncryptedKey:     encryptedKey,
+		EncryptedKeyIV:   encryptedKeyIV,
+		Version:          version,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return Note{}, err
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/notes/%d", c.baseURL(), id), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return Note{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return Note{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return Note{}, ErrNoteConflict
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Note{}, responseError("update note failed", resp)
+	}
+
+	var note Note
+	if err := json.NewDecoder(resp.Body).Decode(&note); err != nil {
+		return Note{}, err
+	}
+
+	return note, nil
+}
+
+// ListNoteRevisions lists previous versions of a note, newest first (without content)
+func (c *Client) ListNoteRevisions(noteID uint) ([]NoteRevision, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/notes/%d/revisions", c.baseURL(), noteID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("list revisions failed", resp)
+	}
+
+	var listResp ListNoteRevisionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, err
+	}
+
+	return listResp.Revisions, nil
+}
+
+// GetNoteRevision retrieves one previous version of a note with its ciphertext
+func (c *Client) GetNoteRevision(noteID, revisionID uint) (NoteRevision, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/notes/%d/revisions/%d", c.baseURL(), noteID, revisionID), nil)
+	if err != nil {
+		return NoteRevision{}, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return NoteRevision{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return NoteRevision{}, responseError("get revision failed", resp)
+	}
+
+	var revision NoteRevision
+	if err := json.NewDecoder(resp.Body).Decode(&revision); err != nil {
+		return NoteRevision{}, err
+	}
+
+	return revision, nil
+}
+
+// RestoreNoteRevision makes a previous version the current content of a note.
+// version is the note version the caller last saw (0 skips the check).
+func (c *Client) RestoreNoteRevision(noteID, revisionID uint, version int) (Note, error) {
+	jsonData, err := json.Marshal(map[string]int{"version": version})
+	if err != nil {
+		return Note{}, err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/revisions/%d/restore", c.baseURL(), noteID, revisionID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return Note{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return Note{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return Note{}, ErrNoteConflict
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Note{}, responseError("restore revision failed", resp)
+	}
+
+	var note Note
+	if err := json.NewDecoder(resp.Body).Decode(&note); err != nil {
+		return Note{}, err
+	}
+
+	return note, nil
+}
+
 // DeleteNote deletes a note by ID
 func (c *Client) DeleteNote(id uint) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/notes/%d", BaseURL, id), nil)
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/notes/%d", c.baseURL(), id), nil)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("delete note failed: %s", string(body))
+		return responseError("delete note failed", resp)
 	}
 
 	return nil
@@ -229,27 +635,182 @@ func (c *Client) DeleteNote(id uint) error {
 
 // RevokeShare revokes all sharing links for a note
 func (c *Client) RevokeShare(id uint) error {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/revoke", BaseURL, id), nil)
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/revoke", c.baseURL(), id), nil)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("revoke share failed: %s", string(body))
+		return responseError("revoke share failed", resp)
 	}
 
 	return nil
 }
 
+// ShareLinkInfo describes one share link of a note to its owner
+type ShareLinkInfo struct {
+	ID               uint       `json:"id"`
+	NoteID           uint       `json:"note_id"`
+	ShareToken       string     `json:"share_token"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	AccessCount      int        `json:"access_count"`
+	MaxAccessCount   int        `json:"max_access_count"` // 0 = unlimited
+	RequirePassword  bool       `json:"require_password"`
+	BurnAfterReading bool       `json:"burn_after_reading"`
+	ReadAt           *time.Time `json:"read_at,omitempty"`
+	Status           string     `json:"status"` // "active", "expired", "exhausted" or "read"
+}
+
+// UpdateShareLinkRequest changes a share link; nil fields are left unchanged
+type UpdateShareLinkRequest struct {
+	DurationHours  *int `json:"duration_hours,omitempty"`
+	MaxAccessCount *int `json:"max_access_count,omitempty"`
+}
+
+// ListShareLinks lists the share links of a note, newest first
+func (c *Client) ListShareLinks(noteID uint) ([]ShareLinkInfo, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/notes/%d/shares", c.baseURL(), noteID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("list share links failed", resp)
+	}
+
+	var response struct {
+		Links []ShareLinkInfo `json:"links"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Links, nil
+}
+
+// UpdateShareLink changes the expiry (in hours from now) or the max access
+// count of one share link
+func (c *Client) UpdateShareLink(noteID, linkID uint, update UpdateShareLinkRequest) (ShareLinkInfo, error) {
+	jsonData, err := json.Marshal(update)
+	if err != nil {
+		return ShareLinkInfo{}, err
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/notes/%d/shares/%d", c.baseURL(), noteID, linkID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return ShareLinkInfo{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return ShareLinkInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ShareLinkInfo{}, responseError("update share link failed", resp)
+	}
+
+	var link ShareLinkInfo
+	if err := json.NewDecoder(resp.Body).Decode(&link); err != nil {
+		return ShareLinkInfo{}, err
+	}
+
+	return link, nil
+}
+
+// DeleteShareLink revokes one share link of a note
+func (c *Client) DeleteShareLink(noteID, linkID uint) error {
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/notes/%d/shares/%d", c.baseURL(), noteID, linkID), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("revoke share link failed", resp)
+	}
+
+	return nil
+}
+
+// ShareAccessEvent is one attempt to open a share link
+type ShareAccessEvent struct {
+	ID        uint      `json:"id"`
+	LinkID    uint      `json:"link_id"`
+	NoteID    uint      `json:"note_id"`
+	Outcome   string    `json:"outcome"` // "success", "wrong_password", "expired", "exhausted", ...
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListNoteAccessLog lists the access attempts on every share link of a note, newest first
+func (c *Client) ListNoteAccessLog(noteID uint) ([]ShareAccessEvent, error) {
+	return c.listAccessLog(fmt.Sprintf("%s/notes/%d/access-log", c.baseURL(), noteID))
+}
+
+// ListShareLinkAccessLog lists the access attempts on one share link, newest first
+func (c *Client) ListShareLinkAccessLog(noteID, linkID uint) ([]ShareAccessEvent, error) {
+	return c.listAccessLog(fmt.Sprintf("%s/notes/%d/shares/%d/access-log", c.baseURL(), noteID, linkID))
+}
+
+// listAccessLog fetches an access log from url
+func (c *Client) listAccessLog(url string) ([]ShareAccessEvent, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("list access log failed", resp)
+	}
+
+	var response struct {
+		Events []ShareAccessEvent `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Events, nil
+}
+
 // CreateShare creates a share link for a note
 func (c *Client) CreateShare(id uint, durationHours int) (string, error) {
 	if durationHours == 0 {
@@ -265,7 +826,7 @@ func (c *Client) CreateShare(id uint, durationHours int) (string, error) {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/share", BaseURL, id), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/share", c.baseURL(), id), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -273,15 +834,14 @@ func (c *Client) CreateShare(id uint, durationHours int) (string, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("create share failed: %s", string(body))
+		return "", responseError("create share failed", resp)
 	}
 
 	var response map[string]interface{}
@@ -321,7 +881,7 @@ func (c *Client) CreateShareWithOptions(id uint, durationHours int, password str
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/share", BaseURL, id), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/share", c.baseURL(), id), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -329,15 +889,14 @@ func (c *Client) CreateShareWithOptions(id uint, durationHours int, password str
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("create share failed: %s", string(body))
+		return "", responseError("create share failed", resp)
 	}
 
 	var response map[string]interface{}
@@ -352,6 +911,59 @@ func (c *Client) CreateShareWithOptions(id uint, durationHours int, password str
 	return "", fmt.Errorf("no share token in response")
 }
 
+// CreateBurnShare creates a link that can be opened once. The server keeps
+// only encryptedContent (the note encrypted under a one-time key) for it and
+// destroys that copy when the link is read.
+func (c *Client) CreateBurnShare(id uint, durationHours int, password, encryptedContent, iv string) (string, error) {
+	if durationHours == 0 {
+		durationHours = 24
+	}
+
+	reqBody := map[string]interface{}{
+		"duration_hours":     durationHours,
+		"burn_after_reading": true,
+		"encrypted_content":  encryptedContent,
+		"iv":                 iv,
+	}
+	if password != "" {
+		reqBody["password"] = password
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/share", c.baseURL(), id), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", responseError("create share failed", resp)
+	}
+
+	var response struct {
+		ShareToken string `json:"share_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	if response.ShareToken == "" {
+		return "", fmt.Errorf("no share token in response")
+	}
+	return response.ShareToken, nil
+}
+
 // GetSharedNote retrieves a note via share token (with optional password)
 func (c *Client) GetSharedNote(shareToken string, password string) (SharedNote, error) {
 	var reqBody []byte
@@ -368,9 +980,10 @@ func (c *Client) GetSharedNote(shareToken string, password string) (SharedNote,
 
 	var req *http.Request
 	if password != "" {
-		req, err = http.NewRequest("GET", fmt.Sprintf("%s/shares/%s", BaseURL, shareToken), bytes.NewBuffer(reqBody))
+		// The password goes in a POST body; GET requests with a body are dropped by some proxies
+		req, err = http.NewRequest("POST", fmt.Sprintf("%s/shares/%s", c.baseURL(), shareToken), bytes.NewBuffer(reqBody))
 	} else {
-		req, err = http.NewRequest("GET", fmt.Sprintf("%s/shares/%s", BaseURL, shareToken), nil)
+		req, err = http.NewRequest("GET", fmt.Sprintf("%s/shares/%s", c.baseURL(), shareToken), nil)
 	}
 	
 	if err != nil {
@@ -381,24 +994,22 @@ func (c *Client) GetSharedNote(shareToken string, password string) (SharedNote,
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return SharedNote{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		body, _ := io.ReadAll(resp.Body)
-		return SharedNote{}, fmt.Errorf("unauthorized: %s", string(body))
+		return SharedNote{}, responseError("unauthorized", resp)
 	}
 
 	if resp.StatusCode == http.StatusGone {
-		return SharedNote{}, fmt.Errorf("share link has expired or reached maximum access count")
+		return SharedNote{}, &APIError{Op: "share link has expired or reached maximum access count", StatusCode: resp.StatusCode}
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return SharedNote{}, fmt.Errorf("get shared note failed: %s", string(body))
+		return SharedNote{}, responseError("get shared note failed", resp)
 	}
 
 	var sharedNote SharedNote
@@ -411,22 +1022,21 @@ func (c *Client) GetSharedNote(shareToken string, password string) (SharedNote,
 
 // GetNote retrieves a specific note by ID
 func (c *Client) GetNote(id uint) (Note, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/notes/%d", BaseURL, id), nil)
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/notes/%d", c.baseURL(), id), nil)
 	if err != nil {
 		return Note{}, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return Note{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return Note{}, fmt.Errorf("get note failed: %s", string(body))
+		return Note{}, responseError("get note failed", resp)
 	}
 
 	var note Note
@@ -448,7 +1058,7 @@ func (c *Client) CreateShareWithMinutes(id uint, durationMinutes int) (string, e
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/share", BaseURL, id), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/share", c.baseURL(), id), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -456,15 +1066,14 @@ func (c *Client) CreateShareWithMinutes(id uint, durationMinutes int) (string, e
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("create share failed: %s", string(body))
+		return "", responseError("create share failed", resp)
 	}
 
 	var response map[string]interface{}
@@ -481,14 +1090,17 @@ func (c *Client) CreateShareWithMinutes(id uint, durationMinutes int) (string, e
 
 // E2EEShare represents an E2EE share
 type E2EEShare struct {
-	ID               uint      `json:"id"`
-	NoteTitle        string    `json:"note_title"`
-	SenderUsername   string    `json:"sender_username"`
-	SenderPublicKey  string    `json:"sender_public_key"`
-	EncryptedContent string    `json:"encrypted_content"`
-	ContentIV        string    `json:"content_iv"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               uint       `json:"id"`
+	NoteTitle        string     `json:"note_title"`
+	SenderUsername   string     `json:"sender_username"`
+	SenderPublicKey  string     `json:"sender_public_key"`
+	EncryptedContent string     `json:"encrypted_content"` // Empty in listings of burn-after-reading shares
+	ContentIV        string     `json:"content_iv"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	BurnAfterReading bool       `json:"burn_after_reading"`
+	Burned           bool       `json:"burned"` // This read destroyed the server's copy
+	BurnedAt         *time.Time `json:"burned_at"`
 }
 
 // ListE2EESharesResponse represents the response from listing E2EE shares
@@ -504,16 +1116,19 @@ type CreateE2EEShareRequest struct {
 	EncryptedContent  string `json:"encrypted_content"`
 	ContentIV         string `json:"content_iv"`
 	DurationHours     int    `json:"duration_hours,omitempty"`
+	BurnAfterReading  bool   `json:"burn_after_reading,omitempty"`
 }
 
-// CreateE2EEShare creates an E2EE share with a specific user
-func (c *Client) CreateE2EEShare(noteID uint, recipientUsername, senderPublicKey, encryptedContent, contentIV string, durationHours int) (uint, error) {
+// CreateE2EEShare creates an E2EE share with a specific user. A
+// burn-after-reading share is destroyed when the recipient first opens it.
+func (c *Client) CreateE2EEShare(noteID uint, recipientUsername, senderPublicKey, encryptedContent, contentIV string, durationHours int, burnAfterReading bool) (uint, error) {
 	reqBody := CreateE2EEShareRequest{
 		RecipientUsername: recipientUsername,
 		SenderPublicKey:   senderPublicKey,
 		EncryptedContent:  encryptedContent,
 		ContentIV:         contentIV,
 		DurationHours:     durationHours,
+		BurnAfterReading:  burnAfterReading,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -521,7 +1136,7 @@ func (c *Client) CreateE2EEShare(noteID uint, recipientUsername, senderPublicKey
 		return 0, err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/e2ee", BaseURL, noteID), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/notes/%d/e2ee", c.baseURL(), noteID), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return 0, err
 	}
@@ -529,15 +1144,14 @@ func (c *Client) CreateE2EEShare(noteID uint, recipientUsername, senderPublicKey
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("create E2EE share failed: %s", string(body))
+		return 0, responseError("create E2EE share failed", resp)
 	}
 
 	var response map[string]interface{}
@@ -554,22 +1168,21 @@ func (c *Client) CreateE2EEShare(noteID uint, recipientUsername, senderPublicKey
 
 // ListE2EEShares retrieves all E2EE shares received by the user
 func (c *Client) ListE2EEShares() ([]E2EEShare, error) {
-	req, err := http.NewRequest("GET", BaseURL+"/e2ee", nil)
+	req, err := http.NewRequest("GET", c.baseURL()+"/e2ee", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("list E2EE shares failed: %s", string(body))
+		return nil, responseError("list E2EE shares failed", resp)
 	}
 
 	var response ListE2EESharesResponse
@@ -582,22 +1195,21 @@ func (c *Client) ListE2EEShares() ([]E2EEShare, error) {
 
 // GetE2EEShare retrieves a specific E2EE share by ID
 func (c *Client) GetE2EEShare(shareID uint) (E2EEShare, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/e2ee/%d", BaseURL, shareID), nil)
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/e2ee/%d", c.baseURL(), shareID), nil)
 	if err != nil {
 		return E2EEShare{}, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return E2EEShare{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return E2EEShare{}, fmt.Errorf("get E2EE share failed: %s", string(body))
+		return E2EEShare{}, responseError("get E2EE share failed", resp)
 	}
 
 	var share E2EEShare
@@ -610,27 +1222,67 @@ func (c *Client) GetE2EEShare(shareID uint) (E2EEShare, error) {
 
 // DeleteE2EEShare deletes an E2EE share (revokes sharing)
 func (c *Client) DeleteE2EEShare(shareID uint) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/e2ee/%d", BaseURL, shareID), nil)
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/e2ee/%d", c.baseURL(), shareID), nil)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("delete E2EE share failed: %s", string(body))
+		return responseError("delete E2EE share failed", resp)
 	}
 
 	return nil
 }
 
+// ReadReceipt tells whether a burn-after-reading share was read, and when
+type ReadReceipt struct {
+	Type      string     `json:"type"` // "link" or "e2ee"
+	ID        uint       `json:"id"`
+	NoteID    uint       `json:"note_id"`
+	NoteTitle string     `json:"note_title"`
+	Recipient string     `json:"recipient,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// ListReadReceipts lists the user's burn-after-reading shares
+func (c *Client) ListReadReceipts() ([]ReadReceipt, error) {
+	req, err := http.NewRequest("GET", c.baseURL()+"/receipts", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("list read receipts failed", resp)
+	}
+
+	var response struct {
+		Receipts []ReadReceipt `json:"receipts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Receipts, nil
+}
+
 // UpdatePublicKey updates user's DH public key on server
 func (c *Client) UpdatePublicKey(publicKey string) error {
 	reqBody := map[string]string{
@@ -642,7 +1294,7 @@ func (c *Client) UpdatePublicKey(publicKey string) error {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", BaseURL+"/user/publickey", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", c.baseURL()+"/user/publickey", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -650,15 +1302,14 @@ func (c *Client) UpdatePublicKey(publicKey string) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("update public key failed: %s", string(body))
+		return responseError("update public key failed", resp)
 	}
 
 	return nil
@@ -666,22 +1317,21 @@ func (c *Client) UpdatePublicKey(publicKey string) error {
 
 // GetUserPublicKey retrieves a user's DH public key
 func (c *Client) GetUserPublicKey(username string) (string, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/users/%s/publickey", BaseURL, username), nil)
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/users/%s/publickey", c.baseURL(), username), nil)
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("get public key failed: %s", string(body))
+		return "", responseError("get public key failed", resp)
 	}
 
 	var response map[string]interface{}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned when the server answers a request with an error status
+type APIError struct {
+	Op         string // What failed, e.g. "login failed"
+	StatusCode int
+	Message    string // The "message" of the server's JSON error, or the raw body
+	Body       string // The raw response body
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return e.Op
+	}
+	return e.Op + ": " + e.Body
+}
+
+// maxErrorBody limits how much of an error response is kept
+const maxErrorBody = 64 << 10
+
+// responseError reads an error response into an APIError
+func responseError(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	apiErr := &APIError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Message:    strings.TrimSpace(string(body)),
+	}
+
+	var errorBody struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &errorBody) == nil && errorBody.Message != "" {
+		apiErr.Message = errorBody.Message
+	}
+	return apiErr
+}
+
+// StatusCode returns the HTTP status of an APIError in err's chain, or 0
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
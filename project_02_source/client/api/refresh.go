@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// RefreshRequest represents a refresh token exchange
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshResponse represents the tokens returned by a refresh
+type RefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Refresh exchanges the refresh token for a new access token and refresh token
+func (c *Client) Refresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.refreshLocked()
+}
+
+// refreshLocked performs the exchange; refreshMu must be held
+func (c *Client) refreshLocked() error {
+	if c.RefreshToken == "" {
+		return errors.New("no refresh token, please log in again")
+	}
+
+	jsonData, err := json.Marshal(RefreshRequest{RefreshToken: c.RefreshToken})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient().Post(c.baseURL()+"/auth/refresh", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// A refresh token only works once; whatever happened, it is gone now
+		c.RefreshToken = ""
+		return responseError("session expired, please log in again", resp)
+	}
+
+	var refreshResp RefreshResponse
+	if err := json.NewDecoder(resp.Body).Decode(&refreshResp); err != nil {
+		return err
+	}
+
+	c.Token = refreshResp.Token
+	c.RefreshToken = refreshResp.RefreshToken
+	AuthToken = refreshResp.Token
+
+	if c.OnTokenRefresh != nil {
+		c.OnTokenRefresh(c.Token, c.RefreshToken)
+	}
+	return nil
+}
+
+// do sends an authenticated request. If the access token was rejected and a
+// refresh token is available, the tokens are renewed and the request is sent
+// once more with the new access token.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient().Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	usedToken := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if usedToken == "" || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil // Not retryable
+	}
+
+	// Only one refresh at a time: a second exchange of the same refresh token
+	// would look like token theft and end the session
+	c.refreshMu.Lock()
+	if c.Token == usedToken {
+		err = c.refreshLocked()
+	}
+	token := c.Token
+	c.refreshMu.Unlock()
+	if err != nil || token == usedToken {
+		return resp, nil // Keep the original 401
+	}
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", "Bearer "+token)
+
+	return c.httpClient().Do(retry)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSOptions configures how the client verifies the server and authenticates to it
+type TLSOptions struct {
+	CAFile   string // PEM bundle of CAs to trust in addition to the system roots
+	CertFile string // Client certificate (PEM) for servers that use mutual TLS
+	KeyFile  string // Private key for CertFile
+}
+
+// TLSOptionsFromEnv reads SECURE_NOTES_CA_FILE, SECURE_NOTES_CLIENT_CERT and SECURE_NOTES_CLIENT_KEY
+func TLSOptionsFromEnv() TLSOptions {
+	return TLSOptions{
+		CAFile:   os.Getenv("SECURE_NOTES_CA_FILE"),
+		CertFile: os.Getenv("SECURE_NOTES_CLIENT_CERT"),
+		KeyFile:  os.Getenv("SECURE_NOTES_CLIENT_KEY"),
+	}
+}
+
+// IsZero reports whether no TLS option is set
+func (o TLSOptions) IsZero() bool {
+	return o == TLSOptions{}
+}
+
+// NewHTTPClient returns an HTTP client using the given TLS options.
+// Without options it returns http.DefaultClient.
+func NewHTTPClient(opts TLSOptions) (*http.Client, error) {
+	if opts.IsZero() {
+		return http.DefaultClient, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("client certificate and key must be given together")
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// ConfigureTLS makes the client use the given TLS options for every request
+func (c *Client) ConfigureTLS(opts TLSOptions) error {
+	httpClient, err := NewHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	c.HTTPClient = httpClient
+	return nil
+}
+
+// httpClient returns the HTTP client used for requests
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrMFARequired is returned by Login when the password was right but the
+// account needs a two-factor code; finish the login with CompleteMFALogin
+var ErrMFARequired = errors.New("two-factor code required")
+
+// MFALoginRequest represents the second step of a login
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	OTP          string `json:"otp,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// TOTPStatus represents the two-factor state of the account
+type TOTPStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TOTPSetup represents a new TOTP secret waiting to be confirmed
+type TOTPSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	Message    string `json:"message"`
+}
+
+// TOTPConfirmRequest represents the code that proves the authenticator works
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// TOTPConfirmResponse represents the recovery codes given when TOTP is enabled
+type TOTPConfirmResponse struct {
+	Success       bool     `json:"success"`
+	RecoveryCodes []string `json:"recovery_codes"`
+	Message       string   `json:"message"`
+}
+
+// TOTPDisableRequest represents turning two-factor authentication off
+type TOTPDisableRequest struct {
+	Password     string `json:"password"`
+	OTP          string `json:"otp,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// isTOTPCode tells a 6-digit TOTP code from a recovery code
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CompleteMFALogin finishes a login that returned ErrMFARequired. code is
+// either a TOTP code from the authenticator app or a recovery code.
+func (c *Client) CompleteMFALogin(code string) (string, error) {
+	if c.MFAToken == "" {
+		return "", errors.New("no login waiting for a two-factor code")
+	}
+
+	reqBody := MFALoginRequest{MFAToken: c.MFAToken}
+	if isTOTPCode(code) {
+		reqBody.OTP = code
+	} else {
+		reqBody.RecoveryCode = code
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient().Post(c.baseURL()+"/auth/login/mfa", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError("login failed", resp)
+	}
+
+	return c.finishLogin(resp)
+}
+
+// GetTOTPStatus tells whether two-factor authentication is enabled
+func (c *Client) GetTOTPStatus() (TOTPStatus, error) {
+	var status TOTPStatus
+
+	req, err := http.NewRequest("GET", c.baseURL()+"/auth/totp", nil)
+	if err != nil {
+		return status, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return status, responseError("get two-factor status failed", resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return status, err
+}
+
+// SetupTOTP generates a new TOTP secret. Two-factor authentication is only
+// turned on once a code for it is sent to ConfirmTOTP.
+func (c *Client) SetupTOTP() (TOTPSetup, error) {
+	var setup TOTPSetup
+
+	req, err := http.NewRequest("POST", c.baseURL()+"/auth/totp/setup", nil)
+	if err != nil {
+		return setup, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return setup, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return setup, responseError("two-factor setup failed", resp)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&setup)
+	return setup, err
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery codes
+func (c *Client) ConfirmTOTP(code string) ([]string, error) {
+	jsonData, err := json.Marshal(TOTPConfirmRequest{Code: code})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL()+"/auth/totp/confirm", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("two-factor confirmation failed", resp)
+	}
+
+	var confirmResp TOTPConfirmResponse
+	if err := json.NewDecoder(resp.Body).Decode(&confirmResp); err != nil {
+		return nil, err
+	}
+	return confirmResp.RecoveryCodes, nil
+}
+
+// DisableTOTP turns two-factor authentication off. code is a TOTP code or a
+// recovery code.
+func (c *Client) DisableTOTP(password, code string) error {
+	reqBody := TOTPDisableRequest{Password: password}
+	if isTOTPCode(code) {
+		reqBody.OTP = code
+	} else {
+		reqBody.RecoveryCode = code
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL()+"/auth/totp/disable", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("disable two-factor failed", resp)
+	}
+
+	return nil
+}
@@ -1,240 +1,471 @@
 package cli
 
 import (
-	"bytes"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
+	"lab02_mahoa/client/account"
 	"lab02_mahoa/client/api"
 	"lab02_mahoa/client/crypto"
-	"net/http"
+	"lab02_mahoa/client/diff"
 	"os"
-	"text/tabwriter"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// Run executes CLI commands
-func Run(args []string) {
+// Run executes CLI commands and returns the exit code of the process
+func Run(args []string) int {
+	args, err := parseGlobalFlags(args)
+	if err != nil {
+		return reportError(err)
+	}
 	if len(args) == 0 {
 		printUsage()
-		return
+		return exitOK
 	}
 
 	command := args[0]
+	if command != "profile" {
+		if active, err = loadProfile(profileName); err != nil {
+			return reportError(err)
+		}
+	}
 
 	switch command {
 	case "list":
-		handleList()
+		err = handleList()
 	case "delete":
-		handleDelete(args[1:])
+		err = handleDelete(args[1:])
 	case "revoke":
-		handleRevoke(args[1:])
+		err = handleRevoke(args[1:])
 	case "login":
-		handleLogin(args[1:])
+		err = handleLogin(args[1:])
+	case "logout":
+		err = handleLogout(args[1:])
 	case "register":
-		handleRegister(args[1:])
+		err = handleRegister(args[1:])
 	case "upload":
-		handleUpload(args[1:])
+		err = handleUpload(args[1:])
+	case "get", "decrypt":
+		err = handleGet(args[1:])
+	case "share":
+		err = handleShare(args[1:])
+	case "open":
+		err = handleOpen(args[1:])
+	case "send":
+		err = handleSend(args[1:])
+	case "inbox":
+		err = handleInbox()
+	case "receive":
+		err = handleReceive(args[1:])
+	case "receipts":
+		err = handleReceipts()
+	case "links":
+		err = handleLinks(args[1:])
+	case "link":
+		err = handleLink(args[1:])
+	case "audit":
+		err = handleAudit(args[1:])
+	case "edit":
+		err = handleEdit(args[1:])
+	case "history":
+		err = handleHistory(args[1:])
+	case "diff":
+		err = handleDiff(args[1:])
+	case "restore":
+		err = handleRestore(args[1:])
+	case "passwd":
+		err = handlePasswd(args[1:])
+	case "kdf":
+		err = handleKDF(args[1:])
+	case "profile":
+		err = handleProfile(args[1:])
+	case "help", "-h", "--help":
+		printUsage()
 	default:
-		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
+		err = usageError(fmt.Sprintf("Unknown command: %s", command), "")
 	}
+
+	if err != nil {
+		return reportError(err)
+	}
+	return exitOK
 }
 
 func printUsage() {
-	fmt.Println(`
+	fmt.Fprint(messages(), `
 Secure Notes CLI - Usage:
+  secure-notes [--output json|table|plain] [--profile <name>] <command>
+
   list                         List all notes
   delete -id <note_id>         Delete a note by ID
-  revoke -id <note_id>         Revoke sharing for a note
-  login -token <jwt_token>     Save JWT token for authentication
+  revoke -id <note_id> [-link <link_id>]
+                               Revoke sharing for a note, or only one link
+  login -u <user> [-otp <code>]
+                               Log in (asks for the password) and save the
+                               session in ~/.lab02_mahoa/cli; -otp is the
+                               two-factor code (or a recovery code) if enabled
+  login -token <jwt_token>     Save an existing JWT token instead
+  logout [-all]                Revoke the saved session (or every token of
+                               the account with -all) and remove it
   register -u <user> -p <pass> Register new account
   upload -t <title> -c <file>  Upload and encrypt a note from file
+  get -id <note_id> [-o <file>]
+                               Decrypt a note and print it (or save it);
+                               "decrypt" is an alias
+  share -id <note_id> [-hours 24] [-password] [-max N | -burn]
+                               Create a share link with the key in its
+                               #key= fragment; -password asks for a password;
+                               -burn makes a link that works once, after which
+                               the server destroys its copy of the note
+  open <share_url> [-key <key>] [-o <file>]
+                               Fetch and decrypt a share link (asks for the
+                               password if the link has one)
+  send -id <note_id> -to <user> [-hours 24] [-burn]
+                               Share a note end-to-end encrypted with a user;
+                               -burn destroys it once the user has read it
+  inbox                        List E2EE shares sent to you
+  receive -id <share_id> [-o <file>]
+                               Decrypt an E2EE share from your inbox
+  receipts                     List your burn-after-reading shares and when
+                               they were read
+  links -id <note_id>          List the share links of a note with their
+                               expiry, opens and password flag
+  link -id <note_id> -link <link_id> [-hours N] [-max N]
+                               Set a link to expire N hours from now and/or
+                               change its max opens (0 = unlimited)
+  audit -id <note_id> [-link <link_id>]
+                               Show who tried to open the share links of a
+                               note (time, outcome, IP, user agent)
+  edit -id <note_id> [-c <file>]
+                               Decrypt a note, edit it in $EDITOR (or replace it
+                               with a file), re-encrypt and upload
+  history -id <note_id>        List previous versions of a note
+  diff -id <note_id> -rev <revision_id>
+                               Show what changed since a previous version
+  restore -id <note_id> -rev <revision_id>
+                               Restore a previous version of a note
+  passwd -u <user>             Change your password (re-encrypts all note keys
+                               and the local keystore)
+  kdf [-alg argon2id|pbkdf2-sha256] [-target 500ms]
+      [-time N -memory KiB -threads N] [-iterations N]
+                               Show or change how your key is derived from
+                               the password (re-encrypts all note keys)
+  profile list                 List saved server profiles
+  profile add -name <name> -server <url> [-u <user>] [-ca <file>] [-default]
+                               Save a server profile (URL of the API, e.g.
+                               https://notes.example.com/api)
+  profile use -name <name>     Use a profile when --profile is not given
+                               ("-name ''" goes back to the local server)
+  profile remove -name <name>  Delete a profile
+
+Server (--profile, before the command):
+  Without --profile the default profile is used, or the local server
+  (http://localhost:8080/api). SECURE_NOTES_SERVER overrides the server URL
+  of any profile. Each profile keeps its own login session.
+
+Output (--output, before the command):
+  table   Tables and messages for people (default)
+  plain   Tab-separated values without headers, messages on stderr
+  json    JSON on stdout; errors as {"error": {...}} on stderr
+
+Exit codes:
+  0 ok, 1 other error, 2 bad arguments, 3 not logged in or access denied,
+  4 not found, 5 share expired or gone, 6 network error
 `)
 }
 
+// noteSummary is a note in `list` JSON output
+type noteSummary struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Size      int       `json:"size"`
+	Version   int       `json:"version"`
+	IsShared  bool      `json:"is_shared"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // handleList lists all notes
-func handleList() {
+func handleList() error {
 	token := loadToken()
 	if token == "" {
-		fmt.Println("❌ Error: No token found. Please login first.")
-		fmt.Println("   Use: secure-notes login -token <your_jwt_token>")
-		return
+		return errNotLoggedIn
 	}
 
 	// Create client with token
-	client := &api.Client{Token: token}
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
 
 	// Call API
 	notes, err := client.ListNotes()
 	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+		return err
+	}
+
+	if outputFormat == outputJSON {
+		summaries := make([]noteSummary, 0, len(notes))
+		for _, note := range notes {
+			summaries = append(summaries, noteSummary{
+				ID:        note.ID,
+				Title:     note.Title,
+				Size:      len(note.EncryptedContent),
+				Version:   note.Version,
+				IsShared:  note.IsShared,
+				CreatedAt: note.CreatedAt,
+				UpdatedAt: note.UpdatedAt,
+			})
+		}
+		return printJSON(summaries)
 	}
 
 	if len(notes) == 0 {
-		fmt.Println("📭 No notes found")
-		return
+		status("📭 No notes found")
+		return nil
 	}
 
 	// Display in table format
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tTitle\tSize\tCreated At")
-	fmt.Fprintln(w, "--\t-----\t----\t----------")
-
+	rows := make([][]string, 0, len(notes))
 	for _, note := range notes {
 		size := len(note.EncryptedContent)
-		fmt.Fprintf(w, "%d\t%s\t%d bytes\t%s\n", note.ID, note.Title, size, note.CreatedAt.Format("2006-01-02 15:04"))
+		if outputFormat == outputPlain {
+			rows = append(rows, []string{fmt.Sprint(note.ID), note.Title, fmt.Sprint(size), note.CreatedAt.Format(time.RFC3339)})
+		} else {
+			rows = append(rows, []string{fmt.Sprint(note.ID), note.Title, fmt.Sprintf("%d bytes", size), note.CreatedAt.Format("2006-01-02 15:04")})
+		}
 	}
-
-	w.Flush()
-	fmt.Printf("\n✅ Total: %d notes\n", len(notes))
+	printRows([]string{"ID", "Title", "Size", "Created At"}, rows)
+	status("\n✅ Total: %d notes", len(notes))
+	return nil
 }
 
 // handleDelete deletes a note
-func handleDelete(args []string) {
+func handleDelete(args []string) error {
 	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
 	noteID := fs.String("id", "", "Note ID to delete")
-	fs.Parse(args)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
 
 	if *noteID == "" {
-		fmt.Println("❌ Error: Please provide -id <note_id>")
-		fmt.Println("   Usage: secure-notes delete -id 123")
-		return
+		return usageError("Please provide -id <note_id>", "delete -id 123")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
 	}
 
 	token := loadToken()
 	if token == "" {
-		fmt.Println("❌ Error: No token found. Please login first.")
-		return
+		return errNotLoggedIn
 	}
 
-	// Parse ID and create client
-	var id uint
-	fmt.Sscanf(*noteID, "%d", &id)
-	client := &api.Client{Token: token}
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
 
 	if err := client.DeleteNote(id); err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+		return err
 	}
 
-	fmt.Println("✅ Note deleted successfully")
+	return printResult(map[string]any{"id": id, "deleted": true}, "", "✅ Note deleted successfully")
 }
 
-// handleRevoke revokes sharing for a note
-func handleRevoke(args []string) {
+// handleRevoke revokes sharing for a note, or only one of its links with -link
+func handleRevoke(args []string) error {
 	fs := flag.NewFlagSet("revoke", flag.ContinueOnError)
 	noteID := fs.String("id", "", "Note ID to revoke sharing")
-	fs.Parse(args)
+	linkID := fs.String("link", "", "Revoke only this share link")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
 
 	if *noteID == "" {
-		fmt.Println("❌ Error: Please provide -id <note_id>")
-		fmt.Println("   Usage: secure-notes revoke -id 123")
-		return
+		return usageError("Please provide -id <note_id>", "revoke -id 123 [-link 4]")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
 	}
 
 	token := loadToken()
 	if token == "" {
-		fmt.Println("❌ Error: No token found. Please login first.")
-		return
+		return errNotLoggedIn
 	}
 
-	// Parse ID and create client
-	var id uint
-	fmt.Sscanf(*noteID, "%d", &id)
-	client := &api.Client{Token: token}
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	if *linkID != "" {
+		link, err := parseID(*linkID, "-link")
+		if err != nil {
+			return err
+		}
+		if err := client.DeleteShareLink(id, link); err != nil {
+			return err
+		}
+		return printResult(map[string]any{"id": id, "link_id": link, "revoked": true}, "", "✅ Share link %d revoked", link)
+	}
 
 	if err := client.RevokeShare(id); err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+		return err
 	}
 
-	fmt.Println("✅ Sharing revoked successfully")
+	return printResult(map[string]any{"id": id, "revoked": true}, "", "✅ Sharing revoked successfully")
 }
 
-// handleLogin saves JWT token to file
-func handleLogin(args []string) {
+// handleLogin logs in with a username and password and saves the session.
+// With -token an existing JWT is saved instead.
+func handleLogin(args []string) error {
 	fs := flag.NewFlagSet("login", flag.ContinueOnError)
-	token := fs.String("token", "", "JWT token")
-	fs.Parse(args)
+	username := fs.String("u", "", "Username")
+	otp := fs.String("otp", "", "Two-factor code from the authenticator app, or a recovery code")
+	token := fs.String("token", "", "JWT token (instead of -u)")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *token != "" {
+		if err := saveSession(&session{Server: active.ServerURL(), Token: *token}); err != nil {
+			return err
+		}
+		return printResult(map[string]any{"token_saved": true}, "", "✅ Token saved")
+	}
 
-	if *token == "" {
-		fmt.Println("❌ Error: Please provide -token <jwt_token>")
-		fmt.Println("   Usage: secure-notes login -token eyJhbGc...")
-		return
+	if *username == "" {
+		*username = active.Username
+	}
+	if *username == "" {
+		return usageError("Please provide -u <username>", "login -u alice [-otp 123456]")
 	}
 
-	// Save token to .cli_token file
-	tokenFile := ".cli_token"
-	if err := os.WriteFile(tokenFile, []byte(*token), 0600); err != nil {
-		fmt.Printf("❌ Error saving token: %v\n", err)
-		return
+	password, err := readPassword("Password: ")
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("✅ Token saved to .cli_token")
+	client, err := newClient("")
+	if err != nil {
+		return err
+	}
+	_, err = client.LoginWithOTP(*username, password, *otp)
+	if errors.Is(err, api.ErrMFARequired) {
+		code, readErr := readLine("Two-factor code (or a recovery code): ")
+		if readErr != nil {
+			return readErr
+		}
+		_, err = client.CompleteMFALogin(strings.TrimSpace(code))
+	}
+	if err != nil {
+		return err
+	}
+
+	sess := &session{Username: *username, Server: client.BaseURL, Token: client.Token, RefreshToken: client.RefreshToken}
+	if err := saveSession(sess); err != nil {
+		return err
+	}
+
+	// Unlock the E2EE keypair now, creating it on the first login like the GUI does
+	_, created, err := account.LoadOrCreateDHKey(client, *username, password)
+	if err != nil {
+		status("⚠️  Logged in, but the E2EE keystore is not ready: %v", err)
+	} else if created {
+		status("🔑 New E2EE keypair created and its public key registered")
+	}
+
+	result := map[string]any{"username": *username, "keypair_created": created, "keystore_ready": err == nil}
+	return printResult(result, *username, "✅ Logged in as %s", *username)
+}
+
+// handleLogout revokes the saved token on the server and removes it
+func handleLogout(args []string) error {
+	fs := flag.NewFlagSet("logout", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Revoke every token of the account (sign out everywhere)")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	token := loadToken()
+	if token == "" {
+		return &cliError{code: exitAuth, msg: "No token found. Nothing to log out."}
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+	revoke := client.Logout
+	if *all {
+		revoke = client.RevokeAllTokens
+	}
+	if err := revoke(); err != nil {
+		return err
+	}
+
+	if err := removeSession(); err != nil {
+		return fmt.Errorf("token revoked but the saved session could not be removed: %w", err)
+	}
+
+	result := map[string]any{"logged_out": true, "all_sessions": *all}
+	if *all {
+		return printResult(result, "", "✅ Signed out of every session")
+	}
+	return printResult(result, "", "✅ Logged out, token revoked")
 }
 
 // handleRegister registers a new user
-func handleRegister(args []string) {
+func handleRegister(args []string) error {
 	fs := flag.NewFlagSet("register", flag.ContinueOnError)
 	username := fs.String("u", "", "Username")
 	password := fs.String("p", "", "Password")
-	fs.Parse(args)
-
-	if *username == "" || *password == "" {
-		fmt.Println("❌ Error: Please provide -u <username> -p <password>")
-		fmt.Println("   Usage: secure-notes register -u alice -p pass123")
-		return
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
 	}
 
-	// Call API
-	regReq := api.RegisterRequest{
-		Username: *username,
-		Password: *password,
+	if *username == "" || *password == "" {
+		return usageError("Please provide -u <username> -p <password>", "register -u alice -p pass123")
 	}
 
-	jsonData, err := json.Marshal(regReq)
+	client, err := newClient("")
 	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+		return err
 	}
 
-	resp, err := http.Post(api.BaseURL+"/auth/register", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+	// Call API
+	if err := client.Register(*username, *password); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
 
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
-		fmt.Println("✅ Registration successful!")
-		fmt.Println("   Now use: secure-notes login -token <your_jwt_token>")
-	} else {
-		fmt.Printf("❌ Error: %s\n", string(body))
-	}
+	return printResult(map[string]any{"username": *username, "registered": true}, *username,
+		"✅ Registration successful!\n   Now use: secure-notes login -u %s", *username)
 }
 
 // handleUpload uploads and encrypts a note
-func handleUpload(args []string) {
+func handleUpload(args []string) error {
 	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
 	title := fs.String("t", "", "Note title")
 	filePath := fs.String("c", "", "File path or content")
-	fs.Parse(args)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
 
 	if *title == "" || *filePath == "" {
-		fmt.Println("❌ Error: Please provide -t <title> -c <content>")
-		fmt.Println("   Usage: secure-notes upload -t \"My Note\" -c \"/path/to/file.txt\"")
-		return
+		return usageError("Please provide -t <title> -c <content>", "upload -t \"My Note\" -c \"/path/to/file.txt\"")
 	}
 
 	token := loadToken()
 	if token == "" {
-		fmt.Println("❌ Error: No token found. Please login first.")
-		return
+		return errNotLoggedIn
 	}
 
 	// Read file content or treat as direct content
@@ -243,8 +474,7 @@ func handleUpload(args []string) {
 		// File exists, read it
 		data, err := os.ReadFile(*filePath)
 		if err != nil {
-			fmt.Printf("❌ Error reading file: %v\n", err)
-			return
+			return fmt.Errorf("reading file: %w", err)
 		}
 		content = string(data)
 	} else {
@@ -255,47 +485,583 @@ func handleUpload(args []string) {
 	// Generate encryption key
 	key, err := crypto.GenerateKey()
 	if err != nil {
-		fmt.Printf("❌ Error generating key: %v\n", err)
-		return
+		return fmt.Errorf("generating key: %w", err)
 	}
 	// Encrypt content with DEK
 	encryptedContent, iv, err := crypto.EncryptAES(content, key)
 	if err != nil {
-		fmt.Printf("❌ Error encrypting: %v\n", err)
-		return
+		return fmt.Errorf("encrypting: %w", err)
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
 	}
 
 	// Derive KEK from password
-	fmt.Print("Enter your password to encrypt key: ")
-	var password string
-	fmt.Scanln(&password)
-	kek := crypto.DeriveKeyFromPassword(password, nil)
+	password, err := readPassword("Enter your password to encrypt key: ")
+	if err != nil {
+		return err
+	}
+	keys, params, err := unlockKeys(client, password)
+	if err != nil {
+		return err
+	}
+	kek, err := keys.KEK(params.Descriptor())
+	if err != nil {
+		return err
+	}
 
 	// Encrypt DEK with KEK
 	keyBase64 := base64.StdEncoding.EncodeToString(key)
 	encryptedKey, ivKey, err := crypto.EncryptAES(keyBase64, kek)
 	if err != nil {
-		fmt.Printf("❌ Error encrypting key: %v\n", err)
-		return
+		return fmt.Errorf("encrypting key: %w", err)
 	}
 
-	// Create client and upload
-	client := &api.Client{Token: token}
-	if err := client.CreateNote(*title, encryptedContent, iv, encryptedKey, ivKey); err != nil {
-		fmt.Printf("❌ Error uploading: %v\n", err)
-		return
+	// Upload
+	if err := client.CreateNote(*title, encryptedContent, iv, encryptedKey, ivKey, params.Descriptor()); err != nil {
+		return fmt.Errorf("uploading: %w", err)
+	}
+
+	return printResult(map[string]any{"title": *title, "uploaded": true}, "", "✅ Note uploaded and encrypted successfully!")
+}
+
+// handleEdit decrypts a note, lets the user edit it, then re-encrypts and uploads it
+func handleEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID to edit")
+	filePath := fs.String("c", "", "Replace content with this file or text instead of opening an editor")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" {
+		return usageError("Please provide -id <note_id>", "edit -id 123")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	// Derive KEK from password
+	password, err := readPassword("Enter your password to decrypt key: ")
+	if err != nil {
+		return err
+	}
+	note, dek, err := unlockNote(client, id, password)
+	if err != nil {
+		return err
+	}
+
+	content, err := crypto.DecryptAES(note.EncryptedContent, note.IV, dek)
+	if err != nil {
+		return fmt.Errorf("decrypting: %w", err)
+	}
+
+	var newContent string
+	if *filePath != "" {
+		// Read file content or treat as direct content
+		if data, err := os.ReadFile(*filePath); err == nil {
+			newContent = string(data)
+		} else {
+			newContent = *filePath
+		}
+	} else {
+		newContent, err = editInEditor(content)
+		if err != nil {
+			return fmt.Errorf("editing: %w", err)
+		}
+	}
+
+	if newContent == content {
+		return printResult(map[string]any{"id": id, "version": note.Version, "changed": false}, "",
+			"ℹ️  No changes, nothing uploaded")
+	}
+
+	// Re-encrypt with the same DEK (fresh IV), the wrapped key stays unchanged
+	encryptedContent, iv, err := crypto.EncryptAES(newContent, dek)
+	if err != nil {
+		return fmt.Errorf("encrypting: %w", err)
+	}
+
+	updated, err := client.UpdateNote(id, encryptedContent, iv, "", "", note.Version)
+	if err != nil {
+		if err == api.ErrNoteConflict {
+			return errors.New("The note was changed elsewhere while you were editing. Run edit again.")
+		}
+		return fmt.Errorf("uploading: %w", err)
+	}
+
+	return printResult(map[string]any{"id": id, "version": updated.Version, "changed": true}, fmt.Sprint(updated.Version),
+		"✅ Note updated successfully (version %d)", updated.Version)
+}
+
+// handleHistory lists previous versions of a note
+func handleHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" {
+		return usageError("Please provide -id <note_id>", "history -id 123")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("✅ Note uploaded and encrypted successfully!")
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	note, err := client.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := client.ListNoteRevisions(id)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case outputJSON:
+		if revisions == nil {
+			revisions = []api.NoteRevision{}
+		}
+		return printJSON(map[string]any{"id": id, "title": note.Title, "version": note.Version, "revisions": revisions})
+	case outputPlain:
+		for _, revision := range revisions {
+			fmt.Printf("%d\t%d\t%s\t%d\n", revision.ID, revision.Version, revision.SavedAt.Format(time.RFC3339), revision.Size)
+		}
+		return nil
+	}
+
+	fmt.Printf("🕘 History of '%s' (current version %d)\n", note.Title, note.Version)
+	if len(revisions) == 0 {
+		fmt.Println("No previous versions.")
+		return nil
+	}
+
+	fmt.Println("----------------------------------------")
+	for _, revision := range revisions {
+		fmt.Printf("Revision %d | v%d | %s | %d bytes\n",
+			revision.ID, revision.Version, revision.SavedAt.Local().Format("2006-01-02 15:04"), revision.Size)
+	}
+	fmt.Println("----------------------------------------")
+	fmt.Println("Use: secure-notes diff -id <note_id> -rev <revision_id>")
+	return nil
 }
 
-// loadToken loads JWT token from file or environment variable
+// handleDiff decrypts a previous version and the current content and prints a line diff
+func handleDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID")
+	revisionID := fs.String("rev", "", "Revision ID to compare with the current content")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" || *revisionID == "" {
+		return usageError("Please provide -id <note_id> and -rev <revision_id>", "diff -id 123 -rev 4")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+	revID, err := parseID(*revisionID, "-rev")
+	if err != nil {
+		return err
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword("Enter your password to decrypt key: ")
+	if err != nil {
+		return err
+	}
+	keys, _, err := unlockKeys(client, password)
+	if err != nil {
+		return err
+	}
+
+	note, err := client.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	revision, err := client.GetNoteRevision(id, revID)
+	if err != nil {
+		return err
+	}
+
+	// The current note and the revision each carry their own wrapped DEK and KDF descriptor
+	kek, err := keys.KEK(note.KeyKDF)
+	if err != nil {
+		return err
+	}
+	dek, err := crypto.UnwrapKey(note.EncryptedKey, note.EncryptedKeyIV, kek)
+	if err != nil {
+		return errWrongPassword
+	}
+	current, err := crypto.DecryptAES(note.EncryptedContent, note.IV, dek)
+	if err != nil {
+		return fmt.Errorf("decrypting: %w", err)
+	}
+
+	revisionKEK, err := keys.KEK(revision.KeyKDF)
+	if err != nil {
+		return err
+	}
+	revisionDEK, err := crypto.UnwrapKey(revision.EncryptedKey, revision.EncryptedKeyIV, revisionKEK)
+	if err != nil {
+		return errors.New("Failed to unwrap the key of this version")
+	}
+	previous, err := crypto.DecryptAES(revision.EncryptedContent, revision.IV, revisionDEK)
+	if err != nil {
+		return fmt.Errorf("decrypting: %w", err)
+	}
+
+	lines := diff.Lines(previous, current)
+	changed := diff.HasChanges(lines)
+	if outputFormat == outputJSON {
+		result := map[string]any{
+			"id":           id,
+			"revision_id":  revID,
+			"from_version": revision.Version,
+			"to_version":   note.Version,
+			"changed":      changed,
+			"diff":         diff.Format(lines),
+		}
+		return printJSON(result)
+	}
+
+	if !changed {
+		status("ℹ️  Version %d is identical to the current content", revision.Version)
+		return nil
+	}
+
+	if outputFormat == outputTable {
+		fmt.Printf("--- version %d\n+++ version %d (current)\n", revision.Version, note.Version)
+	}
+	fmt.Print(diff.Format(lines))
+	return nil
+}
+
+// handleRestore makes a previous version the current content of a note
+func handleRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID")
+	revisionID := fs.String("rev", "", "Revision ID to restore")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" || *revisionID == "" {
+		return usageError("Please provide -id <note_id> and -rev <revision_id>", "restore -id 123 -rev 4")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+	revID, err := parseID(*revisionID, "-rev")
+	if err != nil {
+		return err
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	note, err := client.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	updated, err := client.RestoreNoteRevision(id, revID, note.Version)
+	if err != nil {
+		if err == api.ErrNoteConflict {
+			return errors.New("The note was changed elsewhere. Run restore again.")
+		}
+		return err
+	}
+
+	return printResult(map[string]any{"id": id, "revision_id": revID, "version": updated.Version}, fmt.Sprint(updated.Version),
+		"✅ Revision %d restored (note is now at version %d)", revID, updated.Version)
+}
+
+// handlePasswd changes the account password and re-wraps every note key
+func handlePasswd(args []string) error {
+	fs := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	username := fs.String("u", "", "Username (used to find the local keystore)")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *username == "" {
+		return usageError("Please provide -u <username>", "passwd -u alice")
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	oldPassword, err := readPassword("Current password: ")
+	if err != nil {
+		return err
+	}
+	newPassword, err := readPassword("New password: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := readPassword("Confirm new password: ")
+	if err != nil {
+		return err
+	}
+
+	if len(newPassword) < 6 {
+		return usageError("New password must be at least 6 characters", "")
+	}
+	if newPassword != confirm {
+		return usageError("New passwords do not match", "")
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+	status("⏳ Re-encrypting note keys and keystore...")
+	params, err := client.GetKDFParams()
+	if err != nil {
+		return err
+	}
+
+	if _, err := account.ChangePassword(client, *username, oldPassword, newPassword, params); err != nil {
+		return err
+	}
+
+	return printResult(map[string]any{"username": *username, "password_changed": true}, "", "✅ Password changed successfully")
+}
+
+// handleKDF shows or changes the KDF used to derive the KEK from the password
+func handleKDF(args []string) error {
+	fs := flag.NewFlagSet("kdf", flag.ContinueOnError)
+	algorithm := fs.String("alg", "", "KDF algorithm: argon2id or pbkdf2-sha256")
+	target := fs.Duration("target", 0, "Argon2id: calibrate for this unlock time on this machine (e.g. 500ms)")
+	timeCost := fs.Int("time", crypto.DefaultArgon2idTime, "Argon2id time cost (passes)")
+	memory := fs.Uint("memory", crypto.DefaultArgon2idMemory, "Argon2id memory cost in KiB")
+	threads := fs.Uint("threads", crypto.DefaultArgon2idThreads, "Argon2id parallelism")
+	iterations := fs.Int("iterations", crypto.DefaultPBKDF2Iterations, "PBKDF2 iterations")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+	current, err := client.GetKDFParams()
+	if err != nil {
+		return err
+	}
+
+	if *algorithm == "" {
+		if current.IsLegacy() {
+			return printResult(map[string]any{"kdf": current, "legacy": true}, "legacy", "🔑 KDF: legacy PBKDF2 with a fixed salt")
+		}
+		return printResult(map[string]any{"kdf": current, "descriptor": current.Descriptor()}, current.Descriptor(),
+			"🔑 KDF: %s", current.Descriptor())
+	}
+
+	var params crypto.KDFParams
+	switch *algorithm {
+	case crypto.KDFArgon2id:
+		if *target > 0 {
+			status("⏳ Calibrating Argon2id for %v...", *target)
+			params, err = crypto.CalibrateArgon2id(*target)
+		} else if *threads > crypto.MaxArgon2idThreads || *memory > crypto.MaxArgon2idMemory || *timeCost > crypto.MaxArgon2idTime {
+			err = usageError(fmt.Sprintf("Argon2id allows at most -time %d, -memory %d and -threads %d",
+				crypto.MaxArgon2idTime, crypto.MaxArgon2idMemory, crypto.MaxArgon2idThreads), "")
+		} else {
+			params, err = crypto.NewArgon2idParams(*timeCost, uint32(*memory), uint8(*threads))
+		}
+	case crypto.KDFPBKDF2SHA256:
+		if *iterations > crypto.MaxPBKDF2Iterations {
+			err = usageError(fmt.Sprintf("PBKDF2 allows at most %d iterations", crypto.MaxPBKDF2Iterations), "")
+			break
+		}
+		params, err = crypto.NewKDFParams()
+		params.Iterations = *iterations
+	default:
+		err = usageError(fmt.Sprintf("unsupported KDF algorithm %q", *algorithm), "kdf -alg argon2id|pbkdf2-sha256")
+	}
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword("Enter your password: ")
+	if err != nil {
+		return err
+	}
+
+	status("⏳ Re-encrypting note keys...")
+	if _, err := account.MigrateKDF(client, password, current, params); err != nil {
+		return err
+	}
+
+	return printResult(map[string]any{"kdf": params, "descriptor": params.Descriptor()}, params.Descriptor(),
+		"✅ KDF changed to %s", params.Descriptor())
+}
+
+// editInEditor writes content to a private temp file, opens it in the user's
+// editor and returns the edited content
+func editInEditor(content string) (string, error) {
+	tmpFile, err := os.CreateTemp("", "secure-note-*.txt")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmpFile.Name()
+	// The plaintext must not outlive the edit session
+	defer os.Remove(tmpPath)
+
+	if _, err := tmpFile.WriteString(content); err != nil {
+		tmpFile.Close()
+		return "", err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+
+	// EDITOR may carry arguments, e.g. "code --wait"
+	editorArgs := strings.Fields(editor)
+	cmd := exec.Command(editorArgs[0], append(editorArgs[1:], tmpPath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// unlockKeys derives the KEK from the password using the user's KDF parameters
+// and returns a cache for unwrapping keys by their KDF descriptor, together with
+// the parameters new keys should be wrapped with.
+// Accounts still on the legacy fixed salt are migrated on the way.
+func unlockKeys(client *api.Client, password string) (*crypto.KEKCache, crypto.KDFParams, error) {
+	params, err := client.GetKDFParams()
+	if err != nil {
+		return nil, params, err
+	}
+
+	if params.IsLegacy() {
+		status("⏳ Moving your note keys to a per-user salt...")
+	}
+
+	kek, params, err := account.UnlockKEK(client, password, params)
+	if err != nil {
+		return nil, params, err
+	}
+
+	keys := crypto.NewKEKCache(password, params)
+	keys.Remember(params, kek)
+	return keys, params, nil
+}
+
+// loadToken loads JWT token from the saved session or environment variable
 func loadToken() string {
-	// Try to read from .cli_token file first
-	if data, err := os.ReadFile(".cli_token"); err == nil {
-		return string(bytes.TrimSpace(data))
+	// Try the session saved by login first
+	if s := loadSession(); s != nil {
+		return s.Token
 	}
 
 	// Fall back to environment variable
 	return os.Getenv("CLI_TOKEN")
 }
+
+// newClient creates an API client for token on the server of the active
+// profile. A custom CA bundle and a client certificate can be set with
+// SECURE_NOTES_CA_FILE, SECURE_NOTES_CLIENT_CERT and SECURE_NOTES_CLIENT_KEY.
+func newClient(token string) (*api.Client, error) {
+	client := &api.Client{Token: token}
+	if err := active.Apply(client); err != nil {
+		return nil, err
+	}
+
+	// Renew an expired token of the saved session and keep the new tokens
+	if s := loadSession(); s != nil && token != "" && s.Token == token {
+		client.RefreshToken = s.RefreshToken
+		client.OnTokenRefresh = func(token, refreshToken string) {
+			s.Token, s.RefreshToken = token, refreshToken
+			if err := saveSession(s); err != nil {
+				status("⚠️  Could not save the renewed session: %v", err)
+			}
+		}
+	}
+	return client, nil
+}
+
+// parseID parses the value of an ID flag such as -id
+func parseID(value, flagName string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil || id == 0 {
+		return 0, usageError(fmt.Sprintf("%s must be a positive number, got %q", flagName, value), "")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lab02_mahoa/client/api"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Exit codes, so scripts can tell failures apart
+const (
+	exitOK       = 0
+	exitError    = 1 // Anything not listed below
+	exitUsage    = 2 // Bad or missing arguments
+	exitAuth     = 3 // Not logged in, wrong password or two-factor code, access denied
+	exitNotFound = 4 // No such note, share or user
+	exitGone     = 5 // Share link expired, used up or revoked
+	exitNetwork  = 6 // Server unreachable or TLS failure
+)
+
+// errorCodes name the exit codes in JSON error objects
+var errorCodes = map[int]string{
+	exitError:    "error",
+	exitUsage:    "usage",
+	exitAuth:     "auth",
+	exitNotFound: "not_found",
+	exitGone:     "gone",
+	exitNetwork:  "network",
+}
+
+// Output formats selected with --output
+const (
+	outputTable = "table" // For people: tables, emoji and messages (default)
+	outputPlain = "plain" // Tab-separated values without headers, for shell scripts
+	outputJSON  = "json"  // One JSON document on stdout, errors as JSON on stderr
+)
+
+// outputFormat is the --output of the current run
+var outputFormat = outputTable
+
+// cliError is an error found by the CLI itself, with the exit code it maps to
+type cliError struct {
+	code int
+	msg  string
+	hint string // Shown under the error, e.g. an example command line
+}
+
+func (e *cliError) Error() string { return e.msg }
+
+var (
+	errNotLoggedIn   = &cliError{code: exitAuth, msg: "No token found. Please login first.", hint: "Use: secure-notes login -u <username>"}
+	errNoSession     = &cliError{code: exitAuth, msg: "No session found. Please login with -u first."}
+	errWrongPassword = &cliError{code: exitAuth, msg: "wrong password or corrupted key"}
+)
+
+// usageError reports bad or missing arguments; usage is an example command line
+func usageError(msg, usage string) error {
+	err := &cliError{code: exitUsage, msg: msg}
+	if usage != "" {
+		err.hint = "Usage: secure-notes " + usage
+	}
+	return err
+}
+
+// exitCode maps an error to the exit code of the CLI
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var cliErr *cliError
+	if errors.As(err, &cliErr) {
+		return cliErr.code
+	}
+	if errors.Is(err, api.ErrMFARequired) {
+		return exitAuth
+	}
+
+	switch api.StatusCode(err) {
+	case 401, 403:
+		return exitAuth
+	case 404:
+		return exitNotFound
+	case 410:
+		return exitGone
+	}
+
+	// *url.Error from the HTTP client is a net.Error too
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return exitNetwork
+	}
+	return exitError
+}
+
+// errorObject is what a failed command writes to stderr with --output json
+type errorObject struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Status  int    `json:"status,omitempty"` // HTTP status, if the server refused the request
+	Hint    string `json:"hint,omitempty"`
+	Exit    int    `json:"exit_code"`
+}
+
+// reportError writes err to stderr and returns the exit code for it
+func reportError(err error) int {
+	code := exitCode(err)
+
+	var hint string
+	var cliErr *cliError
+	if errors.As(err, &cliErr) {
+		hint = cliErr.hint
+	}
+
+	if outputFormat == outputJSON {
+		obj := errorObject{
+			Code:    errorCodes[code],
+			Message: strings.TrimSpace(err.Error()),
+			Status:  api.StatusCode(err),
+			Hint:    hint,
+			Exit:    code,
+		}
+		enc := json.NewEncoder(os.Stderr)
+		enc.Encode(struct {
+			Error errorObject `json:"error"`
+		}{obj})
+		return code
+	}
+
+	fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+	if hint != "" {
+		fmt.Fprintf(os.Stderr, "   %s\n", hint)
+	}
+	return code
+}
+
+// parseGlobalFlags takes the options given before the command off args:
+// --output json|table|plain and --profile <name>
+func parseGlobalFlags(args []string) ([]string, error) {
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(args[0], "=")
+		if !strings.HasPrefix(name, "-") {
+			break
+		}
+		name = "-" + strings.TrimLeft(name, "-")
+		if name != "-output" && name != "-profile" {
+			break
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				return nil, usageError(fmt.Sprintf("-%s needs a value", name), "")
+			}
+			value, args = args[1], args[1:]
+		}
+		args = args[1:]
+
+		if name == "-profile" {
+			profileName = value
+			continue
+		}
+		switch value {
+		case outputTable, outputPlain, outputJSON:
+			outputFormat = value
+		default:
+			return nil, usageError(fmt.Sprintf("unknown output format %q, use json, table or plain", value), "")
+		}
+	}
+	return args, nil
+}
+
+// messages is where progress and confirmation messages go. With plain or JSON
+// output they go to stderr so stdout only carries the result.
+func messages() io.Writer {
+	if outputFormat == outputTable {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// status prints a progress or confirmation message
+func status(format string, args ...any) {
+	fmt.Fprintf(messages(), format+"\n", args...)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printResult writes the result of a command: v with --output json, plain
+// with --output plain and the table message otherwise. An empty plain prints
+// nothing.
+func printResult(v any, plain string, table string, args ...any) error {
+	switch outputFormat {
+	case outputJSON:
+		return printJSON(v)
+	case outputPlain:
+		if plain != "" {
+			fmt.Println(plain)
+		}
+	default:
+		fmt.Printf(table+"\n", args...)
+	}
+	return nil
+}
+
+// printRows writes rows as an aligned table with a header, or as tab-separated
+// lines without one with --output plain
+func printRows(header []string, rows [][]string) {
+	if outputFormat == outputPlain {
+		for _, row := range rows {
+			fmt.Println(strings.Join(row, "\t"))
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	underline := make([]string, len(header))
+	for i, name := range header {
+		underline[i] = strings.Repeat("-", len(name))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	fmt.Fprintln(w, strings.Join(underline, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
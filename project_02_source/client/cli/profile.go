@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"lab02_mahoa/client/profile"
+	"os"
+)
+
+var (
+	// profileName is the --profile of the current run
+	profileName string
+	// active is the profile commands connect with; the zero Profile is the local server
+	active profile.Profile
+)
+
+// loadProfile returns the profile called name, or the default profile
+func loadProfile(name string) (profile.Profile, error) {
+	store, err := profile.Load()
+	if err != nil {
+		return profile.Profile{}, err
+	}
+	p, err := store.Pick(name)
+	if err != nil {
+		return p, usageError(err.Error(), "profile list")
+	}
+	return p, nil
+}
+
+// handleProfile lists, adds, picks and removes server profiles
+func handleProfile(args []string) error {
+	if len(args) == 0 {
+		return usageError("Please provide list, add, use or remove", "profile add -name work -server https://notes.example.com/api")
+	}
+
+	store, err := profile.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("profile "+args[0], flag.ContinueOnError)
+	name := fs.String("name", "", "Profile name")
+
+	switch args[0] {
+	case "list":
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError(err.Error(), "")
+		}
+		return printProfiles(store)
+
+	case "add":
+		server := fs.String("server", "", "URL of the API, e.g. https://notes.example.com/api")
+		username := fs.String("u", "", "Username used on this server by default")
+		caFile := fs.String("ca", "", "PEM bundle of CAs to trust for this server")
+		makeDefault := fs.Bool("default", false, "Use this profile when --profile is not given")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError(err.Error(), "")
+		}
+		if *name == "" || *server == "" {
+			return usageError("Please provide -name <name> -server <url>", "profile add -name work -server https://notes.example.com/api [-u alice] [-ca ca.pem]")
+		}
+
+		p := profile.Profile{Name: *name, Server: *server, Username: *username, CAFile: *caFile}
+		if err := store.Set(p); err != nil {
+			return usageError(err.Error(), "")
+		}
+		if *makeDefault {
+			store.Default = *name
+		}
+		if err := store.Save(); err != nil {
+			return err
+		}
+		p, _ = store.Get(*name)
+		return printResult(p, p.Name, "✅ Profile '%s' saved (%s)", p.Name, p.Server)
+
+	case "use":
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError(err.Error(), "")
+		}
+		if *name != "" {
+			if _, ok := store.Get(*name); !ok {
+				return usageError(fmt.Sprintf("no profile named %q", *name), "profile list")
+			}
+		}
+		store.Default = *name
+		if err := store.Save(); err != nil {
+			return err
+		}
+		if *name == "" {
+			return printResult(map[string]any{"default": ""}, "", "✅ No default profile, using the local server")
+		}
+		return printResult(map[string]any{"default": *name}, *name, "✅ Now using profile '%s'", *name)
+
+	case "remove", "rm":
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError(err.Error(), "")
+		}
+		if *name == "" {
+			return usageError("Please provide -name <name>", "profile remove -name work")
+		}
+		if !store.Remove(*name) {
+			return &cliError{code: exitNotFound, msg: fmt.Sprintf("no profile named %q", *name)}
+		}
+		if err := store.Save(); err != nil {
+			return err
+		}
+		// The tokens of that server are of no use anymore
+		if path, err := profileSessionPath(*name); err == nil {
+			os.Remove(path)
+		}
+		return printResult(map[string]any{"name": *name, "removed": true}, "", "✅ Profile '%s' removed", *name)
+	}
+
+	return usageError(fmt.Sprintf("Unknown profile command: %s", args[0]), "profile list|add|use|remove")
+}
+
+// printProfiles lists the saved profiles, marking the default one
+func printProfiles(store *profile.Store) error {
+	if outputFormat == outputJSON {
+		profiles := store.Profiles
+		if profiles == nil {
+			profiles = []profile.Profile{}
+		}
+		return printJSON(map[string]any{"default": store.Default, "profiles": profiles})
+	}
+
+	if len(store.Profiles) == 0 {
+		status("📭 No profiles, commands use the local server. Add one with: secure-notes profile add -name <name> -server <url>")
+		return nil
+	}
+
+	rows := make([][]string, 0, len(store.Profiles))
+	for _, p := range store.Profiles {
+		mark := ""
+		if p.Name == store.Default {
+			mark = "*"
+		}
+		rows = append(rows, []string{mark, p.Name, p.Server, p.Username, p.CAFile})
+	}
+	printRows([]string{"Default", "Name", "Server", "Username", "CA Bundle"}, rows)
+	return nil
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// session is what `login` remembers between CLI runs
+type session struct {
+	Username     string `json:"username"`
+	Server       string `json:"server,omitempty"` // API root the tokens belong to
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// sessionPath returns the session file in the user's config directory, next
+// to the keystores (~/.lab02_mahoa), so it does not depend on the working directory.
+// Each profile has its own session.
+func sessionPath() (string, error) {
+	return profileSessionPath(active.Name)
+}
+
+// profileSessionPath returns the session file of the profile called name
+func profileSessionPath(name string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find home directory: %w", err)
+	}
+	file := "session.json"
+	if name != "" {
+		file = "session-" + name + ".json"
+	}
+	return filepath.Join(homeDir, ".lab02_mahoa", "cli", file), nil
+}
+
+// loadSession reads the saved session. It returns nil if there is none, or if
+// it was made for another server (e.g. SECURE_NOTES_SERVER changed).
+func loadSession() *session {
+	path, err := sessionPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil || s.Token == "" {
+		return nil
+	}
+	if s.Server != "" && s.Server != active.ServerURL() {
+		return nil
+	}
+	return &s
+}
+
+// saveSession writes the session, readable by the current user only
+func saveSession(s *session) error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write a temporary file and rename it so a crash never leaves half a session
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// removeSession deletes the saved session
+func removeSession() error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readPassword prompts for a password without echoing it. When stdin is not a
+// terminal (e.g. piped from a script) a line is read as is.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(password), nil
+	}
+
+	return readLine("")
+}
+
+// readLine prompts for a line of input
+func readLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// stdin is shared by all prompts so buffered input is not lost between them
+var stdin = bufio.NewReader(os.Stdin)
@@ -0,0 +1,749 @@
+package cli
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"lab02_mahoa/client/account"
+	"lab02_mahoa/client/api"
+	"lab02_mahoa/client/crypto"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// handleGet decrypts a note and prints it, or writes it to a file
+func handleGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID to decrypt")
+	outPath := fs.String("o", "", "Write the content to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" {
+		return usageError("Please provide -id <note_id>", "get -id 123 [-o note.txt]")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword("Enter your password to decrypt key: ")
+	if err != nil {
+		return err
+	}
+	note, dek, err := unlockNote(client, id, password)
+	if err != nil {
+		return err
+	}
+
+	content, err := crypto.DecryptAES(note.EncryptedContent, note.IV, dek)
+	if err != nil {
+		return fmt.Errorf("decrypting: %w", err)
+	}
+
+	result := map[string]any{"id": note.ID, "title": note.Title, "version": note.Version}
+	return writeContent(result, content, *outPath)
+}
+
+// handleShare creates a share link. The note key goes in the URL fragment,
+// which browsers and the server never send over the network. A -burn link
+// gets its own copy of the note under a one-time key instead, and the
+// fragment carries that key.
+func handleShare(args []string) error {
+	fs := flag.NewFlagSet("share", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID to share")
+	hours := fs.Int("hours", 24, "Hours until the link expires")
+	protect := fs.Bool("password", false, "Ask for a password the recipient must enter")
+	maxAccess := fs.Int("max", 0, "Maximum number of times the link can be opened (0 = unlimited)")
+	burn := fs.Bool("burn", false, "Burn after reading: the link works once, then the server destroys its copy")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" {
+		return usageError("Please provide -id <note_id>", "share -id 123 [-hours 24] [-password] [-max 3 | -burn]")
+	}
+	if *burn && *maxAccess > 0 {
+		return usageError("A -burn link can only be opened once, drop -max", "share -id 123 -burn")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword("Enter your password to decrypt key: ")
+	if err != nil {
+		return err
+	}
+	note, dek, err := unlockNote(client, id, password)
+	if err != nil {
+		return err
+	}
+
+	sharePassword := ""
+	if *protect {
+		sharePassword, err = readPassword("Share password: ")
+		if err != nil {
+			return err
+		}
+	}
+
+	var shareToken string
+	linkKey := dek
+	if *burn {
+		plaintext, err := crypto.DecryptAES(note.EncryptedContent, note.IV, dek)
+		if err != nil {
+			return fmt.Errorf("decrypting: %w", err)
+		}
+		// The link never gets the note key: its copy is under a key of its own
+		if linkKey, err = crypto.GenerateKey(); err != nil {
+			return err
+		}
+		encryptedContent, iv, err := crypto.EncryptAES(plaintext, linkKey)
+		if err != nil {
+			return fmt.Errorf("encrypting: %w", err)
+		}
+		shareToken, err = client.CreateBurnShare(id, *hours, sharePassword, encryptedContent, iv)
+		if err != nil {
+			return err
+		}
+	} else {
+		shareToken, err = client.CreateShareWithOptions(id, *hours, sharePassword, *maxAccess)
+		if err != nil {
+			return err
+		}
+	}
+
+	shareURL := client.ShareLink(shareToken, base64.StdEncoding.EncodeToString(linkKey))
+	result := map[string]any{
+		"note_id":            id,
+		"token":              shareToken,
+		"url":                shareURL,
+		"expires_in_hours":   *hours,
+		"max_access":         *maxAccess,
+		"password_protected": *protect,
+		"burn_after_reading": *burn,
+	}
+	if *burn {
+		status("🔥 Burn after reading: the link works once (see who read it with: secure-notes receipts)")
+	}
+	return printResult(result, shareURL, "✅ Share link created:\n%s", shareURL)
+}
+
+// handleOpen fetches a share link and decrypts it with the key in its fragment
+func handleOpen(args []string) error {
+	fs := flag.NewFlagSet("open", flag.ContinueOnError)
+	key := fs.String("key", "", "Encryption key, if the URL has no #key=... fragment")
+	outPath := fs.String("o", "", "Write the content to this file instead of stdout")
+	// Allow the URL before the flags: flag parsing stops at the first argument
+	if len(args) > 1 && !strings.HasPrefix(args[0], "-") {
+		args = append(append([]string{}, args[1:]...), args[0])
+	}
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if fs.NArg() != 1 {
+		return usageError("Please provide the share URL or token", "open 'http://host/share/abc#key=...' [-o note.txt]")
+	}
+
+	shareToken, fragmentKey := parseShareURL(fs.Arg(0))
+	if *key == "" {
+		*key = fragmentKey
+	}
+	if *key == "" {
+		return usageError("The link has no #key=... fragment, pass the key with -key", "")
+	}
+	dek, err := base64.StdEncoding.DecodeString(*key)
+	if err != nil {
+		return usageError(fmt.Sprintf("Invalid encryption key format: %v", err), "")
+	}
+
+	client, err := newClient("")
+	if err != nil {
+		return err
+	}
+	sharedNote, err := client.GetSharedNote(shareToken, "")
+	if api.StatusCode(err) == http.StatusUnauthorized {
+		// Password protected: ask and try once more
+		password, readErr := readPassword("Share password: ")
+		if readErr != nil {
+			return readErr
+		}
+		sharedNote, err = client.GetSharedNote(shareToken, password)
+	}
+	if err != nil {
+		return err
+	}
+
+	content, err := crypto.DecryptAES(sharedNote.EncryptedContent, sharedNote.IV, dek)
+	if err != nil {
+		return &cliError{code: exitAuth, msg: "Wrong key or corrupted content"}
+	}
+
+	if *outPath != "" {
+		status("📄 %s (shared by %s, expires %s)", sharedNote.Title, sharedNote.OwnerUsername, sharedNote.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	if sharedNote.Burned {
+		status("🔥 Burn after reading: the server destroyed its copy, the link will not open again")
+	}
+	result := map[string]any{
+		"title":      sharedNote.Title,
+		"owner":      sharedNote.OwnerUsername,
+		"created_at": sharedNote.CreatedAt,
+		"expires_at": sharedNote.ExpiresAt,
+		"burned":     sharedNote.Burned,
+	}
+	return writeContent(result, content, *outPath)
+}
+
+// handleSend shares a note end-to-end encrypted with another user
+func handleSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID to send")
+	recipient := fs.String("to", "", "Username of the recipient")
+	hours := fs.Int("hours", 24, "Hours until the share expires")
+	burn := fs.Bool("burn", false, "Burn after reading: destroyed on the server when the recipient opens it")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" || *recipient == "" {
+		return usageError("Please provide -id <note_id> -to <username>", "send -id 123 -to bob")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+
+	s := loadSession()
+	if s == nil || s.Username == "" {
+		return errNoSession
+	}
+
+	client, err := newClient(s.Token)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword("Enter your password to decrypt key: ")
+	if err != nil {
+		return err
+	}
+	note, dek, err := unlockNote(client, id, password)
+	if err != nil {
+		return err
+	}
+	plaintext, err := crypto.DecryptAES(note.EncryptedContent, note.IV, dek)
+	if err != nil {
+		return fmt.Errorf("decrypting: %w", err)
+	}
+
+	var privateKey *ecdh.PrivateKey
+	if *burn {
+		// A one-time key pair: the shared secret exists for this share only
+		pair, err := crypto.GenerateDHKeyPair()
+		if err != nil {
+			return err
+		}
+		privateKey = pair.PrivateKey
+	} else if privateKey, _, err = account.LoadOrCreateDHKey(client, s.Username, password); privateKey == nil {
+		return err
+	}
+
+	recipientKeyBase64, err := client.GetUserPublicKey(*recipient)
+	if err != nil {
+		return fmt.Errorf("User '%s' has no E2EE key yet, they need to log in first (%w)", *recipient, err)
+	}
+	recipientKey, err := crypto.PublicKeyFromBase64(recipientKeyBase64)
+	if err != nil {
+		return fmt.Errorf("Invalid recipient public key: %w", err)
+	}
+
+	sharedSecret, err := crypto.ComputeSharedSecret(privateKey, recipientKey)
+	if err != nil {
+		return err
+	}
+	defer clear(sharedSecret)
+
+	encryptedContent, contentIV, err := crypto.EncryptWithSharedSecret(plaintext, sharedSecret)
+	if err != nil {
+		return fmt.Errorf("encrypting: %w", err)
+	}
+
+	senderKeyBase64 := crypto.PublicKeyToBase64(privateKey.PublicKey())
+	shareID, err := client.CreateE2EEShare(id, *recipient, senderKeyBase64, encryptedContent, contentIV, *hours, *burn)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]any{"share_id": shareID, "note_id": id, "to": *recipient, "expires_in_hours": *hours, "burn_after_reading": *burn}
+	return printResult(result, fmt.Sprint(shareID), "✅ Sent to %s (E2EE share ID: %d)", *recipient, shareID)
+}
+
+// inboxEntry is an E2EE share in `inbox` JSON output
+type inboxEntry struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	From      string    `json:"from"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Burn      bool      `json:"burn_after_reading"`
+}
+
+// handleInbox lists the E2EE shares sent to the user
+func handleInbox() error {
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+	shares, err := client.ListE2EEShares()
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == outputJSON {
+		entries := make([]inboxEntry, 0, len(shares))
+		for _, share := range shares {
+			entries = append(entries, inboxEntry{ID: share.ID, Title: share.NoteTitle, From: share.SenderUsername, ExpiresAt: share.ExpiresAt, Burn: share.BurnAfterReading})
+		}
+		return printJSON(entries)
+	}
+
+	if len(shares) == 0 {
+		status("📭 No shares received")
+		return nil
+	}
+
+	rows := make([][]string, 0, len(shares))
+	for _, share := range shares {
+		expires := share.ExpiresAt.Format("2006-01-02 15:04")
+		if outputFormat == outputPlain {
+			expires = share.ExpiresAt.Format(time.RFC3339)
+		}
+		burn := ""
+		if share.BurnAfterReading {
+			burn = "🔥"
+		}
+		rows = append(rows, []string{fmt.Sprint(share.ID), share.NoteTitle, share.SenderUsername, expires, burn})
+	}
+	printRows([]string{"ID", "Title", "From", "Expires At", "Burn"}, rows)
+	status("\n✅ Total: %d shares (read one with: secure-notes receive -id <id>)", len(shares))
+	return nil
+}
+
+// handleReceive decrypts an E2EE share sent to the user
+func handleReceive(args []string) error {
+	fs := flag.NewFlagSet("receive", flag.ContinueOnError)
+	shareID := fs.String("id", "", "E2EE share ID (see inbox)")
+	outPath := fs.String("o", "", "Write the content to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *shareID == "" {
+		return usageError("Please provide -id <share_id>", "receive -id 7 [-o note.txt]")
+	}
+	id, err := parseID(*shareID, "-id")
+	if err != nil {
+		return err
+	}
+
+	s := loadSession()
+	if s == nil || s.Username == "" {
+		return errNoSession
+	}
+
+	client, err := newClient(s.Token)
+	if err != nil {
+		return err
+	}
+
+	// Unlock the key first: opening a burn-after-reading share destroys it
+	password, err := readPassword("Enter your password to unlock your E2EE key: ")
+	if err != nil {
+		return err
+	}
+	privateKey, err := crypto.LoadDHKeyPair(s.Username, password)
+	if err != nil {
+		return err
+	}
+	if privateKey == nil {
+		return errors.New("No E2EE key on this machine for " + s.Username)
+	}
+
+	share, err := client.GetE2EEShare(id)
+	if err != nil {
+		return err
+	}
+
+	senderKey, err := crypto.PublicKeyFromBase64(share.SenderPublicKey)
+	if err != nil {
+		return fmt.Errorf("Invalid sender public key: %w", err)
+	}
+	sharedSecret, err := crypto.ComputeSharedSecret(privateKey, senderKey)
+	if err != nil {
+		return err
+	}
+	defer clear(sharedSecret)
+
+	content, err := crypto.DecryptWithSharedSecret(share.EncryptedContent, share.ContentIV, sharedSecret)
+	if err != nil {
+		return fmt.Errorf("decrypting: %w", err)
+	}
+
+	if *outPath != "" {
+		status("📄 %s (from %s)", share.NoteTitle, share.SenderUsername)
+	}
+	if share.Burned {
+		status("🔥 Burn after reading: the server destroyed its copy, save the content if you need it")
+	}
+	result := map[string]any{"id": share.ID, "title": share.NoteTitle, "from": share.SenderUsername, "burned": share.Burned}
+	return writeContent(result, content, *outPath)
+}
+
+// handleReceipts lists the user's burn-after-reading shares and whether they were read
+func handleReceipts() error {
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+	receipts, err := client.ListReadReceipts()
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == outputJSON {
+		if receipts == nil {
+			receipts = []api.ReadReceipt{}
+		}
+		return printJSON(receipts)
+	}
+
+	if len(receipts) == 0 {
+		status("📭 No burn-after-reading shares (create one with: secure-notes share -id <id> -burn)")
+		return nil
+	}
+
+	layout := "2006-01-02 15:04"
+	if outputFormat == outputPlain {
+		layout = time.RFC3339
+	}
+	rows := make([][]string, 0, len(receipts))
+	for _, receipt := range receipts {
+		read := "not read yet"
+		if outputFormat == outputPlain {
+			read = ""
+		}
+		if receipt.ReadAt != nil {
+			read = receipt.ReadAt.Local().Format(layout)
+		}
+		rows = append(rows, []string{receipt.Type, fmt.Sprint(receipt.ID), receipt.NoteTitle, receipt.Recipient,
+			receipt.CreatedAt.Local().Format(layout), read})
+	}
+	printRows([]string{"Type", "ID", "Note", "To", "Created At", "Read At"}, rows)
+	return nil
+}
+
+// handleLinks lists the share links of a note with their expiry and access counts
+func handleLinks(args []string) error {
+	fs := flag.NewFlagSet("links", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" {
+		return usageError("Please provide -id <note_id>", "links -id 123")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+	links, err := client.ListShareLinks(id)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == outputJSON {
+		if links == nil {
+			links = []api.ShareLinkInfo{}
+		}
+		return printJSON(links)
+	}
+
+	if len(links) == 0 {
+		status("📭 No share links for this note (create one with: secure-notes share -id %d)", id)
+		return nil
+	}
+
+	layout := "2006-01-02 15:04"
+	if outputFormat == outputPlain {
+		layout = time.RFC3339
+	}
+	rows := make([][]string, 0, len(links))
+	for _, link := range links {
+		limit := "∞"
+		if link.MaxAccessCount > 0 {
+			limit = fmt.Sprint(link.MaxAccessCount)
+		}
+		flags := ""
+		if link.RequirePassword {
+			flags += "🔒"
+		}
+		if link.BurnAfterReading {
+			flags += "🔥"
+		}
+		linkStatus := link.Status
+		if link.ReadAt != nil {
+			linkStatus += " " + link.ReadAt.Local().Format(layout)
+		}
+		rows = append(rows, []string{fmt.Sprint(link.ID), link.ShareToken[:min(10, len(link.ShareToken))] + "...",
+			link.CreatedAt.Local().Format(layout), link.ExpiresAt.Local().Format(layout),
+			fmt.Sprintf("%d/%s", link.AccessCount, limit), flags, linkStatus})
+	}
+	printRows([]string{"ID", "Token", "Created At", "Expires At", "Opened", "Flags", "Status"}, rows)
+	status("\n✅ Total: %d links (change one with: secure-notes link -id %d -link <id> [-hours N] [-max N])", len(links), id)
+	return nil
+}
+
+// handleLink changes the expiry or the max access count of one share link
+func handleLink(args []string) error {
+	fs := flag.NewFlagSet("link", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID")
+	linkID := fs.String("link", "", "Share link ID (see: secure-notes links -id <note_id>)")
+	hours := fs.Int("hours", 0, "New expiry, in hours from now")
+	maxAccess := fs.Int("max", -1, "New maximum number of opens (0 = unlimited)")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	usage := "link -id 123 -link 4 [-hours 48] [-max 10]"
+	if *noteID == "" || *linkID == "" {
+		return usageError("Please provide -id <note_id> and -link <link_id>", usage)
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+	link, err := parseID(*linkID, "-link")
+	if err != nil {
+		return err
+	}
+
+	var update api.UpdateShareLinkRequest
+	if *hours != 0 {
+		update.DurationHours = hours
+	}
+	if *maxAccess >= 0 {
+		update.MaxAccessCount = maxAccess
+	}
+	if update.DurationHours == nil && update.MaxAccessCount == nil {
+		return usageError("Nothing to change: give -hours and/or -max", usage)
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+	updated, err := client.UpdateShareLink(id, link, update)
+	if err != nil {
+		return err
+	}
+
+	limit := "unlimited"
+	if updated.MaxAccessCount > 0 {
+		limit = fmt.Sprintf("%d/%d opens", updated.AccessCount, updated.MaxAccessCount)
+	}
+	return printResult(updated, updated.ExpiresAt.Format(time.RFC3339),
+		"✅ Share link %d updated: expires %s, %s", updated.ID, updated.ExpiresAt.Local().Format("2006-01-02 15:04"), limit)
+}
+
+// handleAudit lists the attempts to open the share links of a note, or of one link with -link
+func handleAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	noteID := fs.String("id", "", "Note ID")
+	linkID := fs.String("link", "", "Only this share link (see: secure-notes links -id <note_id>)")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error(), "")
+	}
+
+	if *noteID == "" {
+		return usageError("Please provide -id <note_id>", "audit -id 123 [-link 4]")
+	}
+	id, err := parseID(*noteID, "-id")
+	if err != nil {
+		return err
+	}
+
+	token := loadToken()
+	if token == "" {
+		return errNotLoggedIn
+	}
+
+	client, err := newClient(token)
+	if err != nil {
+		return err
+	}
+
+	var events []api.ShareAccessEvent
+	if *linkID != "" {
+		link, err := parseID(*linkID, "-link")
+		if err != nil {
+			return err
+		}
+		events, err = client.ListShareLinkAccessLog(id, link)
+		if err != nil {
+			return err
+		}
+	} else if events, err = client.ListNoteAccessLog(id); err != nil {
+		return err
+	}
+
+	if outputFormat == outputJSON {
+		if events == nil {
+			events = []api.ShareAccessEvent{}
+		}
+		return printJSON(events)
+	}
+
+	if len(events) == 0 {
+		status("📭 Nobody has tried to open these share links yet")
+		return nil
+	}
+
+	layout := "2006-01-02 15:04:05"
+	if outputFormat == outputPlain {
+		layout = time.RFC3339
+	}
+	rows := make([][]string, 0, len(events))
+	for _, event := range events {
+		outcome, userAgent := event.Outcome, event.UserAgent
+		if outputFormat == outputTable {
+			if outcome == "success" {
+				outcome = "✅ " + outcome
+			} else {
+				outcome = "❌ " + outcome
+			}
+			if runes := []rune(userAgent); len(runes) > 40 {
+				userAgent = string(runes[:37]) + "..."
+			}
+		}
+		rows = append(rows, []string{event.CreatedAt.Local().Format(layout), fmt.Sprint(event.LinkID),
+			outcome, event.IP, userAgent})
+	}
+	printRows([]string{"Time", "Link", "Outcome", "IP", "User Agent"}, rows)
+	status("\n✅ Total: %d access attempts", len(events))
+	return nil
+}
+
+// unlockNote fetches a note and unwraps its DEK with the password
+func unlockNote(client *api.Client, id uint, password string) (api.Note, []byte, error) {
+	keys, _, err := unlockKeys(client, password)
+	if err != nil {
+		return api.Note{}, nil, err
+	}
+
+	// Fetch the note after unlocking: a KDF migration re-wraps its key
+	note, err := client.GetNote(id)
+	if err != nil {
+		return api.Note{}, nil, err
+	}
+
+	kek, err := keys.KEK(note.KeyKDF)
+	if err != nil {
+		return api.Note{}, nil, err
+	}
+	dek, err := crypto.UnwrapKey(note.EncryptedKey, note.EncryptedKeyIV, kek)
+	if err != nil {
+		return api.Note{}, nil, errWrongPassword
+	}
+	return note, dek, nil
+}
+
+// parseShareURL splits a share URL (or bare token) into the token and the
+// key from its #key= fragment
+func parseShareURL(input string) (token, key string) {
+	input = strings.TrimSpace(input)
+	input, key, _ = strings.Cut(input, "#key=")
+	// Browser viewer links use /share/, API links /shares/
+	for _, prefix := range []string{"/shares/", "/share/"} {
+		if i := strings.LastIndex(input, prefix); i >= 0 {
+			input = input[i+len(prefix):]
+			break
+		}
+	}
+	return strings.Trim(input, "/"), key
+}
+
+// writeContent prints decrypted content, or writes it to path readable by the
+// user only. With --output json the content (or the path) is added to result.
+func writeContent(result map[string]any, content, path string) error {
+	if path != "" {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+		result["path"] = path
+		return printResult(result, path, "✅ Saved to %s", path)
+	}
+
+	if outputFormat == outputJSON {
+		result["content"] = content
+		return printJSON(result)
+	}
+
+	fmt.Print(content)
+	if !strings.HasSuffix(content, "\n") {
+		fmt.Println()
+	}
+	return nil
+}
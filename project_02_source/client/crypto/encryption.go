@@ -79,6 +79,20 @@ func DecryptAES(ciphertext string, ivStr string, key []byte) (string, error) {
 	return string(plaintext), nil
 }
 
+// WrapKey encrypts a DEK with a KEK (envelope encryption)
+func WrapKey(dek, kek []byte) (encryptedKey string, iv string, err error) {
+	return EncryptAES(base64.StdEncoding.EncodeToString(dek), kek)
+}
+
+// UnwrapKey decrypts a DEK that was wrapped with WrapKey
+func UnwrapKey(encryptedKey, iv string, kek []byte) ([]byte, error) {
+	dekBase64, err := DecryptAES(encryptedKey, iv, kek)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(dekBase64)
+}
+
 // DeriveKeyFromPassword derives a 256-bit key from password using PBKDF2
 func DeriveKeyFromPassword(password string, salt []byte) []byte {
 	if len(salt) == 0 {
@@ -0,0 +1,311 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// KDFPBKDF2SHA256 is PBKDF2 with HMAC-SHA256
+	KDFPBKDF2SHA256 = "pbkdf2-sha256"
+
+	// KDFArgon2id is the memory-hard Argon2id (RFC 9106)
+	KDFArgon2id = "argon2id"
+
+	// DefaultPBKDF2Iterations is used for new PBKDF2 salts
+	DefaultPBKDF2Iterations = 600000
+
+	// Default Argon2id cost: 3 passes over 64 MiB with 4 lanes
+	DefaultArgon2idTime    = 3
+	DefaultArgon2idMemory  = 64 * 1024 // KiB
+	DefaultArgon2idThreads = 4
+
+	// MinArgon2idMemory is the smallest memory cost we accept (19 MiB, OWASP minimum)
+	MinArgon2idMemory = 19 * 1024 // KiB
+
+	// Upper bounds on KDF costs (the server enforces the same ones), so that
+	// parameters read from the server cannot make an unlock hang or run out of memory
+	MaxArgon2idTime     = 10          // Passes
+	MaxArgon2idMemory   = 1024 * 1024 // KiB (1 GiB)
+	MaxArgon2idThreads  = 16
+	MaxPBKDF2Iterations = 10000000
+
+	// kdfSaltSize is the size of a per-user salt in bytes
+	kdfSaltSize = 16
+)
+
+// KDFParams describes how a user's KEK is derived from their password.
+// An empty salt marks the legacy scheme (fixed salt, 100000 PBKDF2 iterations).
+type KDFParams struct {
+	Algorithm  string `json:"algorithm"`
+	Iterations int    `json:"iterations"`        // PBKDF2 rounds, or Argon2id time cost
+	Memory     uint32 `json:"memory,omitempty"`  // Argon2id memory cost in KiB
+	Threads    uint8  `json:"threads,omitempty"` // Argon2id parallelism
+	Salt       string `json:"salt"`              // base64
+}
+
+// IsLegacy reports whether these parameters describe the old fixed-salt scheme
+func (p KDFParams) IsLegacy() bool {
+	return p.Salt == ""
+}
+
+// Descriptor encodes the parameters in a PHC-style string that is stored next to
+// each wrapped key, e.g. "$argon2id$v=19$m=65536,t=3,p=4$<salt>".
+// Legacy parameters encode to an empty string.
+func (p KDFParams) Descriptor() string {
+	if p.IsLegacy() {
+		return ""
+	}
+
+	switch p.Algorithm {
+	case KDFArgon2id:
+		return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s", KDFArgon2id, argon2.Version, p.Memory, p.Iterations, p.Threads, p.Salt)
+	default:
+		return fmt.Sprintf("$%s$i=%d$%s", p.Algorithm, p.Iterations, p.Salt)
+	}
+}
+
+// ParseKDFDescriptor decodes a string produced by KDFParams.Descriptor
+func ParseKDFDescriptor(descriptor string) (KDFParams, error) {
+	if descriptor == "" {
+		return KDFParams{Algorithm: KDFPBKDF2SHA256, Iterations: 100000}, nil
+	}
+
+	parts := strings.Split(descriptor, "$")
+	if parts[0] != "" || len(parts) < 4 {
+		return KDFParams{}, fmt.Errorf("invalid KDF descriptor %q", descriptor)
+	}
+
+	params := KDFParams{Algorithm: parts[1], Salt: parts[len(parts)-1]}
+
+	switch params.Algorithm {
+	case KDFPBKDF2SHA256:
+		if len(parts) != 4 {
+			return KDFParams{}, fmt.Errorf("invalid KDF descriptor %q", descriptor)
+		}
+		iterations, err := strconv.Atoi(strings.TrimPrefix(parts[2], "i="))
+		if err != nil {
+			return KDFParams{}, fmt.Errorf("invalid PBKDF2 iterations in %q", descriptor)
+		}
+		params.Iterations = iterations
+		if err := params.checkCost(); err != nil {
+			return KDFParams{}, err
+		}
+
+	case KDFArgon2id:
+		if len(parts) != 5 || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+			return KDFParams{}, fmt.Errorf("unsupported Argon2id descriptor %q", descriptor)
+		}
+		var memory int64
+		var timeCost, threads int
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &timeCost, &threads); err != nil {
+			return KDFParams{}, fmt.Errorf("invalid Argon2id parameters in %q", descriptor)
+		}
+		// Range check before narrowing, so a huge value cannot wrap into a valid one
+		if memory <= 0 || memory > math.MaxUint32 || timeCost <= 0 || threads <= 0 || threads > math.MaxUint8 {
+			return KDFParams{}, fmt.Errorf("invalid Argon2id parameters in %q", descriptor)
+		}
+		params.Memory = uint32(memory)
+		params.Iterations = timeCost
+		params.Threads = uint8(threads)
+		if err := params.checkCost(); err != nil {
+			return KDFParams{}, err
+		}
+
+	default:
+		return KDFParams{}, fmt.Errorf("unsupported KDF algorithm %q", params.Algorithm)
+	}
+
+	return params, nil
+}
+
+// NewKDFParams returns default PBKDF2 parameters with a fresh random salt
+func NewKDFParams() (KDFParams, error) {
+	salt, err := newKDFSalt()
+	if err != nil {
+		return KDFParams{}, err
+	}
+
+	return KDFParams{
+		Algorithm:  KDFPBKDF2SHA256,
+		Iterations: DefaultPBKDF2Iterations,
+		Salt:       salt,
+	}, nil
+}
+
+// NewArgon2idParams returns Argon2id parameters with a fresh random salt.
+// timeCost is the number of passes, memory is in KiB.
+func NewArgon2idParams(timeCost int, memory uint32, threads uint8) (KDFParams, error) {
+	if timeCost < 1 || memory < MinArgon2idMemory || threads < 1 {
+		return KDFParams{}, fmt.Errorf("Argon2id needs time >= 1, memory >= %d KiB and threads >= 1", MinArgon2idMemory)
+	}
+	params := KDFParams{Algorithm: KDFArgon2id, Iterations: timeCost, Memory: memory, Threads: threads}
+	if err := params.checkCost(); err != nil {
+		return KDFParams{}, err
+	}
+
+	salt, err := newKDFSalt()
+	if err != nil {
+		return KDFParams{}, err
+	}
+	params.Salt = salt
+
+	return params, nil
+}
+
+// CalibrateArgon2id picks Argon2id parameters so that deriving a key takes about
+// target on this machine. Memory starts at 64 MiB and is halved (down to 19 MiB)
+// while a single pass is too slow, then the time cost is raised to fill the target.
+func CalibrateArgon2id(target time.Duration) (KDFParams, error) {
+	threads := uint8(DefaultArgon2idThreads)
+	if runtime.NumCPU() < DefaultArgon2idThreads {
+		threads = uint8(runtime.NumCPU())
+	}
+
+	password := []byte("calibration")
+	salt := make([]byte, kdfSaltSize)
+	measure := func(timeCost, memory uint32) time.Duration {
+		start := time.Now()
+		argon2.IDKey(password, salt, timeCost, memory, threads, 32)
+		return time.Since(start)
+	}
+
+	memory := uint32(DefaultArgon2idMemory)
+	onePass := measure(1, memory)
+	for onePass > target && memory/2 >= MinArgon2idMemory {
+		memory /= 2
+		onePass = measure(1, memory)
+	}
+
+	timeCost := 1
+	if onePass > 0 {
+		timeCost = int(target / onePass)
+	}
+	if timeCost < 1 {
+		timeCost = 1
+	}
+	if timeCost > MaxArgon2idTime {
+		timeCost = MaxArgon2idTime
+	}
+
+	return NewArgon2idParams(timeCost, memory, threads)
+}
+
+// DeriveKEK derives the 256-bit key-encryption key from a password
+func DeriveKEK(password string, params KDFParams) ([]byte, error) {
+	if params.IsLegacy() {
+		return DeriveKeyFromPassword(password, nil), nil
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KDF salt: %w", err)
+	}
+
+	if err := params.checkCost(); err != nil {
+		return nil, err
+	}
+
+	switch params.Algorithm {
+	case KDFPBKDF2SHA256:
+		return pbkdf2.Key([]byte(password), salt, params.Iterations, 32, sha256.New), nil
+	case KDFArgon2id:
+		return argon2.IDKey([]byte(password), salt, uint32(params.Iterations), params.Memory, params.Threads, 32), nil
+	default:
+		return nil, fmt.Errorf("unsupported KDF algorithm: %q", params.Algorithm)
+	}
+}
+
+// checkCost checks that the KDF cost is positive and within the Max* bounds
+func (p KDFParams) checkCost() error {
+	switch p.Algorithm {
+	case KDFPBKDF2SHA256:
+		if p.Iterations <= 0 || p.Iterations > MaxPBKDF2Iterations {
+			return fmt.Errorf("PBKDF2 iteration count %d is outside 1..%d", p.Iterations, MaxPBKDF2Iterations)
+		}
+	case KDFArgon2id:
+		if p.Iterations <= 0 || p.Iterations > MaxArgon2idTime ||
+			p.Memory == 0 || p.Memory > MaxArgon2idMemory ||
+			p.Threads == 0 || p.Threads > MaxArgon2idThreads {
+			return fmt.Errorf("Argon2id parameters t=%d m=%d p=%d are outside t<=%d, m<=%d KiB, p<=%d",
+				p.Iterations, p.Memory, p.Threads, MaxArgon2idTime, MaxArgon2idMemory, MaxArgon2idThreads)
+		}
+	default:
+		return fmt.Errorf("unsupported KDF algorithm: %q", p.Algorithm)
+	}
+	return nil
+}
+
+// KEKCache derives KEKs for one password and remembers them per KDF descriptor,
+// so keys wrapped under different parameters can be unwrapped without re-running the KDF.
+type KEKCache struct {
+	mu       sync.Mutex
+	password string
+	fallback KDFParams
+	keys     map[string][]byte
+}
+
+// NewKEKCache creates a cache for password. Wrapped keys without a descriptor
+// are assumed to use fallback (the account's KDF parameters).
+func NewKEKCache(password string, fallback KDFParams) *KEKCache {
+	return &KEKCache{
+		password: password,
+		fallback: fallback,
+		keys:     make(map[string][]byte),
+	}
+}
+
+// Remember stores an already derived KEK for params
+func (c *KEKCache) Remember(params KDFParams, kek []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys[params.Descriptor()] = kek
+}
+
+// KEK returns the KEK for a wrapped key's KDF descriptor
+func (c *KEKCache) KEK(descriptor string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if descriptor == "" {
+		descriptor = c.fallback.Descriptor()
+	}
+	if kek, ok := c.keys[descriptor]; ok {
+		return kek, nil
+	}
+
+	params := c.fallback
+	if descriptor != c.fallback.Descriptor() {
+		var err error
+		if params, err = ParseKDFDescriptor(descriptor); err != nil {
+			return nil, err
+		}
+	}
+
+	kek, err := DeriveKEK(c.password, params)
+	if err != nil {
+		return nil, err
+	}
+	c.keys[descriptor] = kek
+	return kek, nil
+}
+
+// newKDFSalt returns a random base64 salt
+func newKDFSalt() (string, error) {
+	salt := make([]byte, kdfSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(salt), nil
+}
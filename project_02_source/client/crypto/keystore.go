@@ -1,8 +1,10 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/ecdh"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,36 +18,69 @@ func GetKeystorePath(username string) string {
 	return filepath.Join(keystoreDir, username+".key")
 }
 
+// keystoreFile is the on-disk format of a keystore (version 2).
+// Version 1 files are "iv:encryptedKey" with a PBKDF2 key salted by the username.
+type keystoreFile struct {
+	Version      int       `json:"version"`
+	KDF          KDFParams `json:"kdf"`
+	IV           string    `json:"iv"`
+	EncryptedKey string    `json:"encrypted_key"`
+}
+
+// DefaultKeystoreKDF returns the KDF parameters used for newly written keystores
+func DefaultKeystoreKDF() (KDFParams, error) {
+	return NewArgon2idParams(DefaultArgon2idTime, DefaultArgon2idMemory, DefaultArgon2idThreads)
+}
+
 // SaveDHKeyPair saves a DH keypair to encrypted file
 func SaveDHKeyPair(username, password string, privateKey *ecdh.PrivateKey) error {
+	params, err := DefaultKeystoreKDF()
+	if err != nil {
+		return err
+	}
+	return SaveDHKeyPairWithKDF(username, password, privateKey, params)
+}
+
+// SaveDHKeyPairWithKDF saves a DH keypair encrypted under a key derived with params
+func SaveDHKeyPairWithKDF(username, password string, privateKey *ecdh.PrivateKey, params KDFParams) error {
 	// Serialize private key
 	privateKeyBytes := privateKey.Bytes()
-	
+
 	// Derive encryption key from password
-	kek := DeriveKeyFromPassword(password, []byte(username))
-	
+	kek, err := DeriveKEK(password, params)
+	if err != nil {
+		return fmt.Errorf("failed to derive keystore key: %w", err)
+	}
+
 	// Encrypt private key
 	encryptedKey, iv, err := EncryptAES(base64.StdEncoding.EncodeToString(privateKeyBytes), kek)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt private key: %w", err)
 	}
-	
-	// Format: iv:encryptedKey (both base64)
-	data := iv + ":" + encryptedKey
-	
+
+	data, err := json.Marshal(keystoreFile{
+		Version:      2,
+		KDF:          params,
+		IV:           iv,
+		EncryptedKey: encryptedKey,
+	})
+	if err != nil {
+		return err
+	}
+
 	// Save to file
 	keystorePath := GetKeystorePath(username)
-	if err := os.WriteFile(keystorePath, []byte(data), 0600); err != nil {
+	if err := os.WriteFile(keystorePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write keystore: %w", err)
 	}
-	
+
 	return nil
 }
 
 // LoadDHKeyPair loads a DH keypair from encrypted file
 func LoadDHKeyPair(username, password string) (*ecdh.PrivateKey, error) {
 	keystorePath := GetKeystorePath(username)
-	
+
 	// Read file
 	data, err := os.ReadFile(keystorePath)
 	if err != nil {
@@ -54,44 +89,56 @@ func LoadDHKeyPair(username, password string) (*ecdh.PrivateKey, error) {
 		}
 		return nil, fmt.Errorf("failed to read keystore: %w", err)
 	}
-	
-	// Parse format: iv:encryptedKey
-	parts := []byte(data)
+
 	var iv, encryptedKey string
-	for i, b := range parts {
-		if b == ':' {
-			iv = string(parts[:i])
-			encryptedKey = string(parts[i+1:])
-			break
+	var kek []byte
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		// Version 2: KDF parameters are stored in the file
+		var file keystoreFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("invalid keystore format: %w", err)
 		}
+		if file.Version != 2 {
+			return nil, fmt.Errorf("unsupported keystore version %d", file.Version)
+		}
+		iv, encryptedKey = file.IV, file.EncryptedKey
+
+		if kek, err = DeriveKEK(password, file.KDF); err != nil {
+			return nil, fmt.Errorf("failed to derive keystore key: %w", err)
+		}
+	} else {
+		// Version 1: iv:encryptedKey, PBKDF2 salted with the username
+		if i := bytes.IndexByte(data, ':'); i >= 0 {
+			iv = string(data[:i])
+			encryptedKey = string(data[i+1:])
+		}
+		kek = DeriveKeyFromPassword(password, []byte(username))
 	}
-	
+
 	if iv == "" || encryptedKey == "" {
 		return nil, fmt.Errorf("invalid keystore format")
 	}
-	
-	// Derive decryption key from password
-	kek := DeriveKeyFromPassword(password, []byte(username))
-	
+
 	// Decrypt private key
 	privateKeyBase64, err := DecryptAES(encryptedKey, iv, kek)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt private key (wrong password?): %w", err)
 	}
-	
+
 	// Decode base64
 	privateKeyBytes, err := base64.StdEncoding.DecodeString(privateKeyBase64)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode private key: %w", err)
 	}
-	
+
 	// Reconstruct private key
 	curve := ecdh.X25519()
 	privateKey, err := curve.NewPrivateKey(privateKeyBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to reconstruct private key: %w", err)
 	}
-	
+
 	return privateKey, nil
 }
 
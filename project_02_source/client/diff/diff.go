@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Op is the kind of change for one line
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is one line of a diff
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines computes a line diff that turns a into b.
+// It uses a longest-common-subsequence table after trimming the common prefix and suffix,
+// which keeps it cheap for the usual case of a small edit in a long note.
+func Lines(a, b string) []Line {
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+
+	// Common prefix
+	prefix := 0
+	for prefix < len(aLines) && prefix < len(bLines) && aLines[prefix] == bLines[prefix] {
+		prefix++
+	}
+
+	// Common suffix
+	suffix := 0
+	for suffix < len(aLines)-prefix && suffix < len(bLines)-prefix &&
+		aLines[len(aLines)-1-suffix] == bLines[len(bLines)-1-suffix] {
+		suffix++
+	}
+
+	var result []Line
+	for _, text := range aLines[:prefix] {
+		result = append(result, Line{Op: Equal, Text: text})
+	}
+
+	result = append(result, lcsDiff(aLines[prefix:len(aLines)-suffix], bLines[prefix:len(bLines)-suffix])...)
+
+	for _, text := range aLines[len(aLines)-suffix:] {
+		result = append(result, Line{Op: Equal, Text: text})
+	}
+
+	return result
+}
+
+// Format renders a diff in unified style ("+" inserted, "-" deleted, " " unchanged)
+func Format(lines []Line) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		switch line.Op {
+		case Insert:
+			sb.WriteString("+ ")
+		case Delete:
+			sb.WriteString("- ")
+		default:
+			sb.WriteString("  ")
+		}
+		sb.WriteString(line.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// HasChanges reports whether a diff contains any insertion or deletion
+func HasChanges(lines []Line) bool {
+	for _, line := range lines {
+		if line.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// lcsDiff diffs two line slices using a longest-common-subsequence table
+func lcsDiff(a, b []string) []Line {
+	// table[i][j] = LCS length of a[i:] and b[j:]
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else if table[i+1][j] >= table[i][j+1] {
+				table[i][j] = table[i+1][j]
+			} else {
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+
+	var result []Line
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, Line{Op: Equal, Text: a[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			result = append(result, Line{Op: Delete, Text: a[i]})
+			i++
+		default:
+			result = append(result, Line{Op: Insert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, Line{Op: Delete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, Line{Op: Insert, Text: b[j]})
+	}
+
+	return result
+}
+
+// splitLines splits text into lines, ignoring a single trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"lab02_mahoa/client/api"
 	"lab02_mahoa/client/cli"
+	"lab02_mahoa/client/profile"
 	"lab02_mahoa/client/ui"
+	"log"
 	"os"
 
 	"fyne.io/fyne/v2"
@@ -13,16 +15,24 @@ import (
 func main() {
 	// Check if CLI mode (if arguments provided and not "-gui")
 	if len(os.Args) > 1 && os.Args[1] != "-gui" {
-		cli.Run(os.Args[1:])
-		return
+		os.Exit(cli.Run(os.Args[1:]))
 	}
 
 	// GUI mode
 	myApp := app.NewWithID("secure-notes-app")
 	myWindow := myApp.NewWindow("Secure Note Sharing")
 
-	// Initialize API client
+	// Initialize API client on the default server profile (the login screen can pick another)
 	apiClient := &api.Client{}
+	store, err := profile.Load()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		store = &profile.Store{}
+	}
+	defaultProfile, _ := store.Pick("")
+	if err := defaultProfile.Apply(apiClient); err != nil {
+		log.Fatalf("Invalid server settings: %v", err)
+	}
 
 	// Create GUI
 	gui := &ui.GUI{
@@ -0,0 +1,189 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lab02_mahoa/client/api"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EnvServer overrides the server URL of any profile, e.g. for CI
+const EnvServer = "SECURE_NOTES_SERVER"
+
+// Profile is a named server connection
+type Profile struct {
+	Name     string `json:"name"`
+	Server   string `json:"server"`             // API root, e.g. https://notes.example.com/api
+	Username string `json:"username,omitempty"` // Account used on this server by default
+	CAFile   string `json:"ca_file,omitempty"`  // PEM bundle to trust for this server
+}
+
+// Store holds the saved profiles
+type Store struct {
+	Default  string    `json:"default,omitempty"` // Profile used when none is picked
+	Profiles []Profile `json:"profiles"`
+}
+
+// Path returns the profiles file in the user's config directory, next to the
+// keystores (~/.lab02_mahoa)
+func Path() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".lab02_mahoa", "profiles.json"), nil
+}
+
+// Load reads the saved profiles. A missing file is an empty store.
+func Load() (*Store, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Store{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	var s Store
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid profiles file %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes the profiles, readable by the current user only
+func (s *Store) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	sort.Slice(s.Profiles, func(i, j int) bool { return s.Profiles[i].Name < s.Profiles[j].Name })
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write a temporary file and rename it so a crash never leaves half a file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save profiles: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save profiles: %w", err)
+	}
+	return nil
+}
+
+// Get returns the profile called name
+func (s *Store) Get(name string) (Profile, bool) {
+	for _, p := range s.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// Set adds p, or replaces the profile with the same name
+func (s *Store) Set(p Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p.Server = strings.TrimRight(p.Server, "/")
+	for i := range s.Profiles {
+		if s.Profiles[i].Name == p.Name {
+			s.Profiles[i] = p
+			return nil
+		}
+	}
+	s.Profiles = append(s.Profiles, p)
+	return nil
+}
+
+// Remove deletes the profile called name and reports whether it existed
+func (s *Store) Remove(name string) bool {
+	for i, p := range s.Profiles {
+		if p.Name == name {
+			s.Profiles = append(s.Profiles[:i], s.Profiles[i+1:]...)
+			if s.Default == name {
+				s.Default = ""
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Pick returns the profile called name, or the default profile if name is
+// empty. Without a default the zero Profile (the local server) is returned.
+func (s *Store) Pick(name string) (Profile, error) {
+	if name == "" {
+		name = s.Default
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := s.Get(name)
+	if !ok {
+		return Profile{}, fmt.Errorf("no profile named %q", name)
+	}
+	return p, nil
+}
+
+// Validate checks the name and the server URL
+func (p Profile) Validate() error {
+	if p.Name == "" || strings.ContainsAny(p.Name, `/\ `) {
+		return fmt.Errorf("invalid profile name %q", p.Name)
+	}
+	return ValidateServer(p.Server)
+}
+
+// ValidateServer checks that server is an absolute http(s) URL
+func ValidateServer(server string) error {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid server URL %q, expected e.g. https://notes.example.com/api", server)
+	}
+	return nil
+}
+
+// ServerURL returns the API root of the profile, SECURE_NOTES_SERVER if set
+func (p Profile) ServerURL() string {
+	if server := os.Getenv(EnvServer); server != "" {
+		return strings.TrimRight(server, "/")
+	}
+	if p.Server != "" {
+		return strings.TrimRight(p.Server, "/")
+	}
+	return api.DefaultBaseURL
+}
+
+// Apply points client at the profile's server. The CA bundle of the profile is
+// used unless TLS options are set in the environment.
+func (p Profile) Apply(client *api.Client) error {
+	server := p.ServerURL()
+	if err := ValidateServer(server); err != nil {
+		return err
+	}
+	client.BaseURL = server
+
+	opts := api.TLSOptionsFromEnv()
+	if opts.IsZero() {
+		opts.CAFile = p.CAFile
+	}
+	return client.ConfigureTLS(opts)
+}
@@ -1,7 +1,9 @@
 package login
 
 import (
+	"errors"
 	"image/color"
+	"lab02_mahoa/client/account"
 	"lab02_mahoa/client/api"
 	"lab02_mahoa/client/crypto"
 	"log"
@@ -149,6 +151,24 @@ func ShowLoginScreen(window fyne.Window, apiClient *api.Client, onLoginSuccess f
 	passwordEntry := widget.NewPasswordEntry()
 	passwordEntry.SetPlaceHolder("Nhập mật khẩu của bạn")
 
+	// Two-factor code field, shown when the server asks for it
+	otpLabel := canvas.NewText("Mã xác thực 2 bước", colorText)
+	otpLabel.TextStyle = fyne.TextStyle{Bold: true}
+	otpEntry := widget.NewEntry()
+	otpEntry.SetPlaceHolder("Mã 6 số từ ứng dụng xác thực hoặc mã khôi phục")
+	otpBox := container.NewVBox(otpLabel, otpEntry)
+	otpBox.Hide()
+
+	// A new username or password starts the login over
+	resetMFA := func(string) {
+		otpBox.Hide()
+		apiClient.MFAToken = ""
+	}
+	usernameEntry.OnChanged = resetMFA
+	passwordEntry.OnChanged = resetMFA
+
+	setupTOTPCheck := widget.NewCheck("Thiết lập xác thực 2 bước sau khi đăng nhập", nil)
+
 	// Status label
 	statusLabel := canvas.NewText("", colorText)
 	statusLabel.Alignment = fyne.TextAlignCenter
@@ -164,6 +184,9 @@ func ShowLoginScreen(window fyne.Window, apiClient *api.Client, onLoginSuccess f
 		statusLabel.Refresh()
 	}
 
+	// Server profile picker
+	profilePicker := newProfilePicker(apiClient, usernameEntry, setStatus)
+
 	// Login button
 	loginBtn := widget.NewButton("Đăng nhập", func() {
 		username := strings.TrimSpace(usernameEntry.Text)
@@ -181,7 +204,24 @@ func ShowLoginScreen(window fyne.Window, apiClient *api.Client, onLoginSuccess f
 		key := make([]byte, 32)
 		copy(key, []byte(password))
 
-		token, err := apiClient.Login(username, password)
+		var token string
+		var err error
+		if otpBox.Visible() {
+			code := strings.TrimSpace(otpEntry.Text)
+			if code == "" {
+				setStatus("⚠️ Vui lòng nhập mã xác thực 2 bước", true)
+				return
+			}
+			token, err = apiClient.CompleteMFALogin(code)
+		} else {
+			token, err = apiClient.Login(username, password)
+		}
+		if errors.Is(err, api.ErrMFARequired) {
+			otpBox.Show()
+			window.Canvas().Focus(otpEntry)
+			setStatus("🛡️ Nhập mã từ ứng dụng xác thực để tiếp tục", false)
+			return
+		}
 		if err != nil {
 			if strings.Contains(err.Error(), "invalid credentials") || strings.Contains(err.Error(), "Invalid") {
 				setStatus("❌ Tên đăng nhập hoặc mật khẩu không đúng", true)
@@ -198,39 +238,28 @@ func ShowLoginScreen(window fyne.Window, apiClient *api.Client, onLoginSuccess f
 		api.CurrentUsername = username
 		api.CurrentPassword = password
 
+		// Derive the KEK once per session (moves legacy accounts to a per-user salt)
+		kek, kdf, err := account.UnlockKEK(apiClient, password, apiClient.KDF)
+		if err != nil {
+			setStatus("❌ "+err.Error(), true)
+			return
+		}
+		api.CurrentKEK = kek
+		api.CurrentKDF = kdf
+		api.CurrentKeys = crypto.NewKEKCache(password, kdf)
+		api.CurrentKeys.Remember(kdf, kek)
+
 		// Load or generate DH keypair for E2EE
 		go func() {
-			// Try to load existing keypair from encrypted file
-			privateKey, err := crypto.LoadDHKeyPair(username, password)
+			privateKey, created, err := account.LoadOrCreateDHKey(apiClient, username, password)
 			if err != nil {
-				log.Printf("Warning: Failed to load DH keypair: %v", err)
-				return
+				log.Printf("Warning: %v", err)
 			}
-
-			// If no keypair exists, generate new one
 			if privateKey == nil {
-				log.Printf("No existing keypair found, generating new one...")
-				keyPair, err := crypto.GenerateDHKeyPair()
-				if err != nil {
-					log.Printf("Warning: Failed to generate DH keypair: %v", err)
-					return
-				}
-				privateKey = keyPair.PrivateKey
-
-				// Save keypair to encrypted file
-				if err := crypto.SaveDHKeyPair(username, password, privateKey); err != nil {
-					log.Printf("Warning: Failed to save DH keypair: %v", err)
-				} else {
-					log.Printf("DH keypair saved to keystore")
-				}
-
-				// Register public key with server (first time only)
-				publicKeyBase64 := crypto.PublicKeyToBase64(privateKey.PublicKey())
-				if err := apiClient.UpdatePublicKey(publicKeyBase64); err != nil {
-					log.Printf("Warning: Failed to register DH public key: %v", err)
-				} else {
-					log.Printf("DH public key registered successfully")
-				}
+				return
+			}
+			if created {
+				log.Printf("New DH keypair saved to keystore")
 			} else {
 				log.Printf("Loaded existing DH keypair from keystore")
 			}
@@ -240,6 +269,10 @@ func ShowLoginScreen(window fyne.Window, apiClient *api.Client, onLoginSuccess f
 		}()
 
 		setStatus("✅ Đăng nhập thành công!", false)
+		if setupTOTPCheck.Checked {
+			ShowTOTPScreen(window, apiClient, password, func() { onLoginSuccess(username, key) })
+			return
+		}
 		onLoginSuccess(username, key)
 	})
 	loginBtn.Importance = widget.HighImportance
@@ -265,11 +298,15 @@ func ShowLoginScreen(window fyne.Window, apiClient *api.Client, onLoginSuccess f
 		container.NewCenter(headerTitle),
 		container.NewCenter(headerSubtitle),
 		layout.NewSpacer(),
+		profilePicker,
+		layout.NewSpacer(),
 		usernameLabel,
 		usernameEntry,
 		layout.NewSpacer(),
 		passwordLabel,
 		passwordEntry,
+		otpBox,
+		setupTOTPCheck,
 		layout.NewSpacer(),
 		loginBtn,
 		layout.NewSpacer(),
@@ -0,0 +1,66 @@
+package login
+
+import (
+	"lab02_mahoa/client/api"
+	"lab02_mahoa/client/profile"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// localServerOption is the picker entry for the server on this machine
+const localServerOption = "Máy này (localhost)"
+
+// pickedProfile remembers the choice while moving between screens
+var pickedProfile *string
+
+// newProfilePicker returns a select of the saved server profiles. Picking one
+// points apiClient at its server and fills in its username.
+func newProfilePicker(apiClient *api.Client, usernameEntry *widget.Entry, setStatus func(message string, isError bool)) fyne.CanvasObject {
+	store, err := profile.Load()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		store = &profile.Store{}
+	}
+
+	serverLabel := canvas.NewText("", colorTextLight)
+	serverLabel.TextSize = 12
+
+	options := []string{localServerOption}
+	for _, p := range store.Profiles {
+		options = append(options, p.Name)
+	}
+
+	picker := widget.NewSelect(options, func(name string) {
+		p := profile.Profile{}
+		if name != localServerOption {
+			p, _ = store.Get(name)
+		}
+		if err := p.Apply(apiClient); err != nil {
+			setStatus("❌ "+err.Error(), true)
+			return
+		}
+		pickedProfile = &name
+
+		serverLabel.Text = "🌐 " + p.ServerURL()
+		serverLabel.Refresh()
+		if p.Username != "" && usernameEntry.Text == "" {
+			usernameEntry.SetText(p.Username)
+		}
+	})
+
+	selected := localServerOption
+	if pickedProfile != nil {
+		selected = *pickedProfile
+	} else if _, ok := store.Get(store.Default); ok {
+		selected = store.Default
+	}
+	picker.SetSelected(selected)
+
+	label := canvas.NewText("Máy chủ", colorText)
+	label.TextStyle = fyne.TextStyle{Bold: true}
+	return container.NewVBox(label, picker, serverLabel)
+}
@@ -0,0 +1,200 @@
+package login
+
+import (
+	"fmt"
+	"lab02_mahoa/client/api"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/pquerna/otp"
+)
+
+// ShowTOTPScreen lets the logged in user turn two-factor authentication on or
+// off. onDone is called when the user continues to the app.
+func ShowTOTPScreen(window fyne.Window, apiClient *api.Client, password string, onDone func()) {
+	status, err := apiClient.GetTOTPStatus()
+	if err != nil {
+		showTOTPCard(window, "❌ "+err.Error(), nil, onDone)
+		return
+	}
+	if status.Enabled {
+		showTOTPDisable(window, apiClient, password, status, onDone)
+		return
+	}
+
+	setup, err := apiClient.SetupTOTP()
+	if err != nil {
+		showTOTPCard(window, "❌ "+err.Error(), nil, onDone)
+		return
+	}
+	showTOTPSetup(window, apiClient, setup, onDone)
+}
+
+// showTOTPSetup shows the QR code of a new secret and asks for a code to confirm it
+func showTOTPSetup(window fyne.Window, apiClient *api.Client, setup api.TOTPSetup, onDone func()) {
+	info := widget.NewLabel("Quét mã QR bằng ứng dụng xác thực (Google Authenticator, Aegis, ...)\nhoặc nhập khóa bí mật bên dưới, sau đó nhập mã 6 số để xác nhận.")
+	info.Wrapping = fyne.TextWrapWord
+
+	var qr fyne.CanvasObject = widget.NewLabel("")
+	if key, err := otp.NewKeyFromURL(setup.OTPAuthURI); err == nil {
+		if img, err := key.Image(200, 200); err == nil {
+			qrImage := canvas.NewImageFromImage(img)
+			qrImage.FillMode = canvas.ImageFillOriginal
+			qr = qrImage
+		}
+	}
+
+	// Entries so the secret and URI can be copied
+	secretEntry := widget.NewEntry()
+	secretEntry.SetText(setup.Secret)
+	uriEntry := widget.NewEntry()
+	uriEntry.SetText(setup.OTPAuthURI)
+
+	codeEntry := widget.NewEntry()
+	codeEntry.SetPlaceHolder("Mã 6 số")
+
+	statusLabel := canvas.NewText("", colorError)
+	statusLabel.Alignment = fyne.TextAlignCenter
+	statusLabel.TextSize = 13
+
+	confirmBtn := widget.NewButton("Bật xác thực 2 bước", func() {
+		code := strings.TrimSpace(codeEntry.Text)
+		if code == "" {
+			statusLabel.Text = "⚠️ Vui lòng nhập mã 6 số"
+			statusLabel.Refresh()
+			return
+		}
+		codes, err := apiClient.ConfirmTOTP(code)
+		if err != nil {
+			statusLabel.Text = "❌ " + err.Error()
+			statusLabel.Refresh()
+			return
+		}
+		showRecoveryCodes(window, codes, onDone)
+	})
+	confirmBtn.Importance = widget.HighImportance
+
+	skipBtn := widget.NewButton("Bỏ qua", onDone)
+
+	content := container.NewVBox(
+		info,
+		container.NewCenter(qr),
+		widget.NewLabel("Khóa bí mật"),
+		secretEntry,
+		widget.NewLabel("otpauth URI"),
+		uriEntry,
+		layout.NewSpacer(),
+		codeEntry,
+		confirmBtn,
+		container.NewCenter(statusLabel),
+		skipBtn,
+	)
+	showTOTPCard(window, "", content, onDone)
+}
+
+// showRecoveryCodes shows the recovery codes once after enabling two-factor authentication
+func showRecoveryCodes(window fyne.Window, codes []string, onDone func()) {
+	info := widget.NewLabel("✅ Đã bật xác thực 2 bước. Hãy lưu các mã khôi phục dưới đây ở nơi an toàn:\nmỗi mã dùng được một lần khi không có ứng dụng xác thực. Các mã chỉ hiển thị lần này.")
+	info.Wrapping = fyne.TextWrapWord
+
+	codesEntry := widget.NewMultiLineEntry()
+	codesEntry.SetText(strings.Join(codes, "\n"))
+	codesEntry.SetMinRowsVisible(len(codes))
+
+	continueBtn := widget.NewButton("Tôi đã lưu, tiếp tục", onDone)
+	continueBtn.Importance = widget.HighImportance
+
+	showTOTPCard(window, "", container.NewVBox(info, codesEntry, continueBtn), onDone)
+}
+
+// showTOTPDisable offers to turn two-factor authentication off
+func showTOTPDisable(window fyne.Window, apiClient *api.Client, password string, status api.TOTPStatus, onDone func()) {
+	info := widget.NewLabel(fmt.Sprintf("🛡️ Xác thực 2 bước đang bật. Còn %d mã khôi phục chưa dùng.\nĐể tắt, nhập mã từ ứng dụng xác thực hoặc một mã khôi phục.", status.RecoveryCodesLeft))
+	info.Wrapping = fyne.TextWrapWord
+
+	codeEntry := widget.NewEntry()
+	codeEntry.SetPlaceHolder("Mã 6 số hoặc mã khôi phục")
+
+	statusLabel := canvas.NewText("", colorError)
+	statusLabel.Alignment = fyne.TextAlignCenter
+	statusLabel.TextSize = 13
+
+	disableBtn := widget.NewButton("Tắt xác thực 2 bước", func() {
+		code := strings.TrimSpace(codeEntry.Text)
+		if code == "" {
+			statusLabel.Text = "⚠️ Vui lòng nhập mã"
+			statusLabel.Refresh()
+			return
+		}
+		if err := apiClient.DisableTOTP(password, code); err != nil {
+			statusLabel.Text = "❌ " + err.Error()
+			statusLabel.Refresh()
+			return
+		}
+		showTOTPCard(window, "✅ Đã tắt xác thực 2 bước", nil, onDone)
+	})
+	disableBtn.Importance = widget.DangerImportance
+
+	continueBtn := widget.NewButton("Tiếp tục", onDone)
+	continueBtn.Importance = widget.HighImportance
+
+	showTOTPCard(window, "", container.NewVBox(info, codeEntry, disableBtn, container.NewCenter(statusLabel), continueBtn), onDone)
+}
+
+// showTOTPCard lays out a two-factor screen in the same card as the login
+// screen. With no body it shows message and a button to continue.
+func showTOTPCard(window fyne.Window, message string, body fyne.CanvasObject, onDone func()) {
+	bg := canvas.NewRectangle(colorBg)
+	cardBg := canvas.NewRectangle(colorWhite)
+	cardBg.CornerRadius = 16
+
+	headerIcon := canvas.NewText("🛡️", colorPrimary)
+	headerIcon.TextSize = 48
+	headerIcon.Alignment = fyne.TextAlignCenter
+
+	headerTitle := canvas.NewText("Xác thực 2 bước", colorText)
+	headerTitle.TextSize = 28
+	headerTitle.TextStyle = fyne.TextStyle{Bold: true}
+	headerTitle.Alignment = fyne.TextAlignCenter
+
+	if body == nil {
+		messageLabel := widget.NewLabel(message)
+		messageLabel.Alignment = fyne.TextAlignCenter
+		continueBtn := widget.NewButton("Tiếp tục", onDone)
+		continueBtn.Importance = widget.HighImportance
+		body = container.NewVBox(messageLabel, continueBtn)
+	}
+
+	cardContent := container.NewVBox(
+		container.NewCenter(headerIcon),
+		container.NewCenter(headerTitle),
+		container.NewPadded(layout.NewSpacer()),
+		body,
+	)
+
+	card := container.NewPadded(
+		container.NewStack(
+			cardBg,
+			container.NewPadded(
+				container.NewPadded(cardContent),
+			),
+		),
+	)
+
+	content := container.NewMax(
+		bg,
+		container.NewCenter(
+			container.NewVBox(
+				layout.NewSpacer(),
+				container.NewPadded(card),
+				layout.NewSpacer(),
+			),
+		),
+	)
+
+	window.SetContent(content)
+}
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"image/color"
 	"io"
+	"lab02_mahoa/client/account"
 	"lab02_mahoa/client/api"
 	"lab02_mahoa/client/crypto"
+	"lab02_mahoa/client/diff"
+	"log"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -147,7 +151,7 @@ func Screen(window fyne.Window, apiClient *api.Client, username string, onLogout
 			}
 
 			// Derive KEK (Key Encryption Key) from user password
-			kek := crypto.DeriveKeyFromPassword(api.CurrentPassword, nil)
+			kek := api.CurrentKEK
 
 			// Encrypt DEK with KEK
 			dekBase64 := base64.StdEncoding.EncodeToString(dek)
@@ -160,7 +164,7 @@ func Screen(window fyne.Window, apiClient *api.Client, username string, onLogout
 			statusLabel.SetText("⏳ Uploading...")
 			
 			// Upload to server
-			if err := apiClient.CreateNote(fileName, encryptedContent, iv, encryptedKey, ivKey); err != nil {
+			if err := apiClient.CreateNote(fileName, encryptedContent, iv, encryptedKey, ivKey, api.CurrentKDF.Descriptor()); err != nil {
 				statusLabel.SetText("❌ Upload error: " + err.Error())
 				return
 			}
@@ -202,13 +206,25 @@ func Screen(window fyne.Window, apiClient *api.Client, username string, onLogout
 
 	// Action buttons with modern style
 	logoutBtn := widget.NewButton("🚪 Logout", func() {
+		// Revoke the token on the server so it cannot be reused
+		if err := apiClient.Logout(); err != nil {
+			log.Printf("Warning: Failed to revoke token: %v", err)
+		}
 		apiClient.Token = ""
+		api.AuthToken = ""
+		api.CurrentKEK = nil
+		api.CurrentKeys = nil
 		onLogout()
 	})
 	logoutBtn.Importance = widget.DangerImportance
 
+	changePasswordBtn := widget.NewButton("🔑 Change Password", func() {
+		showChangePasswordDialog(window, apiClient, username)
+	})
+
 	actionBar := container.NewHBox(
 		layout.NewSpacer(),
+		changePasswordBtn,
 		logoutBtn,
 	)
 
@@ -273,11 +289,14 @@ func Screen(window fyne.Window, apiClient *api.Client, username string, onLogout
 	e2eeDesc.TextStyle = fyne.TextStyle{Italic: true}
 
 	refreshE2EEBtn := widget.NewButton("🔄 Refresh", refreshE2EEShares)
+	receiptsBtn := widget.NewButton("🧾 Read Receipts", func() {
+		showReadReceiptsDialog(window, apiClient)
+	})
 
 	e2eeContent := container.NewVBox(
 		e2eeTitle,
 		e2eeDesc,
-		refreshE2EEBtn,
+		container.NewHBox(refreshE2EEBtn, receiptsBtn),
 		widget.NewSeparator(),
 		e2eeScroll,
 		e2eeStatusLabel,
@@ -398,11 +417,26 @@ func createNoteCard(note api.Note, apiClient *api.Client, window fyne.Window, on
 	})
 	viewBtn.Importance = widget.HighImportance
 
+	// Edit button (decrypt, edit, re-encrypt and upload)
+	editBtn := widget.NewButton("✏️ Edit", func() {
+		showEditDialog(window, apiClient, note, onRefresh)
+	})
+
+	// History button (previous versions, diff and restore)
+	historyBtn := widget.NewButton("🕘 History", func() {
+		showHistoryDialog(window, apiClient, note, onRefresh)
+	})
+
 	// Share button (to create share link)
 	shareBtn := widget.NewButton("🔗 Share", func() {
 		showShareTypeDialog(window, apiClient, note, onRefresh)
 	})
 
+	// Links button (list, change or revoke single share links)
+	linksBtn := widget.NewButton("🔗 Links", func() {
+		showShareLinksDialog(window, apiClient, note, onRefresh)
+	})
+
 	// Revoke button (only enabled if shared)
 	revokeBtn := widget.NewButton("🚫 Revoke", func() {
 		if err := apiClient.RevokeShare(note.ID); err != nil {
@@ -436,7 +470,10 @@ func createNoteCard(note api.Note, apiClient *api.Client, window fyne.Window, on
 	// Button container with better spacing
 	buttonContainer := container.NewHBox(
 		viewBtn,
+		editBtn,
+		historyBtn,
 		shareBtn,
+		linksBtn,
 		revokeBtn,
 		layout.NewSpacer(),
 		deleteBtn,
@@ -524,8 +561,12 @@ func showDecryptDialog(window fyne.Window, apiClient *api.Client, note api.Note)
 			return
 		}
 
-		// Derive KEK from password
-		kek := crypto.DeriveKeyFromPassword(password, nil)
+		// Derive KEK from password, using the KDF recorded with the wrapped key
+		kek, err := crypto.NewKEKCache(password, api.CurrentKDF).KEK(noteDetail.KeyKDF)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to derive key: %w", err), window)
+			return
+		}
 
 		// Decrypt DEK
 		dekBase64, err := crypto.DecryptAES(noteDetail.EncryptedKey, noteDetail.EncryptedKeyIV, kek)
@@ -570,8 +611,12 @@ func showDecryptDialog(window fyne.Window, apiClient *api.Client, note api.Note)
 			return
 		}
 
-		// Derive KEK from password
-		kek := crypto.DeriveKeyFromPassword(password, nil)
+		// Derive KEK from password, using the KDF recorded with the wrapped key
+		kek, err := crypto.NewKEKCache(password, api.CurrentKDF).KEK(noteDetail.KeyKDF)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to derive key: %w", err), window)
+			return
+		}
 
 		// Decrypt DEK
 		dekBase64, err := crypto.DecryptAES(noteDetail.EncryptedKey, noteDetail.EncryptedKeyIV, kek)
@@ -625,6 +670,612 @@ func showDecryptDialog(window fyne.Window, apiClient *api.Client, note api.Note)
 	dlg.Show()
 }
 
+// showEditDialog decrypts a note, lets the user edit it and uploads the re-encrypted content
+func showEditDialog(window fyne.Window, apiClient *api.Client, note api.Note, onRefresh func()) {
+	// Get latest note (content and version) from server
+	fullNote, err := apiClient.GetNote(note.ID)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to get note: %w", err), window)
+		return
+	}
+
+	// Decrypt the DEK using user's password
+	kek, err := api.CurrentKeys.KEK(fullNote.KeyKDF)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to derive key: %w", err), window)
+		return
+	}
+	dek, err := crypto.UnwrapKey(fullNote.EncryptedKey, fullNote.EncryptedKeyIV, kek)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("❌ Wrong password or corrupted key"), window)
+		return
+	}
+
+	plaintext, err := crypto.DecryptAES(fullNote.EncryptedContent, fullNote.IV, dek)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("❌ Failed to decrypt content: %w", err), window)
+		return
+	}
+
+	// Title
+	titleLabel := widget.NewLabelWithStyle(
+		"✏️ Edit: "+note.Title,
+		fyne.TextAlignCenter,
+		fyne.TextStyle{Bold: true},
+	)
+
+	versionLabel := widget.NewLabel(fmt.Sprintf("Version %d", fullNote.Version))
+
+	// Editable content
+	contentEntry := widget.NewMultiLineEntry()
+	contentEntry.SetText(plaintext)
+	contentEntry.Wrapping = fyne.TextWrapWord
+
+	scrollContent := container.NewScroll(contentEntry)
+	scrollContent.SetMinSize(fyne.NewSize(600, 400))
+
+	statusLabel := widget.NewLabel("")
+	statusLabel.Wrapping = fyne.TextWrapWord
+
+	var d dialog.Dialog
+
+	saveBtn := widget.NewButton("💾 Encrypt & Save", func() {
+		newContent := contentEntry.Text
+		if newContent == plaintext {
+			d.Hide()
+			return
+		}
+
+		// Re-encrypt with the same DEK and a fresh IV
+		encryptedContent, iv, err := crypto.EncryptAES(newContent, dek)
+		if err != nil {
+			statusLabel.SetText("❌ Encryption error: " + err.Error())
+			return
+		}
+
+		statusLabel.SetText("⏳ Uploading...")
+
+		if _, err := apiClient.UpdateNote(note.ID, encryptedContent, iv, "", "", fullNote.Version); err != nil {
+			if err == api.ErrNoteConflict {
+				statusLabel.SetText("❌ This note was changed elsewhere while you were editing. Close and reopen the editor to load the latest version.")
+			} else {
+				statusLabel.SetText("❌ Upload error: " + err.Error())
+			}
+			return
+		}
+
+		d.Hide()
+		dialog.ShowInformation("✅ Success", "Note updated successfully", window)
+		onRefresh()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	content := container.NewVBox(
+		titleLabel,
+		versionLabel,
+		widget.NewSeparator(),
+		scrollContent,
+		statusLabel,
+		container.NewHBox(
+			layout.NewSpacer(),
+			widget.NewButton("Cancel", func() { d.Hide() }),
+			saveBtn,
+		),
+	)
+
+	d = dialog.NewCustomWithoutButtons("Edit Note", content, window)
+	d.Resize(fyne.NewSize(700, 600))
+	d.Show()
+}
+
+// showHistoryDialog lists previous versions of a note, shows a diff against the
+// current content and lets the user restore one
+func showHistoryDialog(window fyne.Window, apiClient *api.Client, note api.Note, onRefresh func()) {
+	// Get latest note (content and version) from server
+	fullNote, err := apiClient.GetNote(note.ID)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to get note: %w", err), window)
+		return
+	}
+
+	revisions, err := apiClient.ListNoteRevisions(note.ID)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to get history: %w", err), window)
+		return
+	}
+
+	if len(revisions) == 0 {
+		dialog.ShowInformation("🕘 History", "This note has no previous versions yet.", window)
+		return
+	}
+
+	// Decrypt the current content to diff against
+	kek, err := api.CurrentKeys.KEK(fullNote.KeyKDF)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to derive key: %w", err), window)
+		return
+	}
+	dek, err := crypto.UnwrapKey(fullNote.EncryptedKey, fullNote.EncryptedKeyIV, kek)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("❌ Wrong password or corrupted key"), window)
+		return
+	}
+
+	currentText, err := crypto.DecryptAES(fullNote.EncryptedContent, fullNote.IV, dek)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("❌ Failed to decrypt content: %w", err), window)
+		return
+	}
+
+	// Title
+	titleLabel := widget.NewLabelWithStyle(
+		"🕘 History: "+note.Title,
+		fyne.TextAlignCenter,
+		fyne.TextStyle{Bold: true},
+	)
+
+	currentLabel := widget.NewLabel(fmt.Sprintf("Current version: %d", fullNote.Version))
+
+	// Diff view ("-" lines were removed since that version, "+" lines were added)
+	diffEntry := widget.NewMultiLineEntry()
+	diffEntry.SetPlaceHolder("Select a version to compare it with the current content")
+	diffEntry.TextStyle = fyne.TextStyle{Monospace: true}
+
+	diffScroll := container.NewScroll(diffEntry)
+	diffScroll.SetMinSize(fyne.NewSize(500, 400))
+
+	statusLabel := widget.NewLabel("")
+	statusLabel.Wrapping = fyne.TextWrapWord
+
+	var d dialog.Dialog
+	var selected *api.NoteRevision
+
+	restoreBtn := widget.NewButton("↩️ Restore This Version", func() {
+		if selected == nil {
+			return
+		}
+		revision := *selected
+
+		dialog.ShowConfirm("↩️ Restore Version",
+			fmt.Sprintf("Replace the current content with version %d?\n\nThe current content will be kept in the history.", revision.Version),
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+
+				if _, err := apiClient.RestoreNoteRevision(note.ID, revision.ID, fullNote.Version); err != nil {
+					if err == api.ErrNoteConflict {
+						statusLabel.SetText("❌ This note was changed elsewhere. Close and reopen the history to load the latest version.")
+					} else {
+						statusLabel.SetText("❌ Restore error: " + err.Error())
+					}
+					return
+				}
+
+				d.Hide()
+				dialog.ShowInformation("✅ Success", fmt.Sprintf("Version %d restored", revision.Version), window)
+				onRefresh()
+			}, window)
+	})
+	restoreBtn.Importance = widget.HighImportance
+	restoreBtn.Disable()
+
+	revisionList := widget.NewList(
+		func() int { return len(revisions) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			revision := revisions[id]
+			obj.(*widget.Label).SetText(fmt.Sprintf("v%d • %s", revision.Version, revision.SavedAt.Local().Format("Jan 02, 2006 15:04")))
+		},
+	)
+	revisionList.OnSelected = func(id widget.ListItemID) {
+		selected = nil
+		restoreBtn.Disable()
+		statusLabel.SetText("⏳ Decrypting...")
+
+		revision, err := apiClient.GetNoteRevision(note.ID, revisions[id].ID)
+		if err != nil {
+			statusLabel.SetText("❌ Failed to get version: " + err.Error())
+			return
+		}
+
+		// Each revision carries its own wrapped DEK and KDF descriptor
+		revisionKEK, err := api.CurrentKeys.KEK(revision.KeyKDF)
+		if err != nil {
+			statusLabel.SetText("❌ Failed to derive the key of this version")
+			return
+		}
+		revisionDEK, err := crypto.UnwrapKey(revision.EncryptedKey, revision.EncryptedKeyIV, revisionKEK)
+		if err != nil {
+			statusLabel.SetText("❌ Failed to unwrap the key of this version")
+			return
+		}
+
+		revisionText, err := crypto.DecryptAES(revision.EncryptedContent, revision.IV, revisionDEK)
+		if err != nil {
+			statusLabel.SetText("❌ Failed to decrypt this version: " + err.Error())
+			return
+		}
+
+		lines := diff.Lines(revisionText, currentText)
+		if diff.HasChanges(lines) {
+			diffEntry.SetText(diff.Format(lines))
+		} else {
+			diffEntry.SetText(revisionText)
+		}
+
+		selected = &revision
+		restoreBtn.Enable()
+		statusLabel.SetText(fmt.Sprintf("Comparing version %d with the current content", revision.Version))
+	}
+
+	listScroll := container.NewScroll(revisionList)
+	listScroll.SetMinSize(fyne.NewSize(220, 400))
+
+	content := container.NewBorder(
+		container.NewVBox(titleLabel, currentLabel, widget.NewSeparator()),
+		container.NewVBox(
+			statusLabel,
+			container.NewHBox(
+				layout.NewSpacer(),
+				widget.NewButton("Close", func() { d.Hide() }),
+				restoreBtn,
+			),
+		),
+		listScroll,
+		nil,
+		diffScroll,
+	)
+
+	d = dialog.NewCustomWithoutButtons("Note History", content, window)
+	d.Resize(fyne.NewSize(850, 600))
+	d.Show()
+}
+
+// showShareLinksDialog lists the share links of a note and lets the owner change
+// the expiry or access limit of one link, revoke it alone, or see who opened it
+func showShareLinksDialog(window fyne.Window, apiClient *api.Client, note api.Note, onRefresh func()) {
+	links, err := apiClient.ListShareLinks(note.ID)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to get share links: %w", err), window)
+		return
+	}
+
+	// Title
+	titleLabel := widget.NewLabelWithStyle(
+		"🔗 Share Links: "+note.Title,
+		fyne.TextAlignCenter,
+		fyne.TextStyle{Bold: true},
+	)
+
+	// The access log stays available after every link has expired or been revoked
+	detailsLabel := widget.NewLabel("Select a link to see its details")
+	if len(links) == 0 {
+		detailsLabel.SetText("This note has no share links.")
+	}
+	detailsLabel.Wrapping = fyne.TextWrapWord
+
+	hoursEntry := widget.NewEntry()
+	hoursEntry.SetPlaceHolder("Expire in N hours from now (e.g. 48)")
+	maxAccessEntry := widget.NewEntry()
+	maxAccessEntry.SetPlaceHolder("Max accesses (0 = unlimited)")
+
+	statusLabel := widget.NewLabel("")
+	statusLabel.Wrapping = fyne.TextWrapWord
+
+	var d dialog.Dialog
+	var selected *api.ShareLinkInfo
+
+	saveBtn := widget.NewButton("💾 Save Changes", nil)
+	saveBtn.Importance = widget.HighImportance
+	revokeBtn := widget.NewButton("🚫 Revoke This Link", nil)
+	revokeBtn.Importance = widget.DangerImportance
+	linkLogBtn := widget.NewButton("📜 Link Access Log", nil)
+
+	showDetails := func(link api.ShareLinkInfo) {
+		limit := "unlimited"
+		if link.MaxAccessCount > 0 {
+			limit = strconv.Itoa(link.MaxAccessCount)
+		}
+		details := fmt.Sprintf("🔑 Token: %s...\n📅 Created: %s\n⏰ Expires: %s\n👁️ Accesses: %d / %s\n📌 Status: %s",
+			link.ShareToken[:min(10, len(link.ShareToken))],
+			link.CreatedAt.Local().Format("Jan 02, 2006 15:04"),
+			link.ExpiresAt.Local().Format("Jan 02, 2006 15:04"),
+			link.AccessCount, limit, link.Status)
+		if link.RequirePassword {
+			details += "\n🔒 Password protected"
+		}
+		if link.BurnAfterReading {
+			details += "\n🔥 Burn after reading"
+			if link.ReadAt != nil {
+				details += ", read " + link.ReadAt.Local().Format("Jan 02, 2006 15:04")
+			}
+		}
+		detailsLabel.SetText(details)
+
+		hoursEntry.SetText("")
+		maxAccessEntry.SetText(strconv.Itoa(link.MaxAccessCount))
+		if link.BurnAfterReading {
+			// A burn link is opened once; only its expiry can change
+			maxAccessEntry.Disable()
+		} else {
+			maxAccessEntry.Enable()
+		}
+	}
+
+	linkList := widget.NewList(
+		func() int { return len(links) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			link := links[id]
+			icon := "🔗"
+			if link.BurnAfterReading {
+				icon = "🔥"
+			} else if link.RequirePassword {
+				icon = "🔒"
+			}
+			obj.(*widget.Label).SetText(fmt.Sprintf("%s #%d • %s • %s", icon, link.ID, link.Status, link.ExpiresAt.Local().Format("Jan 02 15:04")))
+		},
+	)
+
+	// reload fetches the links again after a change and clears the selection
+	reload := func() {
+		selected = nil
+		saveBtn.Disable()
+		revokeBtn.Disable()
+		linkLogBtn.Disable()
+		linkList.UnselectAll()
+		detailsLabel.SetText("Select a link to see its details")
+
+		updated, err := apiClient.ListShareLinks(note.ID)
+		if err != nil {
+			statusLabel.SetText("❌ Failed to reload links: " + err.Error())
+			return
+		}
+		links = updated
+		linkList.Refresh()
+		onRefresh()
+	}
+
+	linkList.OnSelected = func(id widget.ListItemID) {
+		link := links[id]
+		selected = &link
+		showDetails(link)
+		saveBtn.Enable()
+		revokeBtn.Enable()
+		linkLogBtn.Enable()
+		statusLabel.SetText("")
+	}
+
+	saveBtn.OnTapped = func() {
+		if selected == nil {
+			return
+		}
+
+		var update api.UpdateShareLinkRequest
+		if text := strings.TrimSpace(hoursEntry.Text); text != "" {
+			hours, err := strconv.Atoi(text)
+			if err != nil || hours <= 0 {
+				statusLabel.SetText("❌ Hours must be a positive number")
+				return
+			}
+			update.DurationHours = &hours
+		}
+		if text := strings.TrimSpace(maxAccessEntry.Text); !selected.BurnAfterReading && text != "" {
+			maxAccess, err := strconv.Atoi(text)
+			if err != nil || maxAccess < 0 {
+				statusLabel.SetText("❌ Max accesses must be 0 or more")
+				return
+			}
+			if maxAccess != selected.MaxAccessCount {
+				update.MaxAccessCount = &maxAccess
+			}
+		}
+		if update.DurationHours == nil && update.MaxAccessCount == nil {
+			statusLabel.SetText("Nothing to change")
+			return
+		}
+
+		link, err := apiClient.UpdateShareLink(note.ID, selected.ID, update)
+		if err != nil {
+			statusLabel.SetText("❌ Update error: " + err.Error())
+			return
+		}
+		reload()
+		statusLabel.SetText(fmt.Sprintf("✅ Link #%d now expires %s", link.ID, link.ExpiresAt.Local().Format("Jan 02, 2006 15:04")))
+	}
+
+	revokeBtn.OnTapped = func() {
+		if selected == nil {
+			return
+		}
+		link := *selected
+
+		dialog.ShowConfirm("🚫 Revoke Link",
+			fmt.Sprintf("Revoke link #%d?\n\nAnyone holding it will no longer be able to open the note. The other links keep working.", link.ID),
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+
+				if err := apiClient.DeleteShareLink(note.ID, link.ID); err != nil {
+					statusLabel.SetText("❌ Revoke error: " + err.Error())
+					return
+				}
+				reload()
+				statusLabel.SetText(fmt.Sprintf("✅ Link #%d revoked", link.ID))
+			}, window)
+	}
+
+	linkLogBtn.OnTapped = func() {
+		if selected == nil {
+			return
+		}
+		events, err := apiClient.ListShareLinkAccessLog(note.ID, selected.ID)
+		if err != nil {
+			statusLabel.SetText("❌ Failed to get access log: " + err.Error())
+			return
+		}
+		showAccessLogDialog(window, fmt.Sprintf("📜 Access Log: link #%d", selected.ID), events)
+	}
+
+	noteLogBtn := widget.NewButton("📜 Note Access Log", func() {
+		events, err := apiClient.ListNoteAccessLog(note.ID)
+		if err != nil {
+			statusLabel.SetText("❌ Failed to get access log: " + err.Error())
+			return
+		}
+		showAccessLogDialog(window, "📜 Access Log: "+note.Title, events)
+	})
+
+	saveBtn.Disable()
+	revokeBtn.Disable()
+	linkLogBtn.Disable()
+
+	listScroll := container.NewScroll(linkList)
+	listScroll.SetMinSize(fyne.NewSize(300, 350))
+
+	form := container.NewVBox(
+		detailsLabel,
+		widget.NewSeparator(),
+		widget.NewLabel("⏰ New expiry:"),
+		hoursEntry,
+		widget.NewLabel("🔢 Max accesses:"),
+		maxAccessEntry,
+	)
+
+	content := container.NewBorder(
+		container.NewVBox(titleLabel, widget.NewSeparator()),
+		container.NewVBox(
+			statusLabel,
+			container.NewHBox(
+				revokeBtn,
+				linkLogBtn,
+				noteLogBtn,
+				layout.NewSpacer(),
+				widget.NewButton("Close", func() { d.Hide() }),
+				saveBtn,
+			),
+		),
+		listScroll,
+		nil,
+		container.NewPadded(form),
+	)
+
+	d = dialog.NewCustomWithoutButtons("Share Links", content, window)
+	d.Resize(fyne.NewSize(900, 550))
+	d.Show()
+}
+
+// showAccessLogDialog lists attempts to open share links, newest first
+func showAccessLogDialog(window fyne.Window, title string, events []api.ShareAccessEvent) {
+	if len(events) == 0 {
+		dialog.ShowInformation(title, "Nobody has tried to open these share links yet.", window)
+		return
+	}
+
+	failed := 0
+	for _, event := range events {
+		if event.Outcome != "success" {
+			failed++
+		}
+	}
+	summaryLabel := widget.NewLabel(fmt.Sprintf("%d attempts, %d refused", len(events), failed))
+
+	eventList := widget.NewList(
+		func() int { return len(events) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			event := events[id]
+			icon := "✅"
+			if event.Outcome != "success" {
+				icon = "❌"
+			}
+			userAgent := event.UserAgent
+			if runes := []rune(userAgent); len(runes) > 50 {
+				userAgent = string(runes[:47]) + "..."
+			}
+			obj.(*widget.Label).SetText(fmt.Sprintf("%s %s • link #%d • %s • %s • %s",
+				icon, event.CreatedAt.Local().Format("Jan 02, 2006 15:04:05"), event.LinkID, event.Outcome, event.IP, userAgent))
+		},
+	)
+
+	content := container.NewBorder(summaryLabel, nil, nil, nil, eventList)
+
+	d := dialog.NewCustom(title, "Close", content, window)
+	d.Resize(fyne.NewSize(850, 500))
+	d.Show()
+}
+
+// showChangePasswordDialog changes the account password, re-wrapping every note key
+// and re-encrypting the local keystore
+func showChangePasswordDialog(window fyne.Window, apiClient *api.Client, username string) {
+	oldPasswordEntry := widget.NewPasswordEntry()
+	oldPasswordEntry.SetPlaceHolder("Current password")
+
+	newPasswordEntry := widget.NewPasswordEntry()
+	newPasswordEntry.SetPlaceHolder("New password (at least 6 characters)")
+
+	confirmEntry := widget.NewPasswordEntry()
+	confirmEntry.SetPlaceHolder("Confirm new password")
+
+	statusLabel := widget.NewLabel("")
+	statusLabel.Wrapping = fyne.TextWrapWord
+
+	var d dialog.Dialog
+
+	changeBtn := widget.NewButton("🔑 Change Password", nil)
+	changeBtn.Importance = widget.HighImportance
+	changeBtn.OnTapped = func() {
+		if len(newPasswordEntry.Text) < 6 {
+			statusLabel.SetText("❌ New password must be at least 6 characters")
+			return
+		}
+		if newPasswordEntry.Text != confirmEntry.Text {
+			statusLabel.SetText("❌ New passwords do not match")
+			return
+		}
+
+		statusLabel.SetText("⏳ Re-encrypting keys...")
+		changeBtn.Disable()
+
+		go func() {
+			kek, err := account.ChangePassword(apiClient, username, oldPasswordEntry.Text, newPasswordEntry.Text, api.CurrentKDF)
+			fyne.Do(func() {
+				changeBtn.Enable()
+				if err != nil {
+					statusLabel.SetText("❌ " + err.Error())
+					return
+				}
+
+				api.CurrentPassword = newPasswordEntry.Text
+				api.CurrentKEK = kek
+				api.CurrentKeys = crypto.NewKEKCache(newPasswordEntry.Text, api.CurrentKDF)
+				api.CurrentKeys.Remember(api.CurrentKDF, kek)
+				d.Hide()
+				dialog.ShowInformation("✅ Success", "Password changed. All your notes are now protected by the new password.", window)
+			})
+		}()
+	}
+
+	content := container.NewVBox(
+		widget.NewLabel("All note keys and your local keystore will be re-encrypted."),
+		oldPasswordEntry,
+		newPasswordEntry,
+		confirmEntry,
+		statusLabel,
+		container.NewHBox(
+			layout.NewSpacer(),
+			widget.NewButton("Cancel", func() { d.Hide() }),
+			changeBtn,
+		),
+	)
+
+	d = dialog.NewCustomWithoutButtons("Change Password", content, window)
+	d.Resize(fyne.NewSize(450, 320))
+	d.Show()
+}
+
 // showDecryptedContent shows the decrypted content in a dialog
 func showDecryptedContent(window fyne.Window, title string, content string) {
 	// Title
@@ -727,6 +1378,16 @@ func showShareDurationDialog(window fyne.Window, apiClient *api.Client, note api
 		}
 	}
 
+	// Burn after reading: one view, then the server destroys its copy
+	burnCheck := widget.NewCheck("🔥 Burn after reading (one view, then deleted from the server)", func(checked bool) {
+		if checked {
+			maxAccessCheck.SetChecked(false)
+			maxAccessCheck.Disable()
+		} else {
+			maxAccessCheck.Enable()
+		}
+	})
+
 	// Title
 	title := widget.NewLabelWithStyle(fmt.Sprintf("📄 Share: %s", note.Title),
 		fyne.TextAlignCenter,
@@ -749,6 +1410,8 @@ func showShareDurationDialog(window fyne.Window, apiClient *api.Client, note api
 		maxAccessCheck,
 		maxAccessEntry,
 		widget.NewSeparator(),
+		burnCheck,
+		widget.NewSeparator(),
 	)
 
 	// Create and Cancel buttons
@@ -782,7 +1445,14 @@ func showShareDurationDialog(window fyne.Window, apiClient *api.Client, note api
 			}
 			
 			// Decrypt the DEK using user's password
-			kek := crypto.DeriveKeyFromPassword(api.CurrentPassword, nil)
+			kek, err := api.CurrentKeys.KEK(fullNote.KeyKDF)
+			if err != nil {
+				fyne.Do(func() {
+					progressDialog.Hide()
+					dialog.ShowError(fmt.Errorf("failed to derive key: %w", err), window)
+				})
+				return
+			}
 			dekBase64, err := crypto.DecryptAES(fullNote.EncryptedKey, fullNote.EncryptedKeyIV, kek)
 			if err != nil {
 				fyne.Do(func() {
@@ -829,9 +1499,23 @@ func showShareDurationDialog(window fyne.Window, apiClient *api.Client, note api
 				}
 			}
 			
+			// The link carries the note key, except for burn links which
+			// carry the one-time key of their own copy
+			linkKey := dekBase64
+
 			// Use appropriate API based on options
 			// If password or max_access is set, ALWAYS use CreateShareWithOptions
-			if password != "" || maxAccessCount > 0 {
+			if burnCheck.Checked {
+				var encryptedContent, iv string
+				encryptedContent, iv, linkKey, err = encryptBurnCopy(fullNote, dekBase64)
+				if err == nil {
+					if useMinutes {
+						// Like the other options, the server takes whole hours here
+						hours = 1
+					}
+					shareToken, err = apiClient.CreateBurnShare(note.ID, hours, password, encryptedContent, iv)
+				}
+			} else if password != "" || maxAccessCount > 0 {
 				if useMinutes {
 					// For 3 minutes with password/max_access, use CreateShareWithOptions with 1 hour minimum
 					// Note: Server doesn't support minutes with options, so use 1 hour instead
@@ -857,13 +1541,16 @@ func showShareDurationDialog(window fyne.Window, apiClient *api.Client, note api
 				}
 				
 				// Create share URL with encryption key in fragment
-				shareURL := fmt.Sprintf("http://localhost:8080/api/shares/%s#key=%s", shareToken, dekBase64)
+				shareURL := apiClient.ShareLink(shareToken, linkKey)
 				
 				// Prepare additional info
 				additionalInfo := ""
 				if password != "" {
 					additionalInfo += "🔒 Password protected\n"
 				}
+				if burnCheck.Checked {
+					additionalInfo += "🔥 Burn after reading: works once, see 🧾 Read Receipts\n"
+				}
 				if maxAccessCount > 0 {
 					additionalInfo += fmt.Sprintf("🔢 Max accesses: %d\n", maxAccessCount)
 				}
@@ -895,6 +1582,29 @@ func showShareDurationDialog(window fyne.Window, apiClient *api.Client, note api
 	d.Show()
 }
 
+// encryptBurnCopy encrypts the note again under a fresh one-time key for a
+// burn-after-reading link, so the link never reveals the note's own key
+func encryptBurnCopy(note api.Note, dekBase64 string) (encryptedContent, iv, keyBase64 string, err error) {
+	dek, err := base64.StdEncoding.DecodeString(dekBase64)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid encryption key: %w", err)
+	}
+	plaintext, err := crypto.DecryptAES(note.EncryptedContent, note.IV, dek)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to decrypt note: %w", err)
+	}
+
+	oneTimeKey, err := crypto.GenerateKey()
+	if err != nil {
+		return "", "", "", err
+	}
+	encryptedContent, iv, err = crypto.EncryptAES(plaintext, oneTimeKey)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return encryptedContent, iv, base64.StdEncoding.EncodeToString(oneTimeKey), nil
+}
+
 // showShareResultDialog displays the final share link with copy functionality
 func showShareResultDialog(window fyne.Window, shareURL string, noteTitle string, duration string, additionalInfo string) {
 	// Create title
@@ -1099,6 +1809,9 @@ func showE2EEShareDialog(window fyne.Window, apiClient *api.Client, note api.Not
 	usernameEntry := widget.NewEntry()
 	usernameEntry.SetPlaceHolder("Recipient username")
 
+	// Burn after reading: destroyed on the server once the recipient opens it
+	burnCheck := widget.NewCheck("🔥 Burn after reading", nil)
+
 	// Status label
 	statusLabel := widget.NewLabel("")
 	statusLabel.Wrapping = fyne.TextWrapWord
@@ -1121,7 +1834,11 @@ func showE2EEShareDialog(window fyne.Window, apiClient *api.Client, note api.Not
 		}
 
 		// Decrypt the note with user's password
-		kek := crypto.DeriveKeyFromPassword(api.CurrentPassword, nil)
+		kek, err := api.CurrentKeys.KEK(fullNote.KeyKDF)
+		if err != nil {
+			statusLabel.SetText("❌ " + err.Error())
+			return
+		}
 		dekBase64, err := crypto.DecryptAES(fullNote.EncryptedKey, fullNote.EncryptedKeyIV, kek)
 		if err != nil {
 			statusLabel.SetText("❌ Wrong password or corrupted key")
@@ -1164,15 +1881,22 @@ func showE2EEShareDialog(window fyne.Window, apiClient *api.Client, note api.Not
 			return
 		}
 
+		// A burn share uses a one-time key pair, so the shared secret exists for this share only
+		senderPrivateKey := api.CurrentDHPrivateKey
+		if burnCheck.Checked {
+			pair, err := crypto.GenerateDHKeyPair()
+			if err != nil {
+				statusLabel.SetText("❌ Key generation failed: " + err.Error())
+				return
+			}
+			senderPrivateKey = pair.PrivateKey
+		}
+
 		// Get sender's public key for sending to server
-		senderPubKeyBase64 := crypto.PublicKeyToBase64(api.CurrentDHPrivateKey.PublicKey())
-		
-		// Debug: Log public keys
-		fmt.Printf("DEBUG Create - Sender public key: %s\n", senderPubKeyBase64[:20]+"...")
-		fmt.Printf("DEBUG Create - Recipient public key: %s\n", recipientPubKeyBase64[:20]+"...")
+		senderPubKeyBase64 := crypto.PublicKeyToBase64(senderPrivateKey.PublicKey())
 
 		// Compute shared secret using sender's private key and recipient's public key
-		sharedSecret, err := crypto.ComputeSharedSecret(api.CurrentDHPrivateKey, recipientPubKey)
+		sharedSecret, err := crypto.ComputeSharedSecret(senderPrivateKey, recipientPubKey)
 		if err != nil {
 			statusLabel.SetText("❌ Shared secret computation failed: " + err.Error())
 			return
@@ -1186,8 +1910,6 @@ func showE2EEShareDialog(window fyne.Window, apiClient *api.Client, note api.Not
 			}
 		}()
 
-		fmt.Printf("DEBUG Create - Shared secret: %x\n", sharedSecret[:8])
-
 		// Encrypt content with shared secret
 		encryptedContent, contentIV, err := crypto.EncryptWithSharedSecret(plaintext, sharedSecret)
 		if err != nil {
@@ -1196,7 +1918,7 @@ func showE2EEShareDialog(window fyne.Window, apiClient *api.Client, note api.Not
 		}
 
 		// Send to server - include sender's public key so recipient can compute shared secret
-		shareID, err := apiClient.CreateE2EEShare(note.ID, recipientUsername, senderPubKeyBase64, encryptedContent, contentIV, 24)
+		shareID, err := apiClient.CreateE2EEShare(note.ID, recipientUsername, senderPubKeyBase64, encryptedContent, contentIV, 24, burnCheck.Checked)
 		if err != nil {
 			statusLabel.SetText("❌ Failed to create share: " + err.Error())
 			return
@@ -1224,6 +1946,7 @@ func showE2EEShareDialog(window fyne.Window, apiClient *api.Client, note api.Not
 		widget.NewLabel(""),
 		widget.NewLabel("Recipient Username:"),
 		usernameEntry,
+		burnCheck,
 		widget.NewLabel(""),
 		shareBtn,
 		statusLabel,
@@ -1260,6 +1983,13 @@ func createE2EEShareCard(share api.E2EEShare, apiClient *api.Client, window fyne
 		container.NewHBox(timeText, widget.NewLabel("  •  "), expiryText),
 	)
 
+	if share.BurnAfterReading {
+		burnText := canvas.NewText("🔥 Burn after reading: deleted from the server once opened", color.RGBA{R: 234, G: 88, B: 12, A: 255})
+		burnText.TextSize = 11
+		burnText.TextStyle = fyne.TextStyle{Bold: true}
+		infoContainer.Add(burnText)
+	}
+
 	// Decrypt button
 	decryptBtn := widget.NewButton("🔓 Decrypt & View", func() {
 		showE2EEDecryptDialog(window, apiClient, share, onRefresh)
@@ -1296,6 +2026,9 @@ func showE2EEDecryptDialog(window fyne.Window, apiClient *api.Client, share api.
 		fyne.TextAlignCenter, fyne.TextStyle{Italic: true})
 
 	infoLabel := widget.NewLabel("This note was shared using Diffie-Hellman key exchange.\nGenerating shared secret to decrypt...")
+	if share.BurnAfterReading {
+		infoLabel.SetText(infoLabel.Text + "\n\n🔥 Burn after reading: decrypting deletes it from the server, copy the content if you need it.")
+	}
 	infoLabel.Wrapping = fyne.TextWrapWord
 
 	statusLabel := widget.NewLabel("")
@@ -1328,11 +2061,19 @@ func showE2EEDecryptDialog(window fyne.Window, apiClient *api.Client, share api.
 			return
 		}
 
-		// Debug: Log recipient's public key
-		recipientPubKey := api.CurrentDHPrivateKey.PublicKey()
-		recipientPubKeyBase64 := crypto.PublicKeyToBase64(recipientPubKey)
-		fmt.Printf("DEBUG Decrypt - Recipient public key: %s\n", recipientPubKeyBase64[:20]+"...")
-		fmt.Printf("DEBUG Decrypt - Sender public key: %s\n", share.SenderPublicKey[:20]+"...")
+		// Burn shares are listed without their content: opening one fetches it
+		// and makes the server destroy its copy
+		if share.BurnAfterReading && share.EncryptedContent == "" {
+			opened, err := apiClient.GetE2EEShare(share.ID)
+			if err != nil {
+				statusLabel.SetText("❌ Failed to open share: " + err.Error())
+				return
+			}
+			share = opened
+			if onRefresh != nil {
+				go onRefresh()
+			}
+		}
 
 		// Parse sender's public key
 		senderPubKey, err := crypto.PublicKeyFromBase64(share.SenderPublicKey)
@@ -1356,8 +2097,6 @@ func showE2EEDecryptDialog(window fyne.Window, apiClient *api.Client, share api.
 			}
 		}()
 
-		fmt.Printf("DEBUG Decrypt - Shared secret: %x\n", sharedSecret[:8])
-
 		statusLabel.SetText("⏳ Decrypting content...")
 
 		// Decrypt content with shared secret
@@ -1368,6 +2107,9 @@ func showE2EEDecryptDialog(window fyne.Window, apiClient *api.Client, share api.
 		}
 
 		statusLabel.SetText("✅ Decrypted successfully!")
+		if share.Burned {
+			statusLabel.SetText("✅ Decrypted successfully! 🔥 The server copy has been destroyed.")
+		}
 		isUpdating = true
 		lastValidContent = plaintext
 		contentArea.SetText(plaintext)
@@ -1407,6 +2149,45 @@ func showE2EEDecryptDialog(window fyne.Window, apiClient *api.Client, share api.
 	customDialog.Show()
 }
 
+// showReadReceiptsDialog lists the user's burn-after-reading shares and when they were read
+func showReadReceiptsDialog(window fyne.Window, apiClient *api.Client) {
+	receipts, err := apiClient.ListReadReceipts()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to load read receipts: %w", err), window)
+		return
+	}
+
+	list := container.NewVBox()
+	if len(receipts) == 0 {
+		list.Add(container.NewCenter(widget.NewLabel("📭 No burn-after-reading shares yet")))
+	}
+	for _, receipt := range receipts {
+		kind := "🔗 Link"
+		if receipt.Type == "e2ee" {
+			kind = "🔐 E2EE to " + receipt.Recipient
+		}
+		status := "⏳ Not read yet (expires " + receipt.ExpiresAt.Local().Format("Jan 02 15:04") + ")"
+		if receipt.ReadAt != nil {
+			status = "🔥 Read and destroyed " + receipt.ReadAt.Local().Format("Jan 02, 2006 15:04")
+		}
+
+		titleLabel := widget.NewLabelWithStyle(fmt.Sprintf("📄 %s", receipt.NoteTitle), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		list.Add(container.NewVBox(
+			titleLabel,
+			widget.NewLabel(fmt.Sprintf("%s • created %s", kind, receipt.CreatedAt.Local().Format("Jan 02 15:04"))),
+			widget.NewLabel(status),
+			widget.NewSeparator(),
+		))
+	}
+
+	scroll := container.NewScroll(list)
+	scroll.SetMinSize(fyne.NewSize(500, 350))
+
+	d := dialog.NewCustom("🧾 Read Receipts", "Close", scroll, window)
+	d.Resize(fyne.NewSize(560, 450))
+	d.Show()
+}
+
 // createSharedLinkViewer creates the shared link viewer section
 func createSharedLinkViewer(window fyne.Window, apiClient *api.Client) fyne.CanvasObject {
 	// Background
@@ -1466,11 +2247,12 @@ func createSharedLinkViewer(window fyne.Window, apiClient *api.Client) fyne.Canv
 		}
 		
 		// Extract token from URL if full URL provided
+		// (the browser viewer's /share/ links or the API's /shares/ ones)
 		shareToken := tokenInput
-		if strings.Contains(tokenInput, "/shares/") {
-			parts := strings.Split(tokenInput, "/shares/")
-			if len(parts) == 2 {
-				shareToken = parts[1]
+		for _, prefix := range []string{"/shares/", "/share/"} {
+			if i := strings.LastIndex(tokenInput, prefix); i >= 0 {
+				shareToken = strings.Trim(tokenInput[i+len(prefix):], "/")
+				break
 			}
 		}
 		
@@ -1578,6 +2360,11 @@ func displaySharedNote(window fyne.Window, sharedNote api.SharedNote, encryption
 		createdLabel,
 		expiresLabel,
 	)
+	if sharedNote.Burned {
+		burnedLabel := widget.NewLabel("🔥 Burn after reading: the server copy was destroyed, this link will not open again")
+		burnedLabel.Wrapping = fyne.TextWrapWord
+		infoContent.Add(burnedLabel)
+	}
 	
 	infoCard := container.NewMax(
 		infoBg,
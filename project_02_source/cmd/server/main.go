@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/config"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// Load configuration: defaults, config file, environment, then flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+
+	// Stop on SIGINT/SIGTERM; a second signal kills the process right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := srv.Run(ctx); err != nil {
+		os.Exit(1)
+	}
+}
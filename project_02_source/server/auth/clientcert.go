@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/models"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// AuthenticateClientCert maps a verified TLS client certificate to the user
+// whose username is the certificate's common name. Only the automation
+// accounts listed in allowed may log in this way, and never an account with
+// two-factor login, which a certificate would otherwise bypass. The certificate
+// has already been checked against the configured client CA bundle.
+func AuthenticateClientCert(db *gorm.DB, cert *x509.Certificate, allowed []string) (*Claims, error) {
+	username := cert.Subject.CommonName
+	if username == "" {
+		return nil, errors.New("client certificate has no common name")
+	}
+	if !slices.Contains(allowed, username) {
+		return nil, fmt.Errorf("client certificate login is not enabled for %q", username)
+	}
+
+	var user models.User
+	if err := db.Select("id", "username", "totp_enabled").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("no account for client certificate %q", username)
+		}
+		return nil, fmt.Errorf("failed to look up client certificate user: %w", err)
+	}
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("client certificate login is not allowed for %q: the account uses two-factor login", username)
+	}
+
+	return &Claims{UserID: user.ID, Username: user.Username}, nil
+}
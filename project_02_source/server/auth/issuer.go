@@ -0,0 +1,31 @@
+package auth
+
+import "time"
+
+// Issuer signs and checks tokens with one key set, one pair of token lifetimes
+// and one clock. A server instance owns its own Issuer; the package-level token
+// functions use DefaultIssuer, which follows SetKeySet and the lifetime variables.
+type Issuer struct {
+	Keys            *KeySet
+	AccessLifetime  time.Duration
+	RefreshLifetime time.Duration
+	Now             func() time.Time // Defaults to time.Now
+}
+
+// DefaultIssuer returns an Issuer built from the package-level settings
+func DefaultIssuer() *Issuer {
+	return &Issuer{
+		Keys:            CurrentKeySet(),
+		AccessLifetime:  AccessTokenLifetime,
+		RefreshLifetime: RefreshTokenLifetime,
+		Now:             time.Now,
+	}
+}
+
+// now returns the issuer's current time
+func (i *Issuer) now() time.Time {
+	if i.Now == nil {
+		return time.Now()
+	}
+	return i.Now()
+}
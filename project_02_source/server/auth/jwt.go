@@ -1,43 +1,58 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWT Secret Key (In production, this should be stored securely in environment variables)
-var jwtSecret = []byte("your-secret-key-change-this-in-production")
-
 // Claims represents JWT token claims
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	TokenVersion int    `json:"tv,omitempty"` // Must match the user's token version (see RevokeAllTokens)
 	jwt.RegisteredClaims
 }
 
 // GenerateJWT generates a JWT token for the user
 func GenerateJWT(userID uint, username string) (string, error) {
-	// Token expires in 24 hours
-	expirationTime := time.Now().Add(24 * time.Hour)
+	return GenerateJWTWithVersion(userID, username, 0)
+}
+
+// GenerateJWTWithVersion generates a JWT token bound to the user's current token version
+func GenerateJWTWithVersion(userID uint, username string, tokenVersion int) (string, error) {
+	return DefaultIssuer().GenerateJWT(userID, username, tokenVersion)
+}
+
+// GenerateJWT generates a JWT token bound to the user's current token version
+func (i *Issuer) GenerateJWT(userID uint, username string, tokenVersion int) (string, error) {
+	// Access tokens are short-lived; clients renew them with a refresh token
+	now := i.now()
+	expirationTime := now.Add(i.AccessLifetime)
+
+	// Random token ID so a single token can be revoked
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
 
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:       userID,
+		Username:     username,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign token with secret key
-	tokenString, err := token.SignedString(jwtSecret)
+	// Sign token with the active key (its kid goes in the header)
+	tokenString, err := i.Keys.Sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -47,16 +62,15 @@ func GenerateJWT(userID uint, username string) (string, error) {
 
 // ValidateJWT validates a JWT token and returns the claims
 func ValidateJWT(tokenString string) (*Claims, error) {
+	return DefaultIssuer().ValidateJWT(tokenString)
+}
+
+// ValidateJWT validates a JWT token against the issuer's keys and clock and returns the claims
+func (i *Issuer) ValidateJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
-	})
+	// Parse token, verifying it with the key named by its kid
+	token, err := jwt.ParseWithClaims(tokenString, claims, i.Keys.keyFunc, jwt.WithTimeFunc(i.now))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
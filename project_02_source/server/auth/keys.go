@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported JWT signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+	AlgES256 = "ES256"
+)
+
+const (
+	// DefaultKeyID is the kid of the built-in development key and of a key set
+	// built from a single configured secret
+	DefaultKeyID = "default"
+
+	// minHMACSecretSize is the smallest HS256 secret we accept (256 bits)
+	minHMACSecretSize = 32
+)
+
+// Development secret used when no keys are configured. Never use it in production.
+var devSecret = []byte("your-secret-key-change-this-in-production")
+
+// KeyConfig describes one key in the JWT keys file
+type KeyConfig struct {
+	KID            string `json:"kid"`
+	Algorithm      string `json:"alg"`                        // HS256, EdDSA or ES256
+	Secret         string `json:"secret,omitempty"`           // HS256: base64 secret
+	PrivateKey     string `json:"private_key,omitempty"`      // EdDSA/ES256: PKCS#8 PEM
+	PrivateKeyFile string `json:"private_key_file,omitempty"` // EdDSA/ES256: path to a PKCS#8 PEM file
+	PublicKey      string `json:"public_key,omitempty"`       // EdDSA/ES256: PKIX PEM, for retired verify-only keys
+}
+
+// KeySetConfig is the content of the JWT keys file. The active key signs new
+// tokens; the others only verify tokens issued before a rotation.
+type KeySetConfig struct {
+	Active string      `json:"active"`
+	Keys   []KeyConfig `json:"keys"`
+}
+
+// SigningKey is a parsed JWT key
+type SigningKey struct {
+	KID       string
+	Algorithm string
+	signKey   interface{} // nil for verify-only keys
+	verifyKey interface{}
+}
+
+// KeySet holds the keys used to sign and verify JWTs, selected by the kid header
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+var currentKeys atomic.Pointer[KeySet]
+
+func init() {
+	currentKeys.Store(DefaultKeySet())
+}
+
+// DefaultKeySet returns the built-in HS256 development key set
+func DefaultKeySet() *KeySet {
+	key := &SigningKey{KID: DefaultKeyID, Algorithm: AlgHS256, signKey: devSecret, verifyKey: devSecret}
+	return &KeySet{active: key, keys: map[string]*SigningKey{DefaultKeyID: key}}
+}
+
+// CurrentKeySet returns the key set used by GenerateJWT and ValidateJWT
+func CurrentKeySet() *KeySet {
+	return currentKeys.Load()
+}
+
+// SetKeySet replaces the key set used by GenerateJWT and ValidateJWT
+func SetKeySet(keys *KeySet) {
+	currentKeys.Store(keys)
+}
+
+// NewKeySet parses a key set. Relative private_key_file paths are resolved against baseDir.
+func NewKeySet(cfg KeySetConfig, baseDir string) (*KeySet, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("no JWT keys configured")
+	}
+
+	keys := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, kc := range cfg.Keys {
+		if kc.KID == "" {
+			return nil, errors.New("every JWT key needs a kid")
+		}
+		if _, exists := keys.keys[kc.KID]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id %q", kc.KID)
+		}
+		key, err := parseKey(kc, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %q: %w", kc.KID, err)
+		}
+		keys.keys[kc.KID] = key
+	}
+
+	active := cfg.Active
+	if active == "" && len(cfg.Keys) == 1 {
+		active = cfg.Keys[0].KID
+	}
+	keys.active = keys.keys[active]
+	if keys.active == nil {
+		return nil, fmt.Errorf("active JWT key %q is not configured", active)
+	}
+	if keys.active.signKey == nil {
+		return nil, fmt.Errorf("active JWT key %q has no private key", active)
+	}
+
+	return keys, nil
+}
+
+// LoadKeySetFile reads a key set from a JSON keys file
+func LoadKeySetFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT keys file: %w", err)
+	}
+
+	var cfg KeySetConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid JWT keys file: %w", err)
+	}
+
+	return NewKeySet(cfg, filepath.Dir(path))
+}
+
+// LoadKeySet builds a key set from a keys file, or from a single HS256 secret.
+// It returns nil if neither is set.
+func LoadKeySet(keysFile, secret string) (*KeySet, error) {
+	if keysFile != "" {
+		return LoadKeySetFile(keysFile)
+	}
+
+	if secret != "" {
+		return NewKeySet(KeySetConfig{Keys: []KeyConfig{{
+			KID:       DefaultKeyID,
+			Algorithm: AlgHS256,
+			Secret:    base64.StdEncoding.EncodeToString([]byte(secret)),
+		}}}, "")
+	}
+
+	return nil, nil
+}
+
+// ActiveKeyID returns the kid of the key that signs new tokens
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.KID
+}
+
+// Sign signs claims with the active key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(ks.active.Algorithm), claims)
+	token.Header["kid"] = ks.active.KID
+	return token.SignedString(ks.active.signKey)
+}
+
+// keyFunc picks the verification key for a token by its kid header and rejects
+// tokens whose algorithm does not match that key. Tokens without a kid are
+// checked against the active key.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := ks.active
+	if kid, ok := token.Header["kid"]; ok {
+		kidString, _ := kid.(string)
+		if key = ks.keys[kidString]; key == nil {
+			return nil, fmt.Errorf("unknown signing key: %v", kid)
+		}
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set. HS256 secrets are never published,
+// so services can only verify tokens signed with an asymmetric key.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	// Active key first, then the rest in a stable order
+	kids := []string{ks.active.KID}
+	for kid := range ks.keys {
+		if kid != ks.active.KID {
+			kids = append(kids, kid)
+		}
+	}
+	sort.Strings(kids[1:])
+
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		jwk := JWK{KeyID: key.KID, Algorithm: key.Algorithm, Use: "sig"}
+
+		switch pub := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *ecdsa.PublicKey:
+			jwk.KeyType = "EC"
+			jwk.Curve = "P-256"
+			point, err := pub.Bytes() // 0x04 || X || Y
+			if err != nil {
+				continue
+			}
+			jwk.X = base64.RawURLEncoding.EncodeToString(point[1:33])
+			jwk.Y = base64.RawURLEncoding.EncodeToString(point[33:])
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// parseKey turns a KeyConfig into a SigningKey
+func parseKey(kc KeyConfig, baseDir string) (*SigningKey, error) {
+	key := &SigningKey{KID: kc.KID, Algorithm: kc.Algorithm}
+
+	if kc.Algorithm == AlgHS256 {
+		secret, err := base64.StdEncoding.DecodeString(kc.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret: %w", err)
+		}
+		if len(secret) < minHMACSecretSize {
+			return nil, fmt.Errorf("HS256 secret must be at least %d bytes", minHMACSecretSize)
+		}
+		key.signKey, key.verifyKey = secret, secret
+		return key, nil
+	}
+
+	if kc.Algorithm != AlgEdDSA && kc.Algorithm != AlgES256 {
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+
+	privatePEM := kc.PrivateKey
+	if kc.PrivateKeyFile != "" {
+		path := kc.PrivateKeyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		privatePEM = string(data)
+	}
+
+	switch {
+	case privatePEM != "":
+		parsed, err := parsePEM(privatePEM, "PRIVATE KEY", x509.ParsePKCS8PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		switch private := parsed.(type) {
+		case ed25519.PrivateKey:
+			key.signKey, key.verifyKey = private, private.Public()
+		case *ecdsa.PrivateKey:
+			key.signKey, key.verifyKey = private, &private.PublicKey
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", parsed)
+		}
+	case kc.PublicKey != "":
+		parsed, err := parsePEM(kc.PublicKey, "PUBLIC KEY", x509.ParsePKIXPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		key.verifyKey = parsed
+	default:
+		return nil, errors.New("private_key, private_key_file or public_key is required")
+	}
+
+	// The key type must match the declared algorithm
+	switch public := key.verifyKey.(type) {
+	case ed25519.PublicKey:
+		if kc.Algorithm != AlgEdDSA {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", kc.Algorithm)
+		}
+	case *ecdsa.PublicKey:
+		if kc.Algorithm != AlgES256 || public.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s needs a P-256 key", AlgES256)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key.verifyKey)
+	}
+
+	return key, nil
+}
+
+// parsePEM decodes a single PEM block of the given type
+func parsePEM(data, blockType string, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("expected a PEM %q block", blockType)
+	}
+	parsed, err := parse(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return parsed, nil
+}
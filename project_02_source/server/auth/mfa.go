@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MFAChallengeLifetime is how long a user has to enter the second factor after the password
+const MFAChallengeLifetime = 5 * time.Minute
+
+// maxMFAAttempts is how many codes may be tried against one challenge
+const maxMFAAttempts = 5
+
+// ErrInvalidMFAChallenge is returned for unknown, expired or exhausted login challenges
+var ErrInvalidMFAChallenge = errors.New("invalid or expired login challenge, log in again")
+
+// IssueMFAChallenge creates the token a client sends with the second factor.
+// Only the hash is stored.
+func (i *Issuer) IssueMFAChallenge(db *gorm.DB, userID uint) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := models.MFAChallenge{
+		UserID:    userID,
+		TokenHash: hashRefreshToken(token),
+		ExpiresAt: i.now().Add(MFAChallengeLifetime),
+	}
+	if err := db.Create(&challenge).Error; err != nil {
+		return "", fmt.Errorf("failed to store login challenge: %w", err)
+	}
+	return token, nil
+}
+
+// UseMFAChallenge counts an attempt against a live challenge and returns it.
+// A challenge that ran out of attempts or time no longer works.
+func (i *Issuer) UseMFAChallenge(db *gorm.DB, token string) (*models.MFAChallenge, error) {
+	hash := hashRefreshToken(token)
+	result := db.Model(&models.MFAChallenge{}).
+		Where("token_hash = ? AND expires_at > ? AND attempts < ?", hash, i.now(), maxMFAAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to check login challenge: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	var challenge models.MFAChallenge
+	if err := db.Where("token_hash = ?", hash).First(&challenge).Error; err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return &challenge, nil
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Token lifetimes, set from the server configuration at startup
+var (
+	// AccessTokenLifetime is how long a JWT access token is valid
+	AccessTokenLifetime = 15 * time.Minute
+
+	// RefreshTokenLifetime is how long an unused refresh token is valid
+	RefreshTokenLifetime = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+	// ErrRefreshTokenReused is returned when an already used refresh token is presented again.
+	// The whole token family has been revoked when this is returned.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+// IssueRefreshToken creates a refresh token for a user. An empty familyID starts a new family.
+// Only the hash is stored; the returned token must be handed to the client.
+func IssueRefreshToken(db *gorm.DB, userID uint, familyID string) (string, error) {
+	return DefaultIssuer().IssueRefreshToken(db, userID, familyID)
+}
+
+// IssueRefreshToken creates a refresh token for a user that expires after the issuer's refresh lifetime
+func (i *Issuer) IssueRefreshToken(db *gorm.DB, userID uint, familyID string) (string, error) {
+	if familyID == "" {
+		var err error
+		if familyID, err = randomToken(16); err != nil {
+			return "", err
+		}
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(token),
+		ExpiresAt: i.now().Add(i.RefreshLifetime),
+	}
+	if err := db.Create(&refreshToken).Error; err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same family and
+// returns the new token with its user ID. Reusing a token revokes its whole family.
+func RotateRefreshToken(db *gorm.DB, token string) (string, uint, error) {
+	return DefaultIssuer().RotateRefreshToken(db, token)
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one, using the issuer's clock and lifetime
+func (i *Issuer) RotateRefreshToken(db *gorm.DB, token string) (string, uint, error) {
+	var newToken, reusedFamily string
+	var userID uint
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Where("token_hash = ?", hashRefreshToken(token)).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil || i.now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+		if current.UsedAt != nil {
+			reusedFamily = current.FamilyID
+			return nil
+		}
+
+		// Conditional update so two concurrent exchanges cannot both succeed
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", current.ID).
+			Update("used_at", i.now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reusedFamily = current.FamilyID
+			return nil
+		}
+
+		var err error
+		newToken, err = i.IssueRefreshToken(tx, current.UserID, current.FamilyID)
+		userID = current.UserID
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	if reusedFamily != "" {
+		// The legitimate client and an attacker both hold this family: end it
+		if err := i.RevokeRefreshFamily(db, reusedFamily); err != nil {
+			return "", 0, err
+		}
+		return "", 0, ErrRefreshTokenReused
+	}
+
+	return newToken, userID, nil
+}
+
+// RevokeRefreshToken revokes the family of a refresh token (e.g. on logout)
+func RevokeRefreshToken(db *gorm.DB, userID uint, token string) error {
+	return DefaultIssuer().RevokeRefreshToken(db, userID, token)
+}
+
+// RevokeRefreshToken revokes the family of a refresh token (e.g. on logout)
+func (i *Issuer) RevokeRefreshToken(db *gorm.DB, userID uint, token string) error {
+	var current models.RefreshToken
+	if err := db.Where("token_hash = ? AND user_id = ?", hashRefreshToken(token), userID).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+	return i.RevokeRefreshFamily(db, current.FamilyID)
+}
+
+// RevokeRefreshFamily revokes every refresh token rotated from the same login
+func RevokeRefreshFamily(db *gorm.DB, familyID string) error {
+	return DefaultIssuer().RevokeRefreshFamily(db, familyID)
+}
+
+// RevokeRefreshFamily revokes every refresh token rotated from the same login
+func (i *Issuer) RevokeRefreshFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", i.now()).Error
+}
+
+// revokeUserRefreshTokens revokes every refresh token of a user
+func revokeUserRefreshTokens(db *gorm.DB, userID uint, now time.Time) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// hashRefreshToken returns the hex SHA-256 of a refresh token
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes encoded as URL-safe base64
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrTokenRevoked is returned for tokens that were revoked before they expired
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// CheckRevocation returns ErrTokenRevoked if the token was revoked on its own
+// (logout) or together with every other token of the user (revoke all)
+func CheckRevocation(db *gorm.DB, claims *Claims) error {
+	if claims.ID != "" {
+		var count int64
+		if err := db.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if count > 0 {
+			return ErrTokenRevoked
+		}
+	}
+
+	var user models.User
+	if err := db.Select("id", "token_version").First(&user, claims.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user no longer exists")
+		}
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if claims.TokenVersion != user.TokenVersion {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// RevokeToken revokes a single token until it expires
+func RevokeToken(db *gorm.DB, claims *Claims) error {
+	return DefaultIssuer().RevokeToken(db, claims)
+}
+
+// RevokeToken revokes a single token until it expires
+func (i *Issuer) RevokeToken(db *gorm.DB, claims *Claims) error {
+	if claims.ID == "" {
+		return errors.New("token has no ID and cannot be revoked on its own")
+	}
+
+	// Revoking twice is not an error
+	var count int64
+	if err := db.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	expiresAt := i.now().Add(i.AccessLifetime)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	return db.Create(&models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// RevokeAllTokens revokes every access and refresh token issued to a user so far
+func RevokeAllTokens(db *gorm.DB, userID uint) error {
+	return DefaultIssuer().RevokeAllTokens(db, userID)
+}
+
+// RevokeAllTokens revokes every access and refresh token issued to a user so far
+func (i *Issuer) RevokeAllTokens(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumn("token_version", gorm.Expr("token_version + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return revokeUserRefreshTokens(tx, userID, i.now())
+	})
+}
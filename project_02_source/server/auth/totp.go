@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// TOTPIssuer is the name authenticator apps show next to the account
+const TOTPIssuer = "Secure Notes"
+
+// RecoveryCodeCount is how many recovery codes a user gets when enabling TOTP
+const RecoveryCodeCount = 10
+
+// totpOptions are the RFC 6238 defaults every authenticator app supports
+var totpOptions = totp.ValidateOpts{Period: 30, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+// ErrInvalidOTP is returned for a wrong, expired or already used TOTP code
+var ErrInvalidOTP = errors.New("invalid two-factor code")
+
+// GenerateTOTPKey creates a new TOTP secret for a user
+func GenerateTOTPKey(username string) (*otp.Key, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      TOTPIssuer,
+		AccountName: username,
+		Period:      uint(totpOptions.Period),
+		Digits:      totpOptions.Digits,
+		Algorithm:   totpOptions.Algorithm,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return key, nil
+}
+
+// CheckTOTP checks a code against secret at now, allowing one period of clock
+// drift either way. It returns the time step the code belongs to. Steps at or
+// before lastStep are rejected, so each code can only be used once.
+func CheckTOTP(secret, code string, now time.Time, lastStep int64) (int64, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpOptions.Digits.Length() {
+		return 0, ErrInvalidOTP
+	}
+
+	period := int64(totpOptions.Period)
+	current := now.Unix() / period
+	for _, step := range []int64{current - 1, current, current + 1} {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*period, 0), totpOptions)
+		if err != nil {
+			return 0, fmt.Errorf("invalid TOTP secret: %w", err)
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+	return 0, ErrInvalidOTP
+}
+
+// TOTPCode returns the code for secret at t, for tests and tools
+func TOTPCode(secret string, t time.Time) (string, error) {
+	return totp.GenerateCodeCustom(secret, t, totpOptions)
+}
+
+// GenerateRecoveryCodes returns n random codes formatted like "k7p2m-x9q4r"
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the stored form of a recovery code. Case, spaces and
+// dashes are ignored so users can type the code however it was printed.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
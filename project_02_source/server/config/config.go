@@ -0,0 +1,446 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to every environment variable, e.g. SECURE_NOTES_LISTEN_ADDR
+const EnvPrefix = "SECURE_NOTES_"
+
+// Config holds the server settings. Values come from the defaults, then a JSON
+// config file, then SECURE_NOTES_* environment variables, then command-line flags;
+// each source overrides the previous one.
+type Config struct {
+	ListenAddr     string   // Address the HTTP server listens on
+	PublicBaseURL  string   // URL clients use to reach the server (used in share links)
+	DBPath         string   // SQLite database file
+	CORSOrigins    []string // Allowed CORS origins, "*" allows any
+	TrustedProxies []string // Reverse proxies (IPs or CIDRs) whose X-Forwarded-For header is believed
+
+	CleanupInterval    time.Duration // How often expired data is removed
+	ShutdownTimeout    time.Duration // How long in-flight requests may take to finish on shutdown
+	AccessLogRetention time.Duration // How long share access events are kept, 0 keeps them forever
+	ReceiptRetention   time.Duration // How long expired burn-after-reading shares are kept as read receipts, 0 keeps them forever
+
+	RevisionMaxAge      time.Duration // Note revisions older than this are removed, 0 keeps them forever
+	MaxRevisionsPerNote int           // Only the newest N revisions of each note are kept, 0 keeps all
+
+	AccessTokenLifetime  time.Duration
+	RefreshTokenLifetime time.Duration
+
+	ShareDefaultDuration time.Duration // Lifetime of a share when the client does not ask for one
+	ShareMaxDuration     time.Duration // Longest lifetime a client may ask for
+
+	RateLimitPerIP      int           // Requests per minute from one client IP, 0 disables the limit
+	RateLimitPerAccount int           // Login or share password attempts per minute per username or share, 0 disables the limit
+	LockoutThreshold    int           // Failed attempts before a username or share is locked, 0 disables lockout
+	LockoutBase         time.Duration // First lockout; every further failure doubles it
+	LockoutMax          time.Duration // Longest lockout
+
+	JWTKeysFile string // JSON file with the JWT signing keys
+	JWTSecret   string // Single HS256 secret, used when no keys file is set
+
+	TLSCertFile     string   // Serve HTTPS with this certificate (PEM) when set
+	TLSKeyFile      string   // Private key for TLSCertFile
+	TLSClientCAFile string   // CA bundle for client certificates (mutual TLS), optional
+	ClientCertUsers []string // Automation accounts that may log in with a client certificate
+}
+
+// Default returns the settings used when nothing is configured
+func Default() *Config {
+	return &Config{
+		ListenAddr:           ":8080",
+		PublicBaseURL:        "http://localhost:8080",
+		DBPath:               "storage/app.db",
+		CORSOrigins:          []string{"*"},
+		CleanupInterval:      time.Hour,
+		ShutdownTimeout:      30 * time.Second,
+		AccessLogRetention:   30 * 24 * time.Hour,
+		ReceiptRetention:     30 * 24 * time.Hour,
+		MaxRevisionsPerNote:  50,
+		AccessTokenLifetime:  15 * time.Minute,
+		RefreshTokenLifetime: 30 * 24 * time.Hour,
+		ShareDefaultDuration: 24 * time.Hour,
+		ShareMaxDuration:     7 * 24 * time.Hour,
+		RateLimitPerIP:       300,
+		RateLimitPerAccount:  10,
+		LockoutThreshold:     5,
+		LockoutBase:          time.Minute,
+		LockoutMax:           time.Hour,
+	}
+}
+
+// option is one setting, named the same way in the config file (listen_addr),
+// the environment (SECURE_NOTES_LISTEN_ADDR) and on the command line (-listen-addr)
+type option struct {
+	name    string
+	usage   string
+	envOnly bool // secrets are not accepted as flags, they would show up in ps
+	set     func(c *Config, value string) error
+}
+
+var options = []option{
+	{name: "listen_addr", usage: "address to listen on, e.g. :8080", set: func(c *Config, v string) error {
+		c.ListenAddr = v
+		return nil
+	}},
+	{name: "public_url", usage: "public base URL of the server, used in share links", set: func(c *Config, v string) error {
+		c.PublicBaseURL = strings.TrimRight(v, "/")
+		return nil
+	}},
+	{name: "db_path", usage: "SQLite database file", set: func(c *Config, v string) error {
+		c.DBPath = v
+		return nil
+	}},
+	{name: "cors_origins", usage: "comma-separated allowed CORS origins, * for any", set: func(c *Config, v string) error {
+		c.CORSOrigins = splitList(v)
+		return nil
+	}},
+	{name: "trusted_proxies", usage: "comma-separated reverse proxy IPs or CIDRs allowed to set X-Forwarded-For", set: func(c *Config, v string) error {
+		c.TrustedProxies = splitList(v)
+		return nil
+	}},
+	{name: "cleanup_interval", usage: "interval between cleanup runs, e.g. 1h", set: durationSetter(func(c *Config) *time.Duration { return &c.CleanupInterval })},
+	{name: "shutdown_timeout", usage: "how long in-flight requests may take to finish on shutdown", set: durationSetter(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{name: "access_log_retention", usage: "how long share access events are kept, 0 to keep them forever", set: durationSetter(func(c *Config) *time.Duration { return &c.AccessLogRetention })},
+	{name: "receipt_retention", usage: "how long expired burn-after-reading shares are kept as read receipts, 0 to keep them forever", set: durationSetter(func(c *Config) *time.Duration { return &c.ReceiptRetention })},
+	{name: "revision_max_age", usage: "remove note revisions older than this, 0 to keep them forever", set: durationSetter(func(c *Config) *time.Duration { return &c.RevisionMaxAge })},
+	{name: "max_revisions_per_note", usage: "keep only the newest N revisions of each note, 0 to keep all", set: intSetter(func(c *Config) *int { return &c.MaxRevisionsPerNote })},
+	{name: "access_token_lifetime", usage: "lifetime of access tokens, e.g. 15m", set: durationSetter(func(c *Config) *time.Duration { return &c.AccessTokenLifetime })},
+	{name: "refresh_token_lifetime", usage: "lifetime of refresh tokens, e.g. 720h", set: durationSetter(func(c *Config) *time.Duration { return &c.RefreshTokenLifetime })},
+	{name: "share_default_duration", usage: "share lifetime when the client does not choose one", set: durationSetter(func(c *Config) *time.Duration { return &c.ShareDefaultDuration })},
+	{name: "share_max_duration", usage: "longest share lifetime a client may ask for", set: durationSetter(func(c *Config) *time.Duration { return &c.ShareMaxDuration })},
+	{name: "rate_limit_ip", usage: "requests per minute from one client IP, 0 for no limit", set: intSetter(func(c *Config) *int { return &c.RateLimitPerIP })},
+	{name: "rate_limit_account", usage: "login or share password attempts per minute per username or share, 0 for no limit", set: intSetter(func(c *Config) *int { return &c.RateLimitPerAccount })},
+	{name: "lockout_threshold", usage: "failed attempts before a username or share is locked, 0 to disable", set: intSetter(func(c *Config) *int { return &c.LockoutThreshold })},
+	{name: "lockout_base", usage: "first lockout duration, doubled on every further failure", set: durationSetter(func(c *Config) *time.Duration { return &c.LockoutBase })},
+	{name: "lockout_max", usage: "longest lockout duration", set: durationSetter(func(c *Config) *time.Duration { return &c.LockoutMax })},
+	{name: "jwt_keys_file", usage: "JSON file with the JWT signing keys", set: func(c *Config, v string) error {
+		c.JWTKeysFile = v
+		return nil
+	}},
+	{name: "tls_cert_file", usage: "TLS certificate file (PEM), enables HTTPS", set: func(c *Config, v string) error {
+		c.TLSCertFile = v
+		return nil
+	}},
+	{name: "tls_key_file", usage: "TLS private key file (PEM)", set: func(c *Config, v string) error {
+		c.TLSKeyFile = v
+		return nil
+	}},
+	{name: "tls_client_ca_file", usage: "CA bundle for client certificate authentication", set: func(c *Config, v string) error {
+		c.TLSClientCAFile = v
+		return nil
+	}},
+	{name: "client_cert_users", usage: "comma-separated automation accounts that may log in with a client certificate", set: func(c *Config, v string) error {
+		c.ClientCertUsers = splitList(v)
+		return nil
+	}},
+	{name: "jwt_secret", envOnly: true, set: func(c *Config, v string) error {
+		c.JWTSecret = v
+		return nil
+	}},
+}
+
+// Load builds the configuration from the defaults, the config file named by
+// -config or SECURE_NOTES_CONFIG, the environment and args, then validates it
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "JSON config file")
+	flagValues := make(map[string]*string)
+	for _, opt := range options {
+		if !opt.envOnly {
+			flagValues[opt.name] = fs.String(flagName(opt.name), "", opt.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, opt := range options {
+		if value, ok := os.LookupEnv(envName(opt.name)); ok {
+			if err := opt.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", envName(opt.name), err)
+			}
+		}
+	}
+
+	// Only flags that were given on the command line override earlier sources
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, opt := range options {
+			if f.Name == flagName(opt.name) && flagErr == nil {
+				if err := opt.set(cfg, *flagValues[opt.name]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", f.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile applies a JSON config file. Keys are option names; values are
+// strings (durations like "15m"), numbers, or a list of strings for cors_origins.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	for key, raw := range values {
+		opt, ok := findOption(key)
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			var list []string
+			var number json.Number
+			if json.Unmarshal(raw, &list) == nil {
+				value = strings.Join(list, ",")
+			} else if json.Unmarshal(raw, &number) == nil {
+				value = number.String()
+			} else {
+				return fmt.Errorf("config file %s: %s must be a string, a number or a list of strings", path, key)
+			}
+		}
+
+		if err := opt.set(c, value); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that every setting is usable and reports all problems at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		errs = append(errs, fmt.Errorf("listen_addr %q is not a host:port address", c.ListenAddr))
+	}
+
+	if u, err := url.Parse(c.PublicBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("public_url %q must be an absolute http(s) URL", c.PublicBaseURL))
+	} else if u.RawQuery != "" || u.Fragment != "" {
+		errs = append(errs, fmt.Errorf("public_url %q must not have a query or fragment", c.PublicBaseURL))
+	}
+
+	if c.DBPath == "" {
+		errs = append(errs, errors.New("db_path is required"))
+	}
+
+	if len(c.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("cors_origins needs at least one origin (use * to allow any)"))
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors origin %q must look like https://example.com", origin))
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, err := parsePrefix(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("trusted proxy %q must be an IP address or CIDR range", proxy))
+		}
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"cleanup_interval", c.CleanupInterval},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"access_token_lifetime", c.AccessTokenLifetime},
+		{"refresh_token_lifetime", c.RefreshTokenLifetime},
+		{"share_default_duration", c.ShareDefaultDuration},
+		{"share_max_duration", c.ShareMaxDuration},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", d.name))
+		}
+	}
+	if c.AccessTokenLifetime >= c.RefreshTokenLifetime {
+		errs = append(errs, errors.New("access_token_lifetime must be shorter than refresh_token_lifetime"))
+	}
+	if c.ShareDefaultDuration > c.ShareMaxDuration {
+		errs = append(errs, errors.New("share_default_duration must not exceed share_max_duration"))
+	}
+	if c.AccessLogRetention < 0 {
+		errs = append(errs, errors.New("access_log_retention must not be negative (0 keeps events forever)"))
+	}
+	if c.ReceiptRetention < 0 {
+		errs = append(errs, errors.New("receipt_retention must not be negative (0 keeps receipts forever)"))
+	}
+	if c.RevisionMaxAge < 0 {
+		errs = append(errs, errors.New("revision_max_age must not be negative (0 keeps revisions forever)"))
+	}
+
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"rate_limit_ip", c.RateLimitPerIP},
+		{"rate_limit_account", c.RateLimitPerAccount},
+		{"lockout_threshold", c.LockoutThreshold},
+		{"max_revisions_per_note", c.MaxRevisionsPerNote},
+	} {
+		if n.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative (0 disables it)", n.name))
+		}
+	}
+	if c.LockoutThreshold > 0 {
+		if c.LockoutBase <= 0 || c.LockoutMax <= 0 {
+			errs = append(errs, errors.New("lockout_base and lockout_max must be positive"))
+		} else if c.LockoutBase > c.LockoutMax {
+			errs = append(errs, errors.New("lockout_base must not exceed lockout_max"))
+		}
+	}
+
+	if c.JWTKeysFile != "" && c.JWTSecret != "" {
+		errs = append(errs, errors.New("set either jwt_keys_file or jwt_secret, not both"))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file and tls_key_file must be set together"))
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		errs = append(errs, errors.New("tls_client_ca_file needs tls_cert_file and tls_key_file"))
+	}
+	if len(c.ClientCertUsers) > 0 && c.TLSClientCAFile == "" {
+		errs = append(errs, errors.New("client_cert_users needs tls_client_ca_file"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// TLSEnabled reports whether the server should serve HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// AllowsAnyOrigin reports whether CORS is open to every origin
+func (c *Config) AllowsAnyOrigin() bool {
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedProxyPrefixes returns the trusted proxies as address ranges; a plain
+// IP becomes a single address range. Entries that do not parse are skipped,
+// Validate reports them.
+func (c *Config) TrustedProxyPrefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, proxy := range c.TrustedProxies {
+		if prefix, err := parsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// parsePrefix parses an IP address or a CIDR range
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// durationSetter returns a setter that parses a Go duration into a field
+func durationSetter(field func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// intSetter returns a setter that parses an integer into a field
+func intSetter(field func(c *Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// findOption looks up an option by its config file name
+func findOption(name string) (option, bool) {
+	for _, opt := range options {
+		if opt.name == name {
+			return opt, true
+		}
+	}
+	return option{}, false
+}
+
+// envName returns the environment variable for an option
+func envName(name string) string {
+	return EnvPrefix + strings.ToUpper(name)
+}
+
+// flagName returns the command-line flag for an option
+func flagName(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
+
+// splitList splits a comma-separated list and drops empty entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, strings.TrimRight(item, "/"))
+		}
+	}
+	return items
+}
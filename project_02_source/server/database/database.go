@@ -10,26 +10,35 @@ import (
 
 var DB *gorm.DB
 
-// InitDB initializes the SQLite database and runs migrations
-func InitDB(models ...interface{}) error {
-	var err error
+// InitDB initializes the SQLite database at path and runs migrations
+func InitDB(path string, models ...interface{}) error {
+	db, err := Open(path, models...)
+	if err != nil {
+		return err
+	}
+	DB = db
 
+	log.Println("✅ Database initialized successfully")
+	return nil
+}
+
+// Open opens the SQLite database at path and runs migrations without touching
+// the global DB, so one process can hold several databases
+func Open(path string, models ...interface{}) (*gorm.DB, error) {
 	// Open SQLite database
-	DB, err = gorm.Open(sqlite.Open("storage/app.db"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// Auto-migrate the database schema
 	if len(models) > 0 {
-		err = DB.AutoMigrate(models...)
-		if err != nil {
-			return fmt.Errorf("failed to migrate database: %w", err)
+		if err := db.AutoMigrate(models...); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
 		}
 	}
 
-	log.Println("✅ Database initialized successfully")
-	return nil
+	return db, nil
 }
 
 // GetDB returns the database instance
@@ -37,6 +46,27 @@ func GetDB() *gorm.DB {
 	return DB
 }
 
+// Close closes the global database connection
+func Close() error {
+	return CloseDB(DB)
+}
+
+// CloseDB closes a database opened with Open
+func CloseDB(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
 // InitTestDB initializes an in-memory SQLite database for testing
 func InitTestDB(models ...interface{}) error {
 	var err error
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"lab02_mahoa/server/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxUserAgentLength caps the user agent stored with an access event
+const maxUserAgentLength = 512
+
+// maxAccessEvents is the most events returned by one access log request
+const maxAccessEvents = 1000
+
+// recordShareAccess stores an attempt to open a share link in its owner's
+// access log. Failing to write the log never fails the request itself.
+func recordShareAccess(r *http.Request, env *Env, link models.SharedLink, outcome string) {
+	event := shareAccessEvent(r, env, link, outcome)
+	if err := env.DB.Create(&event).Error; err != nil {
+		log.Printf("❌ Failed to record share access: link=%d, outcome=%s: %v", link.ID, outcome, err)
+	}
+}
+
+// shareAccessEvent describes an attempt to open a share link by the request r
+func shareAccessEvent(r *http.Request, env *Env, link models.SharedLink, outcome string) models.ShareAccessEvent {
+	userAgent := strings.ToValidUTF8(r.UserAgent(), "")
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	return models.ShareAccessEvent{
+		LinkID:    link.ID,
+		NoteID:    link.NoteID,
+		UserID:    link.UserID,
+		Outcome:   outcome,
+		IP:        clientIP(r),
+		UserAgent: userAgent,
+		CreatedAt: env.Now(),
+	}
+}
+
+// ListNoteAccessLogHandler returns the access attempts on every share link of a note
+func ListNoteAccessLogHandler(w http.ResponseWriter, r *http.Request) {
+	// Note ID from the route: /api/notes/{id}/access-log
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+	listAccessLog(w, r, noteID, 0)
+}
+
+// ListShareLinkAccessLogHandler returns the access attempts on one share link.
+// The log stays readable after the link expires or is revoked.
+func ListShareLinkAccessLogHandler(w http.ResponseWriter, r *http.Request) {
+	// IDs from the route: /api/notes/{id}/shares/{linkId}/access-log
+	noteID, linkID, err := parseShareLinkPath(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	listAccessLog(w, r, noteID, linkID)
+}
+
+// listAccessLog responds with the newest access events of a note the caller
+// owns, limited to one share link unless linkID is 0
+func listAccessLog(w http.ResponseWriter, r *http.Request, noteID, linkID uint64) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	db := envFor(r).DB
+
+	// Verify note belongs to user
+	var note models.Note
+	if err := db.Where("id = ? AND user_id = ?", noteID, claims.UserID).First(&note).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		log.Printf("Error fetching note: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch note")
+		return
+	}
+
+	query := db.Where("note_id = ? AND user_id = ?", noteID, claims.UserID)
+	if linkID != 0 {
+		query = query.Where("link_id = ?", linkID)
+	}
+
+	events := []models.ShareAccessEvent{}
+	if err := query.Order("created_at DESC, id DESC").Limit(maxAccessEvents).Find(&events).Error; err != nil {
+		log.Printf("Error fetching access log: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch access log")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.ListShareAccessEventsResponse{
+		Events: events,
+		Count:  len(events),
+	})
+}
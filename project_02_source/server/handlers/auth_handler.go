@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"lab02_mahoa/server/auth"
-	"lab02_mahoa/server/database"
 	"lab02_mahoa/server/models"
 	"log"
 	"net/http"
@@ -35,7 +35,7 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Check if username already exists
 	var existingUser models.User
@@ -52,10 +52,21 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Every user gets a random salt for deriving their KEK
+	kdf, err := newKDFParams()
+	if err != nil {
+		log.Printf("Error generating KDF salt: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
 	// Create new user
 	user := models.User{
-		Username:     req.Username,
-		PasswordHash: hashedPassword,
+		Username:      req.Username,
+		PasswordHash:  hashedPassword,
+		KDFAlgorithm:  kdf.Algorithm,
+		KDFIterations: kdf.Iterations,
+		KDFSalt:       kdf.Salt,
 	}
 
 	if err := db.Create(&user).Error; err != nil {
@@ -83,12 +94,20 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	env := envFor(r)
+	db := env.DB
+
+	// Limit password guesses per username, whether or not the account exists
+	limitKey, lockKey := loginAttemptKeys(r, req.Username)
+	if !allowAttempt(w, env, limitKey, lockKey) {
+		return
+	}
 
 	// Find user by username
 	var user models.User
 	if err := db.Where("username = ?", req.Username).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			failAttempt(r, env, lockKey)
 			RespondWithError(w, http.StatusUnauthorized, "Invalid username or password")
 			return
 		}
@@ -99,34 +118,201 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Verify password
 	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		failAttempt(r, env, lockKey)
 		RespondWithError(w, http.StatusUnauthorized, "Invalid username or password")
 		return
 	}
 
+	// Accounts with two-factor authentication also need a TOTP or recovery code.
+	// Without one, the client gets a challenge to answer at /api/auth/login/mfa.
+	if user.TOTPEnabled {
+		if req.OTP == "" && req.RecoveryCode == "" {
+			mfaToken, err := env.Tokens.IssueMFAChallenge(db, user.ID)
+			if err != nil {
+				log.Printf("Error issuing login challenge: %v", err)
+				RespondWithError(w, http.StatusInternalServerError, "Failed to start two-factor login")
+				return
+			}
+			RespondWithJSON(w, http.StatusOK, models.LoginResponse{
+				Username:    user.Username,
+				MFARequired: true,
+				MFAToken:    mfaToken,
+				Message:     "Two-factor code required",
+			})
+			return
+		}
+		if !checkSecondFactor(w, r, env, &user, req.OTP, req.RecoveryCode, lockKey) {
+			return
+		}
+	}
+
+	env.Lockout.Reset(lockKey)
+	respondWithLogin(w, env, user)
+}
+
+// respondWithLogin issues the access and refresh tokens of a completed login
+func respondWithLogin(w http.ResponseWriter, env *Env, user models.User) {
+	db := env.DB
+
 	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID, user.Username)
+	token, err := env.Tokens.GenerateJWT(user.ID, user.Username, user.TokenVersion)
 	if err != nil {
 		log.Printf("Error generating token: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
+	// Every login starts a new refresh token family
+	refreshToken, err := env.Tokens.IssueRefreshToken(db, user.ID, "")
+	if err != nil {
+		log.Printf("Error generating refresh token: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
 	RespondWithJSON(w, http.StatusOK, models.LoginResponse{
-		Token:    token,
-		Username: user.Username,
-		Message:  "Login successful",
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(env.Tokens.AccessLifetime.Seconds()),
+		Username:     user.Username,
+		KDF:          userKDFParams(user),
+		Message:      "Login successful",
 	})
 }
 
-// LogoutHandler handles user logout (client-side token removal)
+// RefreshTokenHandler exchanges a refresh token for a new access token and a
+// rotated refresh token. Reusing a refresh token revokes its whole family.
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		RespondWithError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	env := envFor(r)
+	db := env.DB
+
+	refreshToken, userID, err := env.Tokens.RotateRefreshToken(db, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			log.Printf("⚠️ Refresh token reuse detected, token family revoked")
+			RespondWithError(w, http.StatusUnauthorized, "Refresh token was already used; please log in again")
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		log.Printf("Error rotating refresh token: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Error finding user: %v", err)
+		RespondWithError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	token, err := env.Tokens.GenerateJWT(user.ID, user.Username, user.TokenVersion)
+	if err != nil {
+		log.Printf("Error generating token: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.RefreshTokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(env.Tokens.AccessLifetime.Seconds()),
+	})
+}
+
+// LogoutHandler handles user logout by revoking the token used for the request
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Body is optional
+	var req models.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if claims.ID == "" {
+		RespondWithError(w, http.StatusBadRequest, "Requests authenticated with a client certificate have no token to revoke")
+		return
+	}
+
+	env := envFor(r)
+	db := env.DB
+
+	if err := env.Tokens.RevokeToken(db, claims); err != nil {
+		log.Printf("Error revoking token: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+
+	// End the session's refresh token family too, so the client cannot silently log back in
+	if req.RefreshToken != "" {
+		if err := env.Tokens.RevokeRefreshToken(db, claims.UserID, req.RefreshToken); err != nil && !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			log.Printf("Error revoking refresh token: %v", err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to revoke token")
+			return
+		}
+	}
+
+	log.Printf("🚪 Token revoked: user=%s", claims.Username)
+
+	RespondWithJSON(w, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Logout successful. This token can no longer be used.",
+	})
+}
+
+// RevokeAllTokensHandler signs the user out everywhere by revoking every token issued so far
+func RevokeAllTokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	env := envFor(r)
+	if err := env.Tokens.RevokeAllTokens(env.DB, claims.UserID); err != nil {
+		log.Printf("Error revoking tokens: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to revoke tokens")
+		return
+	}
+
+	log.Printf("🚪 All tokens revoked: user=%s", claims.Username)
+
 	RespondWithJSON(w, http.StatusOK, models.SuccessResponse{
 		Success: true,
-		Message: "Logout successful. Please remove your token from the client.",
+		Message: "All sessions have been signed out. Please log in again.",
 	})
 }
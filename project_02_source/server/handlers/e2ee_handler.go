@@ -3,12 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"lab02_mahoa/server/database"
 	"lab02_mahoa/server/models"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -28,14 +27,8 @@ func CreateE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract note ID from URL path: /api/notes/:id/e2ee
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 4 {
-		RespondWithError(w, http.StatusBadRequest, "Note ID is required")
-		return
-	}
-
-	noteID, err := strconv.ParseUint(pathParts[len(pathParts)-2], 10, 32)
+	// Note ID from the route: /api/notes/{id}/e2ee
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
 		return
@@ -66,7 +59,8 @@ func CreateE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	env := envFor(r)
+	db := env.DB
 
 	// Verify note exists and belongs to sender
 	var note models.Note
@@ -99,12 +93,17 @@ func CreateE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set default duration if not specified
-	durationHours := req.DurationHours
-	if durationHours <= 0 {
-		durationHours = 24 // Default 24 hours
+	duration := env.ShareDefaultDuration
+	if req.DurationHours > 0 {
+		duration = time.Duration(req.DurationHours) * time.Hour
+	}
+	if duration > env.ShareMaxDuration {
+		RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Share duration cannot exceed %g hours", env.ShareMaxDuration.Hours()))
+		return
 	}
 
-	expiresAt := time.Now().Add(time.Duration(durationHours) * time.Hour)
+	now := env.Now()
+	expiresAt := now.Add(duration)
 
 	// Create E2EE share
 	e2eeShare := models.E2EEShare{
@@ -115,7 +114,8 @@ func CreateE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 		EncryptedContent: req.EncryptedContent,
 		ContentIV:        req.ContentIV,
 		ExpiresAt:        expiresAt,
-		CreatedAt:        time.Now(),
+		CreatedAt:        now,
+		BurnAfterReading: req.BurnAfterReading,
 	}
 
 	if err := db.Create(&e2eeShare).Error; err != nil {
@@ -124,14 +124,15 @@ func CreateE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("✅ E2EE share created: sender=%d, recipient=%d, note=%d, expires=%v",
-		claims.UserID, recipient.ID, noteID, expiresAt)
+	log.Printf("✅ E2EE share created: sender=%d, recipient=%d, note=%d, expires=%v, burn_after_reading=%v",
+		claims.UserID, recipient.ID, noteID, expiresAt, req.BurnAfterReading)
 
 	RespondWithJSON(w, http.StatusCreated, models.E2EEShareResponse{
 		Success:           true,
 		ShareID:           e2eeShare.ID,
 		RecipientUsername: recipient.Username,
 		ExpiresAt:         expiresAt,
+		BurnAfterReading:  req.BurnAfterReading,
 		Message:           fmt.Sprintf("E2EE share created successfully with %s", recipient.Username),
 	})
 }
@@ -150,12 +151,13 @@ func ListE2EESharesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	env := envFor(r)
+	db := env.DB
 
-	// Get all E2EE shares where user is recipient and not expired
+	// Get all E2EE shares where user is recipient, not expired and not burnt
 	var shares []models.E2EEShare
 	if err := db.Preload("Note").Preload("Sender").
-		Where("recipient_id = ? AND expires_at > ?", claims.UserID, time.Now()).
+		Where("recipient_id = ? AND expires_at > ? AND read_at IS NULL", claims.UserID, env.Now()).
 		Order("created_at DESC").
 		Find(&shares).Error; err != nil {
 		log.Printf("Error fetching E2EE shares: %v", err)
@@ -175,6 +177,12 @@ func ListE2EESharesHandler(w http.ResponseWriter, r *http.Request) {
 			ContentIV:        share.ContentIV,
 			ExpiresAt:        share.ExpiresAt,
 			CreatedAt:        share.CreatedAt,
+			BurnAfterReading: share.BurnAfterReading,
+		}
+		if share.BurnAfterReading {
+			// Only GET /api/e2ee/{id} hands out the content, and burns it
+			shareResponses[i].EncryptedContent = ""
+			shareResponses[i].ContentIV = ""
 		}
 	}
 
@@ -198,20 +206,15 @@ func GetE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract share ID from URL path: /api/e2ee/:id
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 3 {
-		RespondWithError(w, http.StatusBadRequest, "Share ID is required")
-		return
-	}
-
-	shareID, err := strconv.ParseUint(pathParts[len(pathParts)-1], 10, 32)
+	// Share ID from the route: /api/e2ee/{id}
+	shareID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid share ID")
 		return
 	}
 
-	db := database.GetDB()
+	env := envFor(r)
+	db := env.DB
 
 	// Get E2EE share
 	var share models.E2EEShare
@@ -233,17 +236,21 @@ func GetE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if share has expired
-	if time.Now().After(share.ExpiresAt) {
-		// Delete expired share
-		db.Delete(&share)
-		log.Printf("❌ E2EE share expired and deleted: id=%d", shareID)
+	if env.Now().After(share.ExpiresAt) {
+		if share.BurnAfterReading {
+			// Keep an expired burn-after-reading share as its sender's read receipt, without the content
+			db.Model(&share).UpdateColumns(map[string]interface{}{"encrypted_content": "", "content_iv": ""})
+			log.Printf("❌ Burn-after-reading E2EE share expired, content destroyed: id=%d", shareID)
+		} else {
+			// Delete expired share
+			db.Delete(&share)
+			log.Printf("❌ E2EE share expired and deleted: id=%d", shareID)
+		}
 		RespondWithError(w, http.StatusGone, "E2EE share has expired")
 		return
 	}
 
-	log.Printf("✅ E2EE share accessed: id=%d, recipient=%d", shareID, claims.UserID)
-
-	RespondWithJSON(w, http.StatusOK, models.E2EEShareDetailResponse{
+	response := models.E2EEShareDetailResponse{
 		ID:               share.ID,
 		NoteTitle:        share.Note.Title,
 		SenderUsername:   share.Sender.Username,
@@ -252,6 +259,101 @@ func GetE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 		ContentIV:        share.ContentIV,
 		ExpiresAt:        share.ExpiresAt,
 		CreatedAt:        share.CreatedAt,
+		BurnAfterReading: share.BurnAfterReading,
+	}
+
+	if share.BurnAfterReading {
+		// Destroy the content in the same statement that checks it is still there
+		now := env.Now()
+		result := db.Model(&models.E2EEShare{}).
+			Where("id = ? AND read_at IS NULL", share.ID).
+			UpdateColumns(map[string]interface{}{"encrypted_content": "", "content_iv": "", "read_at": now})
+		if result.Error != nil {
+			log.Printf("Error burning E2EE share: %v", result.Error)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to open E2EE share")
+			return
+		}
+		if result.RowsAffected == 0 {
+			RespondWithError(w, http.StatusGone, "E2EE share has already been read and its content destroyed")
+			return
+		}
+		response.Burned = true
+		response.BurnedAt = &now
+		log.Printf("🔥 Burn-after-reading E2EE share read, content destroyed: id=%d", shareID)
+	}
+
+	log.Printf("✅ E2EE share accessed: id=%d, recipient=%d", shareID, claims.UserID)
+
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
+// ListReadReceiptsHandler lists the user's burn-after-reading share links and
+// E2EE shares, and when each was read
+func ListReadReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	db := envFor(r).DB
+
+	var links []models.SharedLink
+	if err := db.Preload("Note").
+		Where("user_id = ? AND burn_after_reading = ?", claims.UserID, true).
+		Find(&links).Error; err != nil {
+		log.Printf("Error fetching burn-after-reading links: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch read receipts")
+		return
+	}
+
+	var shares []models.E2EEShare
+	if err := db.Preload("Note").Preload("Recipient").
+		Where("sender_id = ? AND burn_after_reading = ?", claims.UserID, true).
+		Find(&shares).Error; err != nil {
+		log.Printf("Error fetching burn-after-reading E2EE shares: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch read receipts")
+		return
+	}
+
+	receipts := make([]models.ReadReceipt, 0, len(links)+len(shares))
+	for _, link := range links {
+		receipts = append(receipts, models.ReadReceipt{
+			Type:      "link",
+			ID:        link.ID,
+			NoteID:    link.NoteID,
+			NoteTitle: link.Note.Title,
+			CreatedAt: link.CreatedAt,
+			ExpiresAt: link.ExpiresAt,
+			ReadAt:    link.ReadAt,
+		})
+	}
+	for _, share := range shares {
+		receipts = append(receipts, models.ReadReceipt{
+			Type:      "e2ee",
+			ID:        share.ID,
+			NoteID:    share.NoteID,
+			NoteTitle: share.Note.Title,
+			Recipient: share.Recipient.Username,
+			CreatedAt: share.CreatedAt,
+			ExpiresAt: share.ExpiresAt,
+			ReadAt:    share.ReadAt,
+		})
+	}
+	// Newest first
+	sort.SliceStable(receipts, func(i, j int) bool {
+		return receipts[i].CreatedAt.After(receipts[j].CreatedAt)
+	})
+
+	RespondWithJSON(w, http.StatusOK, models.ListReadReceiptsResponse{
+		Receipts: receipts,
+		Count:    len(receipts),
 	})
 }
 
@@ -269,20 +371,14 @@ func DeleteE2EEShareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract share ID from URL path: /api/e2ee/:id
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 3 {
-		RespondWithError(w, http.StatusBadRequest, "Share ID is required")
-		return
-	}
-
-	shareID, err := strconv.ParseUint(pathParts[len(pathParts)-1], 10, 32)
+	// Share ID from the route: /api/e2ee/{id}
+	shareID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid share ID")
 		return
 	}
 
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Find E2EE share
 	var share models.E2EEShare
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// JWKSHandler publishes the public JWT verification keys (RFC 7517) so other
+// services can verify our tokens without holding a secret. HS256 keys are not listed.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Verifiers may cache the set; rotated-in keys should be published before they sign
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	RespondWithJSON(w, http.StatusOK, envFor(r).Tokens.Keys.JWKS())
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/models"
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// kdfPBKDF2SHA256 is PBKDF2 with HMAC-SHA256
+	kdfPBKDF2SHA256 = "pbkdf2-sha256"
+
+	// kdfArgon2id is the memory-hard Argon2id
+	kdfArgon2id = "argon2id"
+
+	// legacyKDFIterations is what clients used with the old fixed salt
+	legacyKDFIterations = 100000
+
+	// defaultKDFIterations is used for new users
+	defaultKDFIterations = 600000
+
+	// minKDFSaltSize is the smallest accepted salt in bytes
+	minKDFSaltSize = 16
+
+	// minArgon2idMemory is the OWASP minimum; the maximums are in models
+	minArgon2idMemory = 19 * 1024 // KiB
+)
+
+// newKDFParams returns default KDF parameters with a fresh random salt
+func newKDFParams() (models.KDFParams, error) {
+	salt := make([]byte, minKDFSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return models.KDFParams{}, err
+	}
+
+	return models.KDFParams{
+		Algorithm:  kdfPBKDF2SHA256,
+		Iterations: defaultKDFIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+	}, nil
+}
+
+// userKDFParams returns the KDF parameters stored for a user.
+// Users created before per-user salts get the legacy parameters with an empty salt.
+func userKDFParams(user models.User) models.KDFParams {
+	if user.KDFSalt == "" {
+		return models.KDFParams{
+			Algorithm:  kdfPBKDF2SHA256,
+			Iterations: legacyKDFIterations,
+		}
+	}
+
+	return models.KDFParams{
+		Algorithm:  user.KDFAlgorithm,
+		Iterations: user.KDFIterations,
+		Memory:     user.KDFMemory,
+		Threads:    user.KDFThreads,
+		Salt:       user.KDFSalt,
+	}
+}
+
+// validateKDFParams checks parameters sent by a client
+func validateKDFParams(params models.KDFParams) error {
+	switch params.Algorithm {
+	case kdfPBKDF2SHA256:
+		if params.Iterations < legacyKDFIterations || params.Iterations > models.MaxPBKDF2Iterations {
+			return fmt.Errorf("KDF iterations must be between %d and %d", legacyKDFIterations, models.MaxPBKDF2Iterations)
+		}
+		if params.Memory != 0 || params.Threads != 0 {
+			return fmt.Errorf("Memory and threads only apply to %s", kdfArgon2id)
+		}
+	case kdfArgon2id:
+		if params.Iterations < 1 || params.Iterations > models.MaxArgon2idTime {
+			return fmt.Errorf("Argon2id time cost must be between 1 and %d", models.MaxArgon2idTime)
+		}
+		if params.Memory < minArgon2idMemory || params.Memory > models.MaxArgon2idMemory {
+			return fmt.Errorf("Argon2id memory must be between %d and %d KiB", minArgon2idMemory, models.MaxArgon2idMemory)
+		}
+		if params.Threads < 1 || params.Threads > models.MaxArgon2idThreads {
+			return fmt.Errorf("Argon2id threads must be between 1 and %d", models.MaxArgon2idThreads)
+		}
+	default:
+		return fmt.Errorf("Unsupported KDF algorithm %q", params.Algorithm)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(params.Salt)
+	if err != nil || len(salt) < minKDFSaltSize {
+		return fmt.Errorf("KDF salt must be at least %d random bytes (base64)", minKDFSaltSize)
+	}
+
+	return nil
+}
+
+// GetKDFHandler returns the KDF parameters of the authenticated user
+func GetKDFHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var user models.User
+	if err := envFor(r).DB.First(&user, claims.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+		log.Printf("Error finding user: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, userKDFParams(user))
+}
+
+// MigrateKDFHandler moves a user to new KDF parameters (e.g. from the legacy fixed salt
+// to a per-user salt). Like a password change, all re-wrapped DEKs are stored in one transaction.
+func MigrateKDFHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req models.MigrateKDFRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if err := validateKDFParams(req.KDF); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validRewrappedKeys(req.NoteKeys) || !validRewrappedKeys(req.RevisionKeys) {
+		RespondWithError(w, http.StatusBadRequest, "Every re-wrapped key needs an encrypted_key and encrypted_key_iv")
+		return
+	}
+
+	db := envFor(r).DB
+
+	var user models.User
+	if err := db.First(&user, claims.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+		log.Printf("Error finding user: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// The password proves the client could unwrap the old keys
+	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		RespondWithError(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := applyRewrappedKeys(tx, user.ID, req.NoteKeys, req.RevisionKeys); err != nil {
+			return err
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"kdf_algorithm":  req.KDF.Algorithm,
+			"kdf_iterations": req.KDF.Iterations,
+			"kdf_memory":     req.KDF.Memory,
+			"kdf_threads":    req.KDF.Threads,
+			"kdf_salt":       req.KDF.Salt,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errKeySetMismatch) {
+			RespondWithError(w, http.StatusConflict, "Your notes changed during the migration, please try again")
+			return
+		}
+		log.Printf("Error migrating KDF: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update KDF parameters")
+		return
+	}
+
+	log.Printf("🧂 KDF parameters updated: user=%s, algorithm=%s, iterations=%d, memory=%d", user.Username, req.KDF.Algorithm, req.KDF.Iterations, req.KDF.Memory)
+
+	RespondWithJSON(w, http.StatusOK, req.KDF)
+}
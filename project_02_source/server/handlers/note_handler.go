@@ -1,19 +1,16 @@
 package handlers
 
 import (
-	"bytes"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"lab02_mahoa/server/auth"
-	"lab02_mahoa/server/database"
 	"lab02_mahoa/server/models"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -45,7 +42,7 @@ func CreateNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Create note
 	note := models.Note{
@@ -55,6 +52,8 @@ func CreateNoteHandler(w http.ResponseWriter, r *http.Request) {
 		IV:               req.IV,
 		EncryptedKey:     req.EncryptedKey,
 		EncryptedKeyIV:   req.EncryptedKeyIV,
+		KeyKDF:           req.KeyKDF,
+		Version:          1,
 	}
 
 	if err := db.Create(&note).Error; err != nil {
@@ -70,7 +69,10 @@ func CreateNoteHandler(w http.ResponseWriter, r *http.Request) {
 		IV:               note.IV,
 		EncryptedKey:     note.EncryptedKey,
 		EncryptedKeyIV:   note.EncryptedKeyIV,
+		KeyKDF:           note.KeyKDF,
 		CreatedAt:        note.CreatedAt,
+		UpdatedAt:        note.UpdatedAt,
+		Version:          note.Version,
 	})
 }
 
@@ -88,7 +90,8 @@ func ListNotesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	env := envFor(r)
+	db := env.DB
 
 	// Get all notes for user
 	var notes []models.Note
@@ -103,7 +106,7 @@ func ListNotesHandler(w http.ResponseWriter, r *http.Request) {
 	for i, note := range notes {
 		// Check if note has active shared links
 		var shareCount int64
-		db.Model(&models.SharedLink{}).Where("note_id = ? AND expires_at > ?", note.ID, time.Now()).Count(&shareCount)
+		db.Model(&models.SharedLink{}).Where("note_id = ? AND expires_at > ?", note.ID, env.Now()).Count(&shareCount)
 
 		noteResponses[i] = models.NoteResponse{
 			ID:               note.ID,
@@ -112,7 +115,10 @@ func ListNotesHandler(w http.ResponseWriter, r *http.Request) {
 			IV:               note.IV,
 			EncryptedKey:     note.EncryptedKey,
 			EncryptedKeyIV:   note.EncryptedKeyIV,
+			KeyKDF:           note.KeyKDF,
 			CreatedAt:        note.CreatedAt,
+			UpdatedAt:        note.UpdatedAt,
+			Version:          note.Version,
 			IsShared:         shareCount > 0,
 		}
 	}
@@ -137,20 +143,14 @@ func GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract note ID from URL path
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 3 {
-		RespondWithError(w, http.StatusBadRequest, "Note ID is required")
-		return
-	}
-
-	noteID, err := strconv.ParseUint(pathParts[len(pathParts)-1], 10, 32)
+	// Note ID from the route: /api/notes/{id}
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
 		return
 	}
 
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Get note
 	var note models.Note
@@ -171,13 +171,16 @@ func GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 		IV:               note.IV,
 		EncryptedKey:     note.EncryptedKey,
 		EncryptedKeyIV:   note.EncryptedKeyIV,
+		KeyKDF:           note.KeyKDF,
 		CreatedAt:        note.CreatedAt,
+		UpdatedAt:        note.UpdatedAt,
+		Version:          note.Version,
 	})
 }
 
-// DeleteNoteHandler deletes a note by ID
-func DeleteNoteHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
+// UpdateNoteHandler replaces the ciphertext of a note using optimistic concurrency
+func UpdateNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
 		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
@@ -189,20 +192,97 @@ func DeleteNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract note ID from URL path
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 3 {
-		RespondWithError(w, http.StatusBadRequest, "Note ID is required")
+	// Note ID from the route: /api/notes/{id}
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	var req models.UpdateNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if req.EncryptedContent == "" || req.IV == "" {
+		RespondWithError(w, http.StatusBadRequest, "Content and IV are required")
+		return
+	}
+	if (req.EncryptedKey == "") != (req.EncryptedKeyIV == "") {
+		RespondWithError(w, http.StatusBadRequest, "Encrypted key and encrypted key IV must be provided together")
+		return
+	}
+	if req.Version <= 0 {
+		RespondWithError(w, http.StatusBadRequest, "Version is required")
 		return
 	}
 
-	noteID, err := strconv.ParseUint(pathParts[len(pathParts)-1], 10, 32)
+	env := envFor(r)
+	db := env.DB
+
+	// Archive the current ciphertext and replace it in one transaction
+	var note models.Note
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", noteID, claims.UserID).First(&note).Error; err != nil {
+			return err
+		}
+		return replaceNoteContent(tx, &note, req.Version, env.Now(), req.EncryptedContent, req.IV, req.EncryptedKey, req.EncryptedKeyIV, req.KeyKDF)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondWithError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		if errors.Is(err, errVersionConflict) {
+			RespondWithError(w, http.StatusConflict,
+				fmt.Sprintf("Note was modified since version %d (current version is %d)", req.Version, note.Version))
+			return
+		}
+		log.Printf("Error updating note: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update note")
+		return
+	}
+
+	log.Printf("✏️ Note updated: id=%d, version=%d", note.ID, note.Version)
+
+	RespondWithJSON(w, http.StatusOK, models.NoteResponse{
+		ID:               note.ID,
+		Title:            note.Title,
+		EncryptedContent: note.EncryptedContent,
+		IV:               note.IV,
+		EncryptedKey:     note.EncryptedKey,
+		EncryptedKeyIV:   note.EncryptedKeyIV,
+		KeyKDF:           note.KeyKDF,
+		CreatedAt:        note.CreatedAt,
+		UpdatedAt:        note.UpdatedAt,
+		Version:          note.Version,
+	})
+}
+
+// DeleteNoteHandler deletes a note by ID
+func DeleteNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Note ID from the route: /api/notes/{id}
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
 		return
 	}
 
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Delete note (only if it belongs to the user)
 	result := db.Where("id = ? AND user_id = ?", noteID, claims.UserID).Delete(&models.Note{})
@@ -217,6 +297,15 @@ func DeleteNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Old versions go away with the note
+	if err := db.Where("note_id = ?", noteID).Delete(&models.NoteRevision{}).Error; err != nil {
+		log.Printf("Error deleting note revisions: %v", err)
+	}
+	// and so does the access log of its share links
+	if err := db.Where("note_id = ?", noteID).Delete(&models.ShareAccessEvent{}).Error; err != nil {
+		log.Printf("Error deleting share access log: %v", err)
+	}
+
 	RespondWithJSON(w, http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Message: "Note deleted successfully",
@@ -237,22 +326,14 @@ func RevokeShareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract note ID from URL path: /api/notes/:id/revoke
-	pathParts := strings.Split(r.URL.Path, "/")
-	// pathParts = ["", "api", "notes", "id", "revoke"]
-	if len(pathParts) < 4 {
-		RespondWithError(w, http.StatusBadRequest, "Note ID is required")
-		return
-	}
-
-	// The ID is at position len(pathParts)-2 (before "revoke")
-	noteID, err := strconv.ParseUint(pathParts[len(pathParts)-2], 10, 32)
+	// Note ID from the route: /api/notes/{id}/revoke
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
 		return
 	}
 
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Verify note belongs to user
 	var note models.Note
@@ -281,19 +362,30 @@ func RevokeShareHandler(w http.ResponseWriter, r *http.Request) {
 
 // Helper Functions
 
-// AuthenticateRequest validates JWT token from Authorization header
+// AuthenticateRequest validates JWT token from Authorization header. Without a
+// header, a verified TLS client certificate identifies the user instead.
 func AuthenticateRequest(r *http.Request) (*auth.Claims, error) {
+	env := envFor(r)
 	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return auth.AuthenticateClientCert(env.DB, r.TLS.VerifiedChains[0][0], env.ClientCertUsers)
+	}
+
 	tokenString, err := auth.ExtractTokenFromHeader(authHeader)
 	if err != nil {
 		return nil, err
 	}
 
-	claims, err := auth.ValidateJWT(tokenString)
+	claims, err := env.Tokens.ValidateJWT(tokenString)
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
+	// Reject tokens revoked by logout or "revoke all"
+	if err := auth.CheckRevocation(env.DB, claims); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
 	return claims, nil
 }
 
@@ -311,14 +403,8 @@ func CreateShareHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract note ID from URL path
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 4 {
-		RespondWithError(w, http.StatusBadRequest, "Note ID is required")
-		return
-	}
-
-	noteID, err := strconv.ParseUint(pathParts[len(pathParts)-2], 10, 32)
+	// Note ID from the route: /api/notes/{id}/share
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
 		return
@@ -326,23 +412,30 @@ func CreateShareHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req models.CreateShareRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		req.DurationHours = 24 // Default 24 hours
+		req = models.CreateShareRequest{} // No usable body: use the defaults
+	}
+	if req.BurnAfterReading && (req.EncryptedContent == "" || req.IV == "") {
+		RespondWithError(w, http.StatusBadRequest, "Burn-after-reading links need their own encrypted copy of the note")
+		return
 	}
 
+	env := envFor(r)
+
 	// Calculate expiration duration
-	var duration time.Duration
+	duration := env.ShareDefaultDuration
 	if req.DurationMinutes > 0 {
 		// Use minutes if specified (for testing)
 		duration = time.Minute * time.Duration(req.DurationMinutes)
 	} else if req.DurationHours > 0 {
 		// Use hours if specified
 		duration = time.Hour * time.Duration(req.DurationHours)
-	} else {
-		// Default 24 hours
-		duration = time.Hour * 24
+	}
+	if duration > env.ShareMaxDuration {
+		RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Share duration cannot exceed %g hours", env.ShareMaxDuration.Hours()))
+		return
 	}
 
-	db := database.GetDB()
+	db := env.DB
 
 	// Verify note belongs to user
 	var note models.Note
@@ -369,7 +462,7 @@ func CreateShareHandler(w http.ResponseWriter, r *http.Request) {
 		NoteID:          uint(noteID),
 		UserID:          claims.UserID,
 		ShareToken:      shareToken,
-		ExpiresAt:       time.Now().Add(duration),
+		ExpiresAt:       env.Now().Add(duration),
 		MaxAccessCount:  0, // Default: unlimited
 		AccessCount:     0,
 		RequirePassword: false,
@@ -393,46 +486,55 @@ func CreateShareHandler(w http.ResponseWriter, r *http.Request) {
 		shareLink.MaxAccessCount = *req.MaxAccessCount
 	}
 
+	// A burn-after-reading link serves its own copy, once
+	if req.BurnAfterReading {
+		shareLink.BurnAfterReading = true
+		shareLink.EncryptedContent = req.EncryptedContent
+		shareLink.IV = req.IV
+		shareLink.MaxAccessCount = 1
+	}
+
 	if err := db.Create(&shareLink).Error; err != nil {
 		log.Printf("Error creating share link: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Failed to create share link")
 		return
 	}
 
-	log.Printf("✅ Share link created: token=%s, expires_at=%v, duration=%v, max_access=%d, password_protected=%v", 
-		shareToken[:10]+"...", shareLink.ExpiresAt, duration, shareLink.MaxAccessCount, shareLink.RequirePassword)
+	log.Printf("✅ Share link created: token=%s, expires_at=%v, duration=%v, max_access=%d, password_protected=%v, burn_after_reading=%v", 
+		shareToken[:10]+"...", shareLink.ExpiresAt, duration, shareLink.MaxAccessCount, shareLink.RequirePassword, shareLink.BurnAfterReading)
 
 	// Create share URL (the encryption key should be added by client in fragment)
-	shareURL := fmt.Sprintf("http://localhost:8080/share/%s", shareToken)
+	shareURL := fmt.Sprintf("%s/share/%s", env.PublicBaseURL, shareToken)
 
 	RespondWithJSON(w, http.StatusCreated, models.ShareLinkResponse{
-		Success:         true,
-		ShareToken:      shareToken,
-		ShareURL:        shareURL,
-		ExpiresAt:       shareLink.ExpiresAt,
-		MaxAccessCount:  shareLink.MaxAccessCount,
-		RequirePassword: shareLink.RequirePassword,
-		Message:         "Share link created successfully",
+		Success:          true,
+		ShareToken:       shareToken,
+		ShareURL:         shareURL,
+		ExpiresAt:        shareLink.ExpiresAt,
+		MaxAccessCount:   shareLink.MaxAccessCount,
+		RequirePassword:  shareLink.RequirePassword,
+		BurnAfterReading: shareLink.BurnAfterReading,
+		Message:          "Share link created successfully",
 	})
 }
 
 // GetSharedNoteHandler retrieves a note via share token with time validation
 func GetSharedNoteHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	// POST carries the password of protected links; GET is kept for older clients
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
 		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Extract share token from URL path: /api/shares/:token
-	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/shares/"), "/")
-	if len(pathParts) == 0 || pathParts[0] == "" {
+	// Share token from the route: /api/shares/{token}
+	shareToken := r.PathValue("token")
+	if shareToken == "" {
 		RespondWithError(w, http.StatusBadRequest, "Share token is required")
 		return
 	}
 
-	shareToken := pathParts[0]
-
-	db := database.GetDB()
+	env := envFor(r)
+	db := env.DB
 
 	// Find share link
 	var shareLink models.SharedLink
@@ -447,24 +549,40 @@ func GetSharedNoteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if link has expired
-	now := time.Now()
+	now := env.Now()
 	log.Printf("🔍 Checking expiry: now=%v, expires_at=%v, expired=%v", 
 		now, shareLink.ExpiresAt, now.After(shareLink.ExpiresAt))
 	
 	if now.After(shareLink.ExpiresAt) {
-		// Delete expired link
-		db.Delete(&shareLink)
-		log.Printf("❌ Share link expired and deleted: token=%s", shareToken[:10]+"...")
+		if shareLink.BurnAfterReading {
+			// Keep an expired burn-after-reading link as its owner's read receipt, without the content
+			db.Model(&shareLink).UpdateColumns(map[string]interface{}{"encrypted_content": "", "iv": ""})
+			log.Printf("❌ Burn-after-reading link expired, content destroyed: token=%s", shareToken[:10]+"...")
+		} else {
+			// Delete expired link
+			db.Delete(&shareLink)
+			log.Printf("❌ Share link expired and deleted: token=%s", shareToken[:10]+"...")
+		}
+		recordShareAccess(r, env, shareLink, models.ShareAccessExpired)
 		RespondWithError(w, http.StatusGone, "Share link has expired")
 		return
 	}
 
+	// A burnt link is kept after it is read so its owner can see when that happened
+	if shareLink.BurnAfterReading && shareLink.ReadAt != nil {
+		log.Printf("❌ Burn-after-reading link already read: token=%s", shareToken[:10]+"...")
+		recordShareAccess(r, env, shareLink, models.ShareAccessAlreadyRead)
+		RespondWithError(w, http.StatusGone, "Share link has already been read and its content destroyed")
+		return
+	}
+
 	// Check if max access count reached
 	if shareLink.MaxAccessCount > 0 && shareLink.AccessCount >= shareLink.MaxAccessCount {
 		// Delete exhausted link
 		db.Delete(&shareLink)
 		log.Printf("❌ Share link exhausted and deleted: token=%s, access_count=%d/%d", 
 			shareToken[:10]+"...", shareLink.AccessCount, shareLink.MaxAccessCount)
+		recordShareAccess(r, env, shareLink, models.ShareAccessExhausted)
 		RespondWithError(w, http.StatusGone, "Share link has reached maximum access count")
 		return
 	}
@@ -475,40 +593,77 @@ func GetSharedNoteHandler(w http.ResponseWriter, r *http.Request) {
 	
 	if shareLink.RequirePassword {
 		var req models.AccessShareRequest
-		bodyBytes, _ := io.ReadAll(r.Body)
-		log.Printf("📥 Request body received: %s", string(bodyBytes))
-		
-		// Restore body for json.Decode
-		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
 			log.Printf("❌ Password required but not provided. Error: %v, Password empty: %v", err, req.Password == "")
+			recordShareAccess(r, env, shareLink, models.ShareAccessPasswordRequired)
 			RespondWithError(w, http.StatusUnauthorized, "Password required to access this share")
 			return
 		}
 
+		// Limit password guesses per share link
+		attemptKey := "share:" + shareToken
+		if !allowAttempt(w, env, attemptKey, attemptKey) {
+			recordShareAccess(r, env, shareLink, models.ShareAccessThrottled)
+			return
+		}
+
 		// Verify password
 		if err := auth.CheckPassword(req.Password, shareLink.PasswordHash); err != nil {
 			log.Printf("❌ Wrong password for share link: token=%s", shareToken[:10]+"...")
+			failAttempt(r, env, attemptKey)
+			recordShareAccess(r, env, shareLink, models.ShareAccessWrongPassword)
 			RespondWithError(w, http.StatusUnauthorized, "Incorrect password")
 			return
 		}
+		env.Lockout.Reset(attemptKey)
 
 		log.Printf("✅ Password verified for share link: token=%s", shareToken[:10]+"...")
 	}
 
-	// Increment access count
-	shareLink.AccessCount++
-	if err := db.Save(&shareLink).Error; err != nil {
+	// Count this access; concurrent requests for the last view race here and only one wins.
+	// The count and the success entry of the access log commit together, so a
+	// success is only logged for an open that was counted.
+	var consumed bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if shareLink.BurnAfterReading {
+			consumed, err = burnShareLink(tx, shareLink.ID, now)
+		} else {
+			consumed, err = consumeShareLink(tx, shareLink.ID)
+		}
+		if err != nil || !consumed {
+			return err
+		}
+
+		// A failed log write is rolled back to its savepoint and never fails the request
+		event := shareAccessEvent(r, env, shareLink, models.ShareAccessSuccess)
+		if err := tx.Transaction(func(tx *gorm.DB) error { return tx.Create(&event).Error }); err != nil {
+			log.Printf("❌ Failed to record share access: link=%d, outcome=%s: %v", shareLink.ID, models.ShareAccessSuccess, err)
+		}
+		return nil
+	})
+	if err != nil {
 		log.Printf("Error updating access count: %v", err)
-		// Don't fail the request, just log the error
+		RespondWithError(w, http.StatusInternalServerError, "Failed to open share link")
+		return
 	}
+	if !consumed {
+		log.Printf("❌ Share link exhausted by a concurrent request: token=%s", shareToken[:10]+"...")
+		outcome := models.ShareAccessExhausted
+		if shareLink.BurnAfterReading {
+			outcome = models.ShareAccessAlreadyRead
+		}
+		recordShareAccess(r, env, shareLink, outcome)
+		RespondWithError(w, http.StatusGone, "Share link has reached maximum access count")
+		return
+	}
+	shareLink.AccessCount++
 
 	log.Printf("✅ Share link valid: token=%s, remaining=%v, access_count=%d/%d", 
 		shareToken[:10]+"...", shareLink.ExpiresAt.Sub(now), shareLink.AccessCount, shareLink.MaxAccessCount)
 
 	// Return shared note data (without encrypted key - key should be in URL fragment)
-	RespondWithJSON(w, http.StatusOK, models.SharedNoteResponse{
+	response := models.SharedNoteResponse{
 		ID:               shareLink.Note.ID,
 		Title:            shareLink.Note.Title,
 		EncryptedContent: shareLink.Note.EncryptedContent,
@@ -516,7 +671,48 @@ func GetSharedNoteHandler(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:        shareLink.Note.CreatedAt,
 		ExpiresAt:        shareLink.ExpiresAt,
 		OwnerUsername:    shareLink.User.Username,
-	})
+	}
+	if shareLink.BurnAfterReading {
+		// The copy read before burning it; the note itself is never sent
+		response.EncryptedContent = shareLink.EncryptedContent
+		response.IV = shareLink.IV
+		response.BurnAfterReading = true
+		response.Burned = true
+		response.BurnedAt = &now
+		log.Printf("🔥 Burn-after-reading link read, copy destroyed: token=%s", shareToken[:10]+"...")
+	}
+	RespondWithJSON(w, http.StatusOK, response)
+}
+
+// consumeShareLink counts one access to a share link in a single conditional
+// UPDATE, so the check against max_access_count and the increment cannot be
+// interleaved by concurrent requests. It returns false if no views are left.
+func consumeShareLink(db *gorm.DB, linkID uint) (bool, error) {
+	result := db.Model(&models.SharedLink{}).
+		Where("id = ? AND (max_access_count = 0 OR access_count < max_access_count)", linkID).
+		UpdateColumn("access_count", gorm.Expr("access_count + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// burnShareLink marks a burn-after-reading link as read and destroys its copy
+// of the note in one conditional UPDATE. Only one request can win; it returns
+// false for the others.
+func burnShareLink(db *gorm.DB, linkID uint, now time.Time) (bool, error) {
+	result := db.Model(&models.SharedLink{}).
+		Where("id = ? AND read_at IS NULL", linkID).
+		UpdateColumns(map[string]interface{}{
+			"access_count":      gorm.Expr("access_count + 1"),
+			"encrypted_content": "",
+			"iv":                "",
+			"read_at":           now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
 }
 
 // generateSecureToken generates a cryptographically secure random token
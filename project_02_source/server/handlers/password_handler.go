@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/models"
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// errKeySetMismatch is returned when the re-wrapped keys don't cover exactly
+// the notes and revisions stored for the user
+var errKeySetMismatch = errors.New("re-wrapped keys do not match the stored notes")
+
+// ChangePasswordHandler changes the account password. The client re-wraps every
+// note and revision DEK under the new KEK and all of it is stored in one transaction,
+// so the account never ends up with keys wrapped under two different passwords.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if len(req.NewPassword) < 6 {
+		RespondWithError(w, http.StatusBadRequest, "Password must be at least 6 characters")
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		RespondWithError(w, http.StatusBadRequest, "New password must be different from the current password")
+		return
+	}
+	if !validRewrappedKeys(req.NoteKeys) || !validRewrappedKeys(req.RevisionKeys) {
+		RespondWithError(w, http.StatusBadRequest, "Every re-wrapped key needs an encrypted_key and encrypted_key_iv")
+		return
+	}
+
+	db := envFor(r).DB
+
+	var user models.User
+	if err := db.First(&user, claims.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+		log.Printf("Error finding user: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Verify old password
+	if err := auth.CheckPassword(req.OldPassword, user.PasswordHash); err != nil {
+		RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to process password")
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := applyRewrappedKeys(tx, user.ID, req.NoteKeys, req.RevisionKeys); err != nil {
+			return err
+		}
+		return tx.Model(&user).Update("password_hash", hashedPassword).Error
+	})
+	if err != nil {
+		if errors.Is(err, errKeySetMismatch) {
+			RespondWithError(w, http.StatusConflict, "Your notes changed during the password change, please try again")
+			return
+		}
+		log.Printf("Error changing password: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	log.Printf("🔑 Password changed: user=%s, notes=%d, revisions=%d", user.Username, len(req.NoteKeys), len(req.RevisionKeys))
+
+	RespondWithJSON(w, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Password changed successfully",
+	})
+}
+
+// applyRewrappedKeys stores new wrapped DEKs for every note and revision of a user.
+// It must run inside a transaction and fails with errKeySetMismatch unless the keys
+// cover exactly the user's notes and revisions.
+func applyRewrappedKeys(tx *gorm.DB, userID uint, noteKeys, revisionKeys []models.RewrappedKey) error {
+	var noteIDs []uint
+	if err := tx.Model(&models.Note{}).Where("user_id = ?", userID).Pluck("id", &noteIDs).Error; err != nil {
+		return err
+	}
+	if !coversExactly(noteIDs, noteKeys) {
+		return errKeySetMismatch
+	}
+
+	var revisionIDs []uint
+	if err := tx.Model(&models.NoteRevision{}).Where("user_id = ?", userID).Pluck("id", &revisionIDs).Error; err != nil {
+		return err
+	}
+	if !coversExactly(revisionIDs, revisionKeys) {
+		return errKeySetMismatch
+	}
+
+	// UpdateColumns keeps updated_at: only the wrapping changed, not the content
+	for _, key := range noteKeys {
+		if err := tx.Model(&models.Note{}).Where("id = ? AND user_id = ?", key.ID, userID).UpdateColumns(map[string]interface{}{
+			"encrypted_key":    key.EncryptedKey,
+			"encrypted_key_iv": key.EncryptedKeyIV,
+			"key_kdf":          key.KeyKDF,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, key := range revisionKeys {
+		if err := tx.Model(&models.NoteRevision{}).Where("id = ? AND user_id = ?", key.ID, userID).UpdateColumns(map[string]interface{}{
+			"encrypted_key":    key.EncryptedKey,
+			"encrypted_key_iv": key.EncryptedKeyIV,
+			"key_kdf":          key.KeyKDF,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// coversExactly reports whether keys has exactly one entry for each ID
+func coversExactly(ids []uint, keys []models.RewrappedKey) bool {
+	if len(ids) != len(keys) {
+		return false
+	}
+
+	remaining := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	for _, key := range keys {
+		if !remaining[key.ID] {
+			return false // Unknown or duplicate ID
+		}
+		delete(remaining, key.ID)
+	}
+	return true
+}
+
+// validRewrappedKeys checks that every key has both parts
+func validRewrappedKeys(keys []models.RewrappedKey) bool {
+	for _, key := range keys {
+		if key.EncryptedKey == "" || key.EncryptedKeyIV == "" {
+			return false
+		}
+	}
+	return true
+}
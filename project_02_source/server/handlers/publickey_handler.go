@@ -2,11 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
-	"lab02_mahoa/server/database"
 	"lab02_mahoa/server/models"
 	"log"
 	"net/http"
-	"strings"
 
 	"gorm.io/gorm"
 )
@@ -37,7 +35,7 @@ func UpdatePublicKeyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Update user's public key
 	if err := db.Model(&models.User{}).Where("id = ?", claims.UserID).Update("dh_public_key", req.DHPublicKey).Error; err != nil {
@@ -68,16 +66,14 @@ func GetPublicKeyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract username from URL path: /api/users/:username/publickey
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 4 {
+	// Username from the route: /api/users/{username}/publickey
+	username := r.PathValue("username")
+	if username == "" {
 		RespondWithError(w, http.StatusBadRequest, "Username is required")
 		return
 	}
 
-	username := pathParts[len(pathParts)-2]
-
-	db := database.GetDB()
+	db := envFor(r).DB
 
 	// Get user's public key
 	var user models.User
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit limits how many requests one client IP can make, using the
+// IPLimiter of the request's Env. Clients over the limit get a 429 with Retry-After.
+func RateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := envFor(r).IPLimiter.Allow("ip:" + clientIP(r)); !ok {
+			tooManyRequests(w, wait, "Too many requests")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientIP returns the IP address of the client that sent the request.
+// X-Forwarded-For is only believed when the connection comes from a trusted
+// proxy, because clients can set it to anything. The header is read from the
+// right: the first address that is not itself a trusted proxy is the client.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	proxies := envFor(r).TrustedProxies
+	if !isTrustedProxy(proxies, host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			// A garbled header cannot be trusted past this point
+			return host
+		}
+		if !isTrustedProxy(proxies, hop) {
+			return addr.Unmap().String()
+		}
+		host = hop
+	}
+	return host
+}
+
+// isTrustedProxy reports whether ip belongs to one of the trusted proxies
+func isTrustedProxy(proxies []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowAttempt checks whether a password attempt may go ahead: lockKey must
+// not be locked and limitKey (a username or a share token) must have a token
+// left. If not, it responds 429 and returns false.
+func allowAttempt(w http.ResponseWriter, env *Env, limitKey, lockKey string) bool {
+	if wait := env.Lockout.Locked(lockKey); wait > 0 {
+		tooManyRequests(w, wait, "Too many failed attempts")
+		return false
+	}
+	if ok, wait := env.AccountLimiter.Allow(limitKey); !ok {
+		tooManyRequests(w, wait, "Too many attempts")
+		return false
+	}
+	return true
+}
+
+// loginAttemptKeys returns the keys limiting password attempts on username.
+// The token bucket is shared by every client and brakes guessing spread over
+// many IPs; the lockout only holds back the client IP that failed, so nobody
+// can lock the real user out by failing on purpose.
+func loginAttemptKeys(r *http.Request, username string) (limitKey, lockKey string) {
+	limitKey = "login:" + username
+	return limitKey, limitKey + "@" + clientIP(r)
+}
+
+// failAttempt records a wrong password for key and logs the lockout it causes
+func failAttempt(r *http.Request, env *Env, key string) {
+	if lock := env.Lockout.Fail(key); lock > 0 {
+		name := key
+		if strings.HasPrefix(name, "share:") && len(name) > 24 {
+			name = name[:16] + "..." // Share tokens are secrets, keep them out of the log
+		}
+		log.Printf("🔒 Locked %s for %v after %d failed attempts (ip=%s)", name, lock, env.Lockout.Failures(key), clientIP(r))
+	}
+}
+
+// tooManyRequests responds 429 and tells the client when to try again
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, reason string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	RespondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("%s, try again in %d seconds", reason, seconds))
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errVersionConflict is returned when a note changed since the client read it
+var errVersionConflict = errors.New("note version conflict")
+
+// replaceNoteContent archives the current ciphertext of note as a NoteRevision and
+// replaces it with the given ciphertext. It must run inside a transaction and fails
+// with errVersionConflict if the note is no longer at expectedVersion.
+// encryptedKey may be empty to keep the current wrapped DEK; otherwise keyKDF
+// records which KDF produced the KEK that wraps it.
+func replaceNoteContent(tx *gorm.DB, note *models.Note, expectedVersion int, now time.Time, encryptedContent, iv, encryptedKey, encryptedKeyIV, keyKDF string) error {
+	if note.Version != expectedVersion {
+		return errVersionConflict
+	}
+
+	savedAt := note.UpdatedAt
+	if savedAt.IsZero() {
+		savedAt = note.CreatedAt
+	}
+
+	// Keep the old ciphertext
+	revision := models.NoteRevision{
+		NoteID:           note.ID,
+		UserID:           note.UserID,
+		Version:          note.Version,
+		EncryptedContent: note.EncryptedContent,
+		IV:               note.IV,
+		EncryptedKey:     note.EncryptedKey,
+		EncryptedKeyIV:   note.EncryptedKeyIV,
+		KeyKDF:           note.KeyKDF,
+		SavedAt:          savedAt,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+
+	updates := map[string]interface{}{
+		"encrypted_content": encryptedContent,
+		"iv":                iv,
+		"version":           gorm.Expr("version + 1"),
+		"updated_at":        now,
+	}
+	if encryptedKey != "" {
+		updates["encrypted_key"] = encryptedKey
+		updates["encrypted_key_iv"] = encryptedKeyIV
+		updates["key_kdf"] = keyKDF
+	}
+
+	// Conditional update: only succeeds if nobody changed the note since it was read
+	result := tx.Model(&models.Note{}).
+		Where("id = ? AND version = ?", note.ID, expectedVersion).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+
+	return tx.First(note, note.ID).Error
+}
+
+// ListNoteRevisionsHandler lists previous versions of a note (wrapped keys, without content)
+func ListNoteRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Note ID from the route: /api/notes/{id}/revisions
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	db := envFor(r).DB
+
+	// Verify note belongs to user
+	var note models.Note
+	if err := db.Where("id = ? AND user_id = ?", noteID, claims.UserID).First(&note).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		log.Printf("Error fetching note: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch note")
+		return
+	}
+
+	var revisions []models.NoteRevision
+	if err := db.Where("note_id = ?", noteID).Order("version DESC, id DESC").Find(&revisions).Error; err != nil {
+		log.Printf("Error fetching revisions: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch revisions")
+		return
+	}
+
+	revisionResponses := make([]models.NoteRevisionResponse, len(revisions))
+	for i, revision := range revisions {
+		revisionResponses[i] = models.NoteRevisionResponse{
+			ID:             revision.ID,
+			NoteID:         revision.NoteID,
+			Version:        revision.Version,
+			EncryptedKey:   revision.EncryptedKey,
+			EncryptedKeyIV: revision.EncryptedKeyIV,
+			KeyKDF:         revision.KeyKDF,
+			Size:           len(revision.EncryptedContent),
+			SavedAt:        revision.SavedAt,
+			CreatedAt:      revision.CreatedAt,
+		}
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.ListNoteRevisionsResponse{
+		Revisions: revisionResponses,
+		Count:     len(revisionResponses),
+	})
+}
+
+// GetNoteRevisionHandler returns one previous version of a note with its ciphertext
+func GetNoteRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// IDs from the route: /api/notes/{id}/revisions/{revisionId}
+	noteID, revisionID, err := parseRevisionPath(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db := envFor(r).DB
+
+	var revision models.NoteRevision
+	if err := db.Where("id = ? AND note_id = ? AND user_id = ?", revisionID, noteID, claims.UserID).First(&revision).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusNotFound, "Revision not found")
+			return
+		}
+		log.Printf("Error fetching revision: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch revision")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.NoteRevisionResponse{
+		ID:               revision.ID,
+		NoteID:           revision.NoteID,
+		Version:          revision.Version,
+		EncryptedContent: revision.EncryptedContent,
+		IV:               revision.IV,
+		EncryptedKey:     revision.EncryptedKey,
+		EncryptedKeyIV:   revision.EncryptedKeyIV,
+		KeyKDF:           revision.KeyKDF,
+		Size:             len(revision.EncryptedContent),
+		SavedAt:          revision.SavedAt,
+		CreatedAt:        revision.CreatedAt,
+	})
+}
+
+// RestoreNoteRevisionHandler makes an older revision the current content of a note.
+// The content being replaced is archived as a new revision, so a restore can be undone.
+func RestoreNoteRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// IDs from the route: /api/notes/{id}/revisions/{revisionId}/restore
+	noteID, revisionID, err := parseRevisionPath(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Body is optional
+	var req models.RestoreRevisionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	env := envFor(r)
+	db := env.DB
+
+	var note models.Note
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", noteID, claims.UserID).First(&note).Error; err != nil {
+			return err
+		}
+
+		var revision models.NoteRevision
+		if err := tx.Where("id = ? AND note_id = ?", revisionID, noteID).First(&revision).Error; err != nil {
+			return err
+		}
+
+		expectedVersion := req.Version
+		if expectedVersion == 0 {
+			expectedVersion = note.Version
+		}
+		return replaceNoteContent(tx, &note, expectedVersion, env.Now(),
+			revision.EncryptedContent, revision.IV, revision.EncryptedKey, revision.EncryptedKeyIV, revision.KeyKDF)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondWithError(w, http.StatusNotFound, "Note or revision not found")
+			return
+		}
+		if errors.Is(err, errVersionConflict) {
+			RespondWithError(w, http.StatusConflict,
+				fmt.Sprintf("Note was modified since version %d (current version is %d)", req.Version, note.Version))
+			return
+		}
+		log.Printf("Error restoring revision: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to restore revision")
+		return
+	}
+
+	log.Printf("↩️ Note restored: id=%d, revision=%d, version=%d", note.ID, revisionID, note.Version)
+
+	RespondWithJSON(w, http.StatusOK, models.NoteResponse{
+		ID:               note.ID,
+		Title:            note.Title,
+		EncryptedContent: note.EncryptedContent,
+		IV:               note.IV,
+		EncryptedKey:     note.EncryptedKey,
+		EncryptedKeyIV:   note.EncryptedKeyIV,
+		KeyKDF:           note.KeyKDF,
+		CreatedAt:        note.CreatedAt,
+		UpdatedAt:        note.UpdatedAt,
+		Version:          note.Version,
+	})
+}
+
+// parseRevisionPath extracts note and revision IDs from /api/notes/{id}/revisions/{revisionId}[/restore]
+func parseRevisionPath(r *http.Request) (noteID uint64, revisionID uint64, err error) {
+	if r.PathValue("revisionId") == "" {
+		return 0, 0, errors.New("Revision ID is required")
+	}
+
+	noteID, err = strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		return 0, 0, errors.New("Invalid note ID")
+	}
+
+	revisionID, err = strconv.ParseUint(r.PathValue("revisionId"), 10, 32)
+	if err != nil {
+		return 0, 0, errors.New("Invalid revision ID")
+	}
+
+	return noteID, revisionID, nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/config"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/ratelimit"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Deployment settings used when a request carries no Env. The defaults match
+// config.Default; Configure replaces them.
+var (
+	// publicBaseURL is where clients reach the server, used to build share URLs
+	publicBaseURL = "http://localhost:8080"
+
+	// shareDefaultDuration is the lifetime of a share when the client does not choose one
+	shareDefaultDuration = 24 * time.Hour
+
+	// shareMaxDuration is the longest share lifetime a client may ask for
+	shareMaxDuration = 7 * 24 * time.Hour
+
+	// Rate limits and lockout of password attempts
+	ipLimiter      = ratelimit.NewLimiter(300, 300, time.Now)
+	accountLimiter = ratelimit.NewLimiter(10, 10, time.Now)
+	lockout        = ratelimit.NewLockout(5, time.Minute, time.Hour, time.Now)
+
+	// trustedProxies may report the client IP in X-Forwarded-For; none by default
+	trustedProxies []netip.Prefix
+
+	// clientCertUsers may log in with a TLS client certificate; none by default
+	clientCertUsers []string
+)
+
+// Configure applies the server configuration to handlers called without an Env
+func Configure(cfg *config.Config) {
+	publicBaseURL = cfg.PublicBaseURL
+	shareDefaultDuration = cfg.ShareDefaultDuration
+	shareMaxDuration = cfg.ShareMaxDuration
+	ipLimiter, accountLimiter, lockout = NewLimits(cfg, time.Now)
+	trustedProxies = cfg.TrustedProxyPrefixes()
+	clientCertUsers = cfg.ClientCertUsers
+}
+
+// NewLimits creates the rate limiters and lockout policy described by cfg
+func NewLimits(cfg *config.Config, now func() time.Time) (ip, account *ratelimit.Limiter, lock *ratelimit.Lockout) {
+	ip = ratelimit.NewLimiter(cfg.RateLimitPerIP, cfg.RateLimitPerIP, now)
+	account = ratelimit.NewLimiter(cfg.RateLimitPerAccount, cfg.RateLimitPerAccount, now)
+	lock = ratelimit.NewLockout(cfg.LockoutThreshold, cfg.LockoutBase, cfg.LockoutMax, now)
+	return ip, account, lock
+}
+
+// Env is everything a handler needs from the server instance that runs it.
+// WithEnv attaches it to each request, so several servers can share a process.
+type Env struct {
+	DB     *gorm.DB
+	Now    func() time.Time
+	Tokens *auth.Issuer
+
+	PublicBaseURL        string
+	ShareDefaultDuration time.Duration
+	ShareMaxDuration     time.Duration
+
+	IPLimiter      *ratelimit.Limiter // Requests per client IP
+	AccountLimiter *ratelimit.Limiter // Password attempts per username or share token
+	Lockout        *ratelimit.Lockout // Locks a username or share token after repeated failures
+
+	TrustedProxies  []netip.Prefix // Proxies whose X-Forwarded-For header names the client
+	ClientCertUsers []string       // Automation accounts allowed to log in with a client certificate
+}
+
+// envKey is the request context key for the Env
+type envKey struct{}
+
+// WithEnv makes every handler behind next use env
+func WithEnv(env *Env, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), envKey{}, env)))
+	})
+}
+
+// envFor returns the Env of a request. Requests that did not come through
+// WithEnv (handlers called directly) use the global database and settings.
+func envFor(r *http.Request) *Env {
+	if env, ok := r.Context().Value(envKey{}).(*Env); ok {
+		return env
+	}
+	return &Env{
+		DB:                   database.GetDB(),
+		Now:                  time.Now,
+		Tokens:               auth.DefaultIssuer(),
+		PublicBaseURL:        publicBaseURL,
+		ShareDefaultDuration: shareDefaultDuration,
+		ShareMaxDuration:     shareMaxDuration,
+		IPLimiter:            ipLimiter,
+		AccountLimiter:       accountLimiter,
+		Lockout:              lockout,
+		TrustedProxies:       trustedProxies,
+		ClientCertUsers:      clientCertUsers,
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ListShareLinksHandler lists the share links of a note for its owner, newest first
+func ListShareLinksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Note ID from the route: /api/notes/{id}/shares
+	noteID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+
+	env := envFor(r)
+	db := env.DB
+
+	// Verify note belongs to user
+	var note models.Note
+	if err := db.Where("id = ? AND user_id = ?", noteID, claims.UserID).First(&note).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		log.Printf("Error fetching note: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch note")
+		return
+	}
+
+	var links []models.SharedLink
+	if err := db.Where("note_id = ?", noteID).Order("created_at DESC, id DESC").Find(&links).Error; err != nil {
+		log.Printf("Error fetching share links: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch share links")
+		return
+	}
+
+	now := env.Now()
+	linkInfos := make([]models.ShareLinkInfo, len(links))
+	for i, link := range links {
+		linkInfos[i] = shareLinkInfo(link, now)
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.ListShareLinksResponse{
+		Links: linkInfos,
+		Count: len(linkInfos),
+	})
+}
+
+// UpdateShareLinkHandler changes the expiry or the max access count of one share link
+func UpdateShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// IDs from the route: /api/notes/{id}/shares/{linkId}
+	noteID, linkID, err := parseShareLinkPath(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req models.UpdateShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.DurationHours == nil && req.MaxAccessCount == nil {
+		RespondWithError(w, http.StatusBadRequest, "Nothing to update: set duration_hours or max_access_count")
+		return
+	}
+
+	env := envFor(r)
+	db := env.DB
+
+	var link models.SharedLink
+	if err := db.Where("id = ? AND note_id = ? AND user_id = ?", linkID, noteID, claims.UserID).First(&link).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusNotFound, "Share link not found")
+			return
+		}
+		log.Printf("Error fetching share link: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch share link")
+		return
+	}
+
+	now := env.Now()
+	updates := make(map[string]interface{})
+	if req.DurationHours != nil {
+		duration := time.Hour * time.Duration(*req.DurationHours)
+		if duration <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "duration_hours must be positive")
+			return
+		}
+		if duration > env.ShareMaxDuration {
+			RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Share duration cannot exceed %g hours", env.ShareMaxDuration.Hours()))
+			return
+		}
+		link.ExpiresAt = now.Add(duration)
+		updates["expires_at"] = link.ExpiresAt
+	}
+	if req.MaxAccessCount != nil {
+		if *req.MaxAccessCount < 0 {
+			RespondWithError(w, http.StatusBadRequest, "max_access_count cannot be negative")
+			return
+		}
+		if link.BurnAfterReading {
+			RespondWithError(w, http.StatusBadRequest, "A burn-after-reading link can only be opened once")
+			return
+		}
+		link.MaxAccessCount = *req.MaxAccessCount
+		updates["max_access_count"] = link.MaxAccessCount
+	}
+
+	if err := db.Model(&link).Updates(updates).Error; err != nil {
+		log.Printf("Error updating share link: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to update share link")
+		return
+	}
+
+	log.Printf("✅ Share link updated: id=%d, note=%d, expires_at=%v, max_access=%d",
+		link.ID, link.NoteID, link.ExpiresAt, link.MaxAccessCount)
+
+	RespondWithJSON(w, http.StatusOK, shareLinkInfo(link, now))
+}
+
+// DeleteShareLinkHandler revokes one share link of a note, leaving the others working
+func DeleteShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Authenticate user
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// IDs from the route: /api/notes/{id}/shares/{linkId}
+	noteID, linkID, err := parseShareLinkPath(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db := envFor(r).DB
+
+	result := db.Where("id = ? AND note_id = ? AND user_id = ?", linkID, noteID, claims.UserID).Delete(&models.SharedLink{})
+	if result.Error != nil {
+		log.Printf("Error revoking share link: %v", result.Error)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to revoke share link")
+		return
+	}
+	if result.RowsAffected == 0 {
+		RespondWithError(w, http.StatusNotFound, "Share link not found")
+		return
+	}
+
+	log.Printf("🚫 Share link revoked: id=%d, note=%d", linkID, noteID)
+
+	RespondWithJSON(w, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Share link revoked successfully",
+	})
+}
+
+// shareLinkInfo describes a share link to its owner
+func shareLinkInfo(link models.SharedLink, now time.Time) models.ShareLinkInfo {
+	status := "active"
+	switch {
+	case link.ReadAt != nil:
+		status = "read"
+	case !now.Before(link.ExpiresAt):
+		status = "expired"
+	case link.MaxAccessCount > 0 && link.AccessCount >= link.MaxAccessCount:
+		status = "exhausted"
+	}
+
+	return models.ShareLinkInfo{
+		ID:               link.ID,
+		NoteID:           link.NoteID,
+		ShareToken:       link.ShareToken,
+		CreatedAt:        link.CreatedAt,
+		ExpiresAt:        link.ExpiresAt,
+		AccessCount:      link.AccessCount,
+		MaxAccessCount:   link.MaxAccessCount,
+		RequirePassword:  link.RequirePassword,
+		BurnAfterReading: link.BurnAfterReading,
+		ReadAt:           link.ReadAt,
+		Status:           status,
+	}
+}
+
+// parseShareLinkPath extracts note and link IDs from /api/notes/{id}/shares/{linkId}
+func parseShareLinkPath(r *http.Request) (noteID uint64, linkID uint64, err error) {
+	noteID, err = strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		return 0, 0, errors.New("Invalid note ID")
+	}
+
+	linkID, err = strconv.ParseUint(r.PathValue("linkId"), 10, 32)
+	if err != nil {
+		return 0, 0, errors.New("Invalid share link ID")
+	}
+
+	return noteID, linkID, nil
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/models"
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// TOTPStatusHandler tells whether two-factor authentication is enabled
+func TOTPStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	var left int64
+	if user.TOTPEnabled {
+		if err := envFor(r).DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&left).Error; err != nil {
+			log.Printf("Error counting recovery codes: %v", err)
+			RespondWithError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.TOTPStatusResponse{
+		Enabled:           user.TOTPEnabled,
+		RecoveryCodesLeft: int(left),
+	})
+}
+
+// TOTPSetupHandler generates a new TOTP secret. It is not required at login
+// until the user proves their authenticator works with TOTPConfirmHandler.
+func TOTPSetupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	key, err := auth.GenerateTOTPKey(user.Username)
+	if err != nil {
+		log.Printf("Error generating TOTP secret: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+
+	if err := envFor(r).DB.Model(&user).Update("totp_secret", key.Secret()).Error; err != nil {
+		log.Printf("Error saving TOTP secret: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to save secret")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.TOTPSetupResponse{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+		Message:    "Add the secret to your authenticator app, then confirm with a code",
+	})
+}
+
+// TOTPConfirmHandler enables two-factor authentication once the user sends a
+// valid code for the new secret, and returns a fresh set of recovery codes
+func TOTPConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.TOTPConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		RespondWithError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	if user.TOTPEnabled {
+		RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if user.TOTPSecret == "" {
+		RespondWithError(w, http.StatusConflict, "Start two-factor setup first")
+		return
+	}
+
+	env := envFor(r)
+	step, err := auth.CheckTOTP(user.TOTPSecret, req.Code, env.Now(), 0)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid code, check the time on your device and try again")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	err = env.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, user.ID, codes)
+	})
+	if err != nil {
+		log.Printf("Error enabling TOTP: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	log.Printf("🛡️ Two-factor authentication enabled: user=%s", user.Username)
+
+	RespondWithJSON(w, http.StatusOK, models.TOTPConfirmResponse{
+		Success:       true,
+		RecoveryCodes: codes,
+		Message:       "Two-factor authentication enabled. Store the recovery codes somewhere safe, they are shown only once",
+	})
+}
+
+// TOTPDisableHandler turns two-factor authentication off. It needs the
+// password and a current TOTP or recovery code.
+func TOTPDisableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.TOTPDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !user.TOTPEnabled {
+		RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+
+	env := envFor(r)
+	limitKey, lockKey := loginAttemptKeys(r, user.Username)
+	if !allowAttempt(w, env, limitKey, lockKey) {
+		return
+	}
+	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		failAttempt(r, env, lockKey)
+		RespondWithError(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+	if !checkSecondFactor(w, r, env, &user, req.OTP, req.RecoveryCode, lockKey) {
+		return
+	}
+
+	err := env.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		log.Printf("Error disabling TOTP: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	log.Printf("🛡️ Two-factor authentication disabled: user=%s", user.Username)
+
+	RespondWithJSON(w, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// MFALoginHandler completes a login that answered mfa_required, exchanging the
+// login challenge and a TOTP or recovery code for the tokens
+func MFALoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		RespondWithError(w, http.StatusBadRequest, "Login challenge is required")
+		return
+	}
+	if req.OTP == "" && req.RecoveryCode == "" {
+		RespondWithError(w, http.StatusBadRequest, "Two-factor code is required")
+		return
+	}
+
+	env := envFor(r)
+	db := env.DB
+
+	challenge, err := env.Tokens.UseMFAChallenge(db, req.MFAToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidMFAChallenge) {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		log.Printf("Error checking login challenge: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, challenge.UserID).Error; err != nil || !user.TOTPEnabled {
+		RespondWithError(w, http.StatusUnauthorized, auth.ErrInvalidMFAChallenge.Error())
+		return
+	}
+
+	limitKey, lockKey := loginAttemptKeys(r, user.Username)
+	if !allowAttempt(w, env, limitKey, lockKey) {
+		return
+	}
+	if !checkSecondFactor(w, r, env, &user, req.OTP, req.RecoveryCode, lockKey) {
+		return
+	}
+
+	// The challenge is single-use
+	db.Delete(challenge)
+	env.Lockout.Reset(lockKey)
+	respondWithLogin(w, env, user)
+}
+
+// authenticatedUser loads the user of an authenticated request, responding 401 if there is none
+func authenticatedUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	var user models.User
+
+	claims, err := AuthenticateRequest(r)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return user, false
+	}
+
+	if err := envFor(r).DB.First(&user, claims.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			RespondWithError(w, http.StatusUnauthorized, "User not found")
+			return user, false
+		}
+		log.Printf("Error finding user: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Database error")
+		return user, false
+	}
+	return user, true
+}
+
+// checkSecondFactor verifies a TOTP or recovery code of user. A wrong code
+// counts as a failed attempt against lockKey and gets a 401.
+func checkSecondFactor(w http.ResponseWriter, r *http.Request, env *Env, user *models.User, otp, recoveryCode, lockKey string) bool {
+	err := verifySecondFactor(env, user, otp, recoveryCode)
+	if err == nil {
+		return true
+	}
+
+	if errors.Is(err, auth.ErrInvalidOTP) {
+		failAttempt(r, env, lockKey)
+		RespondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return false
+	}
+	log.Printf("Error checking second factor: %v", err)
+	RespondWithError(w, http.StatusInternalServerError, "Database error")
+	return false
+}
+
+// verifySecondFactor accepts a TOTP code newer than the last one used, or an
+// unused recovery code, and marks it used. Both updates are conditional so two
+// concurrent requests cannot use the same code.
+func verifySecondFactor(env *Env, user *models.User, otp, recoveryCode string) error {
+	db := env.DB
+
+	if otp != "" {
+		step, err := auth.CheckTOTP(user.TOTPSecret, otp, env.Now(), user.TOTPLastStep)
+		if err != nil {
+			return auth.ErrInvalidOTP
+		}
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return auth.ErrInvalidOTP
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if recoveryCode == "" {
+		return auth.ErrInvalidOTP
+	}
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, auth.HashRecoveryCode(recoveryCode)).
+		Update("used_at", env.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrInvalidOTP
+	}
+	log.Printf("🛟 Recovery code used: user=%s", user.Username)
+	return nil
+}
+
+// replaceRecoveryCodes stores codes as the only recovery codes of a user
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	recoveryCodes := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		recoveryCodes[i] = models.RecoveryCode{UserID: userID, CodeHash: auth.HashRecoveryCode(code)}
+	}
+	return tx.Create(&recoveryCodes).Error
+}
@@ -1,44 +1,247 @@
 package jobs
 
 import (
+	"context"
 	"lab02_mahoa/server/database"
 	"lab02_mahoa/server/models"
 	"log"
+	"sort"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// CleanupOptions configures the background cleanup job
+type CleanupOptions struct {
+	// Interval between cleanup runs
+	Interval time.Duration
+	// RevisionMaxAge removes note revisions older than this (0 keeps them forever)
+	RevisionMaxAge time.Duration
+	// MaxRevisionsPerNote keeps only the newest N revisions of each note (0 keeps all)
+	MaxRevisionsPerNote int
+	// AccessLogMaxAge removes share access events older than this (0 keeps them forever)
+	AccessLogMaxAge time.Duration
+	// ReceiptMaxAge removes burn-after-reading shares this long after they expire (0 keeps them forever)
+	ReceiptMaxAge time.Duration
+	// Now is the clock used to decide what has expired (nil uses time.Now)
+	Now func() time.Time
+}
+
+// DefaultCleanupOptions returns the options used by StartCleanupJob
+func DefaultCleanupOptions() CleanupOptions {
+	return CleanupOptions{
+		Interval:            1 * time.Hour,
+		RevisionMaxAge:      0,
+		MaxRevisionsPerNote: 50,
+		AccessLogMaxAge:     30 * 24 * time.Hour,
+		ReceiptMaxAge:       30 * 24 * time.Hour,
+	}
+}
+
 // StartCleanupJob starts a background job to clean up expired shares and links
 func StartCleanupJob(db *gorm.DB) {
-	log.Println("🧹 Starting cleanup job for expired shares and links...")
+	StartCleanupJobWithOptions(db, DefaultCleanupOptions())
+}
+
+// StartCleanupJobWithOptions starts the cleanup job with custom interval and retention
+func StartCleanupJobWithOptions(db *gorm.DB, opts CleanupOptions) {
+	StartCleanupJobContext(context.Background(), db, opts)
+}
 
-	// Run cleanup every hour
-	ticker := time.NewTicker(1 * time.Hour)
-	
-	// Run immediately on start
-	go cleanupExpiredData(db)
+// StartCleanupJobContext starts the cleanup job and stops it when ctx is cancelled.
+// The returned channel is closed once the job has stopped; a cleanup pass that is
+// already running is allowed to finish first.
+func StartCleanupJobContext(ctx context.Context, db *gorm.DB, opts CleanupOptions) <-chan struct{} {
+	log.Println("🧹 Starting cleanup job for expired shares, links and revoked tokens...")
 
-	// Then run periodically
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultCleanupOptions().Interval
+	}
+
+	done := make(chan struct{})
 	go func() {
-		for range ticker.C {
-			cleanupExpiredData(db)
+		defer close(done)
+
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+
+		// Run immediately on start, then periodically
+		runCleanup(db, opts)
+		for {
+			select {
+			case <-ticker.C:
+				runCleanup(db, opts)
+			case <-ctx.Done():
+				log.Println("🧹 Cleanup job stopped")
+				return
+			}
 		}
 	}()
+
+	return done
+}
+
+// runCleanup runs every cleanup step once
+func runCleanup(db *gorm.DB, opts CleanupOptions) {
+	now := opts.now()
+	cleanupExpiredData(db, now)
+	pruneNoteRevisions(db, opts)
+	pruneRevokedTokens(db, now)
+	pruneRefreshTokens(db, now)
+	pruneMFAChallenges(db, now)
+	pruneShareAccessEvents(db, opts)
+	pruneReadReceipts(db, opts)
 }
 
-// cleanupExpiredData removes expired shared links and E2EE shares
-func cleanupExpiredData(db *gorm.DB) {
-	now := time.Now()
-	
+// now returns the current time of the options' clock
+func (opts CleanupOptions) now() time.Time {
+	if opts.Now == nil {
+		return time.Now()
+	}
+	return opts.Now()
+}
+
+// pruneRevokedTokens forgets revoked tokens that have expired anyway
+func pruneRevokedTokens(db *gorm.DB, now time.Time) {
+	deleteResult := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	if deleteResult.Error != nil {
+		log.Printf("❌ Error deleting expired revoked tokens: %v", deleteResult.Error)
+	} else if deleteResult.RowsAffected > 0 {
+		log.Printf("🧹 Cleaned up %d expired revoked tokens", deleteResult.RowsAffected)
+	}
+}
+
+// pruneRefreshTokens removes expired refresh tokens. Used tokens are kept until
+// they expire so that reuse can still be detected.
+func pruneRefreshTokens(db *gorm.DB, now time.Time) {
+	deleteResult := db.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+	if deleteResult.Error != nil {
+		log.Printf("❌ Error deleting expired refresh tokens: %v", deleteResult.Error)
+	} else if deleteResult.RowsAffected > 0 {
+		log.Printf("🧹 Cleaned up %d expired refresh tokens", deleteResult.RowsAffected)
+	}
+}
+
+// pruneMFAChallenges removes login challenges nobody answered in time
+func pruneMFAChallenges(db *gorm.DB, now time.Time) {
+	deleteResult := db.Where("expires_at < ?", now).Delete(&models.MFAChallenge{})
+	if deleteResult.Error != nil {
+		log.Printf("❌ Error deleting expired login challenges: %v", deleteResult.Error)
+	} else if deleteResult.RowsAffected > 0 {
+		log.Printf("🧹 Cleaned up %d expired login challenges", deleteResult.RowsAffected)
+	}
+}
+
+// pruneShareAccessEvents removes share access events past the retention period
+func pruneShareAccessEvents(db *gorm.DB, opts CleanupOptions) {
+	if opts.AccessLogMaxAge <= 0 {
+		return
+	}
+	cutoff := opts.now().Add(-opts.AccessLogMaxAge)
+	deleteResult := db.Where("created_at < ?", cutoff).Delete(&models.ShareAccessEvent{})
+	if deleteResult.Error != nil {
+		log.Printf("❌ Error deleting old share access events: %v", deleteResult.Error)
+	} else if deleteResult.RowsAffected > 0 {
+		log.Printf("🧹 Cleaned up %d share access events older than %v", deleteResult.RowsAffected, opts.AccessLogMaxAge)
+	}
+}
+
+// pruneReadReceipts removes burn-after-reading shares, kept after expiry as
+// read receipts, once their retention period is over
+func pruneReadReceipts(db *gorm.DB, opts CleanupOptions) {
+	if opts.ReceiptMaxAge <= 0 {
+		return
+	}
+	cutoff := opts.now().Add(-opts.ReceiptMaxAge)
+
+	deleteResult := db.Where("expires_at < ? AND burn_after_reading = ?", cutoff, true).Delete(&models.SharedLink{})
+	if deleteResult.Error != nil {
+		log.Printf("❌ Error deleting old burn-after-reading links: %v", deleteResult.Error)
+	} else if deleteResult.RowsAffected > 0 {
+		log.Printf("🧹 Cleaned up %d old burn-after-reading links", deleteResult.RowsAffected)
+	}
+
+	deleteResult = db.Where("expires_at < ? AND burn_after_reading = ?", cutoff, true).Delete(&models.E2EEShare{})
+	if deleteResult.Error != nil {
+		log.Printf("❌ Error deleting old burn-after-reading E2EE shares: %v", deleteResult.Error)
+	} else if deleteResult.RowsAffected > 0 {
+		log.Printf("🧹 Cleaned up %d old burn-after-reading E2EE shares", deleteResult.RowsAffected)
+	}
+}
+
+// pruneNoteRevisions applies the revision retention policy
+func pruneNoteRevisions(db *gorm.DB, opts CleanupOptions) {
+	// Remove revisions older than the max age
+	if opts.RevisionMaxAge > 0 {
+		cutoff := opts.now().Add(-opts.RevisionMaxAge)
+		deleteResult := db.Where("created_at < ?", cutoff).Delete(&models.NoteRevision{})
+		if deleteResult.Error != nil {
+			log.Printf("❌ Error deleting old note revisions: %v", deleteResult.Error)
+		} else if deleteResult.RowsAffected > 0 {
+			log.Printf("🧹 Cleaned up %d old note revisions", deleteResult.RowsAffected)
+		}
+	}
+
+	// Keep only the newest revisions of each note
+	if opts.MaxRevisionsPerNote > 0 {
+		var noteIDs []uint
+		result := db.Model(&models.NoteRevision{}).
+			Group("note_id").
+			Having("COUNT(*) > ?", opts.MaxRevisionsPerNote).
+			Pluck("note_id", &noteIDs)
+		if result.Error != nil {
+			log.Printf("❌ Error finding notes with too many revisions: %v", result.Error)
+			return
+		}
+
+		var pruned int64
+		for _, noteID := range noteIDs {
+			var revisions []models.NoteRevision
+			if err := db.Select("id", "version").Where("note_id = ?", noteID).Find(&revisions).Error; err != nil {
+				log.Printf("❌ Error loading revisions of note %d: %v", noteID, err)
+				continue
+			}
+
+			// Newest first
+			sort.Slice(revisions, func(i, j int) bool {
+				if revisions[i].Version != revisions[j].Version {
+					return revisions[i].Version > revisions[j].Version
+				}
+				return revisions[i].ID > revisions[j].ID
+			})
+
+			var staleIDs []uint
+			for _, revision := range revisions[opts.MaxRevisionsPerNote:] {
+				staleIDs = append(staleIDs, revision.ID)
+			}
+
+			deleteResult := db.Where("id IN ?", staleIDs).Delete(&models.NoteRevision{})
+			if deleteResult.Error != nil {
+				log.Printf("❌ Error deleting revisions of note %d: %v", noteID, deleteResult.Error)
+				continue
+			}
+			pruned += deleteResult.RowsAffected
+		}
+		if pruned > 0 {
+			log.Printf("🧹 Cleaned up %d surplus note revisions", pruned)
+		}
+	}
+}
+
+// cleanupExpiredData removes expired shared links and E2EE shares. Expired
+// burn-after-reading shares only lose their content; they are kept as read
+// receipts until pruneReadReceipts removes them.
+func cleanupExpiredData(db *gorm.DB, now time.Time) {
+	expired := "expires_at < ? AND burn_after_reading = ?"
+
 	// Clean up expired shared links
 	var expiredLinks []models.SharedLink
-	result := db.Where("expires_at < ?", now).Find(&expiredLinks)
+	result := db.Where(expired, now, false).Find(&expiredLinks)
 	if result.Error != nil {
 		log.Printf("❌ Error finding expired links: %v", result.Error)
 	} else if len(expiredLinks) > 0 {
 		// Delete expired links
-		deleteResult := db.Where("expires_at < ?", now).Delete(&models.SharedLink{})
+		deleteResult := db.Where(expired, now, false).Delete(&models.SharedLink{})
 		if deleteResult.Error != nil {
 			log.Printf("❌ Error deleting expired links: %v", deleteResult.Error)
 		} else if deleteResult.RowsAffected > 0 {
@@ -48,12 +251,12 @@ func cleanupExpiredData(db *gorm.DB) {
 
 	// Clean up expired E2EE shares
 	var expiredShares []models.E2EEShare
-	result = db.Where("expires_at < ?", now).Find(&expiredShares)
+	result = db.Where(expired, now, false).Find(&expiredShares)
 	if result.Error != nil {
 		log.Printf("❌ Error finding expired E2EE shares: %v", result.Error)
 	} else if len(expiredShares) > 0 {
 		// Delete expired shares
-		deleteResult := db.Where("expires_at < ?", now).Delete(&models.E2EEShare{})
+		deleteResult := db.Where(expired, now, false).Delete(&models.E2EEShare{})
 		if deleteResult.Error != nil {
 			log.Printf("❌ Error deleting expired E2EE shares: %v", deleteResult.Error)
 		} else if deleteResult.RowsAffected > 0 {
@@ -61,14 +264,34 @@ func cleanupExpiredData(db *gorm.DB) {
 		}
 	}
 
-	// Clean up exhausted shared links (access_count >= max_access_count)
+	// Destroy the content of expired burn-after-reading shares nobody read
+	updateResult := db.Model(&models.SharedLink{}).
+		Where("expires_at < ? AND burn_after_reading = ? AND (encrypted_content <> '' OR iv <> '')", now, true).
+		UpdateColumns(map[string]interface{}{"encrypted_content": "", "iv": ""})
+	if updateResult.Error != nil {
+		log.Printf("❌ Error destroying content of expired burn-after-reading links: %v", updateResult.Error)
+	} else if updateResult.RowsAffected > 0 {
+		log.Printf("🧹 Destroyed the content of %d expired burn-after-reading links", updateResult.RowsAffected)
+	}
+	updateResult = db.Model(&models.E2EEShare{}).
+		Where("expires_at < ? AND burn_after_reading = ? AND (encrypted_content <> '' OR content_iv <> '')", now, true).
+		UpdateColumns(map[string]interface{}{"encrypted_content": "", "content_iv": ""})
+	if updateResult.Error != nil {
+		log.Printf("❌ Error destroying content of expired burn-after-reading E2EE shares: %v", updateResult.Error)
+	} else if updateResult.RowsAffected > 0 {
+		log.Printf("🧹 Destroyed the content of %d expired burn-after-reading E2EE shares", updateResult.RowsAffected)
+	}
+
+	// Clean up exhausted shared links (access_count >= max_access_count). Burnt
+	// links hold no content anymore and stay, as read receipts.
+	exhausted := "max_access_count > 0 AND access_count >= max_access_count AND burn_after_reading = ?"
 	var exhaustedLinks []models.SharedLink
-	result = db.Where("max_access_count > 0 AND access_count >= max_access_count").Find(&exhaustedLinks)
+	result = db.Where(exhausted, false).Find(&exhaustedLinks)
 	if result.Error != nil {
 		log.Printf("❌ Error finding exhausted links: %v", result.Error)
 	} else if len(exhaustedLinks) > 0 {
 		// Delete exhausted links
-		deleteResult := db.Where("max_access_count > 0 AND access_count >= max_access_count").Delete(&models.SharedLink{})
+		deleteResult := db.Where(exhausted, false).Delete(&models.SharedLink{})
 		if deleteResult.Error != nil {
 			log.Printf("❌ Error deleting exhausted links: %v", deleteResult.Error)
 		} else if deleteResult.RowsAffected > 0 {
@@ -85,5 +308,5 @@ func CleanupExpiredDataNow() {
 		return
 	}
 	log.Println("🧹 Manual cleanup triggered...")
-	cleanupExpiredData(db)
+	runCleanup(db, DefaultCleanupOptions())
 }
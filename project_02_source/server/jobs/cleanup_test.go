@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"context"
 	"lab02_mahoa/server/database"
 	"lab02_mahoa/server/models"
 	"testing"
@@ -17,7 +18,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// Auto-migrate models
-	if err := db.AutoMigrate(&models.User{}, &models.Note{}, &models.SharedLink{}, &models.E2EEShare{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Note{}, &models.NoteRevision{}, &models.SharedLink{}, &models.E2EEShare{}, &models.RevokedToken{}, &models.RefreshToken{}, &models.MFAChallenge{}, &models.ShareAccessEvent{}); err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
@@ -60,7 +61,7 @@ func TestCleanupExpiredSharedLinks(t *testing.T) {
 	db.Create(&validLink)
 
 	// Run cleanup
-	cleanupExpiredData(db)
+	cleanupExpiredData(db, time.Now())
 
 	// Verify expired link was deleted
 	var links []models.SharedLink
@@ -122,7 +123,7 @@ func TestCleanupExpiredE2EEShares(t *testing.T) {
 	db.Create(&validShare)
 
 	// Run cleanup
-	cleanupExpiredData(db)
+	cleanupExpiredData(db, time.Now())
 
 	// Verify expired share was deleted
 	var shares []models.E2EEShare
@@ -139,6 +140,82 @@ func TestCleanupExpiredE2EEShares(t *testing.T) {
 	t.Log("✅ Expired E2EE shares cleaned up successfully")
 }
 
+func TestCleanupKeepsReadReceiptsAfterExpiry(t *testing.T) {
+	db := setupTestDB(t)
+
+	readAt := time.Now().Add(-2 * time.Hour)
+	db.Create(&models.SharedLink{NoteID: 1, UserID: 1, ShareToken: "exhausted", ExpiresAt: time.Now().Add(time.Hour),
+		MaxAccessCount: 1, AccessCount: 1})
+	db.Create(&models.SharedLink{NoteID: 1, UserID: 1, ShareToken: "burnt", ExpiresAt: time.Now().Add(time.Hour),
+		MaxAccessCount: 1, AccessCount: 1, BurnAfterReading: true, ReadAt: &readAt})
+	db.Create(&models.SharedLink{NoteID: 1, UserID: 1, ShareToken: "burnt-expired", ExpiresAt: time.Now().Add(-time.Hour),
+		MaxAccessCount: 1, AccessCount: 1, BurnAfterReading: true, ReadAt: &readAt})
+	db.Create(&models.SharedLink{NoteID: 1, UserID: 1, ShareToken: "unread-expired", ExpiresAt: time.Now().Add(-time.Hour),
+		MaxAccessCount: 1, BurnAfterReading: true, EncryptedContent: "encrypted", IV: "iv"})
+	db.Create(&models.E2EEShare{NoteID: 1, SenderID: 1, RecipientID: 2, SenderPublicKey: "pubkey",
+		ExpiresAt: time.Now().Add(-time.Hour), BurnAfterReading: true, ReadAt: &readAt})
+	db.Create(&models.E2EEShare{NoteID: 1, SenderID: 1, RecipientID: 2, SenderPublicKey: "pubkey2", EncryptedContent: "encrypted",
+		ContentIV: "iv", ExpiresAt: time.Now().Add(-time.Hour), BurnAfterReading: true})
+
+	runCleanup(db, DefaultCleanupOptions())
+
+	// Burn-after-reading links are their sender's read receipts, even after they expire
+	var links []models.SharedLink
+	db.Order("id").Find(&links)
+	if len(links) != 3 || links[0].ShareToken != "burnt" || links[1].ShareToken != "burnt-expired" || links[2].ShareToken != "unread-expired" {
+		t.Fatalf("Expected the burn-after-reading links to remain, got %+v", links)
+	}
+	if links[1].ReadAt == nil || !links[1].ReadAt.Equal(readAt) {
+		t.Errorf("Expected the read time of the expired link to be kept, got %v", links[1].ReadAt)
+	}
+	if links[2].ReadAt != nil || links[2].EncryptedContent != "" || links[2].IV != "" {
+		t.Errorf("Expected the expired unread link to keep no content and no read time, got %+v", links[2])
+	}
+
+	var shares []models.E2EEShare
+	db.Order("id").Find(&shares)
+	if len(shares) != 2 {
+		t.Fatalf("Expected the burn-after-reading E2EE shares to remain, got %+v", shares)
+	}
+	if shares[0].ReadAt == nil {
+		t.Errorf("Expected the read time of the expired E2EE share to be kept")
+	}
+	if shares[1].EncryptedContent != "" || shares[1].ContentIV != "" {
+		t.Errorf("Expected the content of the expired unread E2EE share to be destroyed, got %+v", shares[1])
+	}
+}
+
+func TestPruneReadReceipts(t *testing.T) {
+	db := setupTestDB(t)
+
+	now := time.Now()
+	db.Create(&models.SharedLink{NoteID: 1, UserID: 1, ShareToken: "old", ExpiresAt: now.Add(-31 * 24 * time.Hour), BurnAfterReading: true})
+	db.Create(&models.SharedLink{NoteID: 1, UserID: 1, ShareToken: "recent", ExpiresAt: now.Add(-time.Hour), BurnAfterReading: true})
+	db.Create(&models.E2EEShare{NoteID: 1, SenderID: 1, RecipientID: 2, SenderPublicKey: "old", ExpiresAt: now.Add(-31 * 24 * time.Hour), BurnAfterReading: true})
+	db.Create(&models.E2EEShare{NoteID: 1, SenderID: 1, RecipientID: 2, SenderPublicKey: "recent", ExpiresAt: now.Add(-time.Hour), BurnAfterReading: true})
+
+	// 0 keeps the receipts forever
+	pruneReadReceipts(db, CleanupOptions{Now: func() time.Time { return now }})
+	var count int64
+	db.Model(&models.SharedLink{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected no links to be removed without a retention period, got %d left", count)
+	}
+
+	pruneReadReceipts(db, CleanupOptions{ReceiptMaxAge: 30 * 24 * time.Hour, Now: func() time.Time { return now }})
+
+	var links []models.SharedLink
+	db.Find(&links)
+	if len(links) != 1 || links[0].ShareToken != "recent" {
+		t.Errorf("Expected only the recently expired link to remain, got %+v", links)
+	}
+	var shares []models.E2EEShare
+	db.Find(&shares)
+	if len(shares) != 1 || shares[0].SenderPublicKey != "recent" {
+		t.Errorf("Expected only the recently expired E2EE share to remain, got %+v", shares)
+	}
+}
+
 // Note: Max access count feature can be added later by extending SharedLink model
 // with MaxAccessCount and AccessCount fields
 
@@ -164,3 +241,148 @@ func TestCleanupJobIntegration(t *testing.T) {
 
 	t.Log("✅ Cleanup job started successfully")
 }
+
+func TestCleanupJobStopsOnCancel(t *testing.T) {
+	db := setupTestDB(t)
+
+	// An expired link the first pass should remove
+	db.Create(&models.SharedLink{NoteID: 1, UserID: 1, ShareToken: "expired", ExpiresAt: time.Now().Add(-time.Hour)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := StartCleanupJobContext(ctx, db, CleanupOptions{Interval: 10 * time.Millisecond})
+
+	// Let a few passes run
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Cleanup job did not stop after its context was cancelled")
+	}
+
+	var count int64
+	db.Model(&models.SharedLink{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected expired link to be removed before stopping, found %d links", count)
+	}
+}
+
+func TestPruneNoteRevisions(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := models.User{Username: "testuser", PasswordHash: "hash"}
+	db.Create(&user)
+
+	note := models.Note{
+		UserID:           user.ID,
+		Title:            "Test Note",
+		EncryptedContent: "encrypted",
+		IV:               "iv",
+		EncryptedKey:     "key",
+		EncryptedKeyIV:   "keyiv",
+	}
+	db.Create(&note)
+
+	// Five revisions; the first one is well past the max age
+	for version := 1; version <= 5; version++ {
+		revision := models.NoteRevision{
+			NoteID:           note.ID,
+			UserID:           user.ID,
+			Version:          version,
+			EncryptedContent: "encrypted",
+			IV:               "iv",
+			SavedAt:          time.Now(),
+		}
+		db.Create(&revision)
+	}
+	db.Model(&models.NoteRevision{}).Where("version = ?", 1).Update("created_at", time.Now().Add(-48*time.Hour))
+
+	pruneNoteRevisions(db, CleanupOptions{
+		RevisionMaxAge:      24 * time.Hour,
+		MaxRevisionsPerNote: 3,
+	})
+
+	var revisions []models.NoteRevision
+	db.Order("version ASC").Find(&revisions)
+
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions remaining, got %d", len(revisions))
+	}
+
+	if revisions[0].Version != 3 {
+		t.Errorf("Expected oldest kept revision to be version 3, got %d", revisions[0].Version)
+	}
+
+	t.Log("✅ Note revisions pruned successfully")
+}
+
+func TestPruneRevokedTokens(t *testing.T) {
+	db := setupTestDB(t)
+
+	db.Create(&models.RevokedToken{JTI: "expired", UserID: 1, ExpiresAt: time.Now().Add(-1 * time.Hour)})
+	db.Create(&models.RevokedToken{JTI: "active", UserID: 1, ExpiresAt: time.Now().Add(1 * time.Hour)})
+
+	pruneRevokedTokens(db, time.Now())
+
+	var remaining []models.RevokedToken
+	db.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].JTI != "active" {
+		t.Errorf("Expected only the unexpired revocation to remain, got %+v", remaining)
+	}
+}
+
+func TestPruneRefreshTokens(t *testing.T) {
+	db := setupTestDB(t)
+
+	used := time.Now().Add(-1 * time.Minute)
+	db.Create(&models.RefreshToken{UserID: 1, FamilyID: "f", TokenHash: "expired", ExpiresAt: time.Now().Add(-1 * time.Hour)})
+	db.Create(&models.RefreshToken{UserID: 1, FamilyID: "f", TokenHash: "used", ExpiresAt: time.Now().Add(1 * time.Hour), UsedAt: &used})
+
+	pruneRefreshTokens(db, time.Now())
+
+	var remaining []models.RefreshToken
+	db.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].TokenHash != "used" {
+		t.Errorf("Expected only the unexpired (used) token to remain for reuse detection, got %d", len(remaining))
+	}
+}
+
+func TestPruneMFAChallenges(t *testing.T) {
+	db := setupTestDB(t)
+
+	db.Create(&models.MFAChallenge{UserID: 1, TokenHash: "expired", ExpiresAt: time.Now().Add(-1 * time.Minute)})
+	db.Create(&models.MFAChallenge{UserID: 1, TokenHash: "pending", ExpiresAt: time.Now().Add(5 * time.Minute)})
+
+	pruneMFAChallenges(db, time.Now())
+
+	var remaining []models.MFAChallenge
+	db.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].TokenHash != "pending" {
+		t.Errorf("Expected only the pending challenge to remain, got %+v", remaining)
+	}
+}
+
+func TestPruneShareAccessEvents(t *testing.T) {
+	db := setupTestDB(t)
+
+	now := time.Now()
+	db.Create(&models.ShareAccessEvent{LinkID: 1, NoteID: 1, UserID: 1, Outcome: models.ShareAccessSuccess, CreatedAt: now.Add(-31 * 24 * time.Hour)})
+	db.Create(&models.ShareAccessEvent{LinkID: 1, NoteID: 1, UserID: 1, Outcome: models.ShareAccessWrongPassword, CreatedAt: now.Add(-1 * time.Hour)})
+
+	// 0 keeps the log forever
+	pruneShareAccessEvents(db, CleanupOptions{Now: func() time.Time { return now }})
+	var count int64
+	db.Model(&models.ShareAccessEvent{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected no events to be removed without a retention period, got %d left", count)
+	}
+
+	pruneShareAccessEvents(db, CleanupOptions{AccessLogMaxAge: 30 * 24 * time.Hour, Now: func() time.Time { return now }})
+
+	var remaining []models.ShareAccessEvent
+	db.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].Outcome != models.ShareAccessWrongPassword {
+		t.Errorf("Expected only the recent event to remain, got %+v", remaining)
+	}
+}
@@ -10,9 +10,29 @@ type Note struct {
 	EncryptedContent string    `gorm:"type:text;not null" json:"encrypted_content"`
 	IV               string    `gorm:"not null" json:"iv"` // Initialization Vector for content
 	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 	User             User      `gorm:"foreignKey:UserID" json:"-"`
 	EncryptedKey     string    `gorm:"type:text;not null" json:"encrypted_key"`
 	EncryptedKeyIV   string    `gorm:"type:text" json:"encrypted_key_iv"` // IV for encrypted key (nullable for backward compatibility)
+	KeyKDF           string    `gorm:"type:text" json:"key_kdf"`          // KDF descriptor of the KEK that wraps EncryptedKey (empty = account default)
+	Version          int       `gorm:"not null;default:1" json:"version"` // Incremented on every update (optimistic concurrency)
+}
+
+// NoteRevision stores a previous ciphertext of a note. A revision is written
+// every time a note's content is replaced, so older versions can be restored.
+type NoteRevision struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	NoteID           uint      `gorm:"not null;index" json:"note_id"`
+	UserID           uint      `gorm:"not null;index" json:"user_id"`
+	Version          int       `gorm:"not null" json:"version"` // Note version this ciphertext belonged to
+	EncryptedContent string    `gorm:"type:text;not null" json:"encrypted_content"`
+	IV               string    `gorm:"not null" json:"iv"`
+	EncryptedKey     string    `gorm:"type:text;not null" json:"encrypted_key"`
+	EncryptedKeyIV   string    `gorm:"type:text" json:"encrypted_key_iv"`
+	KeyKDF           string    `gorm:"type:text" json:"key_kdf"`
+	SavedAt          time.Time `json:"saved_at"`   // When this version was originally saved
+	CreatedAt        time.Time `json:"created_at"` // When this version was replaced
+	Note             Note      `gorm:"foreignKey:NoteID" json:"-"`
 }
 
 // SharedLink represents a time-limited sharing link
@@ -22,28 +42,64 @@ type SharedLink struct {
 	UserID          uint      `gorm:"not null;index" json:"user_id"`
 	ShareToken      string    `gorm:"uniqueIndex;not null" json:"share_token"`
 	ExpiresAt       time.Time `gorm:"not null" json:"expires_at"`
-	MaxAccessCount  int       `gorm:"default:0" json:"max_access_count"`          // 0 = unlimited
+	MaxAccessCount  int       `gorm:"default:0" json:"max_access_count"` // 0 = unlimited
 	AccessCount     int       `gorm:"default:0" json:"access_count"`
 	RequirePassword bool      `gorm:"default:false" json:"require_password"`
-	PasswordHash    string    `gorm:"type:text" json:"-"`                         // Bcrypt hash, not exposed in JSON
+	PasswordHash    string    `gorm:"type:text" json:"-"` // Bcrypt hash, not exposed in JSON
 	CreatedAt       time.Time `json:"created_at"`
 	Note            Note      `gorm:"foreignKey:NoteID" json:"-"`
 	User            User      `gorm:"foreignKey:UserID" json:"-"`
+
+	// Burn after reading: the link serves its own copy of the note, encrypted
+	// by the client under a one-time key, and destroys it on the first read
+	BurnAfterReading bool       `gorm:"default:false" json:"burn_after_reading"`
+	EncryptedContent string     `gorm:"type:text" json:"-"` // Emptied when read
+	IV               string     `json:"-"`
+	ReadAt           *time.Time `json:"read_at,omitempty"` // When the copy was read and destroyed
+}
+
+// Outcomes of an attempt to open a share link
+const (
+	ShareAccessSuccess          = "success"
+	ShareAccessPasswordRequired = "password_required" // No password was sent for a protected link
+	ShareAccessWrongPassword    = "wrong_password"
+	ShareAccessThrottled        = "throttled" // Locked out or rate limited before the password was checked
+	ShareAccessExpired          = "expired"
+	ShareAccessExhausted        = "exhausted" // max_access_count reached
+	ShareAccessAlreadyRead      = "already_read"
+)
+
+// ShareAccessEvent records one attempt to open a share link, for the note's
+// owner to audit. Events outlive the link so that attempts on expired or
+// revoked links stay visible; the cleanup job removes old events.
+type ShareAccessEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LinkID    uint      `gorm:"not null;index" json:"link_id"`
+	NoteID    uint      `gorm:"not null;index" json:"note_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"` // Owner of the note
+	Outcome   string    `gorm:"not null" json:"outcome"`
+	IP        string    `json:"ip"`
+	UserAgent string    `gorm:"type:text" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 }
 
 // E2EEShare represents an end-to-end encrypted share between two specific users
 // Uses Diffie-Hellman key exchange for secure session key generation
 type E2EEShare struct {
-	ID                 uint      `gorm:"primaryKey" json:"id"`
-	NoteID             uint      `gorm:"not null;index" json:"note_id"`
-	SenderID           uint      `gorm:"not null;index" json:"sender_id"`
-	RecipientID        uint      `gorm:"not null;index" json:"recipient_id"`
-	SenderPublicKey    string    `gorm:"type:text;not null" json:"sender_public_key"`    // Sender's DH public key (base64)
-	EncryptedContent   string    `gorm:"type:text;not null" json:"encrypted_content"`    // Content encrypted with DH shared secret
-	ContentIV          string    `gorm:"not null" json:"content_iv"`                     // IV for encrypted content
-	ExpiresAt          time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt          time.Time `json:"created_at"`
-	Note               Note      `gorm:"foreignKey:NoteID" json:"-"`
-	Sender             User      `gorm:"foreignKey:SenderID" json:"-"`
-	Recipient          User      `gorm:"foreignKey:RecipientID" json:"-"`
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	NoteID           uint      `gorm:"not null;index" json:"note_id"`
+	SenderID         uint      `gorm:"not null;index" json:"sender_id"`
+	RecipientID      uint      `gorm:"not null;index" json:"recipient_id"`
+	SenderPublicKey  string    `gorm:"type:text;not null" json:"sender_public_key"` // Sender's DH public key (base64)
+	EncryptedContent string    `gorm:"type:text;not null" json:"encrypted_content"` // Content encrypted with DH shared secret
+	ContentIV        string    `gorm:"not null" json:"content_iv"`                  // IV for encrypted content
+	ExpiresAt        time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	Note             Note      `gorm:"foreignKey:NoteID" json:"-"`
+	Sender           User      `gorm:"foreignKey:SenderID" json:"-"`
+	Recipient        User      `gorm:"foreignKey:RecipientID" json:"-"`
+
+	// Burn after reading: EncryptedContent is destroyed when the recipient first opens the share
+	BurnAfterReading bool       `gorm:"default:false" json:"burn_after_reading"`
+	ReadAt           *time.Time `json:"read_at,omitempty"` // When the content was read and destroyed
 }
@@ -12,8 +12,95 @@ type RegisterRequest struct {
 
 // LoginRequest for user login
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username     string `json:"username" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	OTP          string `json:"otp,omitempty"`           // TOTP code, lets accounts with two-factor auth log in in one step
+	RecoveryCode string `json:"recovery_code,omitempty"` // Single-use alternative to the TOTP code
+}
+
+// MFALoginRequest completes a login that answered mfa_required
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	OTP          string `json:"otp,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// TOTPStatusResponse tells whether two-factor authentication is on
+type TOTPStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TOTPSetupResponse returns a new TOTP secret; it is not active until confirmed
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`      // Base32, for typing into an authenticator app
+	OTPAuthURI string `json:"otpauth_uri"` // otpauth://totp/... for a QR code
+	Message    string `json:"message"`
+}
+
+// TOTPConfirmRequest enables two-factor authentication with a code from the new secret
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// TOTPConfirmResponse returns the recovery codes; they are shown only once
+type TOTPConfirmResponse struct {
+	Success       bool     `json:"success"`
+	RecoveryCodes []string `json:"recovery_codes"`
+	Message       string   `json:"message"`
+}
+
+// TOTPDisableRequest turns two-factor authentication off
+type TOTPDisableRequest struct {
+	Password     string `json:"password"`
+	OTP          string `json:"otp,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// Upper bounds on KDF costs, so that stored parameters cannot make every
+// unlock of an account hang or run out of memory. client/crypto enforces the
+// same limits when it reads parameters from the server.
+const (
+	MaxArgon2idTime     = 10          // Passes
+	MaxArgon2idMemory   = 1024 * 1024 // KiB (1 GiB)
+	MaxArgon2idThreads  = 16
+	MaxPBKDF2Iterations = 10000000
+)
+
+// KDFParams describes how the client derives its KEK from the password.
+// An empty salt marks the legacy scheme (fixed salt, 100000 iterations).
+type KDFParams struct {
+	Algorithm  string `json:"algorithm"`         // "pbkdf2-sha256" or "argon2id"
+	Iterations int    `json:"iterations"`        // PBKDF2 rounds, or Argon2id time cost
+	Memory     uint32 `json:"memory,omitempty"`  // Argon2id memory cost in KiB
+	Threads    uint8  `json:"threads,omitempty"` // Argon2id parallelism
+	Salt       string `json:"salt"`              // Base64
+}
+
+// MigrateKDFRequest for moving a user to new KDF parameters.
+// Every note and revision DEK must be re-wrapped under the KEK derived with the new parameters.
+type MigrateKDFRequest struct {
+	Password     string         `json:"password"`
+	KDF          KDFParams      `json:"kdf"`
+	NoteKeys     []RewrappedKey `json:"note_keys"`
+	RevisionKeys []RewrappedKey `json:"revision_keys"`
+}
+
+// RewrappedKey is a note (or revision) DEK wrapped under a new KEK
+type RewrappedKey struct {
+	ID             uint   `json:"id"`
+	EncryptedKey   string `json:"encrypted_key"`
+	EncryptedKeyIV string `json:"encrypted_key_iv"`
+	KeyKDF         string `json:"key_kdf,omitempty"` // KDF descriptor of the new KEK
+}
+
+// ChangePasswordRequest for changing the account password.
+// Every note and revision DEK must be re-wrapped under the KEK of the new password.
+type ChangePasswordRequest struct {
+	OldPassword  string         `json:"old_password"`
+	NewPassword  string         `json:"new_password"`
+	NoteKeys     []RewrappedKey `json:"note_keys"`     // One entry per note of the user
+	RevisionKeys []RewrappedKey `json:"revision_keys"` // One entry per note revision of the user
 }
 
 // CreateNoteRequest for creating a new note
@@ -23,14 +110,42 @@ type CreateNoteRequest struct {
 	IV               string `json:"iv"`
 	EncryptedKey     string `json:"encrypted_key"`
 	EncryptedKeyIV   string `json:"encrypted_key_iv"`
+	KeyKDF           string `json:"key_kdf,omitempty"` // KDF descriptor of the KEK that wraps encrypted_key
+}
+
+// UpdateNoteRequest for replacing the ciphertext of an existing note
+type UpdateNoteRequest struct {
+	EncryptedContent string `json:"encrypted_content"`
+	IV               string `json:"iv"`
+	EncryptedKey     string `json:"encrypted_key,omitempty"`    // Optional: re-wrapped DEK
+	EncryptedKeyIV   string `json:"encrypted_key_iv,omitempty"` // Required when encrypted_key is set
+	KeyKDF           string `json:"key_kdf,omitempty"`          // KDF descriptor of the KEK that wraps encrypted_key
+	Version          int    `json:"version"`                    // Version the client read; must match the stored version
+}
+
+// RestoreRevisionRequest for restoring an older revision of a note
+type RestoreRevisionRequest struct {
+	Version int `json:"version,omitempty"` // Optional: version the client read; must match the stored version
 }
 
 // CreateShareRequest for creating a share link
 type CreateShareRequest struct {
-	DurationHours   int     `json:"duration_hours"`         // How many hours the link is valid (default 24)
-	DurationMinutes int     `json:"duration_minutes"`       // Alternative: duration in minutes (for testing)
-	Password        *string `json:"password,omitempty"`     // Optional password protection
+	DurationHours   int     `json:"duration_hours"`             // How many hours the link is valid (default 24)
+	DurationMinutes int     `json:"duration_minutes"`           // Alternative: duration in minutes (for testing)
+	Password        *string `json:"password,omitempty"`         // Optional password protection
 	MaxAccessCount  *int    `json:"max_access_count,omitempty"` // Optional max access limit (0 or nil = unlimited)
+
+	// Burn after reading: the link can be opened once and serves this copy of
+	// the note, encrypted under a fresh one-time key, which is destroyed when read
+	BurnAfterReading bool   `json:"burn_after_reading,omitempty"`
+	EncryptedContent string `json:"encrypted_content,omitempty"` // Required with burn_after_reading
+	IV               string `json:"iv,omitempty"`                // Required with burn_after_reading
+}
+
+// UpdateShareLinkRequest for changing an existing share link; omitted fields are left unchanged
+type UpdateShareLinkRequest struct {
+	DurationHours  *int `json:"duration_hours,omitempty"`   // New expiry, in hours from now
+	MaxAccessCount *int `json:"max_access_count,omitempty"` // New max access limit (0 = unlimited)
 }
 
 // AccessShareRequest for accessing a password-protected share
@@ -40,12 +155,34 @@ type AccessShareRequest struct {
 
 // Response Models
 
+// LogoutRequest for logging out. The body is optional.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"` // Revoked together with the access token
+}
+
+// RefreshTokenRequest for exchanging a refresh token for new tokens
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenResponse returns a new access token and the rotated refresh token
+type RefreshTokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+}
+
 // LoginResponse returns JWT token
 type LoginResponse struct {
-	Token       string `json:"token"`
-	Username    string `json:"username"`
-	DHPublicKey string `json:"dh_public_key,omitempty"`
-	Message     string `json:"message"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"` // Single-use token for POST /api/auth/refresh
+	ExpiresIn    int       `json:"expires_in"`    // Access token lifetime in seconds
+	Username     string    `json:"username"`
+	DHPublicKey  string    `json:"dh_public_key,omitempty"`
+	KDF          KDFParams `json:"kdf"` // Parameters for deriving the KEK from the password
+	MFARequired  bool      `json:"mfa_required,omitempty"` // Password was right but a second factor is needed; no tokens yet
+	MFAToken     string    `json:"mfa_token,omitempty"`    // Send to POST /api/auth/login/mfa with the code
+	Message      string    `json:"message"`
 }
 
 // UpdatePublicKeyRequest for updating user's DH public key
@@ -79,7 +216,10 @@ type NoteResponse struct {
 	IV               string    `json:"iv"`
 	EncryptedKey     string    `json:"encrypted_key"`
 	EncryptedKeyIV   string    `json:"encrypted_key_iv"`
+	KeyKDF           string    `json:"key_kdf,omitempty"` // KDF descriptor of the KEK; empty = account default
 	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Version          int       `json:"version"`
 	IsShared         bool      `json:"is_shared"` // Track if note has active shares
 }
 
@@ -89,35 +229,89 @@ type ListNotesResponse struct {
 	Count int            `json:"count"`
 }
 
-// SharedNoteResponse for returning shared note data
-type SharedNoteResponse struct {
+// NoteRevisionResponse for returning a previous version of a note.
+// The ciphertext is omitted when listing revisions; the wrapped DEK is always included.
+type NoteRevisionResponse struct {
 	ID               uint      `json:"id"`
-	Title            string    `json:"title"`
-	EncryptedContent string    `json:"encrypted_content"`
-	IV               string    `json:"iv"`
+	NoteID           uint      `json:"note_id"`
+	Version          int       `json:"version"`
+	EncryptedContent string    `json:"encrypted_content,omitempty"`
+	IV               string    `json:"iv,omitempty"`
+	EncryptedKey     string    `json:"encrypted_key,omitempty"`
+	EncryptedKeyIV   string    `json:"encrypted_key_iv,omitempty"`
+	KeyKDF           string    `json:"key_kdf,omitempty"`
+	Size             int       `json:"size"` // Size of the ciphertext in bytes
+	SavedAt          time.Time `json:"saved_at"`
 	CreatedAt        time.Time `json:"created_at"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	OwnerUsername    string    `json:"owner_username"`
+}
+
+// ListNoteRevisionsResponse for returning the revision history of a note
+type ListNoteRevisionsResponse struct {
+	Revisions []NoteRevisionResponse `json:"revisions"`
+	Count     int                    `json:"count"`
+}
+
+// SharedNoteResponse for returning shared note data
+type SharedNoteResponse struct {
+	ID               uint       `json:"id"`
+	Title            string     `json:"title"`
+	EncryptedContent string     `json:"encrypted_content"`
+	IV               string     `json:"iv"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	OwnerUsername    string     `json:"owner_username"`
+	BurnAfterReading bool       `json:"burn_after_reading,omitempty"`
+	Burned           bool       `json:"burned,omitempty"`    // This read destroyed the server's copy
+	BurnedAt         *time.Time `json:"burned_at,omitempty"` // When it was destroyed
 }
 
 // ShareLinkResponse for returning share link info
 type ShareLinkResponse struct {
-	Success         bool      `json:"success"`
-	ShareToken      string    `json:"share_token"`
-	ShareURL        string    `json:"share_url"`
-	ExpiresAt       time.Time `json:"expires_at"`
-	MaxAccessCount  int       `json:"max_access_count,omitempty"`  // If set
-	RequirePassword bool      `json:"require_password"`            // If password is set
-	Message         string    `json:"message"`
+	Success          bool      `json:"success"`
+	ShareToken       string    `json:"share_token"`
+	ShareURL         string    `json:"share_url"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	MaxAccessCount   int       `json:"max_access_count,omitempty"` // If set
+	RequirePassword  bool      `json:"require_password"`           // If password is set
+	BurnAfterReading bool      `json:"burn_after_reading,omitempty"`
+	Message          string    `json:"message"`
+}
+
+// ShareLinkInfo describes one share link of a note to its owner
+type ShareLinkInfo struct {
+	ID               uint       `json:"id"`
+	NoteID           uint       `json:"note_id"`
+	ShareToken       string     `json:"share_token"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	AccessCount      int        `json:"access_count"`
+	MaxAccessCount   int        `json:"max_access_count"` // 0 = unlimited
+	RequirePassword  bool       `json:"require_password"`
+	BurnAfterReading bool       `json:"burn_after_reading"`
+	ReadAt           *time.Time `json:"read_at,omitempty"` // Burn-after-reading links only
+	Status           string     `json:"status"`            // "active", "expired", "exhausted" or "read"
+}
+
+// ListShareLinksResponse for listing the share links of a note
+type ListShareLinksResponse struct {
+	Links []ShareLinkInfo `json:"links"`
+	Count int             `json:"count"`
+}
+
+// ListShareAccessEventsResponse for the access log of a note or of one of its share links
+type ListShareAccessEventsResponse struct {
+	Events []ShareAccessEvent `json:"events"` // Newest first
+	Count  int                `json:"count"`
 }
 
 // CreateE2EEShareRequest for creating an E2EE share with specific user
 type CreateE2EEShareRequest struct {
-	RecipientUsername string `json:"recipient_username"`        // Username of recipient
-	SenderPublicKey   string `json:"sender_public_key"`         // Sender's DH public key (base64)
-	EncryptedContent  string `json:"encrypted_content"`         // Content encrypted with DH shared secret
-	ContentIV         string `json:"content_iv"`                // IV for encrypted content
-	DurationHours     int    `json:"duration_hours,omitempty"`  // Optional: default 24 hours
+	RecipientUsername string `json:"recipient_username"`           // Username of recipient
+	SenderPublicKey   string `json:"sender_public_key"`            // Sender's DH public key (base64)
+	EncryptedContent  string `json:"encrypted_content"`            // Content encrypted with DH shared secret
+	ContentIV         string `json:"content_iv"`                   // IV for encrypted content
+	DurationHours     int    `json:"duration_hours,omitempty"`     // Optional: default 24 hours
+	BurnAfterReading  bool   `json:"burn_after_reading,omitempty"` // Destroy the content when the recipient first opens it
 }
 
 // E2EEShareResponse for returning E2EE share info
@@ -126,19 +320,23 @@ type E2EEShareResponse struct {
 	ShareID           uint      `json:"share_id"`
 	RecipientUsername string    `json:"recipient_username"`
 	ExpiresAt         time.Time `json:"expires_at"`
+	BurnAfterReading  bool      `json:"burn_after_reading,omitempty"`
 	Message           string    `json:"message"`
 }
 
 // E2EEShareDetailResponse for recipient to get share details
 type E2EEShareDetailResponse struct {
-	ID               uint      `json:"id"`
-	NoteTitle        string    `json:"note_title"`
-	SenderUsername   string    `json:"sender_username"`
-	SenderPublicKey  string    `json:"sender_public_key"`  // Sender's DH public key
-	EncryptedContent string    `json:"encrypted_content"`  // Content encrypted with shared secret
-	ContentIV        string    `json:"content_iv"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               uint       `json:"id"`
+	NoteTitle        string     `json:"note_title"`
+	SenderUsername   string     `json:"sender_username"`
+	SenderPublicKey  string     `json:"sender_public_key"` // Sender's DH public key
+	EncryptedContent string     `json:"encrypted_content"` // Content encrypted with shared secret
+	ContentIV        string     `json:"content_iv"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	BurnAfterReading bool       `json:"burn_after_reading,omitempty"` // Content is only returned by GET /api/e2ee/{id}, once
+	Burned           bool       `json:"burned,omitempty"`             // This read destroyed the server's copy
+	BurnedAt         *time.Time `json:"burned_at,omitempty"`
 }
 
 // ListE2EESharesResponse for listing received E2EE shares
@@ -146,3 +344,21 @@ type ListE2EESharesResponse struct {
 	Shares []E2EEShareDetailResponse `json:"shares"`
 	Count  int                       `json:"count"`
 }
+
+// ReadReceipt tells the sender of a burn-after-reading share whether it was read
+type ReadReceipt struct {
+	Type      string     `json:"type"` // "link" or "e2ee"
+	ID        uint       `json:"id"`   // Share link ID or E2EE share ID
+	NoteID    uint       `json:"note_id"`
+	NoteTitle string     `json:"note_title"`
+	Recipient string     `json:"recipient,omitempty"` // E2EE shares only
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"` // Empty until read; the copy is destroyed at that time
+}
+
+// ListReadReceiptsResponse for listing the user's burn-after-reading shares
+type ListReadReceiptsResponse struct {
+	Receipts []ReadReceipt `json:"receipts"`
+	Count    int           `json:"count"`
+}
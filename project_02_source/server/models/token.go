@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// RevokedToken is a JWT that was revoked before it expired (e.g. on logout).
+// Entries are removed by the cleanup job once the token would have expired anyway.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JTI       string    `gorm:"column:jti;uniqueIndex;not null" json:"jti"` // "jti" claim of the token
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"` // "exp" claim of the token
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RefreshToken is an opaque, single-use token for getting a new access token.
+// Only the SHA-256 hash is stored. Every use rotates it: the token is marked used
+// and a new one is issued in the same family. Presenting a used token again means
+// it was stolen, so the whole family is revoked.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"not null;index" json:"family_id"` // Shared by all tokens rotated from the same login
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`   // Hex SHA-256 of the token
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`    // Set when the token was exchanged
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // Set when the family was revoked
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the user has
+// lost their authenticator. Only the SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null;index" json:"-"` // Hex SHA-256 of the normalized code
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// MFAChallenge is the short-lived token returned by a login whose password was
+// right but which still needs a second factor. Only the SHA-256 hash is stored.
+type MFAChallenge struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	TokenHash string    `gorm:"uniqueIndex;not null" json:"-"` // Hex SHA-256 of the token
+	Attempts  int       `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
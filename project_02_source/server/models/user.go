@@ -4,9 +4,18 @@ import "time"
 
 // User represents a user in the system
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Username     string    `gorm:"uniqueIndex;not null" json:"username"`
-	PasswordHash string    `gorm:"not null" json:"-"`
-	DHPublicKey  string    `gorm:"type:text" json:"dh_public_key,omitempty"` // User's DH public key for E2EE
-	CreatedAt    time.Time `json:"created_at"`
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Username      string    `gorm:"uniqueIndex;not null" json:"username"`
+	PasswordHash  string    `gorm:"not null" json:"-"`
+	DHPublicKey   string    `gorm:"type:text" json:"dh_public_key,omitempty"` // User's DH public key for E2EE
+	KDFAlgorithm  string    `json:"-"`                                        // How the client derives its KEK from the password
+	KDFIterations int       `json:"-"` // PBKDF2 rounds, or Argon2id time cost
+	KDFMemory     uint32    `json:"-"` // Argon2id memory cost in KiB
+	KDFThreads    uint8     `json:"-"` // Argon2id parallelism
+	KDFSalt       string    `json:"-"` // Base64 per-user salt; empty means the legacy fixed salt
+	TokenVersion  int       `gorm:"not null;default:0" json:"-"` // Incremented to revoke every token issued so far
+	TOTPSecret    string    `json:"-"` // Base32 TOTP secret, set at enrollment
+	TOTPEnabled   bool      `gorm:"not null;default:false" json:"-"` // Login needs a TOTP or recovery code once set
+	TOTPLastStep  int64     `gorm:"not null;default:0" json:"-"` // Last accepted TOTP time step, so a code works only once
+	CreatedAt     time.Time `json:"created_at"`
 }
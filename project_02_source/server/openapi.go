@@ -0,0 +1,265 @@
+package server
+
+import (
+	"encoding/json"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The OpenAPI document is built from the route table and the request and
+// response structs, so it cannot describe an API the server does not have.
+// Response schemas list every field without omitempty as required and allow
+// no other fields; request schemas only describe the fields.
+
+// openAPIVersion is 3.1 because GET /api/shares/{token} takes an optional body
+const openAPIVersion = "3.1.0"
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+)
+
+// OpenAPI returns the OpenAPI document of the API as JSON
+func OpenAPI() []byte {
+	openAPIOnce.Do(func() {
+		data, err := json.MarshalIndent(buildOpenAPI(routes), "", "  ")
+		if err != nil {
+			panic("openapi: " + err.Error())
+		}
+		openAPIJSON = data
+	})
+	return openAPIJSON
+}
+
+// serveOpenAPI serves the OpenAPI document
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(OpenAPI())
+}
+
+// Schema is the subset of JSON Schema used in the document
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*Schema           `json:"schemas"`
+	SecuritySchemes map[string]map[string]string `json:"securitySchemes"`
+}
+
+// pathParam matches {name} in a route pattern
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// buildOpenAPI describes the routes
+func buildOpenAPI(routes []Route) *openAPIDocument {
+	gen := &schemaGenerator{schemas: make(map[string]*Schema)}
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: "Secure Notes API", Version: "1.0.0"},
+		Paths:   make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: gen.schemas,
+			SecuritySchemes: map[string]map[string]string{
+				"bearerAuth": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+	errorSchema := gen.schema(reflect.TypeOf(models.ErrorResponse{}), true)
+
+	// Response types first: a struct used both ways is described as a response
+	operations := make([]*openAPIOperation, len(routes))
+	for i, route := range routes {
+		op := &openAPIOperation{
+			Summary: route.Summary,
+			Tags:    []string{routeTag(route.Pattern)},
+			Responses: map[string]*openAPIResponse{
+				"default": {Description: "Error", Content: jsonContent(errorSchema)},
+			},
+		}
+		success := &openAPIResponse{Description: http.StatusText(route.Status)}
+		if route.Response != nil {
+			success.Content = jsonContent(gen.schema(reflect.TypeOf(route.Response), true))
+		} else {
+			success.Content = jsonContent(&Schema{Type: "object"})
+		}
+		op.Responses[strconv.Itoa(route.Status)] = success
+		if route.Auth {
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+		}
+		operations[i] = op
+	}
+
+	for i, route := range routes {
+		op := operations[i]
+		for _, match := range pathParam.FindAllStringSubmatch(route.Pattern, -1) {
+			param := openAPIParameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}}
+			if strings.HasSuffix(strings.ToLower(match[1]), "id") {
+				param.Schema = &Schema{Type: "integer", Minimum: intPtr(1)}
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+		if route.Request != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: route.Method == http.MethodPost || route.Method == http.MethodPut,
+				Content:  jsonContent(gen.schema(reflect.TypeOf(route.Request), false)),
+			}
+		}
+
+		if doc.Paths[route.Pattern] == nil {
+			doc.Paths[route.Pattern] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[route.Pattern][strings.ToLower(route.Method)] = op
+	}
+
+	return doc
+}
+
+// routeTag groups operations by the first path segment after /api
+func routeTag(pattern string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(pattern, "/api/"), "/")
+	switch segment {
+	case "", ".well-known":
+		return "auth"
+	case "user", "users":
+		return "users"
+	case "openapi.json":
+		return "docs"
+	}
+	return segment
+}
+
+// jsonContent wraps a schema as application/json content
+func jsonContent(schema *Schema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+func intPtr(v int) *int { return &v }
+
+func boolPtr(v bool) *bool { return &v }
+
+// schemaGenerator turns Go types into schemas, collecting named structs as components
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of t. Named structs become references to components.
+func (g *schemaGenerator) schema(t reflect.Type, response bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil // Placeholder so recursive types terminate
+			g.schemas[name] = g.object(t, response)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), response)}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return &Schema{Type: "integer"}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: intPtr(0)}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{}
+	}
+}
+
+// object describes the JSON fields of a struct
+func (g *schemaGenerator) object(t reflect.Type, response bool) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	if response {
+		schema.AdditionalProperties = boolPtr(false)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schema(field.Type, response)
+		omitempty := strings.Contains(options, "omitempty")
+		if response && !omitempty && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
@@ -0,0 +1,314 @@
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// maxEntries caps how many keys a Limiter or Lockout tracks. When it is
+// reached the key used least recently is forgotten to make room.
+const maxEntries = 10000
+
+// pruneInterval is how often a Limiter or Lockout drops its idle keys
+const pruneInterval = time.Minute
+
+// Limiter is a set of token buckets, one per key (client IP, username, share
+// token...). Each bucket holds up to Burst tokens and refills at PerMinute
+// tokens per minute; every request takes one token.
+type Limiter struct {
+	perMinute int
+	burst     int
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets *lru[*bucket]
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewLimiter creates a limiter allowing perMinute requests per key on average
+// and burst requests at once. A perMinute of 0 or less disables the limiter.
+func NewLimiter(perMinute, burst int, now func() time.Time) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &Limiter{perMinute: perMinute, burst: burst, now: now, buckets: newLRU[*bucket]()}
+}
+
+// Allow takes a token from the bucket of key. If the bucket is empty it
+// returns false and how long until the next token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil || l.perMinute <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.buckets.pruneDue(now) {
+		// A bucket idle long enough to refill completely behaves like a new one
+		idle := l.idle()
+		l.buckets.prune(func(b *bucket) bool { return now.Sub(b.updated) >= idle })
+	}
+
+	b, ok := l.buckets.touch(key)
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), updated: now}
+		l.buckets.add(key, b)
+	}
+	b.tokens = l.refill(b, now)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate() * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Len returns how many keys the limiter tracks
+func (l *Limiter) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buckets.len()
+}
+
+// rate is the refill rate in tokens per second
+func (l *Limiter) rate() float64 {
+	return float64(l.perMinute) / 60
+}
+
+// idle is how long an empty bucket takes to refill completely
+func (l *Limiter) idle() time.Duration {
+	return time.Duration(float64(l.burst) / l.rate() * float64(time.Second))
+}
+
+// refill returns the tokens in b at now
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(l.burst), b.tokens+elapsed*l.rate())
+}
+
+// Lockout locks a key (a username, a share token) after repeated failures.
+// After Threshold consecutive failures the key is locked for Base, and every
+// further failure doubles the lock up to Max. A success clears the key.
+type Lockout struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries *lru[*failures]
+}
+
+type failures struct {
+	count       int
+	lockedUntil time.Time
+	last        time.Time
+}
+
+// NewLockout creates a lockout policy. A threshold of 0 or less disables it.
+func NewLockout(threshold int, base, max time.Duration, now func() time.Time) *Lockout {
+	if now == nil {
+		now = time.Now
+	}
+	return &Lockout{threshold: threshold, base: base, max: max, now: now, entries: newLRU[*failures]()}
+}
+
+// Locked returns how long key stays locked, or 0 if it is not locked
+func (l *Lockout) Locked(key string) time.Duration {
+	if l == nil || l.threshold <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.entries.peek(key)
+	if !ok {
+		return 0
+	}
+	if remaining := f.lockedUntil.Sub(l.now()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Fail records a failure for key. It returns the lock duration when this
+// failure locks the key, or 0 if the key is still below the threshold.
+func (l *Lockout) Fail(key string) time.Duration {
+	if l == nil || l.threshold <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.entries.pruneDue(now) {
+		// A lock never outlasts max, so keys whose last failure is older are unlocked
+		l.entries.prune(func(f *failures) bool { return now.Sub(f.last) > l.max })
+	}
+
+	f, ok := l.entries.touch(key)
+	if !ok {
+		f = &failures{}
+		l.entries.add(key, f)
+	} else if now.Sub(f.last) > l.max && !now.Before(f.lockedUntil) {
+		// Failures long ago no longer count
+		f.count = 0
+	}
+	f.count++
+	f.last = now
+
+	if f.count < l.threshold {
+		return 0
+	}
+	lock := l.base
+	for i := l.threshold; i < f.count && lock < l.max; i++ {
+		lock *= 2
+	}
+	if lock > l.max {
+		lock = l.max
+	}
+	f.lockedUntil = now.Add(lock)
+	return lock
+}
+
+// Failures returns the number of consecutive failures recorded for key
+func (l *Lockout) Failures(key string) int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f, ok := l.entries.peek(key); ok {
+		return f.count
+	}
+	return 0
+}
+
+// Reset clears the failures of key after a success
+func (l *Lockout) Reset(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries.remove(key)
+}
+
+// Len returns how many keys the lockout tracks
+func (l *Lockout) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries.len()
+}
+
+// lru holds values by key, ordered from the most to the least recently used,
+// and never more than maxEntries of them. It is not safe for concurrent use.
+type lru[V any] struct {
+	items     map[string]*list.Element
+	order     *list.List // of *lruEntry[V], most recently used first
+	lastPrune time.Time
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRU[V any]() *lru[V] {
+	return &lru[V]{items: make(map[string]*list.Element), order: list.New()}
+}
+
+// peek returns the value of key without marking it as used
+func (c *lru[V]) peek(key string) (V, bool) {
+	if e, ok := c.items[key]; ok {
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// touch returns the value of key and marks it as the most recently used
+func (c *lru[V]) touch(key string) (V, bool) {
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// add stores a new key as the most recently used, forgetting the least
+// recently used key if the cache is full
+func (c *lru[V]) add(key string, value V) {
+	if c.order.Len() >= maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+}
+
+// remove forgets key
+func (c *lru[V]) remove(key string) {
+	if e, ok := c.items[key]; ok {
+		c.order.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// len returns the number of keys
+func (c *lru[V]) len() int {
+	return c.order.Len()
+}
+
+// pruneDue reports whether pruneInterval has passed since the last prune,
+// and starts a new interval if so
+func (c *lru[V]) pruneDue(now time.Time) bool {
+	if now.Sub(c.lastPrune) < pruneInterval {
+		return false
+	}
+	c.lastPrune = now
+	return true
+}
+
+// prune drops idle keys, starting from the least recently used. It stops at
+// the first key that is not idle, so idle must hold for every key used before
+// an idle one; only the dropped keys are visited.
+func (c *lru[V]) prune(idle func(V) bool) {
+	for e := c.order.Back(); e != nil; e = c.order.Back() {
+		entry := e.Value.(*lruEntry[V])
+		if !idle(entry.value) {
+			return
+		}
+		c.order.Remove(e)
+		delete(c.items, entry.key)
+	}
+}
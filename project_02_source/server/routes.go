@@ -0,0 +1,213 @@
+package server
+
+import (
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"lab02_mahoa/server/viewer"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Route is one entry of the API route table. The table is the only place
+// routes are declared: it feeds the ServeMux, the Allow header of 405
+// responses and the API documentation.
+type Route struct {
+	Method  string
+	Pattern string // ServeMux path pattern, e.g. /api/notes/{id}
+	Handler http.HandlerFunc
+	Auth    bool // Needs a bearer token (or a client certificate)
+	Summary string
+
+	// Documented in the OpenAPI document; nil means no body
+	Request  interface{} // JSON request body
+	Status   int         // Success status
+	Response interface{} // JSON response body on success
+}
+
+// routes is the API route table. It is filled in init because the OpenAPI
+// route documents the table itself.
+var routes []Route
+
+func init() {
+	routes = []Route{
+		// Auth
+		{http.MethodPost, "/api/auth/register", handlers.RegisterHandler, false, "Create an account",
+			models.RegisterRequest{}, http.StatusCreated, models.SuccessResponse{}},
+		{http.MethodPost, "/api/auth/login", handlers.LoginHandler, false, "Log in and get an access token and a refresh token",
+			models.LoginRequest{}, http.StatusOK, models.LoginResponse{}},
+		{http.MethodPost, "/api/auth/login/mfa", handlers.MFALoginHandler, false, "Complete a login with a TOTP or recovery code",
+			models.MFALoginRequest{}, http.StatusOK, models.LoginResponse{}},
+		{http.MethodPost, "/api/auth/refresh", handlers.RefreshTokenHandler, false, "Exchange a refresh token for new tokens",
+			models.RefreshTokenRequest{}, http.StatusOK, models.RefreshTokenResponse{}},
+		{http.MethodPost, "/api/auth/logout", handlers.LogoutHandler, true, "Revoke the access token and its refresh token",
+			models.LogoutRequest{}, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodPost, "/api/auth/revoke-all", handlers.RevokeAllTokensHandler, true, "Revoke every token of the account",
+			nil, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodPost, "/api/auth/password", handlers.ChangePasswordHandler, true, "Change the password and re-wrap the note keys",
+			models.ChangePasswordRequest{}, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodGet, "/api/auth/kdf", handlers.GetKDFHandler, true, "Get the key derivation parameters",
+			nil, http.StatusOK, models.KDFParams{}},
+		{http.MethodPut, "/api/auth/kdf", handlers.MigrateKDFHandler, true, "Migrate to new key derivation parameters",
+			models.MigrateKDFRequest{}, http.StatusOK, models.KDFParams{}},
+		{http.MethodGet, "/api/auth/totp", handlers.TOTPStatusHandler, true, "Get the two-factor authentication status",
+			nil, http.StatusOK, models.TOTPStatusResponse{}},
+		{http.MethodPost, "/api/auth/totp/setup", handlers.TOTPSetupHandler, true, "Generate a TOTP secret for two-factor authentication",
+			nil, http.StatusOK, models.TOTPSetupResponse{}},
+		{http.MethodPost, "/api/auth/totp/confirm", handlers.TOTPConfirmHandler, true, "Enable two-factor authentication and get recovery codes",
+			models.TOTPConfirmRequest{}, http.StatusOK, models.TOTPConfirmResponse{}},
+		{http.MethodPost, "/api/auth/totp/disable", handlers.TOTPDisableHandler, true, "Disable two-factor authentication",
+			models.TOTPDisableRequest{}, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodGet, "/api/auth/jwks", handlers.JWKSHandler, false, "Public JWT verification keys",
+			nil, http.StatusOK, auth.JWKS{}},
+		{http.MethodGet, "/.well-known/jwks.json", handlers.JWKSHandler, false, "Public JWT verification keys",
+			nil, http.StatusOK, auth.JWKS{}},
+
+		// Notes
+		{http.MethodGet, "/api/notes", handlers.ListNotesHandler, true, "List notes",
+			nil, http.StatusOK, models.ListNotesResponse{}},
+		{http.MethodPost, "/api/notes", handlers.CreateNoteHandler, true, "Create a note",
+			models.CreateNoteRequest{}, http.StatusCreated, models.NoteResponse{}},
+		{http.MethodGet, "/api/notes/{id}", handlers.GetNoteHandler, true, "Get a note",
+			nil, http.StatusOK, models.NoteResponse{}},
+		{http.MethodPut, "/api/notes/{id}", handlers.UpdateNoteHandler, true, "Update a note",
+			models.UpdateNoteRequest{}, http.StatusOK, models.NoteResponse{}},
+		{http.MethodDelete, "/api/notes/{id}", handlers.DeleteNoteHandler, true, "Delete a note",
+			nil, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodPost, "/api/notes/{id}/share", handlers.CreateShareHandler, true, "Create a share link",
+			models.CreateShareRequest{}, http.StatusCreated, models.ShareLinkResponse{}},
+		{http.MethodPost, "/api/notes/{id}/revoke", handlers.RevokeShareHandler, true, "Revoke the share links of a note",
+			nil, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodGet, "/api/notes/{id}/shares", handlers.ListShareLinksHandler, true, "List the share links of a note",
+			nil, http.StatusOK, models.ListShareLinksResponse{}},
+		{http.MethodPut, "/api/notes/{id}/shares/{linkId}", handlers.UpdateShareLinkHandler, true, "Change the expiry or access limit of a share link",
+			models.UpdateShareLinkRequest{}, http.StatusOK, models.ShareLinkInfo{}},
+		{http.MethodDelete, "/api/notes/{id}/shares/{linkId}", handlers.DeleteShareLinkHandler, true, "Revoke one share link",
+			nil, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodGet, "/api/notes/{id}/shares/{linkId}/access-log", handlers.ListShareLinkAccessLogHandler, true, "List the access attempts on one share link",
+			nil, http.StatusOK, models.ListShareAccessEventsResponse{}},
+		{http.MethodGet, "/api/notes/{id}/access-log", handlers.ListNoteAccessLogHandler, true, "List the access attempts on the share links of a note",
+			nil, http.StatusOK, models.ListShareAccessEventsResponse{}},
+		{http.MethodPost, "/api/notes/{id}/e2ee", handlers.CreateE2EEShareHandler, true, "Share a note end-to-end encrypted with another user",
+			models.CreateE2EEShareRequest{}, http.StatusCreated, models.E2EEShareResponse{}},
+		{http.MethodGet, "/api/notes/{id}/revisions", handlers.ListNoteRevisionsHandler, true, "List the revisions of a note",
+			nil, http.StatusOK, models.ListNoteRevisionsResponse{}},
+		{http.MethodGet, "/api/notes/{id}/revisions/{revisionId}", handlers.GetNoteRevisionHandler, true, "Get a revision",
+			nil, http.StatusOK, models.NoteRevisionResponse{}},
+		{http.MethodPost, "/api/notes/{id}/revisions/{revisionId}/restore", handlers.RestoreNoteRevisionHandler, true, "Restore a revision",
+			models.RestoreRevisionRequest{}, http.StatusOK, models.NoteResponse{}},
+
+		// Share links
+		{http.MethodGet, "/api/shares/{token}", handlers.GetSharedNoteHandler, false, "Open a share link",
+			nil, http.StatusOK, models.SharedNoteResponse{}},
+		{http.MethodPost, "/api/shares/{token}", handlers.GetSharedNoteHandler, false, "Open a password-protected share link",
+			models.AccessShareRequest{}, http.StatusOK, models.SharedNoteResponse{}},
+
+		// E2EE shares
+		{http.MethodGet, "/api/e2ee", handlers.ListE2EESharesHandler, true, "List E2EE shares sent to the user",
+			nil, http.StatusOK, models.ListE2EESharesResponse{}},
+		{http.MethodGet, "/api/e2ee/{id}", handlers.GetE2EEShareHandler, true, "Get an E2EE share",
+			nil, http.StatusOK, models.E2EEShareDetailResponse{}},
+		{http.MethodDelete, "/api/e2ee/{id}", handlers.DeleteE2EEShareHandler, true, "Delete an E2EE share",
+			nil, http.StatusOK, models.SuccessResponse{}},
+
+		// Read receipts
+		{http.MethodGet, "/api/receipts", handlers.ListReadReceiptsHandler, true, "List burn-after-reading shares and when they were read",
+			nil, http.StatusOK, models.ListReadReceiptsResponse{}},
+
+		// Documentation
+		{http.MethodGet, "/api/openapi.json", serveOpenAPI, false, "This OpenAPI document",
+			nil, http.StatusOK, nil},
+
+		// Public keys
+		{http.MethodPost, "/api/user/publickey", handlers.UpdatePublicKeyHandler, true, "Publish the user's DH public key",
+			models.UpdatePublicKeyRequest{}, http.StatusOK, models.SuccessResponse{}},
+		{http.MethodGet, "/api/users/{username}/publickey", handlers.GetPublicKeyHandler, true, "Get another user's DH public key",
+			nil, http.StatusOK, models.GetPublicKeyResponse{}},
+	}
+}
+
+// Routes returns a copy of the API route table
+func Routes() []Route {
+	return append([]Route(nil), routes...)
+}
+
+// corsMiddleware adds the CORS headers for the configured origins
+func (s *Server) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := s.allowedOrigin(r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Max-Age", "3600")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for a request
+// origin, or "" if the origin is not allowed
+func (s *Server) allowedOrigin(origin string) string {
+	for _, allowed := range s.cfg.CORSOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(origin, allowed) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// setupRoutes registers the route table on mux. Every route is rate limited
+// per client IP. A path that matches a route with another method gets a JSON
+// 405 with an Allow header; any other path gets a JSON 404.
+func (s *Server) setupRoutes(mux *http.ServeMux) {
+	allowed := make(map[string][]string)
+	for _, route := range routes {
+		mux.HandleFunc(route.Method+" "+route.Pattern, s.corsMiddleware(handlers.RateLimit(exactMethod(route.Method, route.Handler))))
+		if _, ok := allowed[route.Pattern]; !ok {
+			// Registered once per pattern; the method routes above are more specific
+			mux.HandleFunc(route.Pattern, s.corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				methodNotAllowed(w, allowed[route.Pattern])
+			}))
+		}
+		allowed[route.Pattern] = append(allowed[route.Pattern], route.Method)
+	}
+
+	// The share link viewer is a page for browsers, not part of the API table
+	mux.HandleFunc(viewer.PagePattern, handlers.RateLimit(viewer.PageHandler))
+	mux.Handle(viewer.AssetsPattern, viewer.AssetsHandler())
+
+	mux.HandleFunc("/", s.corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.RespondWithError(w, http.StatusNotFound, "Not found")
+	}))
+}
+
+// exactMethod stops ServeMux from sending HEAD requests to GET routes; the
+// handlers only answer the method they are registered for
+func exactMethod(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			methodNotAllowed(w, []string{method})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// methodNotAllowed responds 405 and lists the methods the path supports
+func methodNotAllowed(w http.ResponseWriter, methods []string) {
+	allow := append([]string{http.MethodOptions}, methods...)
+	sort.Strings(allow)
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	handlers.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/config"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/jobs"
+	"lab02_mahoa/server/models"
+	"lab02_mahoa/server/tlsutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Server is one instance of the notes service. It owns its database, clock,
+// token issuer and cleanup job and serves the API as an http.Handler, so it can
+// be mounted in another program or run several times in one process.
+type Server struct {
+	cfg     *config.Config
+	db      *gorm.DB
+	now     func() time.Time
+	handler http.Handler
+
+	jobsMu   sync.Mutex
+	stopJobs context.CancelFunc
+	jobsDone <-chan struct{}
+}
+
+// Option customizes a Server created by New
+type Option func(*Server)
+
+// WithClock makes the server read the time from now instead of time.Now.
+// Token expiry, share expiry and the cleanup job all follow it.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// Models returns every model the server stores
+func Models() []interface{} {
+	return []interface{}{
+		&models.User{}, &models.Note{}, &models.NoteRevision{}, &models.SharedLink{},
+		&models.E2EEShare{}, &models.RevokedToken{}, &models.RefreshToken{},
+		&models.RecoveryCode{}, &models.MFAChallenge{}, &models.ShareAccessEvent{},
+	}
+}
+
+// New opens the database named by cfg, loads the JWT keys and builds the routes.
+// The cleanup job does not run until Start (or Run) is called.
+func New(cfg *config.Config, opts ...Option) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	s := &Server{cfg: cfg, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Load JWT signing keys (keys file or a single secret)
+	keys, err := auth.LoadKeySet(cfg.JWTKeysFile, cfg.JWTSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWT keys: %w", err)
+	}
+	if keys != nil {
+		fmt.Printf("🔑 JWT signing key: %s\n", keys.ActiveKeyID())
+	} else {
+		log.Println("⚠️  No JWT keys configured, using the built-in development secret")
+		keys = auth.DefaultKeySet()
+	}
+
+	// Create the database directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	db, err := database.Open(cfg.DBPath, Models()...)
+	if err != nil {
+		return nil, err
+	}
+	// Timestamps written by GORM (created_at, updated_at) follow the server clock
+	db.NowFunc = func() time.Time { return s.now().Local() }
+	s.db = db
+
+	ipLimiter, accountLimiter, lockout := handlers.NewLimits(cfg, s.now)
+	env := &handlers.Env{
+		DB:  db,
+		Now: s.now,
+		Tokens: &auth.Issuer{
+			Keys:            keys,
+			AccessLifetime:  cfg.AccessTokenLifetime,
+			RefreshLifetime: cfg.RefreshTokenLifetime,
+			Now:             s.now,
+		},
+		PublicBaseURL:        cfg.PublicBaseURL,
+		ShareDefaultDuration: cfg.ShareDefaultDuration,
+		ShareMaxDuration:     cfg.ShareMaxDuration,
+		IPLimiter:            ipLimiter,
+		AccountLimiter:       accountLimiter,
+		Lockout:              lockout,
+		TrustedProxies:       cfg.TrustedProxyPrefixes(),
+		ClientCertUsers:      cfg.ClientCertUsers,
+	}
+
+	mux := http.NewServeMux()
+	s.setupRoutes(mux)
+	s.handler = handlers.WithEnv(env, mux)
+
+	return s, nil
+}
+
+// ServeHTTP serves the API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// DB returns the server's database
+func (s *Server) DB() *gorm.DB {
+	return s.db
+}
+
+// Start starts the background cleanup job for expired shares, links and tokens.
+// Calling it again while the job is running does nothing.
+func (s *Server) Start() {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if s.stopJobs != nil {
+		return
+	}
+
+	cleanupOptions := jobs.DefaultCleanupOptions()
+	cleanupOptions.Interval = s.cfg.CleanupInterval
+	cleanupOptions.AccessLogMaxAge = s.cfg.AccessLogRetention
+	cleanupOptions.ReceiptMaxAge = s.cfg.ReceiptRetention
+	cleanupOptions.RevisionMaxAge = s.cfg.RevisionMaxAge
+	cleanupOptions.MaxRevisionsPerNote = s.cfg.MaxRevisionsPerNote
+	cleanupOptions.Now = s.now
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopJobs = cancel
+	s.jobsDone = jobs.StartCleanupJobContext(ctx, s.db, cleanupOptions)
+}
+
+// Close stops the background jobs and closes the database
+func (s *Server) Close() error {
+	if err := s.stopBackgroundJobs(s.cfg.ShutdownTimeout); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return database.CloseDB(s.db)
+}
+
+// stopBackgroundJobs stops the cleanup job and waits up to timeout for it to finish
+func (s *Server) stopBackgroundJobs(timeout time.Duration) error {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if s.stopJobs == nil {
+		return nil
+	}
+
+	s.stopJobs()
+	s.stopJobs = nil
+	select {
+	case <-s.jobsDone:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("cleanup job did not stop within %v", timeout)
+	}
+}
+
+// Run starts the background jobs and serves on the configured address (HTTPS
+// when TLS files are configured) until ctx is cancelled or serving fails, then
+// shuts down in phases. It returns an error if the server did not stop cleanly.
+func (s *Server) Run(ctx context.Context) error {
+	s.Start()
+
+	httpServer := &http.Server{Addr: s.cfg.ListenAddr, Handler: s}
+	stopReload := func() {}
+	if s.cfg.TLSEnabled() {
+		// Serve HTTPS; send SIGHUP to pick up renewed certificate files
+		reloader, err := tlsutil.NewReloader(s.cfg.TLSCertFile, s.cfg.TLSKeyFile, s.cfg.TLSClientCAFile)
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("failed to load TLS files: %w", err)
+		}
+		stopReload = reloader.ReloadOnSIGHUP()
+		httpServer.TLSConfig = reloader.TLSConfig()
+		if s.cfg.TLSClientCAFile != "" {
+			fmt.Println("🔐 Client certificate authentication enabled")
+		}
+	} else {
+		log.Println("⚠️  TLS is disabled, passwords and tokens travel in clear text unless a proxy terminates TLS")
+	}
+	defer stopReload()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.cfg.TLSEnabled() {
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+	fmt.Printf("🚀 Server is running on %s (TLS: %v, public URL %s)\n", s.cfg.ListenAddr, s.cfg.TLSEnabled(), s.cfg.PublicBaseURL)
+
+	var runErr error
+	select {
+	case err := <-serveErr:
+		log.Printf("❌ Server stopped unexpectedly: %v", err)
+		runErr = err
+	case <-ctx.Done():
+		log.Println("🛑 Shutdown requested")
+	}
+
+	return errors.Join(runErr, s.shutdown(httpServer))
+}
+
+// shutdown stops the server in phases and logs each one: stop accepting
+// connections and drain in-flight requests, stop background jobs, close the
+// database. Requests still running after the shutdown timeout are cut off.
+func (s *Server) shutdown(httpServer *http.Server) error {
+	var shutdownErr error
+	timeout := s.cfg.ShutdownTimeout
+
+	log.Printf("🛑 [1/3] Draining HTTP connections (up to %v)...", timeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := httpServer.Shutdown(drainCtx); err != nil {
+		log.Printf("⚠️  [1/3] Requests still running after %v, closing them: %v", timeout, err)
+		httpServer.Close()
+		shutdownErr = err
+	} else {
+		log.Println("✅ [1/3] All requests finished")
+	}
+
+	log.Println("🛑 [2/3] Stopping background jobs...")
+	if err := s.stopBackgroundJobs(timeout); err != nil {
+		log.Printf("⚠️  [2/3] %v", err)
+		shutdownErr = err
+	} else {
+		log.Println("✅ [2/3] Background jobs stopped")
+	}
+
+	log.Println("🛑 [3/3] Closing database...")
+	if err := database.CloseDB(s.db); err != nil {
+		log.Printf("❌ [3/3] %v", err)
+		return err
+	}
+	log.Println("✅ [3/3] Database closed")
+
+	log.Println("👋 Server stopped")
+	return shutdownErr
+}
@@ -0,0 +1,127 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// Reloader serves a TLS certificate (and optionally a client CA bundle for
+// mutual TLS) from files that can be replaced while the server is running.
+// Reload swaps in the new files only if all of them load successfully.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
+}
+
+// NewReloader loads the certificate, key and optional client CA bundle
+func NewReloader(certFile, keyFile, clientCAFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previous certificate stays in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.clientCAFile != "" {
+		if pool, err = LoadCertPool(r.clientCAFile); err != nil {
+			return fmt.Errorf("failed to load client CA bundle: %w", err)
+		}
+	}
+
+	r.cert.Store(&cert)
+	if pool != nil {
+		r.clientCAs.Store(pool)
+	}
+	return nil
+}
+
+// Certificate returns the certificate currently served
+func (r *Reloader) Certificate() *tls.Certificate {
+	return r.cert.Load()
+}
+
+// TLSConfig returns a server configuration that always uses the latest
+// certificate. With a client CA bundle, clients may present a certificate
+// signed by it; a certificate that does not verify fails the handshake.
+func (r *Reloader) TLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.cert.Load(), nil
+		},
+	}
+
+	if r.clientCAFile != "" {
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			// Pick up a reloaded CA bundle for each new connection
+			connCfg := cfg.Clone()
+			connCfg.GetConfigForClient = nil
+			connCfg.ClientCAs = r.clientCAs.Load()
+			return connCfg, nil
+		}
+	}
+
+	return cfg
+}
+
+// ReloadOnSIGHUP reloads the files whenever the process receives SIGHUP.
+// The returned function stops watching.
+func (r *Reloader) ReloadOnSIGHUP() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := r.Reload(); err != nil {
+					log.Printf("⚠️  TLS reload failed, keeping the current certificate: %v", err)
+					continue
+				}
+				log.Println("🔐 TLS certificate reloaded")
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates found in " + path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,215 @@
+// Secure Notes share link viewer.
+//
+// The link looks like /share/{token}#key={base64 key}. Only the token is sent
+// to the server: the fragment stays in the browser, which decrypts the note
+// with WebCrypto AES-GCM, the same scheme the desktop client uses.
+"use strict";
+
+(function () {
+  const $ = (id) => document.getElementById(id);
+
+  const statusEl = $("status");
+  const passwordForm = $("password-form");
+  const keyForm = $("key-form");
+
+  const token = decodeURIComponent(location.pathname.replace(/^\/share\//, "").replace(/\/+$/, ""));
+  const apiURL = "/api/shares/" + encodeURIComponent(token);
+
+  let keyBase64 = keyFromFragment();
+  let note = null;
+
+  // Forget the key in the address bar so it does not end up in the history
+  // of a shared screen; it is still in memory for this page.
+  if (keyBase64 && history.replaceState) {
+    history.replaceState(null, "", location.pathname);
+  }
+
+  function setStatus(text, isError) {
+    statusEl.hidden = !text;
+    statusEl.textContent = text || "";
+    statusEl.classList.toggle("error", Boolean(isError));
+  }
+
+  function keyFromFragment() {
+    for (const part of location.hash.replace(/^#/, "").split("&")) {
+      const eq = part.indexOf("=");
+      if (eq > 0 && part.slice(0, eq) === "key") {
+        try {
+          return decodeURIComponent(part.slice(eq + 1));
+        } catch (e) {
+          return part.slice(eq + 1);
+        }
+      }
+    }
+    return "";
+  }
+
+  // base64ToBytes accepts standard and URL-safe base64, with or without padding
+  function base64ToBytes(value) {
+    let b64 = value.trim().replace(/-/g, "+").replace(/_/g, "/").replace(/\s/g, "");
+    while (b64.length % 4 !== 0) {
+      b64 += "=";
+    }
+    const binary = atob(b64);
+    const bytes = new Uint8Array(binary.length);
+    for (let i = 0; i < binary.length; i++) {
+      bytes[i] = binary.charCodeAt(i);
+    }
+    return bytes;
+  }
+
+  async function decrypt(shared, key) {
+    const rawKey = base64ToBytes(key);
+    if (rawKey.length !== 32) {
+      throw new Error("Khóa giải mã không hợp lệ (cần 32 byte)");
+    }
+    const cryptoKey = await crypto.subtle.importKey("raw", rawKey, { name: "AES-GCM" }, false, ["decrypt"]);
+    const plain = await crypto.subtle.decrypt(
+      { name: "AES-GCM", iv: base64ToBytes(shared.iv) },
+      cryptoKey,
+      base64ToBytes(shared.encrypted_content)
+    );
+    return new TextDecoder().decode(plain);
+  }
+
+  async function errorMessage(resp) {
+    try {
+      const body = await resp.json();
+      if (body && body.error && body.error.message) {
+        return body.error.message;
+      }
+    } catch (e) {
+      // Not JSON, fall through
+    }
+    return "HTTP " + resp.status;
+  }
+
+  // fetchNote asks the server for the encrypted note; a password is sent in a
+  // POST body so it never appears in a URL
+  async function fetchNote(password) {
+    const init = {
+      cache: "no-store",
+      credentials: "omit",
+      referrerPolicy: "no-referrer",
+    };
+    if (password !== undefined) {
+      init.method = "POST";
+      init.headers = { "Content-Type": "application/json" };
+      init.body = JSON.stringify({ password: password });
+    }
+    return fetch(apiURL, init);
+  }
+
+  async function load(password) {
+    setStatus("🔄 Đang tải...");
+    let resp;
+    try {
+      resp = await fetchNote(password);
+    } catch (e) {
+      setStatus("❌ Không kết nối được tới server", true);
+      return;
+    }
+
+    if (resp.status === 401) {
+      const message = await errorMessage(resp);
+      passwordForm.hidden = false;
+      $("password").value = "";
+      $("password").focus();
+      setStatus(password === undefined ? "" : "❌ " + message, password !== undefined);
+      return;
+    }
+    if (resp.status === 404) {
+      setStatus("❌ Link chia sẻ không tồn tại", true);
+      return;
+    }
+    if (resp.status === 410) {
+      setStatus("⏰ " + (await errorMessage(resp)), true);
+      return;
+    }
+    if (resp.status === 429) {
+      setStatus("⏳ Thử lại quá nhiều lần, vui lòng đợi một lát", true);
+      return;
+    }
+    if (!resp.ok) {
+      setStatus("❌ " + (await errorMessage(resp)), true);
+      return;
+    }
+
+    passwordForm.hidden = true;
+    note = await resp.json();
+    await show();
+  }
+
+  async function show() {
+    if (!keyBase64) {
+      keyForm.hidden = false;
+      $("key").focus();
+      setStatus("");
+      return;
+    }
+
+    let content;
+    try {
+      content = await decrypt(note, keyBase64);
+    } catch (e) {
+      keyForm.hidden = false;
+      setStatus("❌ Không giải mã được: khóa sai hoặc nội dung đã bị thay đổi", true);
+      return;
+    }
+
+    keyForm.hidden = true;
+    setStatus("");
+
+    const meta = [];
+    if (note.owner_username) {
+      meta.push("👤 Chia sẻ bởi " + note.owner_username);
+    }
+    if (note.expires_at && !note.burned) {
+      meta.push("⏰ Hết hạn " + new Date(note.expires_at).toLocaleString("vi-VN"));
+    }
+    $("note-title").textContent = note.title || "(Không có tiêu đề)";
+    $("note-meta").textContent = meta.join(" • ");
+    $("note-content").textContent = content;
+    // A burn-after-reading link is gone from the server once this page has
+    // the note: reloading will not bring it back
+    $("burned").hidden = !note.burned;
+    $("note").hidden = false;
+  }
+
+  passwordForm.addEventListener("submit", (event) => {
+    event.preventDefault();
+    const button = passwordForm.querySelector("button");
+    button.disabled = true;
+    load($("password").value).finally(() => {
+      button.disabled = false;
+    });
+  });
+
+  keyForm.addEventListener("submit", (event) => {
+    event.preventDefault();
+    const input = $("key").value.trim();
+    // Accept a whole link as well as the bare key
+    const hash = input.indexOf("#key=");
+    keyBase64 = hash >= 0 ? input.slice(hash + "#key=".length) : input;
+    show();
+  });
+
+  $("copy").addEventListener("click", async () => {
+    try {
+      await navigator.clipboard.writeText($("note-content").textContent);
+      $("copy").textContent = "✅ Đã sao chép";
+    } catch (e) {
+      $("copy").textContent = "❌ Không sao chép được";
+    }
+  });
+
+  if (!token) {
+    setStatus("❌ Link chia sẻ không hợp lệ", true);
+    return;
+  }
+  if (!window.isSecureContext || !window.crypto || !crypto.subtle) {
+    setStatus("❌ Trình duyệt cần kết nối HTTPS (hoặc localhost) để giải mã", true);
+    return;
+  }
+  load();
+})();
@@ -0,0 +1,70 @@
+package viewer
+
+import (
+	"embed"
+	"io/fs"
+	"lab02_mahoa/server/handlers"
+	"net/http"
+	"strings"
+)
+
+// The share link viewer is a static page: it fetches /api/shares/{token} and
+// decrypts the note in the browser with the key from the URL fragment, which
+// browsers never send to the server. The server only ever serves the same
+// files, whatever the token.
+
+//go:embed static
+var files embed.FS
+
+// PagePattern and AssetsPattern are the ServeMux patterns of the viewer
+const (
+	PagePattern   = "GET /share/{token}"
+	AssetsPattern = "GET /share/static/"
+)
+
+// contentSecurityPolicy only allows the viewer's own script and style and
+// requests to this server, so nothing in a note can load or run anything
+const contentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; " +
+	"img-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// setHeaders adds the headers every viewer response carries
+func setHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+}
+
+// PageHandler serves the viewer page at /share/{token}
+func PageHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := files.ReadFile("static/share.html")
+	if err != nil {
+		http.Error(w, "viewer unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	setHeaders(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The page is the same for every token; do not keep it next to the token in caches
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page)
+}
+
+// AssetsHandler serves the viewer's script and style under /share/static/
+func AssetsHandler() http.Handler {
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		panic("viewer: " + err.Error())
+	}
+	fileServer := http.StripPrefix("/share/static/", http.FileServerFS(static))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			handlers.RespondWithError(w, http.StatusNotFound, "Not found") // No directory listings
+			return
+		}
+		setHeaders(w)
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		fileServer.ServeHTTP(w, r)
+	})
+}
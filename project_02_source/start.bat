@@ -1,6 +1,6 @@
 @echo off
 echo Starting Backend Server...
-start cmd /k "cd /d %~dp0 && go run server/main.go"
+start cmd /k "cd /d %~dp0 && go run ./cmd/server"
 
 timeout /t 2 /nobreak > nul
 
@@ -2,7 +2,7 @@
 
 echo "Starting Backend Server..."
 cd "$(dirname "$0")"
-go run server/main.go &
+go run ./cmd/server &
 
 sleep 2
 
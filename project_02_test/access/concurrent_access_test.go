@@ -0,0 +1,136 @@
+package access
+
+import (
+	"fmt"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parallelReads opens the share link from n goroutines at once and counts the
+// response status codes
+func parallelReads(t *testing.T, token string, n int) map[int]int {
+	handler := http.HandlerFunc(handlers.GetSharedNoteHandler)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = make(map[int]int)
+		start    = make(chan struct{})
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/shares/"+token, nil)
+			req.SetPathValue("token", token)
+			rr := httptest.NewRecorder()
+
+			<-start
+			handler.ServeHTTP(rr, req)
+
+			mu.Lock()
+			statuses[rr.Code]++
+			mu.Unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return statuses
+}
+
+// assertRefused checks that the requests that did not get the note were told
+// the link is used up: 410 Gone, or 404 once a request has deleted the exhausted link
+func assertRefused(t *testing.T, statuses map[int]int, want int) {
+	t.Helper()
+	assert.Equal(t, want, statuses[http.StatusGone]+statuses[http.StatusNotFound], "Statuses: %v", statuses)
+	assert.NotZero(t, statuses[http.StatusGone], "At least one request should see the exhausted link")
+}
+
+// setupConcurrentDB opens a database file shared by a pool of connections, so
+// parallel requests really run at the same time (every ":memory:" connection
+// would be a separate empty database), like the server's own database.
+// GOMAXPROCS is raised so the race shows up on single CPU machines too.
+func setupConcurrentDB(t *testing.T) {
+	procs := runtime.GOMAXPROCS(16)
+	t.Cleanup(func() { runtime.GOMAXPROCS(procs) })
+
+	path := filepath.Join(t.TempDir(), "concurrent.db")
+	db, err := database.Open(path, &models.User{}, &models.Note{}, &models.SharedLink{}, &models.ShareAccessEvent{})
+	require.NoError(t, err)
+	database.DB = db
+}
+
+// createLimitedShare creates a share link that can be opened maxAccess times (0 = unlimited)
+func createLimitedShare(t *testing.T, token string, maxAccess int) models.SharedLink {
+	userID := createTestUser(t, token+"_user", "password123")
+	noteID := createTestNote(t, userID, "Concurrent Test")
+
+	shareLink := models.SharedLink{
+		NoteID:         noteID,
+		UserID:         userID,
+		ShareToken:     fmt.Sprintf("%s_%d", token, time.Now().UnixNano()),
+		ExpiresAt:      time.Now().Add(time.Hour),
+		MaxAccessCount: maxAccess,
+	}
+	require.NoError(t, database.GetDB().Create(&shareLink).Error)
+	return shareLink
+}
+
+// TestConcurrentReadsOfOneViewLink checks that a "1 view" link opened by
+// hundreds of clients at once is only ever returned once
+func TestConcurrentReadsOfOneViewLink(t *testing.T) {
+	setupConcurrentDB(t)
+	defer teardownTestDB(t)
+
+	shareLink := createLimitedShare(t, "race_one", 1)
+
+	statuses := parallelReads(t, shareLink.ShareToken, 300)
+
+	assert.Equal(t, 1, statuses[http.StatusOK], "Only one request may read a 1 view link")
+	assertRefused(t, statuses, 299)
+
+	var stored models.SharedLink
+	if err := database.GetDB().Where("id = ?", shareLink.ID).First(&stored).Error; err == nil {
+		assert.LessOrEqual(t, stored.AccessCount, 1, "The access count must never pass the limit")
+	}
+}
+
+// TestConcurrentReadsRespectMaxAccess checks that a limit above one is honoured exactly
+func TestConcurrentReadsRespectMaxAccess(t *testing.T) {
+	setupConcurrentDB(t)
+	defer teardownTestDB(t)
+
+	shareLink := createLimitedShare(t, "race_five", 5)
+
+	statuses := parallelReads(t, shareLink.ShareToken, 200)
+
+	assert.Equal(t, 5, statuses[http.StatusOK], "Exactly max_access_count requests may succeed")
+	assertRefused(t, statuses, 195)
+}
+
+// TestConcurrentReadsOfUnlimitedLink checks that no access is lost when many
+// clients open an unlimited link at once
+func TestConcurrentReadsOfUnlimitedLink(t *testing.T) {
+	setupConcurrentDB(t)
+	defer teardownTestDB(t)
+
+	shareLink := createLimitedShare(t, "race_unlimited", 0)
+
+	statuses := parallelReads(t, shareLink.ShareToken, 200)
+	assert.Equal(t, 200, statuses[http.StatusOK])
+
+	var stored models.SharedLink
+	require.NoError(t, database.GetDB().Where("id = ?", shareLink.ID).First(&stored).Error)
+	assert.Equal(t, 200, stored.AccessCount, "Every access should be counted")
+}
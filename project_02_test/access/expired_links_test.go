@@ -183,6 +183,7 @@ func TestExpiredSharesDoNotAffectActiveNotes(t *testing.T) {
 	// User should still be able to access their own note directly
 	token := generateTestToken(userID, "testuser")
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/notes/%d", noteID), nil)
+	req.SetPathValue("id", fmt.Sprint(noteID))
 	req.Header.Set("Authorization", "Bearer "+token)
 
 	// Note: This requires the actual handler implementation
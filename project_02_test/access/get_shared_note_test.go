@@ -38,6 +38,7 @@ func TestGetSharedNoteSuccess(t *testing.T) {
 
 	// Test accessing the shared note via API
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/shares/%s", shareToken), nil)
+	req.SetPathValue("token", shareToken)
 	rr := httptest.NewRecorder()
 	
 	handler := http.HandlerFunc(handlers.GetSharedNoteHandler)
@@ -83,6 +84,7 @@ func TestGetSharedNoteExpired(t *testing.T) {
 
 	// Test accessing the expired shared note via API
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/shares/%s", shareToken), nil)
+	req.SetPathValue("token", shareToken)
 	rr := httptest.NewRecorder()
 	
 	handler := http.HandlerFunc(handlers.GetSharedNoteHandler)
@@ -113,6 +115,7 @@ func TestGetSharedNoteNotFound(t *testing.T) {
 
 	// Test accessing a non-existent share token
 	req, _ := http.NewRequest("GET", "/api/shares/nonexistent_token_999", nil)
+	req.SetPathValue("token", "nonexistent_token_999")
 	rr := httptest.NewRecorder()
 	
 	handler := http.HandlerFunc(handlers.GetSharedNoteHandler)
@@ -156,8 +159,9 @@ func TestGetSharedNoteMethodNotAllowed(t *testing.T) {
 	setupTestDB(t)
 	defer teardownTestDB(t)
 
-	// Test with POST instead of GET
-	req, _ := http.NewRequest("POST", "/api/shares/some_token", nil)
+	// Test with DELETE; GET and POST (for the link password) are allowed
+	req, _ := http.NewRequest("DELETE", "/api/shares/some_token", nil)
+	req.SetPathValue("token", "some_token")
 	rr := httptest.NewRecorder()
 	
 	handler := http.HandlerFunc(handlers.GetSharedNoteHandler)
@@ -191,6 +195,7 @@ func TestGetSharedNoteMultipleAccess(t *testing.T) {
 	// Access the shared note multiple times
 	for i := 0; i < 5; i++ {
 		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/shares/%s", shareToken), nil)
+		req.SetPathValue("token", shareToken)
 		rr := httptest.NewRecorder()
 		
 		handler := http.HandlerFunc(handlers.GetSharedNoteHandler)
@@ -232,6 +237,7 @@ func TestGetSharedNoteExpirationBoundary(t *testing.T) {
 
 	// Access while still valid
 	req1, _ := http.NewRequest("GET", fmt.Sprintf("/api/shares/%s", shareToken), nil)
+	req1.SetPathValue("token", shareToken)
 	rr1 := httptest.NewRecorder()
 	handlers.GetSharedNoteHandler(rr1, req1)
 	assert.Equal(t, http.StatusOK, rr1.Code, "Should succeed before expiration")
@@ -241,6 +247,7 @@ func TestGetSharedNoteExpirationBoundary(t *testing.T) {
 
 	// Access after expiration
 	req2, _ := http.NewRequest("GET", fmt.Sprintf("/api/shares/%s", shareToken), nil)
+	req2.SetPathValue("token", shareToken)
 	rr2 := httptest.NewRecorder()
 	handlers.GetSharedNoteHandler(rr2, req2)
 	assert.Equal(t, http.StatusGone, rr2.Code, "Should fail after expiration")
@@ -273,6 +280,7 @@ func TestGetSharedNoteWithDifferentUsers(t *testing.T) {
 	// Anyone (including unauthenticated) should be able to access the shared note
 	// This simulates public access via share link
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/shares/%s", shareToken), nil)
+	req.SetPathValue("token", shareToken)
 	// Note: No Authorization header - simulating public access
 	
 	rr := httptest.NewRecorder()
@@ -318,6 +326,7 @@ func TestGetSharedNoteDataIntegrity(t *testing.T) {
 
 	// Access shared note
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/shares/%s", shareToken), nil)
+	req.SetPathValue("token", shareToken)
 	rr := httptest.NewRecorder()
 	handlers.GetSharedNoteHandler(rr, req)
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/config"
 	"lab02_mahoa/server/database"
 	"lab02_mahoa/server/handlers"
 	"lab02_mahoa/server/models"
@@ -21,7 +22,7 @@ import (
 // setupTestDB initializes a test database
 func setupTestDB(t *testing.T) {
 	// Initialize in-memory test database
-	err := database.InitTestDB(&models.User{}, &models.Note{}, &models.SharedLink{})
+	err := database.InitTestDB(&models.User{}, &models.Note{}, &models.SharedLink{}, &models.ShareAccessEvent{}, &models.RevokedToken{}, &models.RefreshToken{})
 	if err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
@@ -269,6 +270,7 @@ func TestRevokeExpiredShare(t *testing.T) {
 	// Revoke all shares for the note
 	token := generateTestToken(userID, "testuser")
 	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/notes/%d/revoke", noteID), nil)
+	req.SetPathValue("id", fmt.Sprint(noteID))
 	req.Header.Set("Authorization", "Bearer "+token)
 
 	rr := httptest.NewRecorder()
@@ -300,6 +302,7 @@ func TestCreateShareWithCustomExpiration(t *testing.T) {
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/notes/%d/share", noteID), bytes.NewBuffer(bodyBytes))
+	req.SetPathValue("id", fmt.Sprint(noteID))
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
@@ -322,6 +325,43 @@ func TestCreateShareWithCustomExpiration(t *testing.T) {
 	assert.Less(t, timeDiff, 1*time.Minute, "Expiration should be approximately 1 hour from now")
 }
 
+// TestCreateShareDurationLimit tests that share durations are capped by the configuration
+func TestCreateShareDurationLimit(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	cfg := config.Default()
+	cfg.PublicBaseURL = "https://notes.example.com"
+	cfg.ShareMaxDuration = 48 * time.Hour
+	handlers.Configure(cfg)
+	defer handlers.Configure(config.Default())
+
+	userID := createTestUser(t, "testuser", "password123")
+	noteID := createTestNote(t, userID, "Test Note")
+	token := generateTestToken(userID, "testuser")
+
+	createShare := func(body models.CreateShareRequest) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/notes/%d/share", noteID), bytes.NewBuffer(bodyBytes))
+		req.SetPathValue("id", fmt.Sprint(noteID))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handlers.CreateShareHandler(rr, req)
+		return rr
+	}
+
+	rr := createShare(models.CreateShareRequest{DurationHours: 49})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Duration above the maximum should be rejected")
+
+	rr = createShare(models.CreateShareRequest{DurationHours: 48})
+	assert.Equal(t, http.StatusCreated, rr.Code, "Duration at the maximum should be accepted")
+
+	var response models.ShareLinkResponse
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.Equal(t, "https://notes.example.com/share/"+response.ShareToken, response.ShareURL, "Share URL should use the public base URL")
+}
+
 // TestShareLinkExpirationBoundary tests share link at exact expiration moment
 func TestShareLinkExpirationBoundary(t *testing.T) {
 	setupTestDB(t)
@@ -414,6 +454,7 @@ func TestUnauthorizedAccessToExpiredShare(t *testing.T) {
 	// User2 tries to access the note directly
 	token := generateTestToken(userID2, "user2")
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/notes/%d", noteID), nil)
+	req.SetPathValue("id", fmt.Sprint(noteID))
 	req.Header.Set("Authorization", "Bearer "+token)
 
 	rr := httptest.NewRecorder()
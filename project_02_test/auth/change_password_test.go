@@ -0,0 +1,237 @@
+package auth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// changePasswordFixture chứa dữ liệu mẫu cho test đổi mật khẩu
+type changePasswordFixture struct {
+	user     models.User
+	token    string
+	note     models.Note
+	revision models.NoteRevision
+}
+
+// setupChangePasswordTest tạo user có 1 note và 1 revision
+func setupChangePasswordTest(t *testing.T) changePasswordFixture {
+	err := database.InitTestDB(&models.User{}, &models.Note{}, &models.NoteRevision{}, &models.RevokedToken{}, &models.RefreshToken{})
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	db := database.GetDB()
+
+	hashedPassword, _ := auth.HashPassword("oldpassword")
+	user := models.User{Username: "pwchange", PasswordHash: hashedPassword}
+	db.Create(&user)
+
+	note := models.Note{
+		UserID:           user.ID,
+		Title:            "Secret",
+		EncryptedContent: "content",
+		IV:               "iv",
+		EncryptedKey:     "old_key",
+		EncryptedKeyIV:   "old_key_iv",
+		Version:          2,
+	}
+	db.Create(&note)
+
+	revision := models.NoteRevision{
+		NoteID:           note.ID,
+		UserID:           user.ID,
+		Version:          1,
+		EncryptedContent: "old_content",
+		IV:               "old_iv",
+		EncryptedKey:     "old_revision_key",
+		EncryptedKeyIV:   "old_revision_key_iv",
+	}
+	db.Create(&revision)
+
+	token, err := auth.GenerateJWT(user.ID, user.Username)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT: %v", err)
+	}
+
+	return changePasswordFixture{user: user, token: token, note: note, revision: revision}
+}
+
+// changePassword gửi request POST /api/auth/password
+func changePassword(token string, body models.ChangePasswordRequest) *httptest.ResponseRecorder {
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/password", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handlers.ChangePasswordHandler(w, req)
+	return w
+}
+
+// TestChangePasswordSuccess kiểm tra đổi mật khẩu và lưu các key đã wrap lại
+func TestChangePasswordSuccess(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	w := changePassword(f.token, models.ChangePasswordRequest{
+		OldPassword:  "oldpassword",
+		NewPassword:  "newpassword",
+		NoteKeys:     []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "new_key", EncryptedKeyIV: "new_key_iv"}},
+		RevisionKeys: []models.RewrappedKey{{ID: f.revision.ID, EncryptedKey: "new_revision_key", EncryptedKeyIV: "new_revision_key_iv"}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	db.First(&user, f.user.ID)
+	if auth.CheckPassword("newpassword", user.PasswordHash) != nil {
+		t.Error("New password should be accepted")
+	}
+	if auth.CheckPassword("oldpassword", user.PasswordHash) == nil {
+		t.Error("Old password should no longer be accepted")
+	}
+
+	var note models.Note
+	db.First(&note, f.note.ID)
+	if note.EncryptedKey != "new_key" || note.EncryptedKeyIV != "new_key_iv" {
+		t.Errorf("Note key was not re-wrapped: %s / %s", note.EncryptedKey, note.EncryptedKeyIV)
+	}
+	if note.Version != 2 || note.EncryptedContent != "content" {
+		t.Error("Re-wrapping must not change the note content or version")
+	}
+
+	var revision models.NoteRevision
+	db.First(&revision, f.revision.ID)
+	if revision.EncryptedKey != "new_revision_key" || revision.EncryptedKeyIV != "new_revision_key_iv" {
+		t.Errorf("Revision key was not re-wrapped: %s / %s", revision.EncryptedKey, revision.EncryptedKeyIV)
+	}
+}
+
+// TestChangePasswordWrongOldPassword kiểm tra mật khẩu cũ sai
+func TestChangePasswordWrongOldPassword(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	w := changePassword(f.token, models.ChangePasswordRequest{
+		OldPassword:  "wrongpassword",
+		NewPassword:  "newpassword",
+		NoteKeys:     []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "new_key", EncryptedKeyIV: "new_key_iv"}},
+		RevisionKeys: []models.RewrappedKey{{ID: f.revision.ID, EncryptedKey: "k", EncryptedKeyIV: "iv"}},
+	})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	var note models.Note
+	database.GetDB().First(&note, f.note.ID)
+	if note.EncryptedKey != "old_key" {
+		t.Error("Keys must not change when the old password is wrong")
+	}
+}
+
+// TestChangePasswordIncompleteKeys kiểm tra thiếu key của note hoặc revision thì không thay đổi gì
+func TestChangePasswordIncompleteKeys(t *testing.T) {
+	testCases := []struct {
+		name         string
+		noteKeys     func(f changePasswordFixture) []models.RewrappedKey
+		revisionKeys func(f changePasswordFixture) []models.RewrappedKey
+	}{
+		{
+			name: "missing revision key",
+			noteKeys: func(f changePasswordFixture) []models.RewrappedKey {
+				return []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "k", EncryptedKeyIV: "iv"}}
+			},
+			revisionKeys: func(f changePasswordFixture) []models.RewrappedKey { return nil },
+		},
+		{
+			name:     "missing note key",
+			noteKeys: func(f changePasswordFixture) []models.RewrappedKey { return nil },
+			revisionKeys: func(f changePasswordFixture) []models.RewrappedKey {
+				return []models.RewrappedKey{{ID: f.revision.ID, EncryptedKey: "k", EncryptedKeyIV: "iv"}}
+			},
+		},
+		{
+			name: "unknown note id",
+			noteKeys: func(f changePasswordFixture) []models.RewrappedKey {
+				return []models.RewrappedKey{{ID: f.note.ID + 100, EncryptedKey: "k", EncryptedKeyIV: "iv"}}
+			},
+			revisionKeys: func(f changePasswordFixture) []models.RewrappedKey {
+				return []models.RewrappedKey{{ID: f.revision.ID, EncryptedKey: "k", EncryptedKeyIV: "iv"}}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := setupChangePasswordTest(t)
+
+			w := changePassword(f.token, models.ChangePasswordRequest{
+				OldPassword:  "oldpassword",
+				NewPassword:  "newpassword",
+				NoteKeys:     tc.noteKeys(f),
+				RevisionKeys: tc.revisionKeys(f),
+			})
+			if w.Code != http.StatusConflict {
+				t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+			}
+
+			db := database.GetDB()
+
+			var user models.User
+			db.First(&user, f.user.ID)
+			if auth.CheckPassword("oldpassword", user.PasswordHash) != nil {
+				t.Error("Password must not change when keys are incomplete")
+			}
+
+			var note models.Note
+			db.First(&note, f.note.ID)
+			if note.EncryptedKey != "old_key" {
+				t.Error("Note key must not change when keys are incomplete")
+			}
+		})
+	}
+}
+
+// TestChangePasswordValidation kiểm tra dữ liệu đầu vào không hợp lệ
+func TestChangePasswordValidation(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	testCases := []struct {
+		name string
+		body models.ChangePasswordRequest
+	}{
+		{"short new password", models.ChangePasswordRequest{OldPassword: "oldpassword", NewPassword: "123"}},
+		{"same password", models.ChangePasswordRequest{OldPassword: "oldpassword", NewPassword: "oldpassword"}},
+		{"key without IV", models.ChangePasswordRequest{
+			OldPassword: "oldpassword",
+			NewPassword: "newpassword",
+			NoteKeys:    []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "k"}},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := changePassword(f.token, tc.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+// TestChangePasswordUnauthorized kiểm tra request không có token hợp lệ
+func TestChangePasswordUnauthorized(t *testing.T) {
+	setupChangePasswordTest(t)
+
+	w := changePassword("invalid-token", models.ChangePasswordRequest{OldPassword: "oldpassword", NewPassword: "newpassword"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
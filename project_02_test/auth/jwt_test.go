@@ -313,14 +313,14 @@ func TestJWTTokenExpiry(t *testing.T) {
 		t.Fatalf("Failed to validate token: %v", err)
 	}
 
-	// Kiểm tra ExpiresAt là khoảng 24 giờ từ bây giờ
-	expectedExpiry := time.Now().Add(24 * time.Hour)
+	// Kiểm tra ExpiresAt là khoảng AccessTokenLifetime (access token ngắn hạn) từ bây giờ
+	expectedExpiry := time.Now().Add(auth.AccessTokenLifetime)
 	actualExpiry := claims.ExpiresAt.Time
 
 	// Cho phép chênh lệch 1 phút
 	diff := actualExpiry.Sub(expectedExpiry)
 	if diff < -time.Minute || diff > time.Minute {
-		t.Errorf("Expected expiry around 24 hours from now, got diff: %v", diff)
+		t.Errorf("Expected expiry around %v from now, got diff: %v", auth.AccessTokenLifetime, diff)
 	}
 }
 
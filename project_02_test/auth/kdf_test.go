@@ -0,0 +1,311 @@
+package auth_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// registerAndLogin đăng ký user mới rồi đăng nhập, trả về response đăng nhập
+func registerAndLogin(t *testing.T, username, password string) models.LoginResponse {
+	body, _ := json.Marshal(models.RegisterRequest{Username: username, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handlers.RegisterHandler(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Register failed: %d %s", w.Code, w.Body.String())
+	}
+
+	body, _ = json.Marshal(models.LoginRequest{Username: username, Password: password})
+	req = httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+	handlers.LoginHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login failed: %d %s", w.Code, w.Body.String())
+	}
+
+	var response models.LoginResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	return response
+}
+
+// newSalt tạo salt base64 hợp lệ cho test
+func newSalt(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 16))
+}
+
+// migrateKDF gửi request PUT /api/auth/kdf
+func migrateKDF(token string, body models.MigrateKDFRequest) *httptest.ResponseRecorder {
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, "/api/auth/kdf", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handlers.MigrateKDFHandler(w, req)
+	return w
+}
+
+// TestRegisterGeneratesPerUserSalt kiểm tra mỗi user có salt riêng và login trả về tham số KDF
+func TestRegisterGeneratesPerUserSalt(t *testing.T) {
+	setupTestDB(t)
+
+	alice := registerAndLogin(t, "kdfalice", "samepassword")
+	bob := registerAndLogin(t, "kdfbob", "samepassword")
+
+	if alice.KDF.Salt == "" || bob.KDF.Salt == "" {
+		t.Fatal("New users should get a per-user salt")
+	}
+	if alice.KDF.Salt == bob.KDF.Salt {
+		t.Error("Two users with the same password must not share a salt")
+	}
+	if alice.KDF.Algorithm != "pbkdf2-sha256" {
+		t.Errorf("Expected pbkdf2-sha256, got %s", alice.KDF.Algorithm)
+	}
+	if alice.KDF.Iterations < 100000 {
+		t.Errorf("Expected at least 100000 iterations, got %d", alice.KDF.Iterations)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(alice.KDF.Salt)
+	if err != nil || len(salt) < 16 {
+		t.Errorf("Salt should be at least 16 bytes of base64, got %q", alice.KDF.Salt)
+	}
+}
+
+// TestLoginLegacyUserKDF kiểm tra user cũ (chưa có salt) nhận tham số legacy
+func TestLoginLegacyUserKDF(t *testing.T) {
+	testUser := setupLoginTest(t)
+
+	body, _ := json.Marshal(models.LoginRequest{Username: testUser.Username, Password: testUser.Password})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handlers.LoginHandler(w, req)
+
+	var response models.LoginResponse
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if response.KDF.Salt != "" {
+		t.Errorf("Legacy users should get an empty salt, got %q", response.KDF.Salt)
+	}
+	if response.KDF.Iterations != 100000 {
+		t.Errorf("Legacy users should get 100000 iterations, got %d", response.KDF.Iterations)
+	}
+}
+
+// TestMigrateKDFSuccess kiểm tra chuyển user legacy sang salt riêng và wrap lại key
+func TestMigrateKDFSuccess(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	params := models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 600000, Salt: newSalt(7)}
+	w := migrateKDF(f.token, models.MigrateKDFRequest{
+		Password:     "oldpassword",
+		KDF:          params,
+		NoteKeys:     []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "salted_key", EncryptedKeyIV: "salted_key_iv"}},
+		RevisionKeys: []models.RewrappedKey{{ID: f.revision.ID, EncryptedKey: "salted_revision_key", EncryptedKeyIV: "salted_revision_key_iv"}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	db.First(&user, f.user.ID)
+	if user.KDFSalt != params.Salt || user.KDFIterations != 600000 || user.KDFAlgorithm != "pbkdf2-sha256" {
+		t.Errorf("KDF parameters were not stored: %+v", user)
+	}
+	if auth.CheckPassword("oldpassword", user.PasswordHash) != nil {
+		t.Error("Migration must not change the password")
+	}
+
+	var note models.Note
+	db.First(&note, f.note.ID)
+	if note.EncryptedKey != "salted_key" {
+		t.Errorf("Note key was not re-wrapped: %s", note.EncryptedKey)
+	}
+
+	var revision models.NoteRevision
+	db.First(&revision, f.revision.ID)
+	if revision.EncryptedKey != "salted_revision_key" {
+		t.Errorf("Revision key was not re-wrapped: %s", revision.EncryptedKey)
+	}
+}
+
+// TestMigrateKDFWrongPassword kiểm tra sai mật khẩu thì không thay đổi gì
+func TestMigrateKDFWrongPassword(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	w := migrateKDF(f.token, models.MigrateKDFRequest{
+		Password:     "wrongpassword",
+		KDF:          models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 600000, Salt: newSalt(7)},
+		NoteKeys:     []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "k", EncryptedKeyIV: "iv"}},
+		RevisionKeys: []models.RewrappedKey{{ID: f.revision.ID, EncryptedKey: "k", EncryptedKeyIV: "iv"}},
+	})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	var user models.User
+	database.GetDB().First(&user, f.user.ID)
+	if user.KDFSalt != "" {
+		t.Error("KDF parameters must not change when the password is wrong")
+	}
+}
+
+// TestMigrateKDFIncompleteKeys kiểm tra thiếu key thì không lưu tham số mới
+func TestMigrateKDFIncompleteKeys(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	w := migrateKDF(f.token, models.MigrateKDFRequest{
+		Password: "oldpassword",
+		KDF:      models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 600000, Salt: newSalt(7)},
+		NoteKeys: []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "k", EncryptedKeyIV: "iv"}},
+	})
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	db.First(&user, f.user.ID)
+	if user.KDFSalt != "" {
+		t.Error("KDF parameters must not change when keys are incomplete")
+	}
+
+	var note models.Note
+	db.First(&note, f.note.ID)
+	if note.EncryptedKey != "old_key" {
+		t.Error("Note key must not change when keys are incomplete")
+	}
+}
+
+// TestMigrateKDFInvalidParams kiểm tra tham số KDF không hợp lệ
+func TestMigrateKDFInvalidParams(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	testCases := []struct {
+		name   string
+		params models.KDFParams
+	}{
+		{"unknown algorithm", models.KDFParams{Algorithm: "md5", Iterations: 600000, Salt: newSalt(1)}},
+		{"too few iterations", models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 1000, Salt: newSalt(1)}},
+		{"missing salt", models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 600000}},
+		{"short salt", models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 600000, Salt: base64.StdEncoding.EncodeToString([]byte("short"))}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := migrateKDF(f.token, models.MigrateKDFRequest{Password: "oldpassword", KDF: tc.params})
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+// TestGetKDFParams kiểm tra endpoint lấy tham số KDF của user hiện tại
+func TestGetKDFParams(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "kdfcarol", "password123")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/kdf", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	w := httptest.NewRecorder()
+	handlers.GetKDFHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var params models.KDFParams
+	json.NewDecoder(w.Body).Decode(&params)
+	if params != login.KDF {
+		t.Errorf("Expected %+v, got %+v", login.KDF, params)
+	}
+}
+
+// TestMigrateKDFArgon2id kiểm tra chuyển sang Argon2id lưu đủ tham số và descriptor của từng key
+func TestMigrateKDFArgon2id(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	params := models.KDFParams{Algorithm: "argon2id", Iterations: 3, Memory: 65536, Threads: 4, Salt: newSalt(9)}
+	descriptor := "$argon2id$v=19$m=65536,t=3,p=4$" + params.Salt
+	w := migrateKDF(f.token, models.MigrateKDFRequest{
+		Password:     "oldpassword",
+		KDF:          params,
+		NoteKeys:     []models.RewrappedKey{{ID: f.note.ID, EncryptedKey: "argon_key", EncryptedKeyIV: "argon_key_iv", KeyKDF: descriptor}},
+		RevisionKeys: []models.RewrappedKey{{ID: f.revision.ID, EncryptedKey: "argon_revision_key", EncryptedKeyIV: "argon_revision_key_iv", KeyKDF: descriptor}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	db.First(&user, f.user.ID)
+	if user.KDFAlgorithm != "argon2id" || user.KDFIterations != 3 || user.KDFMemory != 65536 || user.KDFThreads != 4 {
+		t.Errorf("Argon2id parameters were not stored: %+v", user)
+	}
+
+	var note models.Note
+	db.First(&note, f.note.ID)
+	if note.KeyKDF != descriptor {
+		t.Errorf("Note key descriptor was not stored: %q", note.KeyKDF)
+	}
+
+	var revision models.NoteRevision
+	db.First(&revision, f.revision.ID)
+	if revision.KeyKDF != descriptor {
+		t.Errorf("Revision key descriptor was not stored: %q", revision.KeyKDF)
+	}
+
+	// Đăng nhập lại phải trả về tham số Argon2id
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/kdf", nil)
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	rec := httptest.NewRecorder()
+	handlers.GetKDFHandler(rec, req)
+
+	var stored models.KDFParams
+	json.NewDecoder(rec.Body).Decode(&stored)
+	if stored != params {
+		t.Errorf("Expected %+v, got %+v", params, stored)
+	}
+}
+
+// TestMigrateKDFInvalidArgon2id kiểm tra tham số Argon2id không hợp lệ
+func TestMigrateKDFInvalidArgon2id(t *testing.T) {
+	f := setupChangePasswordTest(t)
+
+	testCases := []struct {
+		name   string
+		params models.KDFParams
+	}{
+		{"zero time cost", models.KDFParams{Algorithm: "argon2id", Iterations: 0, Memory: 65536, Threads: 1, Salt: newSalt(1)}},
+		{"too little memory", models.KDFParams{Algorithm: "argon2id", Iterations: 3, Memory: 1024, Threads: 1, Salt: newSalt(1)}},
+		{"zero threads", models.KDFParams{Algorithm: "argon2id", Iterations: 3, Memory: 65536, Salt: newSalt(1)}},
+		{"memory on pbkdf2", models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 600000, Memory: 65536, Salt: newSalt(1)}},
+		// Tham số quá lớn sẽ làm mọi lần mở khóa treo hoặc hết bộ nhớ
+		{"time cost too high", models.KDFParams{Algorithm: "argon2id", Iterations: models.MaxArgon2idTime + 1, Memory: 65536, Threads: 1, Salt: newSalt(1)}},
+		{"too much memory", models.KDFParams{Algorithm: "argon2id", Iterations: 3, Memory: models.MaxArgon2idMemory + 1, Threads: 1, Salt: newSalt(1)}},
+		{"too many threads", models.KDFParams{Algorithm: "argon2id", Iterations: 3, Memory: 65536, Threads: models.MaxArgon2idThreads + 1, Salt: newSalt(1)}},
+		{"too many pbkdf2 iterations", models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: models.MaxPBKDF2Iterations + 1, Salt: newSalt(1)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := migrateKDF(f.token, models.MigrateKDFRequest{Password: "oldpassword", KDF: tc.params})
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/handlers"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useKeySet đặt key set cho test và khôi phục key set cũ khi test kết thúc
+func useKeySet(t *testing.T, keys *auth.KeySet) {
+	previous := auth.CurrentKeySet()
+	auth.SetKeySet(keys)
+	t.Cleanup(func() { auth.SetKeySet(previous) })
+}
+
+// hmacKey tạo cấu hình key HS256 với secret ngẫu nhiên
+func hmacKey(t *testing.T, kid string) auth.KeyConfig {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+	return auth.KeyConfig{KID: kid, Algorithm: auth.AlgHS256, Secret: base64.StdEncoding.EncodeToString(secret)}
+}
+
+// privateKeyPEM mã hóa private key sang PEM PKCS#8
+func privateKeyPEM(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal private key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// newKeySet tạo key set, fail test nếu cấu hình không hợp lệ
+func newKeySet(t *testing.T, cfg auth.KeySetConfig) *auth.KeySet {
+	keys, err := auth.NewKeySet(cfg, "")
+	if err != nil {
+		t.Fatalf("NewKeySet returned error: %v", err)
+	}
+	return keys
+}
+
+// tokenKID đọc kid trong header của token (không verify)
+func tokenKID(t *testing.T, tokenString string) string {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &auth.Claims{})
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+// TestKeyRotation kiểm tra token ký bằng key cũ vẫn hợp lệ sau khi đổi active key
+func TestKeyRotation(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	oldKey := hmacKey(t, "2024-01")
+	newKey := auth.KeyConfig{KID: "2024-06", Algorithm: auth.AlgEdDSA, PrivateKey: privateKeyPEM(t, edPrivate)}
+
+	// Trước khi rotate: chỉ có key cũ
+	useKeySet(t, newKeySet(t, auth.KeySetConfig{Active: "2024-01", Keys: []auth.KeyConfig{oldKey}}))
+	oldToken, err := auth.GenerateJWT(1, "alice")
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+	if kid := tokenKID(t, oldToken); kid != "2024-01" {
+		t.Errorf("Expected kid 2024-01, got %q", kid)
+	}
+
+	// Rotate: key mới ký, key cũ vẫn dùng để verify
+	auth.SetKeySet(newKeySet(t, auth.KeySetConfig{Active: "2024-06", Keys: []auth.KeyConfig{oldKey, newKey}}))
+	newToken, err := auth.GenerateJWT(2, "bob")
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+	if kid := tokenKID(t, newToken); kid != "2024-06" {
+		t.Errorf("Expected kid 2024-06, got %q", kid)
+	}
+
+	if claims, err := auth.ValidateJWT(oldToken); err != nil || claims.Username != "alice" {
+		t.Errorf("Token signed with the previous key should still validate: %v", err)
+	}
+	if claims, err := auth.ValidateJWT(newToken); err != nil || claims.Username != "bob" {
+		t.Errorf("Token signed with the active key should validate: %v", err)
+	}
+
+	// Gỡ key cũ: token cũ không còn hợp lệ
+	auth.SetKeySet(newKeySet(t, auth.KeySetConfig{Keys: []auth.KeyConfig{newKey}}))
+	if _, err := auth.ValidateJWT(oldToken); err == nil {
+		t.Error("Token signed with a removed key should be rejected")
+	}
+}
+
+// TestES256SignAndVerify kiểm tra ký và verify bằng ECDSA P-256
+func TestES256SignAndVerify(t *testing.T) {
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+	useKeySet(t, newKeySet(t, auth.KeySetConfig{Keys: []auth.KeyConfig{
+		{KID: "ec-1", Algorithm: auth.AlgES256, PrivateKey: privateKeyPEM(t, ecPrivate)},
+	}}))
+
+	token, err := auth.GenerateJWT(7, "carol")
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+
+	claims, err := auth.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT returned error: %v", err)
+	}
+	if claims.UserID != 7 || claims.Username != "carol" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	// Token phải verify được chỉ bằng public key
+	parsed, err := jwt.ParseWithClaims(token, &auth.Claims{}, func(*jwt.Token) (interface{}, error) {
+		return &ecPrivate.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil || !parsed.Valid {
+		t.Errorf("Token should verify with the public key: %v", err)
+	}
+}
+
+// TestValidateJWTRejectsAlgorithmMismatch kiểm tra token không được đổi thuật toán so với key
+func TestValidateJWTRejectsAlgorithmMismatch(t *testing.T) {
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	hsKey := hmacKey(t, "hs")
+	useKeySet(t, newKeySet(t, auth.KeySetConfig{Active: "ed", Keys: []auth.KeyConfig{
+		{KID: "ed", Algorithm: auth.AlgEdDSA, PrivateKey: privateKeyPEM(t, edPrivate)},
+		hsKey,
+	}}))
+
+	claims := &auth.Claims{UserID: 1, Username: "mallory", RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(auth.AccessTokenLifetime)),
+	}}
+
+	// HS256 dùng public key (công khai qua JWKS) làm secret, gắn kid của key EdDSA
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "ed"
+	forgedString, err := forged.SignedString([]byte(edPublic))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	if _, err := auth.ValidateJWT(forgedString); err == nil {
+		t.Error("HS256 token claiming an EdDSA key id should be rejected")
+	}
+
+	// kid không tồn tại
+	secret, _ := base64.StdEncoding.DecodeString(hsKey.Secret)
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	unknown.Header["kid"] = "missing"
+	unknownString, err := unknown.SignedString(secret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	if _, err := auth.ValidateJWT(unknownString); err == nil {
+		t.Error("Token with an unknown kid should be rejected")
+	}
+}
+
+// TestNewKeySetInvalidConfig kiểm tra các cấu hình key không hợp lệ bị từ chối
+func TestNewKeySetInvalidConfig(t *testing.T) {
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	edPEM := privateKeyPEM(t, edPrivate)
+	valid := hmacKey(t, "a")
+
+	testCases := []struct {
+		name string
+		cfg  auth.KeySetConfig
+	}{
+		{"no keys", auth.KeySetConfig{}},
+		{"short secret", auth.KeySetConfig{Keys: []auth.KeyConfig{{KID: "a", Algorithm: auth.AlgHS256, Secret: "c2hvcnQ="}}}},
+		{"missing kid", auth.KeySetConfig{Keys: []auth.KeyConfig{{Algorithm: auth.AlgEdDSA, PrivateKey: edPEM}}}},
+		{"duplicate kid", auth.KeySetConfig{Active: "a", Keys: []auth.KeyConfig{valid, valid}}},
+		{"unknown active key", auth.KeySetConfig{Active: "b", Keys: []auth.KeyConfig{valid}}},
+		{"ambiguous active key", auth.KeySetConfig{Keys: []auth.KeyConfig{valid, hmacKey(t, "b")}}},
+		{"unsupported algorithm", auth.KeySetConfig{Keys: []auth.KeyConfig{{KID: "a", Algorithm: "none"}}}},
+		{"key type mismatch", auth.KeySetConfig{Keys: []auth.KeyConfig{{KID: "a", Algorithm: auth.AlgES256, PrivateKey: edPEM}}}},
+		{"invalid PEM", auth.KeySetConfig{Keys: []auth.KeyConfig{{KID: "a", Algorithm: auth.AlgEdDSA, PrivateKey: "not a key"}}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := auth.NewKeySet(tc.cfg, ""); err == nil {
+				t.Errorf("Expected error for %s", tc.name)
+			}
+		})
+	}
+
+	// Key chỉ có public key không thể là active key
+	der, _ := x509.MarshalPKIXPublicKey(edPrivate.Public())
+	publicPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	_, err := auth.NewKeySet(auth.KeySetConfig{Keys: []auth.KeyConfig{{KID: "a", Algorithm: auth.AlgEdDSA, PublicKey: publicPEM}}}, "")
+	if err == nil {
+		t.Error("Verify-only key should not be accepted as the active key")
+	}
+}
+
+// TestLoadKeySetFile kiểm tra đọc file cấu hình key với private_key_file tương đối
+func TestLoadKeySetFile(t *testing.T) {
+	dir := t.TempDir()
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	if err := os.WriteFile(filepath.Join(dir, "ed.pem"), []byte(privateKeyPEM(t, edPrivate)), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	cfg := auth.KeySetConfig{Active: "ed", Keys: []auth.KeyConfig{
+		{KID: "ed", Algorithm: auth.AlgEdDSA, PrivateKeyFile: "ed.pem"},
+		hmacKey(t, "legacy"),
+	}}
+	data, _ := json.Marshal(cfg)
+	path := filepath.Join(dir, "jwt-keys.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+
+	keys, err := auth.LoadKeySetFile(path)
+	if err != nil {
+		t.Fatalf("LoadKeySetFile returned error: %v", err)
+	}
+	if keys.ActiveKeyID() != "ed" {
+		t.Errorf("Expected active key ed, got %q", keys.ActiveKeyID())
+	}
+
+	if _, err := auth.LoadKeySetFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Missing keys file should return an error")
+	}
+}
+
+// TestJWKSHandler kiểm tra JWKS chỉ công bố public key và verify được token
+func TestJWKSHandler(t *testing.T) {
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	ecPrivate, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	useKeySet(t, newKeySet(t, auth.KeySetConfig{Active: "ed", Keys: []auth.KeyConfig{
+		{KID: "ed", Algorithm: auth.AlgEdDSA, PrivateKey: privateKeyPEM(t, edPrivate)},
+		{KID: "ec", Algorithm: auth.AlgES256, PrivateKey: privateKeyPEM(t, ecPrivate)},
+		hmacKey(t, "hs"),
+	}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	handlers.JWKSHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&set); err != nil {
+		t.Fatalf("Failed to decode JWKS: %v", err)
+	}
+
+	if len(set.Keys) != 2 {
+		t.Fatalf("Expected 2 public keys (HS256 must not be published), got %d", len(set.Keys))
+	}
+	for _, key := range set.Keys {
+		if key["kid"] == "hs" || key["k"] != "" {
+			t.Errorf("HMAC secret must never be published: %v", key)
+		}
+	}
+
+	ed := set.Keys[0]
+	if ed["kid"] != "ed" || ed["kty"] != "OKP" || ed["crv"] != "Ed25519" || ed["alg"] != "EdDSA" || ed["use"] != "sig" {
+		t.Errorf("Unexpected Ed25519 JWK: %v", ed)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(ed["x"])
+	if err != nil || !edPublic.Equal(ed25519.PublicKey(x)) {
+		t.Error("JWK x should be the Ed25519 public key")
+	}
+
+	ec := set.Keys[1]
+	if ec["kid"] != "ec" || ec["kty"] != "EC" || ec["crv"] != "P-256" || ec["x"] == "" || ec["y"] == "" {
+		t.Errorf("Unexpected P-256 JWK: %v", ec)
+	}
+
+	// Một service khác verify token chỉ với JWKS
+	token, err := auth.GenerateJWT(3, "dave")
+	if err != nil {
+		t.Fatalf("GenerateJWT returned error: %v", err)
+	}
+	_, err = jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(x), nil
+	}, jwt.WithValidMethods([]string{"EdDSA"}))
+	if err != nil {
+		t.Errorf("Token should verify with the published key: %v", err)
+	}
+}
@@ -14,7 +14,7 @@ import (
 
 // setupLoginTest khởi tạo database và tạo user test
 func setupLoginTest(t *testing.T) models.RegisterRequest {
-	err := database.InitTestDB(&models.User{})
+	err := database.InitTestDB(&models.User{}, &models.RevokedToken{}, &models.RefreshToken{})
 	if err != nil {
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
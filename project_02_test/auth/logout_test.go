@@ -0,0 +1,153 @@
+package auth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// postWithToken gửi request POST có Bearer token tới handler
+func postWithToken(handler http.HandlerFunc, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+// loginToken đăng nhập user đã có và trả về token
+func loginToken(t *testing.T, username, password string) string {
+	body, _ := json.Marshal(models.LoginRequest{Username: username, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handlers.LoginHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login failed: %d %s", w.Code, w.Body.String())
+	}
+
+	var response models.LoginResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	return response.Token
+}
+
+// getKDFWithToken gọi một endpoint cần xác thực để kiểm tra token còn dùng được không
+func getKDFWithToken(token string) int {
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/kdf", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handlers.GetKDFHandler(w, req)
+	return w.Code
+}
+
+// TestTokenHasID kiểm tra token mới có claim jti ngẫu nhiên
+func TestTokenHasID(t *testing.T) {
+	first, _ := auth.GenerateJWT(1, "jtiuser")
+	second, _ := auth.GenerateJWT(1, "jtiuser")
+
+	firstClaims, err := auth.ValidateJWT(first)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	secondClaims, _ := auth.ValidateJWT(second)
+
+	if firstClaims.ID == "" {
+		t.Error("Token should have a jti claim")
+	}
+	if firstClaims.ID == secondClaims.ID {
+		t.Error("Every token should get its own jti")
+	}
+}
+
+// TestLogoutRevokesToken kiểm tra logout làm token hiện tại mất hiệu lực
+func TestLogoutRevokesToken(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "logoutuser", "password123")
+	other := loginToken(t, "logoutuser", "password123")
+
+	if code := getKDFWithToken(login.Token); code != http.StatusOK {
+		t.Fatalf("Token should work before logout, got %d", code)
+	}
+
+	w := postWithToken(handlers.LogoutHandler, "/api/auth/logout", login.Token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if code := getKDFWithToken(login.Token); code != http.StatusUnauthorized {
+		t.Errorf("Revoked token should be rejected, got %d", code)
+	}
+	if code := getKDFWithToken(other); code != http.StatusOK {
+		t.Errorf("Other sessions should keep working, got %d", code)
+	}
+
+	// Logout lần nữa với token đã bị thu hồi
+	w = postWithToken(handlers.LogoutHandler, "/api/auth/logout", login.Token)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+// TestLogoutWithoutToken kiểm tra logout không có token
+func TestLogoutWithoutToken(t *testing.T) {
+	setupTestDB(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+	w := httptest.NewRecorder()
+	handlers.LogoutHandler(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+// TestRevokeAllTokens kiểm tra thu hồi toàn bộ token của user
+func TestRevokeAllTokens(t *testing.T) {
+	setupTestDB(t)
+	first := registerAndLogin(t, "revokeall", "password123")
+	second := loginToken(t, "revokeall", "password123")
+	bystander := registerAndLogin(t, "bystander", "password123")
+
+	w := postWithToken(handlers.RevokeAllTokensHandler, "/api/auth/revoke-all", first.Token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if code := getKDFWithToken(first.Token); code != http.StatusUnauthorized {
+		t.Errorf("Token used for revoke-all should be rejected, got %d", code)
+	}
+	if code := getKDFWithToken(second); code != http.StatusUnauthorized {
+		t.Errorf("Every other token of the user should be rejected, got %d", code)
+	}
+	if code := getKDFWithToken(bystander.Token); code != http.StatusOK {
+		t.Errorf("Tokens of other users should keep working, got %d", code)
+	}
+
+	// Đăng nhập lại ngay sau đó phải có token hợp lệ
+	fresh := loginToken(t, "revokeall", "password123")
+	if code := getKDFWithToken(fresh); code != http.StatusOK {
+		t.Errorf("New login after revoke-all should work, got %d", code)
+	}
+}
+
+// TestRevokedTokenStored kiểm tra token bị thu hồi được lưu kèm thời điểm hết hạn
+func TestRevokedTokenStored(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "storeduser", "password123")
+	claims, _ := auth.ValidateJWT(login.Token)
+
+	postWithToken(handlers.LogoutHandler, "/api/auth/logout", login.Token)
+
+	var revoked models.RevokedToken
+	if err := database.GetDB().Where("jti = ?", claims.ID).First(&revoked).Error; err != nil {
+		t.Fatalf("Revoked token was not stored: %v", err)
+	}
+	if !revoked.ExpiresAt.Equal(claims.ExpiresAt.Time) {
+		t.Errorf("Expected expiry %v, got %v", claims.ExpiresAt.Time, revoked.ExpiresAt)
+	}
+}
@@ -0,0 +1,140 @@
+package auth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// refreshTokens gửi request POST /api/auth/refresh
+func refreshTokens(refreshToken string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(models.RefreshTokenRequest{RefreshToken: refreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handlers.RefreshTokenHandler(w, req)
+	return w
+}
+
+// TestLoginReturnsRefreshToken kiểm tra login trả về refresh token và thời hạn access token
+func TestLoginReturnsRefreshToken(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "refreshuser", "password123")
+
+	if login.RefreshToken == "" {
+		t.Fatal("Login should return a refresh token")
+	}
+	if login.ExpiresIn != int(auth.AccessTokenLifetime.Seconds()) {
+		t.Errorf("Expected expires_in %d, got %d", int(auth.AccessTokenLifetime.Seconds()), login.ExpiresIn)
+	}
+
+	// Chỉ lưu hash, không lưu token gốc
+	var stored models.RefreshToken
+	database.GetDB().First(&stored)
+	if stored.TokenHash == "" || stored.TokenHash == login.RefreshToken {
+		t.Errorf("Refresh token must be stored hashed, got %q", stored.TokenHash)
+	}
+}
+
+// TestRefreshRotatesToken kiểm tra mỗi lần refresh trả về token mới
+func TestRefreshRotatesToken(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "rotateuser", "password123")
+
+	w := refreshTokens(login.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response models.RefreshTokenResponse
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if response.RefreshToken == "" || response.RefreshToken == login.RefreshToken {
+		t.Error("Refresh should rotate the refresh token")
+	}
+	if code := getKDFWithToken(response.Token); code != http.StatusOK {
+		t.Errorf("New access token should work, got %d", code)
+	}
+
+	// Token mới dùng tiếp được
+	if w := refreshTokens(response.RefreshToken); w.Code != http.StatusOK {
+		t.Errorf("Rotated refresh token should work, got %d", w.Code)
+	}
+}
+
+// TestRefreshTokenReuseRevokesFamily kiểm tra dùng lại refresh token cũ sẽ thu hồi cả family
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "reuseuser", "password123")
+	loginToken(t, "reuseuser", "password123") // Another session with its own family
+
+	w := refreshTokens(login.RefreshToken)
+	var rotated models.RefreshTokenResponse
+	json.NewDecoder(w.Body).Decode(&rotated)
+
+	// Kẻ tấn công dùng lại token đã dùng
+	if w := refreshTokens(login.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Reused refresh token should be rejected, got %d", w.Code)
+	}
+
+	// Token hợp lệ mới nhất của family cũng bị thu hồi
+	if w := refreshTokens(rotated.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("The whole family should be revoked after reuse, got %d", w.Code)
+	}
+
+	// Family của phiên đăng nhập khác không bị ảnh hưởng
+	var active int64
+	database.GetDB().Model(&models.RefreshToken{}).Where("revoked_at IS NULL AND used_at IS NULL").Count(&active)
+	if active != 1 {
+		t.Errorf("Expected the other login's refresh token to stay active, got %d active", active)
+	}
+}
+
+// TestRefreshInvalidToken kiểm tra refresh token không hợp lệ
+func TestRefreshInvalidToken(t *testing.T) {
+	setupTestDB(t)
+
+	if w := refreshTokens("not-a-real-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := refreshTokens(""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// TestLogoutRevokesRefreshToken kiểm tra logout kèm refresh token sẽ thu hồi refresh token
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "logoutrefresh", "password123")
+
+	body, _ := json.Marshal(models.LogoutRequest{RefreshToken: login.RefreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	w := httptest.NewRecorder()
+	handlers.LogoutHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if w := refreshTokens(login.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh token should be revoked by logout, got %d", w.Code)
+	}
+}
+
+// TestRevokeAllRevokesRefreshTokens kiểm tra revoke-all thu hồi cả refresh token
+func TestRevokeAllRevokesRefreshTokens(t *testing.T) {
+	setupTestDB(t)
+	login := registerAndLogin(t, "revokerefresh", "password123")
+
+	postWithToken(handlers.RevokeAllTokensHandler, "/api/auth/revoke-all", login.Token)
+
+	if w := refreshTokens(login.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh token should be revoked by revoke-all, got %d", w.Code)
+	}
+}
@@ -13,7 +13,7 @@ import (
 
 // setupTestDB khởi tạo database test
 func setupTestDB(t *testing.T) {
-	err := database.InitTestDB(&models.User{})
+	err := database.InitTestDB(&models.User{}, &models.RevokedToken{}, &models.RefreshToken{})
 	if err != nil {
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
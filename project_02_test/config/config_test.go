@@ -0,0 +1,259 @@
+package config_test
+
+import (
+	"lab02_mahoa/server/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a JSON config file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "server.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// TestDefaults checks that the server starts with usable settings when nothing is configured
+func TestDefaults(t *testing.T) {
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, ":8080", cfg.ListenAddr)
+	assert.Equal(t, "http://localhost:8080", cfg.PublicBaseURL)
+	assert.Equal(t, "storage/app.db", cfg.DBPath)
+	assert.Equal(t, []string{"*"}, cfg.CORSOrigins)
+	assert.True(t, cfg.AllowsAnyOrigin())
+	assert.Equal(t, time.Hour, cfg.CleanupInterval)
+	assert.Equal(t, 30*time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, 15*time.Minute, cfg.AccessTokenLifetime)
+	assert.Equal(t, 7*24*time.Hour, cfg.ShareMaxDuration)
+	assert.Equal(t, 30*24*time.Hour, cfg.AccessLogRetention)
+	assert.Equal(t, 30*24*time.Hour, cfg.ReceiptRetention)
+	assert.Empty(t, cfg.TrustedProxies, "No proxy is trusted by default")
+	assert.Equal(t, 50, cfg.MaxRevisionsPerNote)
+	assert.Equal(t, time.Duration(0), cfg.RevisionMaxAge, "Revisions have no age limit by default")
+}
+
+// TestPrecedence checks that flags override the environment, which overrides the config file
+func TestPrecedence(t *testing.T) {
+	path := writeConfig(t, `{
+		"listen_addr": ":9000",
+		"public_url": "https://notes.example.com/",
+		"db_path": "/var/lib/notes/file.db",
+		"cors_origins": ["https://app.example.com", "https://admin.example.com"],
+		"share_max_duration": "72h"
+	}`)
+
+	t.Setenv("SECURE_NOTES_CONFIG", path)
+	t.Setenv("SECURE_NOTES_LISTEN_ADDR", ":9100")
+	t.Setenv("SECURE_NOTES_DB_PATH", "/var/lib/notes/env.db")
+
+	cfg, err := config.Load([]string{"-db-path", "/var/lib/notes/flag.db", "-access-token-lifetime", "5m"})
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9100", cfg.ListenAddr, "Environment should override the file")
+	assert.Equal(t, "/var/lib/notes/flag.db", cfg.DBPath, "Flags should override the environment")
+	assert.Equal(t, "https://notes.example.com", cfg.PublicBaseURL, "Trailing slash should be removed")
+	assert.Equal(t, []string{"https://app.example.com", "https://admin.example.com"}, cfg.CORSOrigins)
+	assert.False(t, cfg.AllowsAnyOrigin())
+	assert.Equal(t, 72*time.Hour, cfg.ShareMaxDuration, "File value should apply when nothing overrides it")
+	assert.Equal(t, 5*time.Minute, cfg.AccessTokenLifetime)
+	assert.Equal(t, time.Hour, cfg.CleanupInterval, "Unset values keep their default")
+}
+
+// TestRateLimitSettings checks that rate limits and lockout can be set from every source
+func TestRateLimitSettings(t *testing.T) {
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 300, cfg.RateLimitPerIP)
+	assert.Equal(t, 10, cfg.RateLimitPerAccount)
+	assert.Equal(t, 5, cfg.LockoutThreshold)
+	assert.Equal(t, time.Minute, cfg.LockoutBase)
+	assert.Equal(t, time.Hour, cfg.LockoutMax)
+
+	t.Setenv("SECURE_NOTES_CONFIG", writeConfig(t, `{"rate_limit_ip": 60, "rate_limit_account": "3"}`))
+	t.Setenv("SECURE_NOTES_LOCKOUT_THRESHOLD", "0")
+
+	cfg, err = config.Load([]string{"-lockout-max", "30m"})
+	require.NoError(t, err)
+	assert.Equal(t, 60, cfg.RateLimitPerIP, "Numbers should be accepted in the config file")
+	assert.Equal(t, 3, cfg.RateLimitPerAccount)
+	assert.Equal(t, 0, cfg.LockoutThreshold, "0 disables lockout")
+	assert.Equal(t, 30*time.Minute, cfg.LockoutMax)
+}
+
+// TestAccessLogSettings checks the trusted proxies and the access log retention
+func TestAccessLogSettings(t *testing.T) {
+	t.Setenv("SECURE_NOTES_CONFIG", writeConfig(t, `{"trusted_proxies": ["10.0.0.0/8", "192.168.1.1"]}`))
+	t.Setenv("SECURE_NOTES_ACCESS_LOG_RETENTION", "0")
+
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, cfg.TrustedProxies)
+	assert.Equal(t, time.Duration(0), cfg.AccessLogRetention, "0 keeps events forever")
+
+	prefixes := cfg.TrustedProxyPrefixes()
+	require.Len(t, prefixes, 2)
+	assert.Equal(t, "10.0.0.0/8", prefixes[0].String())
+	assert.Equal(t, "192.168.1.1/32", prefixes[1].String(), "A single IP trusts only that address")
+
+	cfg, err = config.Load([]string{"-trusted-proxies", "::1, 127.0.0.1", "-access-log-retention", "168h"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"::1", "127.0.0.1"}, cfg.TrustedProxies)
+	assert.Equal(t, 7*24*time.Hour, cfg.AccessLogRetention)
+}
+
+// TestRevisionRetentionSettings checks that the revision retention of the cleanup job can be configured
+func TestRevisionRetentionSettings(t *testing.T) {
+	t.Setenv("SECURE_NOTES_CONFIG", writeConfig(t, `{"revision_max_age": "2160h", "max_revisions_per_note": 20}`))
+	t.Setenv("SECURE_NOTES_MAX_REVISIONS_PER_NOTE", "10")
+
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, cfg.RevisionMaxAge)
+	assert.Equal(t, 10, cfg.MaxRevisionsPerNote, "Environment should override the file")
+
+	cfg, err = config.Load([]string{"-max-revisions-per-note", "0", "-revision-max-age", "0"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.MaxRevisionsPerNote, "0 keeps every revision")
+	assert.Equal(t, time.Duration(0), cfg.RevisionMaxAge)
+}
+
+// TestReceiptRetention checks how long expired burn-after-reading shares are kept
+func TestReceiptRetention(t *testing.T) {
+	t.Setenv("SECURE_NOTES_CONFIG", writeConfig(t, `{"receipt_retention": "2160h"}`))
+
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, cfg.ReceiptRetention)
+
+	t.Setenv("SECURE_NOTES_RECEIPT_RETENTION", "0")
+	cfg, err = config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), cfg.ReceiptRetention, "0 keeps receipts forever")
+
+	cfg, err = config.Load([]string{"-receipt-retention", "24h"})
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, cfg.ReceiptRetention, "Flags should override the environment")
+}
+
+// TestClientCertUsers checks that certificate login is off until accounts are listed
+func TestClientCertUsers(t *testing.T) {
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.ClientCertUsers, "No account may use a client certificate by default")
+
+	t.Setenv("SECURE_NOTES_CLIENT_CERT_USERS", "backup-bot, report-bot")
+	cfg, err = config.Load([]string{"-tls-cert-file", "server.crt", "-tls-key-file", "server.key", "-tls-client-ca-file", "clients.pem"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backup-bot", "report-bot"}, cfg.ClientCertUsers)
+}
+
+// TestConfigFlag checks that -config takes precedence over SECURE_NOTES_CONFIG
+func TestConfigFlag(t *testing.T) {
+	t.Setenv("SECURE_NOTES_CONFIG", writeConfig(t, `{"listen_addr": ":9000"}`))
+	flagFile := writeConfig(t, `{"listen_addr": ":9200"}`)
+
+	cfg, err := config.Load([]string{"-config", flagFile})
+	require.NoError(t, err)
+	assert.Equal(t, ":9200", cfg.ListenAddr)
+}
+
+// TestEnvCORSOrigins checks that list settings are comma-separated in the environment
+func TestEnvCORSOrigins(t *testing.T) {
+	t.Setenv("SECURE_NOTES_CORS_ORIGINS", "https://a.example.com, https://b.example.com/")
+
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORSOrigins)
+}
+
+// TestSecretNotAFlag checks that the JWT secret can only come from the file or environment
+func TestSecretNotAFlag(t *testing.T) {
+	_, err := config.Load([]string{"-jwt-secret", "0123456789abcdef0123456789abcdef"})
+	assert.Error(t, err)
+
+	t.Setenv("SECURE_NOTES_JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	cfg, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", cfg.JWTSecret)
+}
+
+// TestInvalidFile checks that broken config files are reported
+func TestInvalidFile(t *testing.T) {
+	testCases := map[string]string{
+		"unknown setting":  `{"listen_adress": ":9000"}`,
+		"not JSON":         `listen_addr = ":9000"`,
+		"wrong value type": `{"listen_addr": true}`,
+		"bad number":       `{"rate_limit_ip": "many"}`,
+		"bad duration":     `{"cleanup_interval": "hourly"}`,
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := config.Load([]string{"-config", writeConfig(t, content)})
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := config.Load([]string{"-config", filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err, "Missing config file should be reported")
+}
+
+// TestValidate checks that invalid settings are rejected at startup
+func TestValidate(t *testing.T) {
+	testCases := map[string]func(cfg *config.Config){
+		"listen address":          func(cfg *config.Config) { cfg.ListenAddr = "8080" },
+		"relative public URL":     func(cfg *config.Config) { cfg.PublicBaseURL = "notes.example.com" },
+		"public URL scheme":       func(cfg *config.Config) { cfg.PublicBaseURL = "ftp://notes.example.com" },
+		"empty DB path":           func(cfg *config.Config) { cfg.DBPath = "" },
+		"no CORS origins":         func(cfg *config.Config) { cfg.CORSOrigins = nil },
+		"CORS origin with path":   func(cfg *config.Config) { cfg.CORSOrigins = []string{"https://app.example.com/login"} },
+		"zero cleanup interval":   func(cfg *config.Config) { cfg.CleanupInterval = 0 },
+		"zero shutdown timeout":   func(cfg *config.Config) { cfg.ShutdownTimeout = 0 },
+		"access outlives refresh": func(cfg *config.Config) { cfg.AccessTokenLifetime = 31 * 24 * time.Hour },
+		"default above max share": func(cfg *config.Config) { cfg.ShareDefaultDuration = 8 * 24 * time.Hour },
+		"TLS cert without key":    func(cfg *config.Config) { cfg.TLSCertFile = "server.crt" },
+		"client CA without TLS":   func(cfg *config.Config) { cfg.TLSClientCAFile = "clients.pem" },
+		"cert users without CA":   func(cfg *config.Config) { cfg.ClientCertUsers = []string{"backup-bot"} },
+		"negative IP rate limit":  func(cfg *config.Config) { cfg.RateLimitPerIP = -1 },
+		"lockout base above max":  func(cfg *config.Config) { cfg.LockoutBase = 2 * time.Hour },
+		"zero lockout duration":   func(cfg *config.Config) { cfg.LockoutMax = 0 },
+		"invalid trusted proxy":   func(cfg *config.Config) { cfg.TrustedProxies = []string{"proxy.local"} },
+		"negative log retention":  func(cfg *config.Config) { cfg.AccessLogRetention = -time.Hour },
+		"negative revision age":   func(cfg *config.Config) { cfg.RevisionMaxAge = -time.Hour },
+		"negative receipt age":    func(cfg *config.Config) { cfg.ReceiptRetention = -time.Hour },
+		"negative revision count": func(cfg *config.Config) { cfg.MaxRevisionsPerNote = -1 },
+		"keys file and secret": func(cfg *config.Config) {
+			cfg.JWTKeysFile = "keys.json"
+			cfg.JWTSecret = "0123456789abcdef0123456789abcdef"
+		},
+	}
+
+	for name, modify := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.Default()
+			modify(cfg)
+			assert.Error(t, cfg.Validate())
+		})
+	}
+
+	assert.NoError(t, config.Default().Validate())
+
+	// Every problem is reported, not just the first one
+	cfg := config.Default()
+	cfg.ListenAddr = ""
+	cfg.DBPath = ""
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "listen_addr")
+	assert.Contains(t, err.Error(), "db_path")
+
+	_, err = config.Load([]string{"-share-max-duration", "-1h"})
+	assert.Error(t, err, "Load should validate the result")
+}
@@ -0,0 +1,173 @@
+package crypto_test
+
+import (
+	"lab02_mahoa/client/crypto"
+	"lab02_mahoa/server/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDeriveKEKLegacy checks that an empty salt keeps the old fixed-salt derivation
+func TestDeriveKEKLegacy(t *testing.T) {
+	kek, err := crypto.DeriveKEK("password123", crypto.KDFParams{Algorithm: crypto.KDFPBKDF2SHA256, Iterations: 100000})
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.DeriveKeyFromPassword("password123", nil), kek, "Legacy params must match existing wrapped keys")
+}
+
+// TestDeriveKEKPerUserSalt checks that the same password gives different KEKs for different salts
+func TestDeriveKEKPerUserSalt(t *testing.T) {
+	aliceParams, err := crypto.NewKDFParams()
+	assert.NoError(t, err)
+	bobParams, err := crypto.NewKDFParams()
+	assert.NoError(t, err)
+
+	assert.False(t, aliceParams.IsLegacy())
+	assert.NotEqual(t, aliceParams.Salt, bobParams.Salt, "Salts should be random")
+
+	aliceKEK, err := crypto.DeriveKEK("samepassword", aliceParams)
+	assert.NoError(t, err)
+	bobKEK, err := crypto.DeriveKEK("samepassword", bobParams)
+	assert.NoError(t, err)
+
+	assert.Len(t, aliceKEK, 32)
+	assert.NotEqual(t, aliceKEK, bobKEK, "Same password with different salts must give different KEKs")
+
+	again, err := crypto.DeriveKEK("samepassword", aliceParams)
+	assert.NoError(t, err)
+	assert.Equal(t, aliceKEK, again, "Derivation must be deterministic")
+}
+
+// TestDeriveKEKInvalidParams checks that unusable parameters are rejected
+func TestDeriveKEKInvalidParams(t *testing.T) {
+	_, err := crypto.DeriveKEK("password", crypto.KDFParams{Algorithm: "md5", Iterations: 1000, Salt: "c2FsdHNhbHRzYWx0c2FsdA=="})
+	assert.Error(t, err)
+
+	_, err = crypto.DeriveKEK("password", crypto.KDFParams{Algorithm: crypto.KDFPBKDF2SHA256, Iterations: 1000, Salt: "not base64!"})
+	assert.Error(t, err)
+}
+
+// TestDeriveKEKArgon2id checks Argon2id derivation and that its parameters matter
+func TestDeriveKEKArgon2id(t *testing.T) {
+	params, err := crypto.NewArgon2idParams(1, crypto.MinArgon2idMemory, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.KDFArgon2id, params.Algorithm)
+
+	kek, err := crypto.DeriveKEK("password123", params)
+	assert.NoError(t, err)
+	assert.Len(t, kek, 32)
+
+	again, err := crypto.DeriveKEK("password123", params)
+	assert.NoError(t, err)
+	assert.Equal(t, kek, again, "Derivation must be deterministic")
+
+	params.Iterations = 2
+	slower, err := crypto.DeriveKEK("password123", params)
+	assert.NoError(t, err)
+	assert.NotEqual(t, kek, slower, "Changing the time cost must change the KEK")
+
+	_, err = crypto.NewArgon2idParams(1, 1024, 1)
+	assert.Error(t, err, "Memory below the minimum should be rejected")
+}
+
+// TestKDFDescriptorRoundTrip checks that descriptors stored with wrapped keys decode to the same parameters
+func TestKDFDescriptorRoundTrip(t *testing.T) {
+	argon, err := crypto.NewArgon2idParams(3, 65536, 4)
+	assert.NoError(t, err)
+	pbkdf, err := crypto.NewKDFParams()
+	assert.NoError(t, err)
+
+	for _, params := range []crypto.KDFParams{argon, pbkdf} {
+		descriptor := params.Descriptor()
+		parsed, err := crypto.ParseKDFDescriptor(descriptor)
+		assert.NoError(t, err, descriptor)
+		assert.Equal(t, params, parsed)
+	}
+
+	assert.Contains(t, argon.Descriptor(), "$argon2id$v=19$m=65536,t=3,p=4$")
+
+	legacy := crypto.KDFParams{Algorithm: crypto.KDFPBKDF2SHA256, Iterations: 100000}
+	assert.Equal(t, "", legacy.Descriptor(), "Legacy keys have no descriptor")
+
+	for _, bad := range []string{"argon2id", "$scrypt$n=1$c2FsdA==", "$argon2id$v=16$m=1,t=1,p=1$c2FsdA==", "$pbkdf2-sha256$i=x$c2FsdA=="} {
+		_, err := crypto.ParseKDFDescriptor(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// TestKDFCostLimits checks that parameters too costly to unlock with are
+// refused, whether they come from a descriptor or from the server
+func TestKDFCostLimits(t *testing.T) {
+	assert.Equal(t, models.MaxArgon2idTime, crypto.MaxArgon2idTime, "Client and server must agree on the limits")
+	assert.Equal(t, models.MaxArgon2idMemory, crypto.MaxArgon2idMemory)
+	assert.Equal(t, models.MaxArgon2idThreads, crypto.MaxArgon2idThreads)
+	assert.Equal(t, models.MaxPBKDF2Iterations, crypto.MaxPBKDF2Iterations)
+
+	for _, bad := range []string{
+		"$argon2id$v=19$m=65536,t=11,p=4$c2FsdA==",
+		"$argon2id$v=19$m=2097152,t=3,p=4$c2FsdA==",
+		"$argon2id$v=19$m=65536,t=3,p=17$c2FsdA==",
+		"$argon2id$v=19$m=4295032832,t=3,p=4$c2FsdA==", // 2^32 + 65536 would wrap to 65536
+		"$pbkdf2-sha256$i=10000001$c2FsdA==",
+	} {
+		_, err := crypto.ParseKDFDescriptor(bad)
+		assert.Error(t, err, bad)
+	}
+
+	salt := "c2FsdHNhbHRzYWx0c2FsdA=="
+	for _, params := range []crypto.KDFParams{
+		{Algorithm: crypto.KDFArgon2id, Iterations: 1, Memory: 4 * 1024 * 1024, Threads: 1, Salt: salt},
+		{Algorithm: crypto.KDFArgon2id, Iterations: 1000, Memory: crypto.MinArgon2idMemory, Threads: 1, Salt: salt},
+		{Algorithm: crypto.KDFArgon2id, Iterations: 1, Memory: crypto.MinArgon2idMemory, Threads: 255, Salt: salt},
+		{Algorithm: crypto.KDFPBKDF2SHA256, Iterations: 1 << 30, Salt: salt},
+	} {
+		_, err := crypto.DeriveKEK("password", params)
+		assert.Error(t, err, "%+v", params)
+	}
+
+	_, err := crypto.NewArgon2idParams(crypto.MaxArgon2idTime+1, crypto.MinArgon2idMemory, 1)
+	assert.Error(t, err)
+	_, err = crypto.NewArgon2idParams(1, crypto.MaxArgon2idMemory+1, 1)
+	assert.Error(t, err)
+}
+
+// TestCalibrateArgon2id checks that calibration returns usable parameters
+func TestCalibrateArgon2id(t *testing.T) {
+	params, err := crypto.CalibrateArgon2id(50 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.KDFArgon2id, params.Algorithm)
+	assert.GreaterOrEqual(t, params.Iterations, 1)
+	assert.GreaterOrEqual(t, params.Memory, uint32(crypto.MinArgon2idMemory))
+	assert.GreaterOrEqual(t, params.Threads, uint8(1))
+
+	_, err = crypto.DeriveKEK("password", params)
+	assert.NoError(t, err)
+}
+
+// TestKEKCache checks that keys wrapped under different KDFs unwrap with the right KEK
+func TestKEKCache(t *testing.T) {
+	current, err := crypto.NewArgon2idParams(1, crypto.MinArgon2idMemory, 1)
+	assert.NoError(t, err)
+	older, err := crypto.NewKDFParams()
+	assert.NoError(t, err)
+	older.Iterations = 1000
+
+	keys := crypto.NewKEKCache("password", current)
+
+	currentKEK, err := crypto.DeriveKEK("password", current)
+	assert.NoError(t, err)
+	olderKEK, err := crypto.DeriveKEK("password", older)
+	assert.NoError(t, err)
+
+	kek, err := keys.KEK("")
+	assert.NoError(t, err)
+	assert.Equal(t, currentKEK, kek, "Keys without a descriptor use the account parameters")
+
+	kek, err = keys.KEK(older.Descriptor())
+	assert.NoError(t, err)
+	assert.Equal(t, olderKEK, kek)
+
+	_, err = keys.KEK("$md5$i=1$c2FsdA==")
+	assert.Error(t, err)
+}
@@ -1,6 +1,8 @@
 package crypto_test
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"lab02_mahoa/client/crypto"
 	"os"
 	"testing"
@@ -243,3 +245,44 @@ func TestKeystoreSpecialCharactersInPassword(t *testing.T) {
 		})
 	}
 }
+
+// TestKeystoreRecordsKDF tests that new keystores store their Argon2id parameters
+func TestKeystoreRecordsKDF(t *testing.T) {
+	username := "testuser_kdf"
+	defer crypto.DeleteDHKeyPair(username)
+
+	keyPair, _ := crypto.GenerateDHKeyPair()
+	assert.NoError(t, crypto.SaveDHKeyPair(username, "password123", keyPair.PrivateKey))
+
+	data, err := os.ReadFile(crypto.GetKeystorePath(username))
+	assert.NoError(t, err)
+
+	var file struct {
+		Version int              `json:"version"`
+		KDF     crypto.KDFParams `json:"kdf"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &file))
+	assert.Equal(t, 2, file.Version)
+	assert.Equal(t, crypto.KDFArgon2id, file.KDF.Algorithm)
+	assert.NotEmpty(t, file.KDF.Salt)
+}
+
+// TestKeystoreLegacyFormat tests that keystores written before the KDF header still load
+func TestKeystoreLegacyFormat(t *testing.T) {
+	username := "testuser_legacy"
+	password := "password123"
+	defer crypto.DeleteDHKeyPair(username)
+
+	keyPair, _ := crypto.GenerateDHKeyPair()
+
+	// Old format: iv:encryptedKey, PBKDF2 salted with the username
+	kek := crypto.DeriveKeyFromPassword(password, []byte(username))
+	encryptedKey, iv, err := crypto.EncryptAES(base64.StdEncoding.EncodeToString(keyPair.PrivateKey.Bytes()), kek)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(crypto.GetKeystorePath(username), []byte(iv+":"+encryptedKey), 0600))
+
+	loaded, err := crypto.LoadDHKeyPair(username, password)
+	assert.NoError(t, err, "Legacy keystore should still load")
+	assert.NotNil(t, loaded)
+	assert.Equal(t, crypto.PublicKeyToBase64(keyPair.PublicKey), crypto.PublicKeyToBase64(loaded.PublicKey()))
+}
@@ -20,7 +20,7 @@ import (
 
 // setupTestDB initializes a test database
 func setupTestDB(t *testing.T) {
-	err := database.InitTestDB(&models.User{}, &models.Note{}, &models.SharedLink{}, &models.E2EEShare{})
+	err := database.InitTestDB(&models.User{}, &models.Note{}, &models.SharedLink{}, &models.E2EEShare{}, &models.RevokedToken{}, &models.RefreshToken{})
 	if err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
@@ -169,6 +169,7 @@ func TestCreateE2EEShare(t *testing.T) {
 	jsonData, _ := json.Marshal(reqBody)
 
 	req := httptest.NewRequest("POST", fmt.Sprintf("/api/notes/%d/e2ee", noteID), bytes.NewBuffer(jsonData))
+	req.SetPathValue("id", fmt.Sprint(noteID))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+getJWTToken(t, senderID, "alice"))
 
@@ -252,6 +253,7 @@ func TestE2EEShareExpiration(t *testing.T) {
 
 	// Try to access expired share
 	req := httptest.NewRequest("GET", fmt.Sprintf("/api/e2ee/%d", expiredShare.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(expiredShare.ID))
 	req.Header.Set("Authorization", "Bearer "+getJWTToken(t, recipientID, "bob"))
 
 	w := httptest.NewRecorder()
@@ -287,6 +289,7 @@ func TestE2EEShareAccessControl(t *testing.T) {
 
 	// Try to access as Eve (unauthorized)
 	req := httptest.NewRequest("GET", fmt.Sprintf("/api/e2ee/%d", share.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(share.ID))
 	req.Header.Set("Authorization", "Bearer "+getJWTToken(t, eveID, "eve"))
 
 	w := httptest.NewRecorder()
@@ -321,6 +324,7 @@ func TestDeleteE2EEShare(t *testing.T) {
 
 	// Delete share as sender
 	req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/e2ee/%d", share.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(share.ID))
 	req.Header.Set("Authorization", "Bearer "+getJWTToken(t, senderID, "alice"))
 
 	w := httptest.NewRecorder()
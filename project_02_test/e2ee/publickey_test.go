@@ -72,6 +72,7 @@ func TestGetUserPublicKey(t *testing.T) {
 
 	// Bob fetches Alice's public key
 	req := httptest.NewRequest(http.MethodGet, "/api/users/alice/publickey", nil)
+	req.SetPathValue("username", "alice")
 	req.Header.Set("Authorization", "Bearer "+bobToken)
 	w := httptest.NewRecorder()
 
@@ -98,6 +99,7 @@ func TestGetUserPublicKeyNotRegistered(t *testing.T) {
 	bobToken := getJWTToken(t, bobID, "bob")
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users/alice/publickey", nil)
+	req.SetPathValue("username", "alice")
 	req.Header.Set("Authorization", "Bearer "+bobToken)
 	w := httptest.NewRecorder()
 
@@ -117,6 +119,7 @@ func TestGetUserPublicKeyNonexistentUser(t *testing.T) {
 	token := getJWTToken(t, userID, "alice")
 
 	req := httptest.NewRequest(http.MethodGet, "/api/users/nonexistent/publickey", nil)
+	req.SetPathValue("username", "nonexistent")
 	req.Header.Set("Authorization", "Bearer "+token)
 	w := httptest.NewRecorder()
 
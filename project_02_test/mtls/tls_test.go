@@ -0,0 +1,272 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"lab02_mahoa/client/api"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/config"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"lab02_mahoa/server/tlsutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a throwaway certificate authority
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCA creates a self-signed CA
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a leaf certificate and writes it and its key to dir/<name>.crt and dir/<name>.key
+func (ca *testCA) issue(t *testing.T, dir, name, commonName string, serial int64, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+// writeCA writes the CA certificate to dir/<name>.pem
+func (ca *testCA) write(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(path, ca.pem, 0600))
+	return path
+}
+
+// startTLSServer serves the KDF endpoint (an authenticated GET) over TLS
+func startTLSServer(t *testing.T, reloader *tlsutil.Reloader) string {
+	require.NoError(t, database.InitTestDB(&models.User{}, &models.RevokedToken{}, &models.RefreshToken{}))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: http.HandlerFunc(handlers.GetKDFHandler)}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return "https://" + listener.Addr().String()
+}
+
+// peerSerial returns the serial number of the certificate the server presented
+func peerSerial(t *testing.T, client *http.Client, url string) int64 {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+}
+
+// TestTLSCertificateReload checks that Reload swaps the certificate for new connections
+// and that a broken certificate file keeps the previous one in use
+func TestTLSCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	certFile, keyFile := ca.issue(t, dir, "server", "localhost", 100, x509.ExtKeyUsageServerAuth)
+
+	reloader, err := tlsutil.NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+	url := startTLSServer(t, reloader)
+
+	client, err := api.NewHTTPClient(api.TLSOptions{CAFile: ca.write(t, dir, "ca")})
+	require.NoError(t, err)
+	client.Transport.(*http.Transport).DisableKeepAlives = true
+
+	assert.Equal(t, int64(100), peerSerial(t, client, url))
+
+	// Renewed certificate written over the old files
+	renewedCert, renewedKey := ca.issue(t, dir, "renewed", "localhost", 200, x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.Rename(renewedCert, certFile))
+	require.NoError(t, os.Rename(renewedKey, keyFile))
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, int64(200), peerSerial(t, client, url), "New connections should get the reloaded certificate")
+
+	// A half-written certificate must not take the server down
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0600))
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, int64(200), peerSerial(t, client, url), "Failed reload should keep the current certificate")
+}
+
+// TestTLSUntrustedServer checks that the client rejects a server whose CA it does not trust
+func TestTLSUntrustedServer(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newTestCA(t, "Server CA").issue(t, dir, "server", "localhost", 1, x509.ExtKeyUsageServerAuth)
+
+	reloader, err := tlsutil.NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+	url := startTLSServer(t, reloader)
+
+	client, err := api.NewHTTPClient(api.TLSOptions{CAFile: newTestCA(t, "Other CA").write(t, dir, "other")})
+	require.NoError(t, err)
+
+	_, err = client.Get(url)
+	assert.Error(t, err, "Server certificate from an unknown CA should be rejected")
+}
+
+// allowCertUsers lets only these accounts log in with a client certificate
+// for the rest of the test
+func allowCertUsers(t *testing.T, usernames ...string) {
+	cfg := config.Default()
+	cfg.ClientCertUsers = usernames
+	handlers.Configure(cfg)
+	t.Cleanup(func() { handlers.Configure(config.Default()) })
+}
+
+// TestMutualTLSClientCertificate checks that a client certificate logs in the
+// automation account named by its common name when no bearer token is sent
+func TestMutualTLSClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	serverCA := newTestCA(t, "Server CA")
+	clientCA := newTestCA(t, "Automation CA")
+	certFile, keyFile := serverCA.issue(t, dir, "server", "localhost", 1, x509.ExtKeyUsageServerAuth)
+
+	reloader, err := tlsutil.NewReloader(certFile, keyFile, clientCA.write(t, dir, "client-ca"))
+	require.NoError(t, err)
+	url := startTLSServer(t, reloader)
+	allowCertUsers(t, "backup-bot", "ghost")
+
+	hash, err := auth.HashPassword("unused-password")
+	require.NoError(t, err)
+	require.NoError(t, database.GetDB().Create(&models.User{Username: "backup-bot", PasswordHash: hash}).Error)
+
+	caFile := serverCA.write(t, dir, "server-ca")
+	get := func(opts api.TLSOptions) (*http.Response, error) {
+		client, err := api.NewHTTPClient(opts)
+		require.NoError(t, err)
+		return client.Get(url)
+	}
+
+	// Certificate for an existing account
+	botCert, botKey := clientCA.issue(t, dir, "bot", "backup-bot", 2, x509.ExtKeyUsageClientAuth)
+	resp, err := get(api.TLSOptions{CAFile: caFile, CertFile: botCert, KeyFile: botKey})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Client certificate should authenticate the account")
+
+	// No certificate and no token
+	resp, err = get(api.TLSOptions{CAFile: caFile})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Certificate for an account that does not exist
+	ghostCert, ghostKey := clientCA.issue(t, dir, "ghost", "ghost", 3, x509.ExtKeyUsageClientAuth)
+	resp, err = get(api.TLSOptions{CAFile: caFile, CertFile: ghostCert, KeyFile: ghostKey})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Certificate signed by a CA the server does not trust: either the client
+	// does not offer it or the handshake fails, never a login
+	rogueCert, rogueKey := newTestCA(t, "Rogue CA").issue(t, dir, "rogue", "backup-bot", 4, x509.ExtKeyUsageClientAuth)
+	resp, err = get(api.TLSOptions{CAFile: caFile, CertFile: rogueCert, KeyFile: rogueKey})
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Certificate from an untrusted CA should not authenticate")
+	}
+}
+
+// TestMutualTLSHumanAccount checks that a valid client certificate cannot log
+// in accounts that did not opt in to certificate login, nor accounts with
+// two-factor login, even if they are on the list
+func TestMutualTLSHumanAccount(t *testing.T) {
+	dir := t.TempDir()
+	serverCA := newTestCA(t, "Server CA")
+	clientCA := newTestCA(t, "Automation CA")
+	certFile, keyFile := serverCA.issue(t, dir, "server", "localhost", 1, x509.ExtKeyUsageServerAuth)
+
+	reloader, err := tlsutil.NewReloader(certFile, keyFile, clientCA.write(t, dir, "client-ca"))
+	require.NoError(t, err)
+	url := startTLSServer(t, reloader)
+	allowCertUsers(t, "backup-bot", "mfa-bot")
+
+	hash, err := auth.HashPassword("unused-password")
+	require.NoError(t, err)
+	require.NoError(t, database.GetDB().Create(&models.User{Username: "alice", PasswordHash: hash}).Error)
+	require.NoError(t, database.GetDB().Create(&models.User{Username: "mfa-bot", PasswordHash: hash, TOTPSecret: "JBSWY3DPEHPK3PXP", TOTPEnabled: true}).Error)
+
+	caFile := serverCA.write(t, dir, "server-ca")
+	for _, username := range []string{"alice", "mfa-bot"} {
+		cert, key := clientCA.issue(t, dir, username, username, 5, x509.ExtKeyUsageClientAuth)
+		client, err := api.NewHTTPClient(api.TLSOptions{CAFile: caFile, CertFile: cert, KeyFile: key})
+		require.NoError(t, err)
+
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "A valid certificate must not log in %s", username)
+	}
+}
+
+// TestNewHTTPClientInvalidOptions checks that unusable client TLS options are reported
+func TestNewHTTPClientInvalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newTestCA(t, "CA").issue(t, dir, "client", "alice", 1, x509.ExtKeyUsageClientAuth)
+
+	_, err := api.NewHTTPClient(api.TLSOptions{CertFile: certFile})
+	assert.Error(t, err, "Certificate without key")
+
+	_, err = api.NewHTTPClient(api.TLSOptions{CAFile: filepath.Join(dir, "missing.pem")})
+	assert.Error(t, err, "Missing CA bundle")
+
+	_, err = api.NewHTTPClient(api.TLSOptions{CAFile: keyFile})
+	assert.Error(t, err, "CA bundle without certificates")
+
+	client, err := api.NewHTTPClient(api.TLSOptions{})
+	require.NoError(t, err)
+	assert.Same(t, http.DefaultClient, client)
+}
@@ -0,0 +1,273 @@
+package notes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"lab02_mahoa/client/diff"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listRevisions sends a GET /api/notes/:id/revisions request
+func listRevisions(token string, noteID uint) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/notes/%d/revisions", noteID), nil)
+	req.SetPathValue("id", fmt.Sprint(noteID))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handlers.ListNoteRevisionsHandler(w, req)
+	return w
+}
+
+// getRevision sends a GET /api/notes/:id/revisions/:revisionId request
+func getRevision(token string, noteID, revisionID uint) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/notes/%d/revisions/%d", noteID, revisionID), nil)
+	req.SetPathValue("id", fmt.Sprint(noteID))
+	req.SetPathValue("revisionId", fmt.Sprint(revisionID))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handlers.GetNoteRevisionHandler(w, req)
+	return w
+}
+
+// restoreRevision sends a POST /api/notes/:id/revisions/:revisionId/restore request
+func restoreRevision(token string, noteID, revisionID uint, version int) *httptest.ResponseRecorder {
+	jsonBody, _ := json.Marshal(models.RestoreRevisionRequest{Version: version})
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/notes/%d/revisions/%d/restore", noteID, revisionID), bytes.NewBuffer(jsonBody))
+	req.SetPathValue("id", fmt.Sprint(noteID))
+	req.SetPathValue("revisionId", fmt.Sprint(revisionID))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handlers.RestoreNoteRevisionHandler(w, req)
+	return w
+}
+
+func TestUpdateNoteKeepsRevision(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Tracked")
+	token := getJWTToken(t, userID, "alice")
+
+	w := updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "encrypted_content_v2", IV: "iv_v2", Version: 1})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var revisions []models.NoteRevision
+	database.GetDB().Where("note_id = ?", note.ID).Find(&revisions)
+	assert.Len(t, revisions, 1, "Each update should archive the previous ciphertext")
+	assert.Equal(t, 1, revisions[0].Version)
+	assert.Equal(t, "encrypted_content_v1", revisions[0].EncryptedContent)
+	assert.Equal(t, "iv_v1", revisions[0].IV)
+	assert.Equal(t, "encrypted_key", revisions[0].EncryptedKey)
+	assert.False(t, revisions[0].SavedAt.IsZero())
+}
+
+func TestConflictingUpdateKeepsNoRevision(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Contended")
+	token := getJWTToken(t, userID, "alice")
+
+	updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "first", IV: "iv1", Version: 1})
+	w := updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "second", IV: "iv2", Version: 1})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var count int64
+	database.GetDB().Model(&models.NoteRevision{}).Where("note_id = ?", note.ID).Count(&count)
+	assert.Equal(t, int64(1), count, "Rejected update must not archive anything")
+}
+
+func TestListAndGetRevisions(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "History")
+	token := getJWTToken(t, userID, "alice")
+
+	updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "encrypted_content_v2", IV: "iv_v2", Version: 1})
+	updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "encrypted_content_v3", IV: "iv_v3", Version: 2})
+
+	w := listRevisions(token, note.ID)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var listResp models.ListNoteRevisionsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listResp))
+	assert.Equal(t, 2, listResp.Count)
+	assert.Equal(t, 2, listResp.Revisions[0].Version, "Newest revision should come first")
+	assert.Equal(t, 1, listResp.Revisions[1].Version)
+	assert.Empty(t, listResp.Revisions[0].EncryptedContent, "List should not include ciphertext")
+
+	w = getRevision(token, note.ID, listResp.Revisions[1].ID)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var revision models.NoteRevisionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &revision))
+	assert.Equal(t, "encrypted_content_v1", revision.EncryptedContent)
+	assert.Equal(t, "iv_v1", revision.IV)
+	assert.Equal(t, "encrypted_key", revision.EncryptedKey)
+	assert.Equal(t, "encrypted_key_iv", revision.EncryptedKeyIV)
+}
+
+func TestRevisionsOfOtherUsersNote(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	ownerID := createTestUser(t, "alice")
+	otherID := createTestUser(t, "mallory")
+	note := createTestNote(t, ownerID, "Private")
+	ownerToken := getJWTToken(t, ownerID, "alice")
+	otherToken := getJWTToken(t, otherID, "mallory")
+
+	updateNote(ownerToken, note.ID, models.UpdateNoteRequest{EncryptedContent: "v2", IV: "iv2", Version: 1})
+
+	var revision models.NoteRevision
+	database.GetDB().Where("note_id = ?", note.ID).First(&revision)
+
+	assert.Equal(t, http.StatusNotFound, listRevisions(otherToken, note.ID).Code)
+	assert.Equal(t, http.StatusNotFound, getRevision(otherToken, note.ID, revision.ID).Code)
+	assert.Equal(t, http.StatusNotFound, restoreRevision(otherToken, note.ID, revision.ID, 2).Code)
+}
+
+func TestRestoreRevision(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Restorable")
+	token := getJWTToken(t, userID, "alice")
+
+	updateNote(token, note.ID, models.UpdateNoteRequest{
+		EncryptedContent: "encrypted_content_v2",
+		IV:               "iv_v2",
+		EncryptedKey:     "rotated_key",
+		EncryptedKeyIV:   "rotated_key_iv",
+		Version:          1,
+	})
+
+	var revision models.NoteRevision
+	database.GetDB().Where("note_id = ? AND version = ?", note.ID, 1).First(&revision)
+
+	w := restoreRevision(token, note.ID, revision.ID, 2)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response models.NoteResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.Version, "Restore should create a new version")
+	assert.Equal(t, "encrypted_content_v1", response.EncryptedContent)
+	assert.Equal(t, "encrypted_key", response.EncryptedKey, "Restore should bring back the revision's wrapped key")
+
+	// The replaced content is kept so the restore can be undone
+	var count int64
+	database.GetDB().Model(&models.NoteRevision{}).Where("note_id = ? AND encrypted_content = ?", note.ID, "encrypted_content_v2").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRestoreRevisionStaleVersion(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Contended")
+	token := getJWTToken(t, userID, "alice")
+
+	updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "v2", IV: "iv2", Version: 1})
+
+	var revision models.NoteRevision
+	database.GetDB().Where("note_id = ?", note.ID).First(&revision)
+
+	w := restoreRevision(token, note.ID, revision.ID, 1)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var stored models.Note
+	database.GetDB().First(&stored, note.ID)
+	assert.Equal(t, "v2", stored.EncryptedContent)
+}
+
+func TestDeleteNoteRemovesRevisions(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Doomed")
+	token := getJWTToken(t, userID, "alice")
+
+	updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "v2", IV: "iv2", Version: 1})
+
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/notes/%d", note.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(note.ID))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handlers.DeleteNoteHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var count int64
+	database.GetDB().Model(&models.NoteRevision{}).Where("note_id = ?", note.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestLineDiff(t *testing.T) {
+	lines := diff.Lines("one\ntwo\nthree\n", "one\n2\nthree\nfour\n")
+
+	assert.True(t, diff.HasChanges(lines))
+	assert.Equal(t, "  one\n- two\n+ 2\n  three\n+ four\n", diff.Format(lines))
+
+	assert.False(t, diff.HasChanges(diff.Lines("same\n", "same")))
+}
+
+func TestKeyKDFFollowsWrappedKey(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Argon2id")
+	token := getJWTToken(t, userID, "alice")
+
+	const descriptor = "$argon2id$v=19$m=65536,t=3,p=4$c2FsdHNhbHRzYWx0c2FsdA=="
+
+	w := updateNote(token, note.ID, models.UpdateNoteRequest{
+		EncryptedContent: "encrypted_content_v2",
+		IV:               "iv_v2",
+		EncryptedKey:     "argon_key",
+		EncryptedKeyIV:   "argon_key_iv",
+		KeyKDF:           descriptor,
+		Version:          1,
+	})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response models.NoteResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, descriptor, response.KeyKDF)
+
+	// Updating content only keeps the wrapped key and its descriptor
+	w = updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "encrypted_content_v3", IV: "iv_v3", Version: 2})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var revision models.NoteRevision
+	database.GetDB().Where("note_id = ? AND version = ?", note.ID, 2).First(&revision)
+	assert.Equal(t, descriptor, revision.KeyKDF, "Revisions keep the descriptor of their wrapped key")
+
+	// Restoring version 1 brings back its key, which has no descriptor
+	var first models.NoteRevision
+	database.GetDB().Where("note_id = ? AND version = ?", note.ID, 1).First(&first)
+	w = restoreRevision(token, note.ID, first.ID, 3)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var restored models.NoteResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	assert.Equal(t, "encrypted_key", restored.EncryptedKey)
+	assert.Equal(t, "", restored.KeyKDF)
+}
@@ -0,0 +1,224 @@
+package notes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/database"
+	"lab02_mahoa/server/handlers"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestDB initializes a test database
+func setupTestDB(t *testing.T) {
+	err := database.InitTestDB(&models.User{}, &models.Note{}, &models.NoteRevision{}, &models.SharedLink{}, &models.ShareAccessEvent{}, &models.RevokedToken{}, &models.RefreshToken{})
+	if err != nil {
+		t.Fatalf("Failed to initialize test database: %v", err)
+	}
+}
+
+// teardownTestDB cleans up the test database
+func teardownTestDB(t *testing.T) {
+	db := database.GetDB()
+	if db != nil {
+		sqlDB, _ := db.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}
+}
+
+// createTestUser creates a user for testing
+func createTestUser(t *testing.T, username string) uint {
+	user := models.User{
+		Username:     username,
+		PasswordHash: "hash",
+	}
+	if err := database.GetDB().Create(&user).Error; err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	return user.ID
+}
+
+// createTestNote creates a note for testing
+func createTestNote(t *testing.T, userID uint, title string) models.Note {
+	note := models.Note{
+		UserID:           userID,
+		Title:            title,
+		EncryptedContent: "encrypted_content_v1",
+		IV:               "iv_v1",
+		EncryptedKey:     "encrypted_key",
+		EncryptedKeyIV:   "encrypted_key_iv",
+	}
+	if err := database.GetDB().Create(&note).Error; err != nil {
+		t.Fatalf("Failed to create test note: %v", err)
+	}
+	return note
+}
+
+// getJWTToken generates a JWT token for testing
+func getJWTToken(t *testing.T, userID uint, username string) string {
+	token, err := auth.GenerateJWT(userID, username)
+	if err != nil {
+		t.Fatalf("Failed to generate JWT: %v", err)
+	}
+	return token
+}
+
+// updateNote sends a PUT /api/notes/:id request
+func updateNote(token string, noteID uint, body models.UpdateNoteRequest) *httptest.ResponseRecorder {
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/notes/%d", noteID), bytes.NewBuffer(jsonBody))
+	req.SetPathValue("id", fmt.Sprint(noteID))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handlers.UpdateNoteHandler(w, req)
+	return w
+}
+
+func TestCreatedNoteStartsAtVersionOne(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Versioned")
+
+	var stored models.Note
+	database.GetDB().First(&stored, note.ID)
+	assert.Equal(t, 1, stored.Version, "New notes should start at version 1")
+}
+
+func TestUpdateNoteSuccess(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Editable")
+	token := getJWTToken(t, userID, "alice")
+
+	w := updateNote(token, note.ID, models.UpdateNoteRequest{
+		EncryptedContent: "encrypted_content_v2",
+		IV:               "iv_v2",
+		Version:          1,
+	})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response models.NoteResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Version, "Version should be incremented")
+	assert.Equal(t, "encrypted_content_v2", response.EncryptedContent)
+	assert.Equal(t, "iv_v2", response.IV)
+	assert.Equal(t, "encrypted_key", response.EncryptedKey, "Wrapped key should be kept when not provided")
+
+	var stored models.Note
+	database.GetDB().First(&stored, note.ID)
+	assert.Equal(t, "encrypted_content_v2", stored.EncryptedContent)
+	assert.Equal(t, 2, stored.Version)
+}
+
+func TestUpdateNoteWithRewrappedKey(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Rewrapped")
+	token := getJWTToken(t, userID, "alice")
+
+	w := updateNote(token, note.ID, models.UpdateNoteRequest{
+		EncryptedContent: "encrypted_content_v2",
+		IV:               "iv_v2",
+		EncryptedKey:     "new_encrypted_key",
+		EncryptedKeyIV:   "new_encrypted_key_iv",
+		Version:          1,
+	})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var stored models.Note
+	database.GetDB().First(&stored, note.ID)
+	assert.Equal(t, "new_encrypted_key", stored.EncryptedKey)
+	assert.Equal(t, "new_encrypted_key_iv", stored.EncryptedKeyIV)
+}
+
+func TestUpdateNoteStaleVersionConflict(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Contended")
+	token := getJWTToken(t, userID, "alice")
+
+	// First writer wins
+	w := updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "first", IV: "iv1", Version: 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Second writer read version 1 too and must be rejected
+	w = updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "second", IV: "iv2", Version: 1})
+	assert.Equal(t, http.StatusConflict, w.Code, "Stale version should be rejected with 409")
+
+	var stored models.Note
+	database.GetDB().First(&stored, note.ID)
+	assert.Equal(t, "first", stored.EncryptedContent, "Rejected update must not overwrite content")
+	assert.Equal(t, 2, stored.Version)
+}
+
+func TestUpdateNoteOtherUsersNote(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	ownerID := createTestUser(t, "alice")
+	otherID := createTestUser(t, "mallory")
+	note := createTestNote(t, ownerID, "Private")
+	token := getJWTToken(t, otherID, "mallory")
+
+	w := updateNote(token, note.ID, models.UpdateNoteRequest{EncryptedContent: "evil", IV: "iv", Version: 1})
+	assert.Equal(t, http.StatusNotFound, w.Code, "Other users' notes should look nonexistent")
+
+	var stored models.Note
+	database.GetDB().First(&stored, note.ID)
+	assert.Equal(t, "encrypted_content_v1", stored.EncryptedContent)
+}
+
+func TestUpdateNoteValidation(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Validated")
+	token := getJWTToken(t, userID, "alice")
+
+	testCases := []struct {
+		name string
+		body models.UpdateNoteRequest
+	}{
+		{"missing content", models.UpdateNoteRequest{IV: "iv", Version: 1}},
+		{"missing IV", models.UpdateNoteRequest{EncryptedContent: "c", Version: 1}},
+		{"missing version", models.UpdateNoteRequest{EncryptedContent: "c", IV: "iv"}},
+		{"key without IV", models.UpdateNoteRequest{EncryptedContent: "c", IV: "iv", EncryptedKey: "k", Version: 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := updateNote(token, note.ID, tc.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestUpdateNoteUnauthorized(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	userID := createTestUser(t, "alice")
+	note := createTestNote(t, userID, "Locked")
+
+	w := updateNote("invalid-token", note.ID, models.UpdateNoteRequest{EncryptedContent: "c", IV: "iv", Version: 1})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
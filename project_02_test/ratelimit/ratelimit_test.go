@@ -0,0 +1,172 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"lab02_mahoa/server/ratelimit"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clock is a time source the test moves by hand
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestLimiterBurstAndRefill checks that a bucket allows a burst, then refills over time
+func TestLimiterBurstAndRefill(t *testing.T) {
+	c := newClock()
+	limiter := ratelimit.NewLimiter(6, 3, c.Now) // One token every 10 seconds
+
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.Allow("ip:1.2.3.4")
+		assert.True(t, ok, "Request %d is within the burst", i+1)
+	}
+
+	ok, wait := limiter.Allow("ip:1.2.3.4")
+	assert.False(t, ok)
+	assert.Equal(t, 10*time.Second, wait)
+
+	ok, _ = limiter.Allow("ip:5.6.7.8")
+	assert.True(t, ok, "Other keys have their own bucket")
+
+	c.Advance(5 * time.Second)
+	ok, wait = limiter.Allow("ip:1.2.3.4")
+	assert.False(t, ok)
+	assert.Equal(t, 5*time.Second, wait)
+
+	c.Advance(5 * time.Second)
+	ok, _ = limiter.Allow("ip:1.2.3.4")
+	assert.True(t, ok, "A token should be back after 10 seconds")
+}
+
+// TestLimiterDisabled checks that a zero rate and a nil limiter allow everything
+func TestLimiterDisabled(t *testing.T) {
+	limiter := ratelimit.NewLimiter(0, 0, nil)
+	var none *ratelimit.Limiter
+	for i := 0; i < 100; i++ {
+		ok, _ := limiter.Allow("key")
+		assert.True(t, ok)
+		ok, _ = none.Allow("key")
+		assert.True(t, ok)
+	}
+}
+
+// TestLimiterManyKeys checks that old buckets are dropped instead of growing forever
+func TestLimiterManyKeys(t *testing.T) {
+	c := newClock()
+	limiter := ratelimit.NewLimiter(60, 1, c.Now)
+
+	for i := 0; i < 20000; i++ {
+		ok, _ := limiter.Allow(fmt.Sprintf("ip:%d", i))
+		assert.True(t, ok)
+		if i%1000 == 0 {
+			c.Advance(time.Second)
+		}
+	}
+
+	ok, _ := limiter.Allow("ip:19999")
+	assert.False(t, ok, "Recent buckets must survive pruning")
+}
+
+// TestLimiterCapsKeys checks that a spray of keys never grows the limiter
+// past its cap, and that idle buckets are dropped over time
+func TestLimiterCapsKeys(t *testing.T) {
+	c := newClock()
+	limiter := ratelimit.NewLimiter(1, 5, c.Now)
+
+	for i := 0; i < 25000; i++ {
+		limiter.Allow(fmt.Sprintf("login:user%d", i))
+	}
+	assert.Equal(t, 10000, limiter.Len(), "Partial buckets must not grow the limiter past its cap")
+
+	ok, _ := limiter.Allow("login:user24999")
+	assert.True(t, ok, "The most recent keys are kept")
+
+	// Five minutes refill every bucket, so they are all idle
+	c.Advance(5 * time.Minute)
+	limiter.Allow("login:new")
+	assert.Equal(t, 1, limiter.Len())
+}
+
+// TestLockoutDoubles checks that the lockout starts at the threshold and doubles up to the maximum
+func TestLockoutDoubles(t *testing.T) {
+	c := newClock()
+	lockout := ratelimit.NewLockout(3, time.Minute, 5*time.Minute, c.Now)
+
+	assert.Zero(t, lockout.Fail("login:alice"))
+	assert.Zero(t, lockout.Fail("login:alice"))
+	assert.Zero(t, lockout.Locked("login:alice"), "Below the threshold")
+
+	assert.Equal(t, time.Minute, lockout.Fail("login:alice"))
+	assert.Equal(t, time.Minute, lockout.Locked("login:alice"))
+	assert.Zero(t, lockout.Locked("login:bob"), "Other keys are not affected")
+
+	c.Advance(time.Minute)
+	assert.Zero(t, lockout.Locked("login:alice"))
+	assert.Equal(t, 2*time.Minute, lockout.Fail("login:alice"))
+	assert.Equal(t, 4*time.Minute, lockout.Fail("login:alice"))
+	assert.Equal(t, 5*time.Minute, lockout.Fail("login:alice"), "Capped at the maximum")
+	assert.Equal(t, 5*time.Minute, lockout.Fail("login:alice"))
+	assert.Equal(t, 7, lockout.Failures("login:alice"))
+
+	lockout.Reset("login:alice")
+	assert.Zero(t, lockout.Locked("login:alice"))
+	assert.Zero(t, lockout.Failures("login:alice"))
+}
+
+// TestLockoutForgetsOldFailures checks that failures spread far apart do not add up
+func TestLockoutForgetsOldFailures(t *testing.T) {
+	c := newClock()
+	lockout := ratelimit.NewLockout(2, time.Minute, 10*time.Minute, c.Now)
+
+	assert.Zero(t, lockout.Fail("share:abc"))
+	c.Advance(time.Hour)
+	assert.Zero(t, lockout.Fail("share:abc"), "The first failure is too old to count")
+	assert.Equal(t, time.Minute, lockout.Fail("share:abc"))
+}
+
+// TestLockoutCapsKeys checks that the lockout forgets idle keys first and never grows past its cap
+func TestLockoutCapsKeys(t *testing.T) {
+	c := newClock()
+	lockout := ratelimit.NewLockout(1, time.Minute, 10*time.Minute, c.Now)
+
+	for i := 0; i < 15000; i++ {
+		lockout.Fail(fmt.Sprintf("login:user%d", i))
+	}
+	assert.Equal(t, 10000, lockout.Len())
+	assert.Zero(t, lockout.Failures("login:user0"), "The least recently failed keys are forgotten")
+	assert.Equal(t, time.Minute, lockout.Locked("login:user14999"))
+
+	c.Advance(11 * time.Minute)
+	lockout.Fail("login:new")
+	assert.Equal(t, 1, lockout.Len(), "Keys whose lock and failures are over are dropped")
+}
+
+// TestLockoutDisabled checks that a zero threshold never locks
+func TestLockoutDisabled(t *testing.T) {
+	lockout := ratelimit.NewLockout(0, time.Minute, time.Hour, nil)
+	for i := 0; i < 20; i++ {
+		assert.Zero(t, lockout.Fail("login:alice"))
+	}
+	assert.Zero(t, lockout.Locked("login:alice"))
+}
@@ -0,0 +1,208 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/config"
+	"lab02_mahoa/server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openShare opens a share link the way a browser behind a proxy would and returns the status
+func openShare(t *testing.T, method, url string, body interface{}, userAgent, forwardedFor string) int {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// accessLog fetches the access log of a note, or of one of its links when linkID is not 0
+func accessLog(t *testing.T, baseURL, token string, noteID, linkID uint) []models.ShareAccessEvent {
+	t.Helper()
+	url := fmt.Sprintf("%s/api/notes/%d/access-log", baseURL, noteID)
+	if linkID != 0 {
+		url = fmt.Sprintf("%s/api/notes/%d/shares/%d/access-log", baseURL, noteID, linkID)
+	}
+
+	var list models.ListShareAccessEventsResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, url, token, nil, &list))
+	assert.Equal(t, len(list.Events), list.Count)
+	return list.Events
+}
+
+// outcomes lists the outcomes of events in order
+func outcomes(events []models.ShareAccessEvent) []string {
+	list := make([]string, len(events))
+	for i, event := range events {
+		list[i] = event.Outcome
+	}
+	return list
+}
+
+// TestAccessLogRecordsOutcomes checks that every attempt to open a link is
+// logged with its outcome, IP and user agent, even after the link is gone
+func TestAccessLogRecordsOutcomes(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	cfg := config.Default()
+	cfg.AccessTokenLifetime = 24 * time.Hour // Outlive the plain link
+	baseURL := startServerConfig(t, cfg, server.WithClock(clock.Now))
+	token := registerAndLogin(t, baseURL, "audrey")
+	noteID := createNoteWithLinks(t, baseURL, token)
+
+	links := listLinks(t, baseURL, token, noteID)
+	require.Len(t, links, 3)
+	limited, protected, plain := links[0], links[1], links[2]
+	shareURL := func(link models.ShareLinkInfo) string { return baseURL + "/api/shares/" + link.ShareToken }
+
+	assert.Empty(t, accessLog(t, baseURL, token, noteID, 0), "Nothing has been opened yet")
+
+	assert.Equal(t, http.StatusUnauthorized, openShare(t, http.MethodPost, shareURL(protected), nil, "curl/8.5", ""))
+	assert.Equal(t, http.StatusUnauthorized, openShare(t, http.MethodPost, shareURL(protected), models.AccessShareRequest{Password: "wrong"}, "curl/8.5", ""))
+	assert.Equal(t, http.StatusOK, openShare(t, http.MethodPost, shareURL(protected), models.AccessShareRequest{Password: "letmein"}, "Firefox/131.0", ""))
+
+	assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, shareURL(limited), nil, "Safari/18.0", ""))
+	assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, shareURL(limited), nil, "Safari/18.0", ""))
+	assert.Equal(t, http.StatusGone, openShare(t, http.MethodGet, shareURL(limited), nil, strings.Repeat("x", 2000), ""))
+
+	clock.Advance(90 * time.Minute)
+	assert.Equal(t, http.StatusGone, openShare(t, http.MethodGet, shareURL(plain), nil, "Chrome/130.0", ""))
+
+	events := accessLog(t, baseURL, token, noteID, 0)
+	assert.Equal(t, []string{
+		models.ShareAccessExpired,
+		models.ShareAccessExhausted, models.ShareAccessSuccess, models.ShareAccessSuccess,
+		models.ShareAccessSuccess, models.ShareAccessWrongPassword, models.ShareAccessPasswordRequired,
+	}, outcomes(events), "Newest first")
+
+	expired := events[0]
+	assert.Equal(t, plain.ID, expired.LinkID)
+	assert.Equal(t, noteID, expired.NoteID)
+	assert.Equal(t, "127.0.0.1", expired.IP)
+	assert.Equal(t, "Chrome/130.0", expired.UserAgent)
+	assert.WithinDuration(t, clock.Now(), expired.CreatedAt, 0)
+	assert.Len(t, events[1].UserAgent, 512, "Long user agents are cut")
+
+	protectedEvents := accessLog(t, baseURL, token, noteID, protected.ID)
+	assert.Equal(t, []string{models.ShareAccessSuccess, models.ShareAccessWrongPassword, models.ShareAccessPasswordRequired}, outcomes(protectedEvents))
+	assert.Equal(t, "Firefox/131.0", protectedEvents[0].UserAgent)
+
+	assert.Len(t, accessLog(t, baseURL, token, noteID, limited.ID), 3, "The log outlives the exhausted link")
+	assert.Len(t, listLinks(t, baseURL, token, noteID), 1, "Expired and exhausted links were deleted")
+}
+
+// TestAccessLogSuccessIsCountedOnce checks that an open writes exactly one
+// success event, and that a burn link keeps it after its content is destroyed
+func TestAccessLogSuccessIsCountedOnce(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "selma")
+
+	// noteOf returns the ID of the note behind a share link
+	noteOf := func(share models.ShareLinkResponse) uint {
+		var notes models.ListNotesResponse
+		require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/notes", token, nil, &notes))
+		for _, note := range notes.Notes {
+			for _, link := range listLinks(t, baseURL, token, note.ID) {
+				if link.ShareToken == share.ShareToken {
+					return note.ID
+				}
+			}
+		}
+		t.Fatalf("No note has the share link %s", share.ShareToken)
+		return 0
+	}
+
+	plain := createShare(t, baseURL, token, models.CreateShareRequest{})
+	assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, baseURL+"/api/shares/"+plain.ShareToken, nil, "test", ""))
+	assert.Equal(t, []string{models.ShareAccessSuccess}, outcomes(accessLog(t, baseURL, token, noteOf(plain), 0)))
+
+	burn := createShare(t, baseURL, token, models.CreateShareRequest{BurnAfterReading: true, EncryptedContent: "b25jZQ==", IV: "aXY="})
+	burnNote := noteOf(burn)
+	assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, baseURL+"/api/shares/"+burn.ShareToken, nil, "test", ""))
+	assert.Equal(t, []string{models.ShareAccessSuccess}, outcomes(accessLog(t, baseURL, token, burnNote, 0)), "The success survives the burn")
+
+	assert.Equal(t, http.StatusGone, openShare(t, http.MethodGet, baseURL+"/api/shares/"+burn.ShareToken, nil, "test", ""))
+	assert.Equal(t, []string{models.ShareAccessAlreadyRead, models.ShareAccessSuccess}, outcomes(accessLog(t, baseURL, token, burnNote, 0)))
+}
+
+// TestAccessLogClientIP checks that X-Forwarded-For is only believed from trusted proxies
+func TestAccessLogClientIP(t *testing.T) {
+	t.Run("untrusted", func(t *testing.T) {
+		baseURL := startServer(t, "")
+		token := registerAndLogin(t, baseURL, "ulrich")
+		share := createShare(t, baseURL, token, models.CreateShareRequest{})
+
+		assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, baseURL+"/api/shares/"+share.ShareToken, nil, "test", "203.0.113.7"))
+
+		var notes models.ListNotesResponse
+		require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/notes", token, nil, &notes))
+		require.Len(t, notes.Notes, 1)
+		events := accessLog(t, baseURL, token, notes.Notes[0].ID, 0)
+		require.Len(t, events, 1)
+		assert.Equal(t, "127.0.0.1", events[0].IP, "A client cannot choose its own IP")
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.TrustedProxies = []string{"127.0.0.1", "10.0.0.0/8"}
+		baseURL := startServerConfig(t, cfg)
+		token := registerAndLogin(t, baseURL, "tristan")
+		share := createShare(t, baseURL, token, models.CreateShareRequest{})
+		openURL := baseURL + "/api/shares/" + share.ShareToken
+
+		// Spoofed hop, real client, then an internal proxy
+		assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, openURL, nil, "test", "198.51.100.1, 203.0.113.7, 10.1.2.3"))
+		assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, openURL, nil, "test", "not-an-ip"))
+
+		var notes models.ListNotesResponse
+		require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/notes", token, nil, &notes))
+		require.Len(t, notes.Notes, 1)
+		events := accessLog(t, baseURL, token, notes.Notes[0].ID, 0)
+		require.Len(t, events, 2)
+		assert.Equal(t, "127.0.0.1", events[0].IP, "A garbled header falls back to the proxy")
+		assert.Equal(t, "203.0.113.7", events[1].IP)
+	})
+}
+
+// TestAccessLogIsPrivate checks that only the note's owner can read its access log
+func TestAccessLogIsPrivate(t *testing.T) {
+	baseURL := startServer(t, "")
+	owner := registerAndLogin(t, baseURL, "olive")
+	other := registerAndLogin(t, baseURL, "oscar")
+	noteID := createNoteWithLinks(t, baseURL, owner)
+	link := listLinks(t, baseURL, owner, noteID)[0]
+
+	assert.Equal(t, http.StatusOK, openShare(t, http.MethodGet, baseURL+"/api/shares/"+link.ShareToken, nil, "test", ""))
+
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodGet, fmt.Sprintf("%s/api/notes/%d/access-log", baseURL, noteID), other, nil, nil))
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodGet, fmt.Sprintf("%s/api/notes/%d/shares/%d/access-log", baseURL, noteID, link.ID), other, nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodGet, fmt.Sprintf("%s/api/notes/%d/access-log", baseURL, noteID), "", nil, nil))
+
+	// The log goes away with the note
+	require.Equal(t, http.StatusOK, call(t, http.MethodDelete, fmt.Sprintf("%s/api/notes/%d", baseURL, noteID), owner, nil, nil))
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodGet, fmt.Sprintf("%s/api/notes/%d/access-log", baseURL, noteID), owner, nil, nil))
+}
@@ -0,0 +1,172 @@
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/config"
+	"lab02_mahoa/server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receipts fetches the user's read receipts
+func receipts(t *testing.T, baseURL, token string) []models.ReadReceipt {
+	t.Helper()
+	var list models.ListReadReceiptsResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/receipts", token, nil, &list))
+	assert.Equal(t, len(list.Receipts), list.Count)
+	return list.Receipts
+}
+
+// TestBurnLinkNeedsOwnCopy checks that a burn link is refused without its own encrypted copy
+func TestBurnLinkNeedsOwnCopy(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "bianca")
+
+	note := models.CreateNoteRequest{Title: "b", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	var created models.NoteResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/notes", token, note, &created))
+
+	shareURL := fmt.Sprintf("%s/api/notes/%d/share", baseURL, created.ID)
+	for _, req := range []models.CreateShareRequest{
+		{BurnAfterReading: true},
+		{BurnAfterReading: true, EncryptedContent: "b25jZQ=="},
+		{BurnAfterReading: true, IV: "aXY="},
+	} {
+		assert.Equal(t, http.StatusBadRequest, call(t, http.MethodPost, shareURL, token, req, nil))
+	}
+}
+
+// TestBurnLinkIsReadOnce checks that a burn link serves its own copy once,
+// confirms the destruction and shows up as read in the receipts
+func TestBurnLinkIsReadOnce(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "boris")
+
+	share := createShare(t, baseURL, token, models.CreateShareRequest{
+		BurnAfterReading: true, EncryptedContent: "b25jZQ==", IV: "b25lLWl2",
+	})
+	assert.True(t, share.BurnAfterReading)
+
+	pending := receipts(t, baseURL, token)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "link", pending[0].Type)
+	assert.Nil(t, pending[0].ReadAt, "The link has not been read yet")
+
+	openURL := baseURL + "/api/shares/" + share.ShareToken
+	var shared models.SharedNoteResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, openURL, "", nil, &shared))
+	assert.Equal(t, "b25jZQ==", shared.EncryptedContent, "The link serves its own copy, not the note")
+	assert.Equal(t, "b25lLWl2", shared.IV)
+	assert.True(t, shared.Burned)
+	require.NotNil(t, shared.BurnedAt)
+
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, openURL, "", nil, nil))
+
+	read := receipts(t, baseURL, token)
+	require.Len(t, read, 1)
+	require.NotNil(t, read[0].ReadAt)
+	assert.WithinDuration(t, *shared.BurnedAt, *read[0].ReadAt, 0)
+}
+
+// TestBurnLinkWithWrongPassword checks that failed password attempts do not burn the link
+func TestBurnLinkWithWrongPassword(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "bruno")
+
+	password := "letmein"
+	share := createShare(t, baseURL, token, models.CreateShareRequest{
+		Password: &password, BurnAfterReading: true, EncryptedContent: "b25jZQ==", IV: "aXY=",
+	})
+	openURL := baseURL + "/api/shares/" + share.ShareToken
+
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, openURL, "", models.AccessShareRequest{Password: "wrong"}, nil))
+	assert.Nil(t, receipts(t, baseURL, token)[0].ReadAt)
+
+	var shared models.SharedNoteResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, openURL, "", models.AccessShareRequest{Password: password}, &shared))
+	assert.True(t, shared.Burned)
+	assert.Equal(t, http.StatusGone, call(t, http.MethodPost, openURL, "", models.AccessShareRequest{Password: password}, nil))
+}
+
+// TestBurnLinkReceiptOutlivesExpiry checks that expired burn links stay in
+// the receipts, read or not, and that opening them no longer serves anything
+func TestBurnLinkReceiptOutlivesExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	cfg := config.Default()
+	cfg.AccessTokenLifetime = 48 * time.Hour // Outlive the links
+	baseURL := startServerConfig(t, cfg, server.WithClock(clock.Now))
+	token := registerAndLogin(t, baseURL, "blake")
+
+	read := createShare(t, baseURL, token, models.CreateShareRequest{BurnAfterReading: true, EncryptedContent: "b25jZQ==", IV: "aXY="})
+	unread := createShare(t, baseURL, token, models.CreateShareRequest{BurnAfterReading: true, EncryptedContent: "dHdpY2U=", IV: "aXY="})
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+read.ShareToken, "", nil, nil))
+
+	clock.Advance(25 * time.Hour)
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, baseURL+"/api/shares/"+read.ShareToken, "", nil, nil))
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, baseURL+"/api/shares/"+unread.ShareToken, "", nil, nil))
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, baseURL+"/api/shares/"+unread.ShareToken, "", nil, nil), "Expiry destroys the unread copy")
+
+	list := receipts(t, baseURL, token)
+	require.Len(t, list, 2, "Expired burn links are kept as receipts")
+	readCount := 0
+	for _, receipt := range list {
+		if receipt.ReadAt != nil {
+			readCount++
+			assert.WithinDuration(t, clock.Now().Add(-25*time.Hour), *receipt.ReadAt, 0)
+		}
+	}
+	assert.Equal(t, 1, readCount, "Only the opened link was read")
+}
+
+// TestBurnE2EEShare checks that a burn E2EE share is listed without its
+// content and is destroyed when the recipient opens it
+func TestBurnE2EEShare(t *testing.T) {
+	baseURL := startServer(t, "")
+	sender := registerAndLogin(t, baseURL, "beatrix")
+	recipient := registerAndLogin(t, baseURL, "basil")
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, baseURL+"/api/user/publickey", recipient, models.UpdatePublicKeyRequest{DHPublicKey: "YmFzaWw="}, nil))
+
+	note := models.CreateNoteRequest{Title: "burn", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	var created models.NoteResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/notes", sender, note, &created))
+
+	var e2ee models.E2EEShareResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, fmt.Sprintf("%s/api/notes/%d/e2ee", baseURL, created.ID), sender, models.CreateE2EEShareRequest{
+		RecipientUsername: "basil", SenderPublicKey: "ZXBoZW1lcmFs", EncryptedContent: "c2VjcmV0", ContentIV: "aXY=", BurnAfterReading: true,
+	}, &e2ee))
+	assert.True(t, e2ee.BurnAfterReading)
+
+	var inbox models.ListE2EESharesResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/e2ee", recipient, nil, &inbox))
+	require.Len(t, inbox.Shares, 1)
+	assert.True(t, inbox.Shares[0].BurnAfterReading)
+	assert.Empty(t, inbox.Shares[0].EncryptedContent, "Listing must not hand out the content")
+	assert.Empty(t, inbox.Shares[0].ContentIV)
+
+	shareURL := fmt.Sprintf("%s/api/e2ee/%d", baseURL, e2ee.ShareID)
+	assert.Equal(t, http.StatusForbidden, call(t, http.MethodGet, shareURL, sender, nil, nil), "Only the recipient can burn the share")
+
+	var opened models.E2EEShareDetailResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, shareURL, recipient, nil, &opened))
+	assert.Equal(t, "c2VjcmV0", opened.EncryptedContent)
+	assert.Equal(t, "ZXBoZW1lcmFs", opened.SenderPublicKey)
+	assert.True(t, opened.Burned)
+	require.NotNil(t, opened.BurnedAt)
+
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, shareURL, recipient, nil, nil))
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/e2ee", recipient, nil, &inbox))
+	assert.Empty(t, inbox.Shares, "A burnt share leaves the inbox")
+
+	read := receipts(t, baseURL, sender)
+	require.Len(t, read, 1)
+	assert.Equal(t, "e2ee", read[0].Type)
+	assert.Equal(t, "basil", read[0].Recipient)
+	require.NotNil(t, read[0].ReadAt)
+	assert.Empty(t, receipts(t, baseURL, recipient), "Receipts belong to the sender")
+}
@@ -0,0 +1,385 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contract calls the API and checks every response body against the OpenAPI
+// document the server publishes
+type contract struct {
+	t       *testing.T
+	baseURL string
+	spec    map[string]interface{}
+	covered map[string]bool // "METHOD pattern" of routes answered with their success status
+}
+
+// newContract starts a server and fetches its OpenAPI document
+func newContract(t *testing.T, opts ...server.Option) *contract {
+	baseURL := startServer(t, "", opts...)
+
+	resp := send(t, http.MethodGet, baseURL+"/api/openapi.json")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var spec map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&spec))
+	require.Equal(t, "3.1.0", spec["openapi"])
+
+	return &contract{t: t, baseURL: baseURL, spec: spec, covered: map[string]bool{"GET /api/openapi.json": true}}
+}
+
+// do sends a request to the route pattern (filled in with args), checks the
+// status and validates the body against the documented response schema
+func (c *contract) do(method, pattern string, args []string, token string, body interface{}, wantStatus int) map[string]interface{} {
+	t := c.t
+	t.Helper()
+
+	path := pattern
+	for _, arg := range args {
+		start := strings.Index(path, "{")
+		end := strings.Index(path, "}")
+		require.True(t, start >= 0 && end > start, "Too many arguments for %s", pattern)
+		path = path[:start] + arg + path[end+1:]
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	key := method + " " + pattern
+	require.Equal(t, wantStatus, resp.StatusCode, "%s %s: %s", method, path, data)
+
+	operation, ok := lookup(c.spec, "paths", pattern, strings.ToLower(method)).(map[string]interface{})
+	require.True(t, ok, "%s is not documented", key)
+	responses := operation["responses"].(map[string]interface{})
+	response, ok := responses[strconv.Itoa(resp.StatusCode)]
+	if ok {
+		c.covered[key] = true
+	} else {
+		require.GreaterOrEqual(t, resp.StatusCode, 400, "%s: undocumented success status %d", key, resp.StatusCode)
+		response = responses["default"]
+	}
+	schema := lookup(response, "content", "application/json", "schema")
+	require.NotNil(t, schema, "%s: response %d has no schema", key, resp.StatusCode)
+
+	var value interface{}
+	require.NoError(t, json.Unmarshal(data, &value), "%s: response is not JSON", key)
+	for _, problem := range c.validate(schema.(map[string]interface{}), value, "body") {
+		t.Errorf("%s %d: %s", key, resp.StatusCode, problem)
+	}
+
+	object, _ := value.(map[string]interface{})
+	return object
+}
+
+// validate checks value against the subset of JSON Schema the server uses
+func (c *contract) validate(schema map[string]interface{}, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target, ok := lookup(c.spec, "components", "schemas", name).(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: unresolved reference %s", at, ref)}
+		}
+		return c.validate(target, value, at)
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %T", at, value)}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range asStrings(schema["required"]) {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required field %q", at, name))
+			}
+		}
+		for name, field := range object {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					problems = append(problems, fmt.Sprintf("%s: undocumented field %q", at, name))
+				}
+				continue
+			}
+			problems = append(problems, c.validate(property, field, at+"."+name)...)
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %T", at, value)}
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range array {
+			problems = append(problems, c.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected string, got %T", at, value)}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, s))
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: expected integer, got %v", at, value)}
+		}
+		if minimum, ok := schema["minimum"].(float64); ok && n < minimum {
+			problems = append(problems, fmt.Sprintf("%s: %v is below the minimum %v", at, n, minimum))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: expected number, got %T", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected boolean, got %T", at, value)}
+		}
+	}
+	return problems
+}
+
+// lookup walks nested JSON objects by key
+func lookup(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func asStrings(value interface{}) []string {
+	var out []string
+	list, _ := value.([]interface{})
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// id formats a numeric JSON field as a path argument
+func id(object map[string]interface{}, field string) string {
+	return strconv.FormatInt(int64(object[field].(float64)), 10)
+}
+
+// TestOpenAPIDocumentsEveryRoute checks that the document has one operation per route
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	c := newContract(t)
+
+	paths := c.spec["paths"].(map[string]interface{})
+	count := 0
+	for _, methods := range paths {
+		count += len(methods.(map[string]interface{}))
+	}
+	assert.Equal(t, len(server.Routes()), count)
+
+	for _, route := range server.Routes() {
+		key := route.Method + " " + route.Pattern
+		operation, ok := lookup(paths, route.Pattern, strings.ToLower(route.Method)).(map[string]interface{})
+		if !assert.True(t, ok, "%s is not documented", key) {
+			continue
+		}
+		assert.Equal(t, route.Summary, operation["summary"], key)
+		assert.NotNil(t, lookup(operation, "responses", strconv.Itoa(route.Status)), key)
+		assert.Equal(t, route.Auth, operation["security"] != nil, key)
+		if strings.Contains(route.Pattern, "{") {
+			assert.NotEmpty(t, operation["parameters"], key)
+		}
+	}
+}
+
+// TestOpenAPIContract calls every route and checks each success and error body
+// against the published schemas, so the handlers cannot drift from the document
+func TestOpenAPIContract(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	c := newContract(t, server.WithClock(clock.Now))
+	const password = "password123"
+	salt := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 16))
+
+	// Auth
+	c.do("POST", "/api/auth/register", nil, "", models.RegisterRequest{Username: "alice", Password: password}, http.StatusCreated)
+	c.do("POST", "/api/auth/register", nil, "", models.RegisterRequest{Username: "bob", Password: password}, http.StatusCreated)
+	c.do("POST", "/api/auth/register", nil, "", models.RegisterRequest{Username: "alice", Password: password}, http.StatusConflict)
+	c.do("POST", "/api/auth/login", nil, "", models.LoginRequest{Username: "alice", Password: "wrong-password"}, http.StatusUnauthorized)
+
+	login := c.do("POST", "/api/auth/login", nil, "", models.LoginRequest{Username: "alice", Password: password}, http.StatusOK)
+	refreshed := c.do("POST", "/api/auth/refresh", nil, "", models.RefreshTokenRequest{RefreshToken: login["refresh_token"].(string)}, http.StatusOK)
+	c.do("POST", "/api/auth/refresh", nil, "", models.RefreshTokenRequest{RefreshToken: "not-a-token"}, http.StatusUnauthorized)
+	alice := refreshed["token"].(string)
+
+	bobLogin := c.do("POST", "/api/auth/login", nil, "", models.LoginRequest{Username: "bob", Password: password}, http.StatusOK)
+	bob := bobLogin["token"].(string)
+
+	c.do("GET", "/api/auth/kdf", nil, alice, nil, http.StatusOK)
+	c.do("GET", "/api/auth/kdf", nil, "", nil, http.StatusUnauthorized)
+	migrate := models.MigrateKDFRequest{
+		Password:     password,
+		KDF:          models.KDFParams{Algorithm: "pbkdf2-sha256", Iterations: 200000, Salt: salt},
+		NoteKeys:     []models.RewrappedKey{},
+		RevisionKeys: []models.RewrappedKey{},
+	}
+	c.do("PUT", "/api/auth/kdf", nil, alice, migrate, http.StatusOK)
+	migrate.KDF.Algorithm = "md5"
+	c.do("PUT", "/api/auth/kdf", nil, alice, migrate, http.StatusBadRequest)
+
+	c.do("GET", "/api/auth/jwks", nil, "", nil, http.StatusOK)
+	c.do("GET", "/.well-known/jwks.json", nil, "", nil, http.StatusOK)
+
+	// Public keys
+	c.do("POST", "/api/user/publickey", nil, alice, models.UpdatePublicKeyRequest{DHPublicKey: "YWxpY2U="}, http.StatusOK)
+	c.do("POST", "/api/user/publickey", nil, bob, models.UpdatePublicKeyRequest{DHPublicKey: "Ym9i"}, http.StatusOK)
+	c.do("GET", "/api/users/{username}/publickey", []string{"bob"}, alice, nil, http.StatusOK)
+	c.do("GET", "/api/users/{username}/publickey", []string{"nobody"}, alice, nil, http.StatusNotFound)
+
+	// Notes
+	note := c.do("POST", "/api/notes", nil, alice, models.CreateNoteRequest{
+		Title: "contract", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY=",
+	}, http.StatusCreated)
+	noteID := id(note, "id")
+	c.do("POST", "/api/notes", nil, alice, models.CreateNoteRequest{Title: "empty"}, http.StatusBadRequest)
+	c.do("GET", "/api/notes", nil, alice, nil, http.StatusOK)
+	c.do("GET", "/api/notes/{id}", []string{noteID}, alice, nil, http.StatusOK)
+	c.do("GET", "/api/notes/{id}", []string{"999"}, alice, nil, http.StatusNotFound)
+	c.do("PUT", "/api/notes/{id}", []string{noteID}, alice, models.UpdateNoteRequest{EncryptedContent: "bmV3", IV: "aXYy", Version: 1}, http.StatusOK)
+	c.do("PUT", "/api/notes/{id}", []string{noteID}, alice, models.UpdateNoteRequest{EncryptedContent: "bmV3", IV: "aXYy", Version: 1}, http.StatusConflict)
+
+	revisions := c.do("GET", "/api/notes/{id}/revisions", []string{noteID}, alice, nil, http.StatusOK)
+	require.NotEmpty(t, revisions["revisions"])
+	revisionID := id(revisions["revisions"].([]interface{})[0].(map[string]interface{}), "id")
+	c.do("GET", "/api/notes/{id}/revisions/{revisionId}", []string{noteID, revisionID}, alice, nil, http.StatusOK)
+	c.do("GET", "/api/notes/{id}/revisions/{revisionId}", []string{noteID, "999"}, alice, nil, http.StatusNotFound)
+	c.do("POST", "/api/notes/{id}/revisions/{revisionId}/restore", []string{noteID, revisionID}, alice, models.RestoreRevisionRequest{Version: 2}, http.StatusOK)
+
+	// Share links
+	share := c.do("POST", "/api/notes/{id}/share", []string{noteID}, alice, models.CreateShareRequest{DurationHours: 1}, http.StatusCreated)
+	c.do("POST", "/api/notes/{id}/share", []string{noteID}, alice, models.CreateShareRequest{DurationHours: 100000}, http.StatusBadRequest)
+	c.do("GET", "/api/shares/{token}", []string{share["share_token"].(string)}, "", nil, http.StatusOK)
+	c.do("GET", "/api/shares/{token}", []string{"unknown"}, "", nil, http.StatusNotFound)
+	sharePassword := "letmein"
+	protected := c.do("POST", "/api/notes/{id}/share", []string{noteID}, alice, models.CreateShareRequest{DurationHours: 1, Password: &sharePassword}, http.StatusCreated)
+	c.do("GET", "/api/shares/{token}", []string{protected["share_token"].(string)}, "", nil, http.StatusUnauthorized)
+	c.do("POST", "/api/shares/{token}", []string{protected["share_token"].(string)}, "", models.AccessShareRequest{Password: "wrong"}, http.StatusUnauthorized)
+	c.do("POST", "/api/shares/{token}", []string{protected["share_token"].(string)}, "", models.AccessShareRequest{Password: "letmein"}, http.StatusOK)
+	burn := c.do("POST", "/api/notes/{id}/share", []string{noteID}, alice, models.CreateShareRequest{
+		DurationHours: 1, BurnAfterReading: true, EncryptedContent: "b25jZQ==", IV: "aXY=",
+	}, http.StatusCreated)
+	c.do("POST", "/api/notes/{id}/share", []string{noteID}, alice, models.CreateShareRequest{DurationHours: 1, BurnAfterReading: true}, http.StatusBadRequest)
+	c.do("GET", "/api/shares/{token}", []string{burn["share_token"].(string)}, "", nil, http.StatusOK)
+	c.do("GET", "/api/shares/{token}", []string{burn["share_token"].(string)}, "", nil, http.StatusGone)
+	c.do("GET", "/api/receipts", nil, alice, nil, http.StatusOK)
+	c.do("GET", "/api/receipts", nil, "", nil, http.StatusUnauthorized)
+	links := c.do("GET", "/api/notes/{id}/shares", []string{noteID}, alice, nil, http.StatusOK)
+	linkList := links["links"].([]interface{})
+	require.NotEmpty(t, linkList)
+	c.do("GET", "/api/notes/{id}/shares", []string{"999"}, alice, nil, http.StatusNotFound)
+	linkID := id(linkList[len(linkList)-1].(map[string]interface{}), "id") // The oldest, a plain link
+	hours, maxAccess := 48, 3
+	c.do("PUT", "/api/notes/{id}/shares/{linkId}", []string{noteID, linkID}, alice, models.UpdateShareLinkRequest{DurationHours: &hours, MaxAccessCount: &maxAccess}, http.StatusOK)
+	c.do("PUT", "/api/notes/{id}/shares/{linkId}", []string{noteID, linkID}, alice, models.UpdateShareLinkRequest{}, http.StatusBadRequest)
+	c.do("PUT", "/api/notes/{id}/shares/{linkId}", []string{noteID, "999"}, alice, models.UpdateShareLinkRequest{DurationHours: &hours}, http.StatusNotFound)
+	c.do("DELETE", "/api/notes/{id}/shares/{linkId}", []string{noteID, linkID}, alice, nil, http.StatusOK)
+	c.do("DELETE", "/api/notes/{id}/shares/{linkId}", []string{noteID, linkID}, alice, nil, http.StatusNotFound)
+	c.do("GET", "/api/notes/{id}/access-log", []string{noteID}, alice, nil, http.StatusOK)
+	c.do("GET", "/api/notes/{id}/access-log", []string{noteID}, bob, nil, http.StatusNotFound)
+	c.do("GET", "/api/notes/{id}/shares/{linkId}/access-log", []string{noteID, linkID}, alice, nil, http.StatusOK)
+	c.do("GET", "/api/notes/{id}/shares/{linkId}/access-log", []string{noteID, "x"}, alice, nil, http.StatusBadRequest)
+	c.do("POST", "/api/notes/{id}/revoke", []string{noteID}, alice, nil, http.StatusOK)
+
+	// E2EE shares
+	e2ee := c.do("POST", "/api/notes/{id}/e2ee", []string{noteID}, alice, models.CreateE2EEShareRequest{
+		RecipientUsername: "bob", SenderPublicKey: "YWxpY2U=", EncryptedContent: "Y2lwaGVy", ContentIV: "aXY=",
+	}, http.StatusCreated)
+	c.do("POST", "/api/notes/{id}/e2ee", []string{noteID}, alice, models.CreateE2EEShareRequest{RecipientUsername: "bob"}, http.StatusBadRequest)
+	shareID := id(e2ee, "share_id")
+	c.do("GET", "/api/e2ee", nil, bob, nil, http.StatusOK)
+	c.do("GET", "/api/e2ee/{id}", []string{shareID}, bob, nil, http.StatusOK)
+	c.do("GET", "/api/e2ee/{id}", []string{shareID}, alice, nil, http.StatusForbidden)
+	c.do("DELETE", "/api/e2ee/{id}", []string{shareID}, alice, nil, http.StatusOK)
+
+	// Two-factor authentication
+	c.do("GET", "/api/auth/totp", nil, alice, nil, http.StatusOK)
+	c.do("POST", "/api/auth/totp/confirm", nil, alice, models.TOTPConfirmRequest{Code: "123456"}, http.StatusConflict)
+	setup := c.do("POST", "/api/auth/totp/setup", nil, alice, nil, http.StatusOK)
+	code := func() string {
+		code, err := auth.TOTPCode(setup["secret"].(string), clock.Now())
+		require.NoError(t, err)
+		return code
+	}
+	confirmed := c.do("POST", "/api/auth/totp/confirm", nil, alice, models.TOTPConfirmRequest{Code: code()}, http.StatusOK)
+	recoveryCodes := confirmed["recovery_codes"].([]interface{})
+	c.do("POST", "/api/auth/totp/setup", nil, alice, nil, http.StatusConflict)
+
+	clock.Advance(30 * time.Second)
+	challenge := c.do("POST", "/api/auth/login", nil, "", models.LoginRequest{Username: "alice", Password: password}, http.StatusOK)
+	require.Equal(t, true, challenge["mfa_required"])
+	mfaToken := challenge["mfa_token"].(string)
+	c.do("POST", "/api/auth/login/mfa", nil, "", models.MFALoginRequest{MFAToken: mfaToken, RecoveryCode: "wrong-code"}, http.StatusUnauthorized)
+	c.do("POST", "/api/auth/login/mfa", nil, "", models.MFALoginRequest{MFAToken: mfaToken, OTP: code()}, http.StatusOK)
+	c.do("POST", "/api/auth/totp/disable", nil, alice, models.TOTPDisableRequest{Password: password, RecoveryCode: recoveryCodes[0].(string)}, http.StatusOK)
+	c.do("POST", "/api/auth/totp/disable", nil, alice, models.TOTPDisableRequest{Password: password}, http.StatusConflict)
+
+	// Clean up and change the password with no keys left to re-wrap
+	c.do("DELETE", "/api/notes/{id}", []string{noteID}, alice, nil, http.StatusOK)
+	c.do("DELETE", "/api/notes/{id}", []string{noteID}, alice, nil, http.StatusNotFound)
+	c.do("POST", "/api/auth/password", nil, alice, models.ChangePasswordRequest{
+		OldPassword: password, NewPassword: "new-password456", NoteKeys: []models.RewrappedKey{}, RevisionKeys: []models.RewrappedKey{},
+	}, http.StatusOK)
+	c.do("POST", "/api/auth/password", nil, alice, models.ChangePasswordRequest{OldPassword: "x", NewPassword: "y"}, http.StatusBadRequest)
+
+	// Sign out
+	c.do("POST", "/api/auth/logout", nil, bob, models.LogoutRequest{RefreshToken: bobLogin["refresh_token"].(string)}, http.StatusOK)
+	c.do("POST", "/api/auth/logout", nil, bob, nil, http.StatusUnauthorized)
+	aliceLogin := c.do("POST", "/api/auth/login", nil, "", models.LoginRequest{Username: "alice", Password: "new-password456"}, http.StatusOK)
+	c.do("POST", "/api/auth/revoke-all", nil, aliceLogin["token"].(string), nil, http.StatusOK)
+
+	var missing []string
+	for _, route := range server.Routes() {
+		if key := route.Method + " " + route.Pattern; !c.covered[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	assert.Empty(t, missing, "Routes not exercised by the contract test")
+}
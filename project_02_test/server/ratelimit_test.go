@@ -0,0 +1,176 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/config"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retryAfter sends a JSON request and returns the status and Retry-After header
+func retryAfter(t *testing.T, method, url string, body interface{}) (int, string) {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("Retry-After")
+}
+
+// TestLoginLockout checks that repeated wrong passwords lock the username with
+// a lockout that doubles, and that the right password works again afterwards
+func TestLoginLockout(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cfg := config.Default()
+	cfg.LockoutThreshold = 3
+	cfg.LockoutBase = time.Minute
+	cfg.LockoutMax = 10 * time.Minute
+	baseURL := startServerConfig(t, cfg, server.WithClock(clock.Now))
+	registerAndLogin(t, baseURL, "carol")
+
+	wrong := models.LoginRequest{Username: "carol", Password: "wrong-password"}
+	right := models.LoginRequest{Username: "carol", Password: "password123"}
+	loginURL := baseURL + "/api/auth/login"
+
+	for i := 0; i < 3; i++ {
+		status, _ := retryAfter(t, http.MethodPost, loginURL, wrong)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	}
+
+	status, retry := retryAfter(t, http.MethodPost, loginURL, right)
+	assert.Equal(t, http.StatusTooManyRequests, status, "Locked even with the right password")
+	assert.Equal(t, "60", retry)
+
+	clock.Advance(time.Minute)
+	status, _ = retryAfter(t, http.MethodPost, loginURL, wrong)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, retry = retryAfter(t, http.MethodPost, loginURL, right)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "120", retry, "The second lockout should be twice as long")
+
+	clock.Advance(2 * time.Minute)
+	status, _ = retryAfter(t, http.MethodPost, loginURL, right)
+	assert.Equal(t, http.StatusOK, status)
+
+	// A success clears the failures
+	status, _ = retryAfter(t, http.MethodPost, loginURL, wrong)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = retryAfter(t, http.MethodPost, loginURL, right)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Unknown usernames are locked the same way, so lockout does not reveal accounts
+	ghost := models.LoginRequest{Username: "ghost", Password: "password123"}
+	for i := 0; i < 3; i++ {
+		retryAfter(t, http.MethodPost, loginURL, ghost)
+	}
+	status, _ = retryAfter(t, http.MethodPost, loginURL, ghost)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+}
+
+// TestLoginLockoutIsPerIP checks that failing from one IP does not lock the
+// user out of logging in from another, while the username rate still applies
+func TestLoginLockoutIsPerIP(t *testing.T) {
+	cfg := config.Default()
+	cfg.LockoutThreshold = 3
+	cfg.RateLimitPerAccount = 6 // Registering logs in once
+	cfg.TrustedProxies = []string{"127.0.0.1"}
+	baseURL := startServerConfig(t, cfg)
+	registerAndLogin(t, baseURL, "gina")
+
+	wrong := models.LoginRequest{Username: "gina", Password: "wrong-password"}
+	right := models.LoginRequest{Username: "gina", Password: "password123"}
+	loginURL := baseURL + "/api/auth/login"
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, openShare(t, http.MethodPost, loginURL, wrong, "test", "198.51.100.66"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, openShare(t, http.MethodPost, loginURL, right, "test", "198.51.100.66"), "The failing IP is locked")
+
+	assert.Equal(t, http.StatusOK, openShare(t, http.MethodPost, loginURL, right, "test", "203.0.113.7"), "Another IP can still log in")
+	assert.Equal(t, http.StatusTooManyRequests, openShare(t, http.MethodPost, loginURL, right, "test", "198.51.100.66"), "Logging in elsewhere does not unlock the attacker")
+
+	// The username rate is shared by every IP
+	assert.Equal(t, http.StatusUnauthorized, openShare(t, http.MethodPost, loginURL, wrong, "test", "192.0.2.1"))
+	assert.Equal(t, http.StatusTooManyRequests, openShare(t, http.MethodPost, loginURL, wrong, "test", "192.0.2.2"))
+}
+
+// TestSharePasswordLockout checks that password guesses on a share link are limited
+func TestSharePasswordLockout(t *testing.T) {
+	cfg := config.Default()
+	cfg.LockoutThreshold = 2
+	baseURL := startServerConfig(t, cfg)
+	token := registerAndLogin(t, baseURL, "dave")
+
+	note := models.CreateNoteRequest{Title: "d", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	var created models.NoteResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/notes", token, note, &created))
+
+	password := "letmein"
+	var share models.ShareLinkResponse
+	shareURL := fmt.Sprintf("%s/api/notes/%d/share", baseURL, created.ID)
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, shareURL, token, models.CreateShareRequest{Password: &password}, &share))
+
+	openURL := baseURL + "/api/shares/" + share.ShareToken
+	for i := 0; i < 2; i++ {
+		status, _ := retryAfter(t, http.MethodGet, openURL, models.AccessShareRequest{Password: "guess"})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	}
+
+	status, retry := retryAfter(t, http.MethodGet, openURL, models.AccessShareRequest{Password: password})
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	seconds, err := strconv.Atoi(retry)
+	require.NoError(t, err)
+	assert.InDelta(t, 60, seconds, 1)
+}
+
+// TestAccountRateLimit checks the per-username attempt rate, separate from lockout
+func TestAccountRateLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimitPerAccount = 2
+	cfg.LockoutThreshold = 0
+	baseURL := startServerConfig(t, cfg)
+
+	wrong := models.LoginRequest{Username: "erin", Password: "wrong-password"}
+	for i := 0; i < 2; i++ {
+		status, _ := retryAfter(t, http.MethodPost, baseURL+"/api/auth/login", wrong)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	}
+	status, retry := retryAfter(t, http.MethodPost, baseURL+"/api/auth/login", wrong)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "30", retry)
+
+	other := models.LoginRequest{Username: "frank", Password: "wrong-password"}
+	status, _ = retryAfter(t, http.MethodPost, baseURL+"/api/auth/login", other)
+	assert.Equal(t, http.StatusUnauthorized, status, "Each username has its own limit")
+}
+
+// TestIPRateLimit checks that one client IP cannot exceed the request rate on any route
+func TestIPRateLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimitPerIP = 5
+	baseURL := startServerConfig(t, cfg)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, send(t, http.MethodGet, baseURL+"/api/auth/jwks").StatusCode)
+	}
+
+	resp := send(t, http.MethodGet, baseURL+"/api/notes")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "12", resp.Header.Get("Retry-After"))
+
+	// Preflight requests are not counted
+	assert.Equal(t, http.StatusNoContent, send(t, http.MethodOptions, baseURL+"/api/notes").StatusCode)
+}
@@ -0,0 +1,99 @@
+package server_test
+
+import (
+	"encoding/json"
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// send makes a request without a body and returns the response
+func send(t *testing.T, method, url string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// TestMethodNotAllowed checks that a known path with the wrong method gets a JSON 405
+// listing the methods the path supports
+func TestMethodNotAllowed(t *testing.T) {
+	baseURL := startServer(t, "")
+
+	testCases := []struct {
+		method, path, allow string
+	}{
+		{http.MethodPatch, "/api/notes/1", "DELETE, GET, OPTIONS, PUT"},
+		{http.MethodDelete, "/api/notes", "GET, OPTIONS, POST"},
+		{http.MethodGet, "/api/auth/login", "OPTIONS, POST"},
+		{http.MethodDelete, "/api/shares/some_token", "GET, OPTIONS, POST"},
+		{http.MethodHead, "/api/notes", "GET, OPTIONS"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			resp := send(t, tc.method, baseURL+tc.path)
+			assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+			assert.Equal(t, tc.allow, resp.Header.Get("Allow"))
+
+			if tc.method != http.MethodHead {
+				var body models.ErrorResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, "Method not allowed", body.Message)
+			}
+		})
+	}
+}
+
+// TestNotFound checks that paths outside the route table get a JSON 404
+func TestNotFound(t *testing.T) {
+	baseURL := startServer(t, "")
+
+	for _, path := range []string{"/api/notes/abc/x/e2ee", "/api/notes/1/", "/api/unknown", "/api/shares/"} {
+		t.Run(path, func(t *testing.T) {
+			resp := send(t, http.MethodPost, baseURL+path)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("Allow"))
+
+			var body models.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, "Not found", body.Message)
+		})
+	}
+}
+
+// TestPreflight checks that CORS preflight requests are answered on every route
+func TestPreflight(t *testing.T) {
+	baseURL := startServer(t, "")
+
+	resp := send(t, http.MethodOptions, baseURL+"/api/notes/1/revisions/2/restore")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+// TestRouteTable checks that the route table is complete and has no duplicates
+func TestRouteTable(t *testing.T) {
+	routes := server.Routes()
+	require.NotEmpty(t, routes)
+
+	seen := make(map[string]bool)
+	for _, route := range routes {
+		key := route.Method + " " + route.Pattern
+		assert.False(t, seen[key], "Duplicate route %s", key)
+		seen[key] = true
+		assert.NotNil(t, route.Handler, key)
+		assert.NotEmpty(t, route.Summary, key)
+	}
+	assert.True(t, seen["POST /api/notes/{id}/e2ee"])
+	assert.True(t, seen["GET /api/shares/{token}"])
+
+	// Callers get a copy
+	routes[0].Pattern = "/changed"
+	assert.NotEqual(t, "/changed", server.Routes()[0].Pattern)
+}
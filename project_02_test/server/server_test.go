@@ -0,0 +1,167 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/config"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock the test moves by hand
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// startServer creates a server with its own database file and serves it over HTTP
+func startServer(t *testing.T, secret string, opts ...server.Option) string {
+	cfg := config.Default()
+	cfg.JWTSecret = secret
+	return startServerConfig(t, cfg, opts...)
+}
+
+// startServerConfig is startServer with a custom configuration; the database
+// path is always replaced by a temporary one
+func startServerConfig(t *testing.T, cfg *config.Config, opts ...server.Option) string {
+	cfg.DBPath = filepath.Join(t.TempDir(), "storage", "app.db")
+
+	srv, err := server.New(cfg, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { srv.Close() })
+
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+// call sends a JSON request and decodes the JSON response into out (if not nil)
+func call(t *testing.T, method, url, token string, body, out interface{}) int {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+// registerAndLogin creates an account and returns its access token
+func registerAndLogin(t *testing.T, baseURL, username string) string {
+	credentials := models.LoginRequest{Username: username, Password: "password123"}
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/auth/register", "", credentials, nil))
+
+	var login models.LoginResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, baseURL+"/api/auth/login", "", credentials, &login))
+	return login.Token
+}
+
+// TestInstancesAreIsolated checks that two servers in one process do not share
+// accounts, data or signing keys
+func TestInstancesAreIsolated(t *testing.T) {
+	serverA := startServer(t, "instance-a-secret-0123456789abcdef")
+	serverB := startServer(t, "instance-b-secret-0123456789abcdef")
+
+	tokenA := registerAndLogin(t, serverA, "alice")
+
+	credentials := models.LoginRequest{Username: "alice", Password: "password123"}
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, serverB+"/api/auth/login", "", credentials, nil),
+		"Account created on A should not exist on B")
+
+	note := models.CreateNoteRequest{Title: "a", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, serverA+"/api/notes", tokenA, note, nil))
+
+	var notes models.ListNotesResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, serverA+"/api/notes", tokenA, nil, &notes))
+	assert.Equal(t, 1, notes.Count)
+
+	// B has its own signing key, so A's token means nothing there
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodGet, serverB+"/api/notes", tokenA, nil, nil))
+
+	// Same username on B is a different account
+	tokenB := registerAndLogin(t, serverB, "alice")
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, serverB+"/api/notes", tokenB, nil, &notes))
+	assert.Equal(t, 0, notes.Count)
+}
+
+// TestServerClock checks that token and share expiry follow the clock passed to New
+func TestServerClock(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	baseURL := startServer(t, "", server.WithClock(clock.Now))
+
+	token := registerAndLogin(t, baseURL, "bob")
+
+	note := models.CreateNoteRequest{Title: "b", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	var created models.NoteResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/notes", token, note, &created))
+	assert.WithinDuration(t, clock.Now(), created.CreatedAt, time.Second, "Timestamps should come from the server clock")
+
+	var share models.ShareLinkResponse
+	shareURL := fmt.Sprintf("%s/api/notes/%d/share", baseURL, created.ID)
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, shareURL, token, models.CreateShareRequest{DurationHours: 2}, &share))
+	assert.Equal(t, clock.Now().Add(2*time.Hour).Unix(), share.ExpiresAt.Unix())
+
+	assert.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+share.ShareToken, "", nil, nil))
+
+	// Past the access token lifetime but within the share lifetime
+	clock.Advance(30 * time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodGet, baseURL+"/api/notes", token, nil, nil),
+		"Access token should expire on the server clock")
+	assert.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+share.ShareToken, "", nil, nil))
+
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, baseURL+"/api/shares/"+share.ShareToken, "", nil, nil),
+		"Share should expire on the server clock")
+}
+
+// TestNewRejectsInvalidConfig checks that New validates the configuration
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.DBPath = ""
+	_, err := server.New(cfg)
+	assert.Error(t, err)
+
+	cfg = config.Default()
+	cfg.DBPath = filepath.Join(t.TempDir(), "app.db")
+	cfg.JWTKeysFile = filepath.Join(t.TempDir(), "missing.json")
+	_, err = server.New(cfg)
+	assert.Error(t, err, "Unreadable keys file should be reported")
+}
@@ -0,0 +1,166 @@
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"lab02_mahoa/server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listLinks lists the share links of a note
+func listLinks(t *testing.T, baseURL, token string, noteID uint) []models.ShareLinkInfo {
+	t.Helper()
+	var list models.ListShareLinksResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, fmt.Sprintf("%s/api/notes/%d/shares", baseURL, noteID), token, nil, &list))
+	assert.Equal(t, len(list.Links), list.Count)
+	return list.Links
+}
+
+// createNoteWithLinks creates a note with a plain, a password-protected and a limited share link
+func createNoteWithLinks(t *testing.T, baseURL, token string) uint {
+	t.Helper()
+	note := models.CreateNoteRequest{Title: "links", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	var created models.NoteResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/notes", token, note, &created))
+
+	password, maxAccess := "letmein", 2
+	shareURL := fmt.Sprintf("%s/api/notes/%d/share", baseURL, created.ID)
+	for _, req := range []models.CreateShareRequest{
+		{DurationHours: 1},
+		{DurationHours: 2, Password: &password},
+		{DurationHours: 3, MaxAccessCount: &maxAccess},
+	} {
+		require.Equal(t, http.StatusCreated, call(t, http.MethodPost, shareURL, token, req, nil))
+	}
+	return created.ID
+}
+
+// TestListShareLinks checks that the owner sees every link of a note with its settings
+func TestListShareLinks(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "lena")
+	noteID := createNoteWithLinks(t, baseURL, token)
+
+	links := listLinks(t, baseURL, token, noteID)
+	require.Len(t, links, 3)
+
+	limited, protected, plain := links[0], links[1], links[2]
+	assert.Equal(t, 2, limited.MaxAccessCount, "Newest link first")
+	assert.True(t, protected.RequirePassword)
+	assert.False(t, plain.RequirePassword)
+	assert.Zero(t, plain.MaxAccessCount)
+	for _, link := range links {
+		assert.Equal(t, noteID, link.NoteID)
+		assert.Equal(t, "active", link.Status)
+		assert.NotEmpty(t, link.ShareToken)
+	}
+
+	// Opening a link shows up in its access count
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+limited.ShareToken, "", nil, nil))
+	assert.Equal(t, 1, listLinks(t, baseURL, token, noteID)[0].AccessCount)
+
+	other := registerAndLogin(t, baseURL, "mallory")
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodGet, fmt.Sprintf("%s/api/notes/%d/shares", baseURL, noteID), other, nil, nil))
+}
+
+// TestUpdateShareLink checks changing the expiry and the access limit of one link
+func TestUpdateShareLink(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "milo")
+	noteID := createNoteWithLinks(t, baseURL, token)
+
+	links := listLinks(t, baseURL, token, noteID)
+	link := links[2]
+	linkURL := fmt.Sprintf("%s/api/notes/%d/shares/%d", baseURL, noteID, link.ID)
+
+	hours, maxAccess := 100, 1
+	var updated models.ShareLinkInfo
+	require.Equal(t, http.StatusOK, call(t, http.MethodPut, linkURL, token, models.UpdateShareLinkRequest{DurationHours: &hours}, &updated))
+	assert.WithinDuration(t, time.Now().Add(100*time.Hour), updated.ExpiresAt, time.Minute, "Extended")
+	assert.Zero(t, updated.MaxAccessCount, "Omitted fields are left unchanged")
+
+	hours = 1
+	require.Equal(t, http.StatusOK, call(t, http.MethodPut, linkURL, token, models.UpdateShareLinkRequest{DurationHours: &hours, MaxAccessCount: &maxAccess}, &updated))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), updated.ExpiresAt, time.Minute, "Shortened")
+	assert.Equal(t, 1, updated.MaxAccessCount)
+
+	// The new limit applies to the link
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+link.ShareToken, "", nil, nil))
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, baseURL+"/api/shares/"+link.ShareToken, "", nil, nil))
+
+	// The other links are untouched
+	after := listLinks(t, baseURL, token, noteID)
+	for i := 0; i < 2; i++ {
+		assert.True(t, links[i].ExpiresAt.Equal(after[i].ExpiresAt))
+		assert.Equal(t, links[i].MaxAccessCount, after[i].MaxAccessCount)
+	}
+
+	negative, tooLong := -1, 100000
+	for _, req := range []models.UpdateShareLinkRequest{
+		{},
+		{DurationHours: &negative},
+		{DurationHours: &tooLong},
+		{MaxAccessCount: &negative},
+	} {
+		assert.Equal(t, http.StatusBadRequest, call(t, http.MethodPut, fmt.Sprintf("%s/api/notes/%d/shares/%d", baseURL, noteID, links[0].ID), token, req, nil))
+	}
+
+	other := registerAndLogin(t, baseURL, "mallory")
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodPut, linkURL, other, models.UpdateShareLinkRequest{DurationHours: &hours}, nil))
+}
+
+// TestRevokeSingleShareLink checks that revoking one link leaves the others working
+func TestRevokeSingleShareLink(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "nadia")
+	noteID := createNoteWithLinks(t, baseURL, token)
+
+	links := listLinks(t, baseURL, token, noteID)
+	revoked, kept := links[2], links[0]
+	linkURL := fmt.Sprintf("%s/api/notes/%d/shares/%d", baseURL, noteID, revoked.ID)
+
+	other := registerAndLogin(t, baseURL, "mallory")
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodDelete, linkURL, other, nil, nil))
+
+	require.Equal(t, http.StatusOK, call(t, http.MethodDelete, linkURL, token, nil, nil))
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodDelete, linkURL, token, nil, nil))
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodGet, baseURL+"/api/shares/"+revoked.ShareToken, "", nil, nil))
+	assert.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+kept.ShareToken, "", nil, nil))
+	assert.Len(t, listLinks(t, baseURL, token, noteID), 2)
+
+	// A link ID only works under its own note
+	otherNote := createNoteWithLinks(t, baseURL, token)
+	assert.Equal(t, http.StatusNotFound, call(t, http.MethodDelete, fmt.Sprintf("%s/api/notes/%d/shares/%d", baseURL, otherNote, kept.ID), token, nil, nil))
+}
+
+// TestBurnLinkLimitCannotChange checks that a burn-after-reading link stays a one-time link
+func TestBurnLinkLimitCannotChange(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "otto")
+
+	note := models.CreateNoteRequest{Title: "burn", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	var created models.NoteResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/notes", token, note, &created))
+	var share models.ShareLinkResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, fmt.Sprintf("%s/api/notes/%d/share", baseURL, created.ID), token,
+		models.CreateShareRequest{BurnAfterReading: true, EncryptedContent: "b25jZQ==", IV: "aXY="}, &share))
+
+	links := listLinks(t, baseURL, token, created.ID)
+	require.Len(t, links, 1)
+	assert.True(t, links[0].BurnAfterReading)
+
+	linkURL := fmt.Sprintf("%s/api/notes/%d/shares/%d", baseURL, created.ID, links[0].ID)
+	maxAccess, hours := 5, 2
+	assert.Equal(t, http.StatusBadRequest, call(t, http.MethodPut, linkURL, token, models.UpdateShareLinkRequest{MaxAccessCount: &maxAccess}, nil))
+	assert.Equal(t, http.StatusOK, call(t, http.MethodPut, linkURL, token, models.UpdateShareLinkRequest{DurationHours: &hours}, nil))
+
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+share.ShareToken, "", nil, nil))
+	read := listLinks(t, baseURL, token, created.ID)[0]
+	assert.Equal(t, "read", read.Status)
+	assert.NotNil(t, read.ReadAt)
+}
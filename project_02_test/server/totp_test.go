@@ -0,0 +1,166 @@
+package server_test
+
+import (
+	"lab02_mahoa/server"
+	"lab02_mahoa/server/auth"
+	"lab02_mahoa/server/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// enableTOTP turns on two-factor authentication for the account of token and
+// returns the secret and the recovery codes
+func enableTOTP(t *testing.T, baseURL, token string, clock *fakeClock) (string, []string) {
+	var setup models.TOTPSetupResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, baseURL+"/api/auth/totp/setup", token, nil, &setup))
+	assert.Contains(t, setup.OTPAuthURI, "otpauth://totp/")
+	assert.Contains(t, setup.OTPAuthURI, setup.Secret)
+
+	var confirmed models.TOTPConfirmResponse
+	confirm := models.TOTPConfirmRequest{Code: totpCode(t, setup.Secret, clock.Now())}
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, baseURL+"/api/auth/totp/confirm", token, confirm, &confirmed))
+	require.Len(t, confirmed.RecoveryCodes, auth.RecoveryCodeCount)
+	return setup.Secret, confirmed.RecoveryCodes
+}
+
+// totpCode returns the code of secret at t
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	code, err := auth.TOTPCode(secret, at)
+	require.NoError(t, err)
+	return code
+}
+
+// TestTOTPLogin checks that an account with two-factor authentication needs a
+// code at login, either in the login request or in a second step
+func TestTOTPLogin(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	baseURL := startServer(t, "", server.WithClock(clock.Now))
+	token := registerAndLogin(t, baseURL, "grace")
+
+	var status models.TOTPStatusResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/auth/totp", token, nil, &status))
+	assert.False(t, status.Enabled)
+
+	secret, _ := enableTOTP(t, baseURL, token, clock)
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/auth/totp", token, nil, &status))
+	assert.True(t, status.Enabled)
+	assert.Equal(t, auth.RecoveryCodeCount, status.RecoveryCodesLeft)
+
+	loginURL := baseURL + "/api/auth/login"
+	credentials := models.LoginRequest{Username: "grace", Password: "password123"}
+
+	// The password alone only gets a challenge
+	var login models.LoginResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, loginURL, "", credentials, &login))
+	assert.True(t, login.MFARequired)
+	assert.NotEmpty(t, login.MFAToken)
+	assert.Empty(t, login.Token)
+	assert.Empty(t, login.RefreshToken)
+
+	// The code used to confirm the setup cannot be used again
+	credentials.OTP = totpCode(t, secret, clock.Now())
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, loginURL, "", credentials, nil))
+
+	clock.Advance(30 * time.Second)
+	credentials.OTP = totpCode(t, secret, clock.Now())
+	login = models.LoginResponse{}
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, loginURL, "", credentials, &login))
+	assert.NotEmpty(t, login.Token)
+	assert.False(t, login.MFARequired)
+
+	// Replay of the same code
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, loginURL, "", credentials, nil))
+
+	// Two-step login with the challenge
+	credentials.OTP = ""
+	var challenge models.LoginResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, loginURL, "", credentials, &challenge))
+
+	mfaURL := baseURL + "/api/auth/login/mfa"
+	clock.Advance(30 * time.Second)
+	wrong := models.MFALoginRequest{MFAToken: challenge.MFAToken, RecoveryCode: "not-a-code"}
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, mfaURL, "", wrong, nil))
+
+	right := models.MFALoginRequest{MFAToken: challenge.MFAToken, OTP: totpCode(t, secret, clock.Now())}
+	login = models.LoginResponse{}
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, mfaURL, "", right, &login))
+	assert.NotEmpty(t, login.Token)
+	assert.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/notes", login.Token, nil, nil))
+
+	// The challenge is single-use
+	clock.Advance(30 * time.Second)
+	right.OTP = totpCode(t, secret, clock.Now())
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, mfaURL, "", right, nil))
+}
+
+// TestMFAChallengeExpires checks that a login challenge cannot be completed late
+func TestMFAChallengeExpires(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	baseURL := startServer(t, "", server.WithClock(clock.Now))
+	token := registerAndLogin(t, baseURL, "heidi")
+	secret, _ := enableTOTP(t, baseURL, token, clock)
+
+	var challenge models.LoginResponse
+	credentials := models.LoginRequest{Username: "heidi", Password: "password123"}
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, baseURL+"/api/auth/login", "", credentials, &challenge))
+
+	clock.Advance(auth.MFAChallengeLifetime + time.Second)
+	req := models.MFALoginRequest{MFAToken: challenge.MFAToken, OTP: totpCode(t, secret, clock.Now())}
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, baseURL+"/api/auth/login/mfa", "", req, nil))
+}
+
+// TestRecoveryCodes checks that each recovery code works once in place of a TOTP code
+func TestRecoveryCodes(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	baseURL := startServer(t, "", server.WithClock(clock.Now))
+	token := registerAndLogin(t, baseURL, "ivan")
+	_, codes := enableTOTP(t, baseURL, token, clock)
+
+	loginURL := baseURL + "/api/auth/login"
+	credentials := models.LoginRequest{Username: "ivan", Password: "password123", RecoveryCode: codes[0]}
+	assert.Equal(t, http.StatusOK, call(t, http.MethodPost, loginURL, "", credentials, nil))
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, loginURL, "", credentials, nil),
+		"A recovery code should only work once")
+
+	// Codes are accepted in upper case and with spaces around them
+	credentials.RecoveryCode = "  " + strings.ToUpper(codes[1]) + " "
+	assert.Equal(t, http.StatusOK, call(t, http.MethodPost, loginURL, "", credentials, nil))
+
+	var status models.TOTPStatusResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/auth/totp", token, nil, &status))
+	assert.Equal(t, auth.RecoveryCodeCount-2, status.RecoveryCodesLeft)
+}
+
+// TestDisableTOTP checks that turning two-factor authentication off needs the
+// password and a second factor, and that login needs only the password afterwards
+func TestDisableTOTP(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	baseURL := startServer(t, "", server.WithClock(clock.Now))
+	token := registerAndLogin(t, baseURL, "judy")
+	secret, codes := enableTOTP(t, baseURL, token, clock)
+
+	disableURL := baseURL + "/api/auth/totp/disable"
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, disableURL, token,
+		models.TOTPDisableRequest{Password: "wrong-password", RecoveryCode: codes[0]}, nil))
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, disableURL, token,
+		models.TOTPDisableRequest{Password: "password123"}, nil))
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, http.StatusOK, call(t, http.MethodPost, disableURL, token,
+		models.TOTPDisableRequest{Password: "password123", OTP: totpCode(t, secret, clock.Now())}, nil))
+
+	var login models.LoginResponse
+	credentials := models.LoginRequest{Username: "judy", Password: "password123"}
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, baseURL+"/api/auth/login", "", credentials, &login))
+	assert.False(t, login.MFARequired)
+	assert.NotEmpty(t, login.Token)
+
+	// Confirming needs a fresh setup
+	assert.Equal(t, http.StatusConflict, call(t, http.MethodPost, baseURL+"/api/auth/totp/confirm", token,
+		models.TOTPConfirmRequest{Code: totpCode(t, secret, clock.Now())}, nil))
+}
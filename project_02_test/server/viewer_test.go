@@ -0,0 +1,102 @@
+package server_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"lab02_mahoa/server/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createShare creates a note and a share link for it and returns the link
+func createShare(t *testing.T, baseURL, token string, req models.CreateShareRequest) models.ShareLinkResponse {
+	t.Helper()
+	note := models.CreateNoteRequest{Title: "v", EncryptedContent: "Y2lwaGVy", IV: "aXY=", EncryptedKey: "a2V5", EncryptedKeyIV: "aXY="}
+	var created models.NoteResponse
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, baseURL+"/api/notes", token, note, &created))
+
+	var share models.ShareLinkResponse
+	shareURL := fmt.Sprintf("%s/api/notes/%d/share", baseURL, created.ID)
+	require.Equal(t, http.StatusCreated, call(t, http.MethodPost, shareURL, token, req, &share))
+	return share
+}
+
+// TestViewerPage checks that /share/{token} serves the viewer page with a strict CSP
+func TestViewerPage(t *testing.T) {
+	baseURL := startServer(t, "")
+
+	resp := send(t, http.MethodGet, baseURL+"/share/some_token")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "no-referrer", resp.Header.Get("Referrer-Policy"))
+	csp := resp.Header.Get("Content-Security-Policy")
+	assert.Contains(t, csp, "default-src 'none'")
+	assert.Contains(t, csp, "script-src 'self'")
+
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `src="/share/static/share.js"`)
+	assert.NotContains(t, string(page), "some_token", "The page is the same for every token")
+}
+
+// TestViewerAssets checks that the script and style are served and directories are not listed
+func TestViewerAssets(t *testing.T) {
+	baseURL := startServer(t, "")
+
+	resp := send(t, http.MethodGet, baseURL+"/share/static/share.js")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "javascript")
+	script, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(script), "AES-GCM")
+
+	resp = send(t, http.MethodGet, baseURL+"/share/static/share.css")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/css")
+
+	for _, path := range []string{"/share/static/", "/share/static/missing.js"} {
+		resp := send(t, http.MethodGet, baseURL+path)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+}
+
+// TestViewerDoesNotUseAccess checks that loading the page does not count as opening the link
+func TestViewerDoesNotUseAccess(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "vera")
+
+	maxAccess := 1
+	share := createShare(t, baseURL, token, models.CreateShareRequest{MaxAccessCount: &maxAccess})
+
+	for i := 0; i < 3; i++ {
+		resp := send(t, http.MethodGet, baseURL+"/share/"+share.ShareToken)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	var shared models.SharedNoteResponse
+	assert.Equal(t, http.StatusOK, call(t, http.MethodGet, baseURL+"/api/shares/"+share.ShareToken, "", nil, &shared))
+	assert.Equal(t, "Y2lwaGVy", shared.EncryptedContent)
+	assert.Equal(t, http.StatusGone, call(t, http.MethodGet, baseURL+"/api/shares/"+share.ShareToken, "", nil, nil))
+}
+
+// TestOpenProtectedShareWithPost checks that the viewer can send the link password in a POST body
+func TestOpenProtectedShareWithPost(t *testing.T) {
+	baseURL := startServer(t, "")
+	token := registerAndLogin(t, baseURL, "walt")
+
+	password := "letmein"
+	share := createShare(t, baseURL, token, models.CreateShareRequest{Password: &password})
+	openURL := baseURL + "/api/shares/" + share.ShareToken
+
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodGet, openURL, "", nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, call(t, http.MethodPost, openURL, "", models.AccessShareRequest{Password: "wrong"}, nil))
+
+	var shared models.SharedNoteResponse
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, openURL, "", models.AccessShareRequest{Password: password}, &shared))
+	assert.Equal(t, "v", shared.Title)
+}